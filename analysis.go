@@ -0,0 +1,49 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+// BandStats represents the statistics of the values of an image band.
+type BandStats struct {
+	Min    float64
+	Max    float64
+	Mean   float64
+	StdDev float64
+}
+
+// ImageStats represents the statistics of an image.
+type ImageStats struct {
+	// Bands holds the statistics of every band, including alpha.
+	Bands []BandStats
+	// Entropy of the 8-bit luminance histogram, from 0 to 8 bits.
+	// Flat or mostly uniform images have a low entropy.
+	Entropy float64
+}
+
+// sharpnessSize is the maximum size images are scaled down to before
+// measuring their sharpness.
+const sharpnessSize = 1000
+
+// sharpnessScore returns the variance of the laplacian of the image.
+func sharpnessScore(buf []byte) (float64, error) {
+	defer C.vips_thread_shutdown()
+
+	image, _, err := loadImage(buf)
+	if err != nil {
+		return 0, err
+	}
+	return vipsSharpness(image, sharpnessSize)
+}
+
+func imageStats(buf []byte) (ImageStats, error) {
+	defer C.vips_thread_shutdown()
+
+	image, _, err := loadImage(buf)
+	if err != nil {
+		return ImageStats{}, err
+	}
+	return vipsStats(image)
+}
@@ -0,0 +1,52 @@
+package bimg
+
+import "testing"
+
+func TestSharpnessScore(t *testing.T) {
+	sharp, err := initImage("test.jpg").SharpnessScore()
+	if err != nil {
+		t.Fatalf("Cannot measure the sharpness: %#v", err)
+	}
+
+	buf, err := initImage("test.jpg").Process(Options{GaussianBlur: GaussianBlur{Sigma: 5}})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	blurry, err := NewImage(buf).SharpnessScore()
+	if err != nil {
+		t.Fatalf("Cannot measure the sharpness: %#v", err)
+	}
+
+	if blurry >= sharp {
+		t.Errorf("Blurred image must score lower: %f >= %f", blurry, sharp)
+	}
+}
+
+func TestStats(t *testing.T) {
+	stats, err := initImage("test.jpg").Stats()
+	if err != nil {
+		t.Fatalf("Cannot read the stats: %#v", err)
+	}
+
+	if len(stats.Bands) != 3 {
+		t.Fatalf("Invalid number of bands: %d", len(stats.Bands))
+	}
+	for i, band := range stats.Bands {
+		if band.Min < 0 || band.Max > 255 || band.Min > band.Mean || band.Mean > band.Max || band.StdDev <= 0 {
+			t.Errorf("Invalid band %d stats: %#v", i, band)
+		}
+	}
+	if stats.Entropy <= 0 || stats.Entropy > 8 {
+		t.Errorf("Invalid entropy: %f", stats.Entropy)
+	}
+}
+
+func TestStatsAlpha(t *testing.T) {
+	stats, err := initImage("transparent.png").Stats()
+	if err != nil {
+		t.Fatalf("Cannot read the stats: %#v", err)
+	}
+	if len(stats.Bands) != 4 {
+		t.Fatalf("Invalid number of bands: %d", len(stats.Bands))
+	}
+}
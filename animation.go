@@ -0,0 +1,124 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultFrameDelay is the delay of the animation frames without one.
+const DefaultFrameDelay = 100 * time.Millisecond
+
+// Animation represents an animation assembled from still frames.
+type Animation struct {
+	// Loop defines the number of animation loops, zero loops forever.
+	Loop   int
+	frames []*Image
+	delays []time.Duration
+}
+
+// NewAnimationFromFrames creates an animation of the given frames, which
+// must share the same dimensions, e.g. burst photos or timelapses. Delays
+// are given per frame, or once for every frame, and default to
+// DefaultFrameDelay.
+func NewAnimationFromFrames(frames []*Image, delays []time.Duration) (*Animation, error) {
+	if len(frames) == 0 {
+		return nil, errors.New("No animation frames given")
+	}
+	if len(delays) > 1 && len(delays) != len(frames) {
+		return nil, fmt.Errorf("Expected %d frame delays, got %d", len(frames), len(delays))
+	}
+	for _, delay := range delays {
+		if delay < 0 {
+			return nil, fmt.Errorf("Invalid frame delay: %s", delay)
+		}
+	}
+	return &Animation{frames: frames, delays: delays}, nil
+}
+
+// Save encodes the animation as GIF, the default, WebP or PNG (APNG).
+// Requires libvips 8.9+.
+func (a *Animation) Save(o SaveOptions) ([]byte, error) {
+	defer C.vips_thread_shutdown()
+
+	o = applySaveDefaults(o, GIF)
+	if o.Type != GIF && o.Type != WEBP && o.Type != PNG {
+		return nil, wrapError(ErrUnsupportedFormat, "Unsupported animation type: "+ImageTypeName(o.Type))
+	}
+	if !IsTypeSupportedSave(o.Type) {
+		return nil, wrapError(ErrUnsupportedFormat, "Unsupported image output type: "+ImageTypeName(o.Type))
+	}
+
+	images := make([]*C.VipsImage, 0, len(a.frames))
+	release := func() {
+		for _, image := range images {
+			C.g_object_unref(C.gpointer(image))
+		}
+	}
+	for i, frame := range a.frames {
+		image, _, err := loadOrientedImage(frame)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		images = append(images, image)
+		if image.Xsize != images[0].Xsize || image.Ysize != images[0].Ysize {
+			release()
+			return nil, fmt.Errorf("Frame %d size %dx%d differs from %dx%d", i, image.Xsize, image.Ysize, images[0].Xsize, images[0].Ysize)
+		}
+	}
+
+	durations := make([]time.Duration, len(images))
+	delays := make([]C.int, len(images))
+	for i := range delays {
+		durations[i] = DefaultFrameDelay
+		if len(a.delays) == 1 {
+			durations[i] = a.delays[0]
+		} else if len(a.delays) > 0 {
+			durations[i] = a.delays[i]
+		}
+		delays[i] = C.int(durations[i] / time.Millisecond)
+	}
+
+	image, err := vipsAnimation(images, delays, a.Loop)
+	if err != nil {
+		return nil, err
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	if o.Type == PNG {
+		return a.saveAPNG(image, durations, o)
+	}
+	return encodeImage(image, o, o.Quality)
+}
+
+// saveAPNG encodes the animation pages as PNG images sharing the same
+// header, assembled as APNG.
+func (a *Animation) saveAPNG(image *C.VipsImage, delays []time.Duration, o SaveOptions) ([]byte, error) {
+	o.Palette = false
+	o.Interlace = false
+
+	width := int(image.Xsize)
+	pageHeight := vipsPageHeight(image)
+	frames := make([][]byte, len(delays))
+	for i := range frames {
+		// vipsExtract releases the image
+		C.g_object_ref(C.gpointer(image))
+		page, err := vipsExtract(image, 0, i*pageHeight, width, pageHeight)
+		if err != nil {
+			return nil, err
+		}
+		frames[i], err = encodeImage(page, o, o.Quality)
+		C.g_object_unref(C.gpointer(page))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return encodeAPNG(frames, delays, a.Loop)
+}
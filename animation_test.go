@@ -0,0 +1,75 @@
+package bimg
+
+import (
+	"testing"
+	"time"
+)
+
+func animationFrames(t *testing.T) []*Image {
+	var frames []*Image
+	for _, o := range []Options{{}, {Flip: true}, {Flop: true}} {
+		o.Width, o.Height, o.Force = 100, 80, true
+		buf, err := initImage("test.jpg").Process(o)
+		if err != nil {
+			t.Fatalf("Cannot process the image: %#v", err)
+		}
+		frames = append(frames, NewImage(buf))
+	}
+	return frames
+}
+
+func TestAnimationSave(t *testing.T) {
+	delays := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond}
+	animation, err := NewAnimationFromFrames(animationFrames(t), delays)
+	if err != nil {
+		t.Fatalf("Cannot create the animation: %#v", err)
+	}
+
+	for _, imageType := range []ImageType{GIF, WEBP} {
+		if !IsTypeSupportedSave(imageType) {
+			continue
+		}
+		buf, err := animation.Save(SaveOptions{Type: imageType})
+		if err != nil {
+			t.Fatalf("Cannot save the animation: %#v", err)
+		}
+		if DetermineImageType(buf) != imageType {
+			t.Errorf("Invalid animation type: %s", ImageTypeName(DetermineImageType(buf)))
+		}
+
+		frames, got, err := NewImage(buf).Frames()
+		if err != nil {
+			t.Fatalf("Cannot extract the frames: %#v", err)
+		}
+		if len(frames) != 3 {
+			t.Fatalf("Invalid number of frames: %d", len(frames))
+		}
+		for i := range delays {
+			if got[i] != delays[i] {
+				t.Errorf("Invalid %s frame delay: %s != %s", ImageTypeName(imageType), got[i], delays[i])
+			}
+		}
+		if err := assertSize(frames[0].Image(), 100, 80); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestAnimationErrors(t *testing.T) {
+	frames := animationFrames(t)
+
+	if _, err := NewAnimationFromFrames(nil, nil); err == nil {
+		t.Error("Animations without frames should fail")
+	}
+	if _, err := NewAnimationFromFrames(frames, []time.Duration{time.Second, time.Second}); err == nil {
+		t.Error("Mismatching delays should fail")
+	}
+
+	animation, _ := NewAnimationFromFrames(append(frames, initImage("test.png")), []time.Duration{time.Second})
+	if _, err := animation.Save(SaveOptions{}); err == nil {
+		t.Error("Frames of different sizes should fail")
+	}
+	if _, err := animation.Save(SaveOptions{Type: JPEG}); err == nil {
+		t.Error("Non animated types should fail")
+	}
+}
@@ -0,0 +1,269 @@
+package bimg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/png"
+	"time"
+)
+
+// pngSignature is the magic number of PNG files.
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngChunk represents a PNG chunk.
+type pngChunk struct {
+	kind string
+	data []byte
+}
+
+// apngFrame represents an APNG frame control chunk and its image data.
+type apngFrame struct {
+	width, height, left, top uint32
+	delay                    time.Duration
+	dispose, blend           byte
+	data                     [][]byte
+}
+
+// APNG frame dispose and blend operations
+const (
+	apngDisposeBackground = 1
+	apngDisposePrevious   = 2
+	apngBlendOver         = 1
+)
+
+// readPNGChunks splits a PNG buffer into its chunks, up to IEND.
+func readPNGChunks(buf []byte) ([]pngChunk, error) {
+	if !bytes.HasPrefix(buf, pngSignature) {
+		return nil, invalidHeader(PNG)
+	}
+	var chunks []pngChunk
+	for i := len(pngSignature); i+12 <= len(buf); {
+		size := int(binary.BigEndian.Uint32(buf[i:]))
+		if size < 0 || i+12+size > len(buf) {
+			return nil, wrapError(ErrTruncatedImage, "Truncated PNG chunk")
+		}
+		chunk := pngChunk{kind: string(buf[i+4 : i+8]), data: buf[i+8 : i+8+size]}
+		chunks = append(chunks, chunk)
+		if chunk.kind == "IEND" {
+			return chunks, nil
+		}
+		i += 12 + size
+	}
+	return nil, wrapError(ErrTruncatedImage, "PNG image without IEND chunk")
+}
+
+// writePNGChunk appends a chunk with its CRC.
+func writePNGChunk(w *bytes.Buffer, kind string, data []byte) {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+	copy(header[4:], kind)
+	w.Write(header[:])
+	w.Write(data)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	binary.Write(w, binary.BigEndian, crc.Sum32())
+}
+
+// isAPNG reports whether the PNG buffer is animated.
+func isAPNG(buf []byte) bool {
+	_, info, err := DetermineImageTypeInfo(buf)
+	return err == nil && info.Animated
+}
+
+// decodeAPNG composes the APNG frames over the canvas, applying their
+// dispose and blend operations, and encodes them as still PNG images.
+func decodeAPNG(buf []byte) ([]*Image, []time.Duration, error) {
+	chunks, err := readPNGChunks(buf)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(chunks) == 0 || chunks[0].kind != "IHDR" || len(chunks[0].data) != 13 {
+		return nil, nil, invalidHeader(PNG)
+	}
+	ihdr := chunks[0].data
+
+	// Chunks shared by every frame, such as the palette
+	var shared []pngChunk
+	var frames []*apngFrame
+	var current *apngFrame
+	for _, chunk := range chunks[1:] {
+		switch chunk.kind {
+		case "PLTE", "tRNS", "gAMA", "cHRM", "sRGB", "iCCP", "sBIT":
+			shared = append(shared, chunk)
+		case "fcTL":
+			if len(chunk.data) != 26 {
+				return nil, nil, wrapError(ErrTruncatedImage, "Invalid APNG frame control chunk")
+			}
+			current = parseAPNGFrame(chunk.data)
+			frames = append(frames, current)
+		case "IDAT":
+			// The default image is only a frame when preceded by fcTL
+			if current != nil {
+				current.data = append(current.data, chunk.data)
+			}
+		case "fdAT":
+			if current == nil || len(chunk.data) < 4 {
+				return nil, nil, wrapError(ErrTruncatedImage, "Invalid APNG frame data chunk")
+			}
+			current.data = append(current.data, chunk.data[4:])
+		}
+	}
+	if len(frames) == 0 {
+		return nil, nil, errors.New("PNG image is not animated")
+	}
+
+	width, height := binary.BigEndian.Uint32(ihdr[0:]), binary.BigEndian.Uint32(ihdr[4:])
+	if err := GetLimits().checkSize(int(width), int(height), len(frames)); err != nil {
+		return nil, nil, err
+	}
+
+	canvas := image.NewNRGBA(image.Rect(0, 0, int(width), int(height)))
+	images := make([]*Image, len(frames))
+	delays := make([]time.Duration, len(frames))
+	for i, frame := range frames {
+		// Written so the uint32 offsets cannot wrap around
+		if frame.width > width || frame.left > width-frame.width ||
+			frame.height > height || frame.top > height-frame.height {
+			return nil, nil, fmt.Errorf("APNG frame %d exceeds the canvas", i)
+		}
+		if err := GetLimits().checkSize(int(frame.width), int(frame.height), 1); err != nil {
+			return nil, nil, err
+		}
+		img, err := png.Decode(bytes.NewReader(apngFramePNG(ihdr, shared, frame)))
+		if err != nil {
+			return nil, nil, wrapError(ErrTruncatedImage, fmt.Sprintf("Cannot decode APNG frame %d: %s", i, err))
+		}
+
+		var previous *image.NRGBA
+		if frame.dispose == apngDisposePrevious {
+			previous = image.NewNRGBA(canvas.Rect)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		rect := image.Rect(int(frame.left), int(frame.top), int(frame.left+frame.width), int(frame.top+frame.height))
+		op := draw.Src
+		if frame.blend == apngBlendOver {
+			op = draw.Over
+		}
+		draw.Draw(canvas, rect, img, img.Bounds().Min, op)
+
+		var out bytes.Buffer
+		if err := png.Encode(&out, canvas); err != nil {
+			return nil, nil, err
+		}
+		images[i] = NewImage(out.Bytes())
+		delays[i] = frame.delay
+
+		switch frame.dispose {
+		case apngDisposeBackground:
+			draw.Draw(canvas, rect, image.Transparent, image.Point{}, draw.Src)
+		case apngDisposePrevious:
+			canvas = previous
+		}
+	}
+	return images, delays, nil
+}
+
+func parseAPNGFrame(data []byte) *apngFrame {
+	frame := &apngFrame{
+		width:   binary.BigEndian.Uint32(data[4:]),
+		height:  binary.BigEndian.Uint32(data[8:]),
+		left:    binary.BigEndian.Uint32(data[12:]),
+		top:     binary.BigEndian.Uint32(data[16:]),
+		dispose: data[24],
+		blend:   data[25],
+	}
+	num, den := binary.BigEndian.Uint16(data[20:]), binary.BigEndian.Uint16(data[22:])
+	if den == 0 {
+		den = 100
+	}
+	frame.delay = time.Duration(num) * time.Second / time.Duration(den)
+	return frame
+}
+
+// apngFramePNG returns a still PNG image of the frame data.
+func apngFramePNG(ihdr []byte, shared []pngChunk, frame *apngFrame) []byte {
+	var out bytes.Buffer
+	out.Write(pngSignature)
+
+	header := append([]byte(nil), ihdr...)
+	binary.BigEndian.PutUint32(header[0:], frame.width)
+	binary.BigEndian.PutUint32(header[4:], frame.height)
+	writePNGChunk(&out, "IHDR", header)
+	for _, chunk := range shared {
+		writePNGChunk(&out, chunk.kind, chunk.data)
+	}
+	for _, data := range frame.data {
+		writePNGChunk(&out, "IDAT", data)
+	}
+	writePNGChunk(&out, "IEND", nil)
+	return out.Bytes()
+}
+
+// encodeAPNG assembles still PNG images, sharing the same header, into an
+// APNG animation. The frames replace each other entirely.
+func encodeAPNG(frames [][]byte, delays []time.Duration, loop int) ([]byte, error) {
+	var out bytes.Buffer
+	var ihdr []byte
+	sequence := uint32(0)
+
+	for i, frame := range frames {
+		chunks, err := readPNGChunks(frame)
+		if err != nil {
+			return nil, err
+		}
+		if len(chunks) == 0 || chunks[0].kind != "IHDR" {
+			return nil, invalidHeader(PNG)
+		}
+
+		if i == 0 {
+			ihdr = chunks[0].data
+			out.Write(pngSignature)
+			writePNGChunk(&out, "IHDR", ihdr)
+			for _, chunk := range chunks[1:] {
+				if chunk.kind == "IDAT" {
+					break
+				}
+				writePNGChunk(&out, chunk.kind, chunk.data)
+			}
+			actl := make([]byte, 8)
+			binary.BigEndian.PutUint32(actl[0:], uint32(len(frames)))
+			binary.BigEndian.PutUint32(actl[4:], uint32(loop))
+			writePNGChunk(&out, "acTL", actl)
+		} else if !bytes.Equal(chunks[0].data, ihdr) {
+			return nil, fmt.Errorf("APNG frame %d header differs from the first frame", i)
+		}
+
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl[0:], sequence)
+		copy(fctl[4:12], ihdr[0:8])
+		binary.BigEndian.PutUint16(fctl[20:], uint16(delays[i]/time.Millisecond))
+		binary.BigEndian.PutUint16(fctl[22:], 1000)
+		writePNGChunk(&out, "fcTL", fctl)
+		sequence++
+
+		for _, chunk := range chunks {
+			if chunk.kind != "IDAT" {
+				continue
+			}
+			if i == 0 {
+				writePNGChunk(&out, "IDAT", chunk.data)
+				continue
+			}
+			fdat := make([]byte, 4, 4+len(chunk.data))
+			binary.BigEndian.PutUint32(fdat, sequence)
+			writePNGChunk(&out, "fdAT", append(fdat, chunk.data...))
+			sequence++
+		}
+	}
+
+	writePNGChunk(&out, "IEND", nil)
+	return out.Bytes(), nil
+}
@@ -0,0 +1,178 @@
+package bimg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+	"time"
+)
+
+func solidPNG(t *testing.T, width, height int, c color.NRGBA) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	var out bytes.Buffer
+	if err := png.Encode(&out, img); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func pixelAt(t *testing.T, img *Image, x, y int) color.NRGBA {
+	decoded, err := png.Decode(bytes.NewReader(img.Image()))
+	if err != nil {
+		t.Fatalf("Cannot decode the frame: %s", err)
+	}
+	return color.NRGBAModel.Convert(decoded.At(x, y)).(color.NRGBA)
+}
+
+var (
+	red  = color.NRGBA{255, 0, 0, 255}
+	blue = color.NRGBA{0, 0, 255, 255}
+)
+
+func TestAPNGRoundTrip(t *testing.T) {
+	frames := [][]byte{solidPNG(t, 4, 3, red), solidPNG(t, 4, 3, blue), solidPNG(t, 4, 3, red)}
+	delays := []time.Duration{100 * time.Millisecond, 250 * time.Millisecond, time.Second}
+
+	buf, err := encodeAPNG(frames, delays, 0)
+	if err != nil {
+		t.Fatalf("Cannot encode the APNG: %s", err)
+	}
+	_, info, err := DetermineImageTypeInfo(buf)
+	if err != nil || !info.Animated || info.Pages != 3 {
+		t.Errorf("Invalid APNG type info: %#v", info)
+	}
+
+	images, got, err := decodeAPNG(buf)
+	if err != nil {
+		t.Fatalf("Cannot decode the APNG: %s", err)
+	}
+	if len(images) != 3 {
+		t.Fatalf("Invalid number of frames: %d", len(images))
+	}
+	for i, expected := range []color.NRGBA{red, blue, red} {
+		if c := pixelAt(t, images[i], 1, 1); c != expected {
+			t.Errorf("Invalid frame %d color: %#v", i, c)
+		}
+		if got[i] != delays[i] {
+			t.Errorf("Invalid frame %d delay: %s", i, got[i])
+		}
+	}
+
+	if _, err := encodeAPNG([][]byte{frames[0], solidPNG(t, 2, 2, red)}, delays, 0); err == nil {
+		t.Error("Frames of different sizes should fail")
+	}
+}
+
+func TestAPNGPartialFrames(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write(pngSignature)
+
+	base, _ := readPNGChunks(solidPNG(t, 4, 4, red))
+	patch, _ := readPNGChunks(solidPNG(t, 2, 2, blue))
+	writePNGChunk(&buf, "IHDR", base[0].data)
+	writePNGChunk(&buf, "acTL", []byte{0, 0, 0, 3, 0, 0, 0, 0})
+
+	fctl := func(sequence, width, height, left, top uint32, dispose byte) []byte {
+		data := make([]byte, 26)
+		binary.BigEndian.PutUint32(data[0:], sequence)
+		binary.BigEndian.PutUint32(data[4:], width)
+		binary.BigEndian.PutUint32(data[8:], height)
+		binary.BigEndian.PutUint32(data[12:], left)
+		binary.BigEndian.PutUint32(data[16:], top)
+		binary.BigEndian.PutUint16(data[20:], 1)
+		binary.BigEndian.PutUint16(data[22:], 10)
+		data[24] = dispose
+		return data
+	}
+	fdat := func(sequence uint32, chunks []pngChunk) {
+		for _, chunk := range chunks {
+			if chunk.kind == "IDAT" {
+				data := make([]byte, 4)
+				binary.BigEndian.PutUint32(data, sequence)
+				writePNGChunk(&buf, "fdAT", append(data, chunk.data...))
+			}
+		}
+	}
+
+	writePNGChunk(&buf, "fcTL", fctl(0, 4, 4, 0, 0, 0))
+	for _, chunk := range base {
+		if chunk.kind == "IDAT" {
+			writePNGChunk(&buf, "IDAT", chunk.data)
+		}
+	}
+	writePNGChunk(&buf, "fcTL", fctl(1, 2, 2, 2, 2, apngDisposePrevious))
+	fdat(2, patch)
+	writePNGChunk(&buf, "fcTL", fctl(3, 2, 2, 0, 0, 0))
+	fdat(4, patch)
+	writePNGChunk(&buf, "IEND", nil)
+
+	images, delays, err := decodeAPNG(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Cannot decode the APNG: %s", err)
+	}
+	if len(images) != 3 || delays[0] != 100*time.Millisecond {
+		t.Fatalf("Invalid frames: %d, %s", len(images), delays[0])
+	}
+	if pixelAt(t, images[1], 0, 0) != red || pixelAt(t, images[1], 3, 3) != blue {
+		t.Error("The partial frame should be composed over the previous one")
+	}
+	if pixelAt(t, images[2], 3, 3) != red || pixelAt(t, images[2], 0, 0) != blue {
+		t.Error("The partial frame should be disposed to the previous canvas")
+	}
+}
+
+func TestAPNGFrameOutOfCanvas(t *testing.T) {
+	base, _ := readPNGChunks(solidPNG(t, 4, 4, red))
+	for _, origin := range [][2]uint32{{2, 0}, {0xfffffffe, 0}, {0, 0xffffffff}} {
+		var buf bytes.Buffer
+		buf.Write(pngSignature)
+		writePNGChunk(&buf, "IHDR", base[0].data)
+		writePNGChunk(&buf, "acTL", []byte{0, 0, 0, 1, 0, 0, 0, 0})
+
+		// The offsets overflow uint32 once added to the frame size
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl[4:], 4)
+		binary.BigEndian.PutUint32(fctl[8:], 4)
+		binary.BigEndian.PutUint32(fctl[12:], origin[0])
+		binary.BigEndian.PutUint32(fctl[16:], origin[1])
+		writePNGChunk(&buf, "fcTL", fctl)
+		for _, chunk := range base {
+			if chunk.kind == "IDAT" {
+				writePNGChunk(&buf, "IDAT", chunk.data)
+			}
+		}
+		writePNGChunk(&buf, "IEND", nil)
+
+		if _, _, err := decodeAPNG(buf.Bytes()); err == nil {
+			t.Errorf("Expected an error for the frame origin %v", origin)
+		}
+	}
+}
+
+func TestAnimationSaveAPNG(t *testing.T) {
+	animation, err := NewAnimationFromFrames(animationFrames(t), []time.Duration{200 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Cannot create the animation: %#v", err)
+	}
+	buf, err := animation.Save(SaveOptions{Type: PNG})
+	if err != nil {
+		t.Fatalf("Cannot save the animation: %#v", err)
+	}
+
+	frames, delays, err := NewImage(buf).Frames()
+	if err != nil {
+		t.Fatalf("Cannot extract the frames: %#v", err)
+	}
+	if len(frames) != 3 || delays[2] != 200*time.Millisecond {
+		t.Fatalf("Invalid APNG frames: %d", len(frames))
+	}
+	if err := assertSize(frames[1].Image(), 100, 80); err != nil {
+		t.Error(err)
+	}
+}
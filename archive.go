@@ -0,0 +1,105 @@
+package bimg
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ArchiveFormat represents the format of an archive of images.
+type ArchiveFormat int
+
+const (
+	// ArchiveZip writes a ZIP archive. It is the default.
+	ArchiveZip ArchiveFormat = iota
+	// ArchiveTar writes an uncompressed tar archive.
+	ArchiveTar
+)
+
+// ArchiveEntry represents a named file of an archive.
+type ArchiveEntry struct {
+	// Name defines the slash separated path of the file in the archive.
+	Name   string
+	Buffer []byte
+}
+
+// WriteArchive streams the entries as a ZIP or tar archive to the writer,
+// in order, e.g. all the variants of a srcset in a download endpoint. The
+// entry names must be unique relative paths. ZIP entries are stored without
+// compression, as images are already compressed.
+func WriteArchive(w io.Writer, entries []ArchiveEntry, format ArchiveFormat) error {
+	if len(entries) == 0 {
+		return errors.New("No archive entries given")
+	}
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		name := entry.Name
+		if name == "" || path.IsAbs(name) || strings.Contains(name, "\\") || path.Clean(name) != name ||
+			name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("Invalid archive entry name: %q", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("Duplicated archive entry name: %s", name)
+		}
+		seen[name] = true
+	}
+
+	now := time.Now()
+	switch format {
+	case ArchiveZip:
+		return writeZip(w, entries, now)
+	case ArchiveTar:
+		return writeTar(w, entries, now)
+	}
+	return fmt.Errorf("Unsupported archive format: %d", format)
+}
+
+func writeZip(w io.Writer, entries []ArchiveEntry, modified time.Time) error {
+	zw := zip.NewWriter(w)
+	for _, entry := range entries {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: entry.Name, Method: zip.Store, Modified: modified})
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(entry.Buffer); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func writeTar(w io.Writer, entries []ArchiveEntry, modified time.Time) error {
+	tw := tar.NewWriter(w)
+	for _, entry := range entries {
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     entry.Name,
+			Mode:     0644,
+			Size:     int64(len(entry.Buffer)),
+			ModTime:  modified,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(entry.Buffer); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// ArchiveEntries returns the srcset variants as archive entries, named
+// "<width>.<extension>", e.g. "640.webp" or "640.jpg".
+func (s *SrcSet) ArchiveEntries() []ArchiveEntry {
+	entries := make([]ArchiveEntry, len(s.Variants))
+	for i, v := range s.Variants {
+		entries[i] = ArchiveEntry{Name: outputPath(strconv.Itoa(v.Width), v.Type), Buffer: v.Buffer}
+	}
+	return entries
+}
@@ -0,0 +1,96 @@
+package bimg
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+var archiveEntries = []ArchiveEntry{
+	{Name: "640.jpg", Buffer: []byte("first")},
+	{Name: "sizes/320.webp", Buffer: []byte("second")},
+}
+
+func TestWriteArchiveZip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteArchive(&buf, archiveEntries, ArchiveZip); err != nil {
+		t.Fatalf("Cannot write the archive: %s", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Cannot read the archive: %s", err)
+	}
+	if len(zr.File) != len(archiveEntries) {
+		t.Fatalf("Invalid number of entries: %d", len(zr.File))
+	}
+	for i, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatalf("Cannot open the entry: %s", err)
+		}
+		data, _ := ioutil.ReadAll(r)
+		r.Close()
+		if f.Name != archiveEntries[i].Name || !bytes.Equal(data, archiveEntries[i].Buffer) || f.Method != zip.Store {
+			t.Errorf("Invalid entry: %s", f.Name)
+		}
+	}
+}
+
+func TestWriteArchiveTar(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteArchive(&buf, archiveEntries, ArchiveTar); err != nil {
+		t.Fatalf("Cannot write the archive: %s", err)
+	}
+
+	tr := tar.NewReader(&buf)
+	for _, entry := range archiveEntries {
+		header, err := tr.Next()
+		if err != nil {
+			t.Fatalf("Cannot read the archive: %s", err)
+		}
+		data, _ := ioutil.ReadAll(tr)
+		if header.Name != entry.Name || !bytes.Equal(data, entry.Buffer) {
+			t.Errorf("Invalid entry: %s", header.Name)
+		}
+	}
+	if _, err := tr.Next(); err != io.EOF {
+		t.Error("Expected the end of the archive")
+	}
+}
+
+func TestWriteArchiveErrors(t *testing.T) {
+	cases := [][]ArchiveEntry{
+		nil,
+		{{Name: ""}},
+		{{Name: "/etc/passwd"}},
+		{{Name: "../up.jpg"}},
+		{{Name: "a/../b.jpg"}},
+		{{Name: "a.jpg"}, {Name: "a.jpg"}},
+	}
+	for _, entries := range cases {
+		if err := WriteArchive(ioutil.Discard, entries, ArchiveZip); err == nil {
+			t.Errorf("Expected an error for entries %v", entries)
+		}
+	}
+	if err := WriteArchive(ioutil.Discard, archiveEntries, ArchiveFormat(2)); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestSrcSetArchiveEntries(t *testing.T) {
+	set, err := GenerateSrcSet(initImage("test.jpg"), []int{320, 640}, []ImageType{JPEG, PNG}, SrcSetOptions{})
+	if err != nil {
+		t.Fatalf("Cannot generate the srcset: %#v", err)
+	}
+	entries := set.ArchiveEntries()
+	if len(entries) != 4 || entries[0].Name != "320.jpg" || entries[3].Name != "640.png" {
+		t.Fatalf("Invalid archive entries: %d", len(entries))
+	}
+	if err := WriteArchive(ioutil.Discard, entries, ArchiveZip); err != nil {
+		t.Errorf("Cannot write the archive: %s", err)
+	}
+}
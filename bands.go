@@ -0,0 +1,50 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import "errors"
+
+// BandJoin joins the bands (channels) of the given images into a new image,
+// in order. All the images must have the same size. The output keeps the
+// type of the first image, unless it cannot store the resultant bands.
+func BandJoin(images ...*Image) ([]byte, error) {
+	if len(images) == 0 {
+		return nil, errors.New("No images to join")
+	}
+
+	defer C.vips_thread_shutdown()
+
+	var imageType ImageType
+	in := make([]*C.VipsImage, 0, len(images))
+	for n, img := range images {
+		image, typ, err := loadImage(img.buf())
+		if err != nil {
+			for _, image := range in {
+				C.g_object_unref(C.gpointer(image))
+			}
+			return nil, err
+		}
+		if n == 0 {
+			imageType = typ
+		}
+		in = append(in, image)
+	}
+
+	image, err := vipsBandJoin(in)
+	if err != nil {
+		return nil, err
+	}
+
+	o := Options{Interpretation: InterpretationBW}
+	if image.Bands >= 3 {
+		o.Interpretation = InterpretationSRGB
+	}
+	if imageType == JPEG && image.Bands != 1 && image.Bands != 3 {
+		o.Type = PNG
+	}
+	return saveImage(image, applyDefaults(o, imageType))
+}
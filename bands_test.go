@@ -0,0 +1,44 @@
+package bimg
+
+import "testing"
+
+func TestBandJoin(t *testing.T) {
+	img := initImage("test.jpg")
+
+	bands := make([]*Image, 3)
+	for n := range bands {
+		buf, err := NewImage(img.Image()).ExtractBand(n, 1)
+		if err != nil {
+			t.Fatalf("Cannot extract band %d: %#v", n, err)
+		}
+		bands[n] = NewImage(buf)
+	}
+
+	// Swap red and blue channels
+	buf, err := BandJoin(bands[2], bands[1], bands[0])
+	if err != nil {
+		t.Fatalf("Cannot join bands: %#v", err)
+	}
+
+	if DetermineImageType(buf) != JPEG {
+		t.Fatal("Image is not jpeg")
+	}
+	meta, err := Metadata(buf)
+	if err != nil {
+		t.Fatalf("Cannot read image metadata: %#v", err)
+	}
+	if meta.Channels != 3 {
+		t.Errorf("Invalid number of channels: %d", meta.Channels)
+	}
+	if meta.Size.Width != 1680 || meta.Size.Height != 1050 {
+		t.Errorf("Invalid image size: %dx%d", meta.Size.Width, meta.Size.Height)
+	}
+
+	Write("testdata/test_bandjoin_out.jpg", buf)
+}
+
+func TestBandJoinEmpty(t *testing.T) {
+	if _, err := BandJoin(); err == nil {
+		t.Fatal("Expected error joining no images")
+	}
+}
@@ -0,0 +1,99 @@
+package bimg
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Recipe represents the transformation applied to every image of a batch.
+type Recipe struct {
+	Options Options
+	// Output returns the output file path of an input file path. Outputs
+	// are kept in memory, in the batch results, when nil.
+	Output func(path string) string
+}
+
+// BatchResult represents the result of a single image of a batch.
+type BatchResult struct {
+	Path string
+	// Output is the written file path, if any.
+	Output string
+	// Buffer is the output image, when the recipe defines no output path.
+	Buffer []byte
+	Err    error
+}
+
+// Batch processes many images with a bounded number of workers, so bulk
+// conversions don't have to pool the calls to libvips themselves.
+type Batch struct {
+	// Progress is called after each processed image, with the number of
+	// images done so far. Calls are serialized.
+	Progress func(done, total int, result BatchResult)
+}
+
+// ProcessFiles reads, transforms and optionally writes the given image
+// files using up to concurrency workers, defaulting to the number of CPUs.
+// Results are returned in the order of the paths.
+func (b *Batch) ProcessFiles(paths []string, recipe Recipe, concurrency int) []BatchResult {
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+
+	results := make([]BatchResult, len(paths))
+	jobs := make(chan int)
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		done int
+	)
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = processFile(paths[i], recipe)
+
+				if b.Progress != nil {
+					mu.Lock()
+					done++
+					b.Progress(done, len(paths), results[i])
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+func processFile(path string, recipe Recipe) BatchResult {
+	result := BatchResult{Path: path}
+
+	buf, err := Read(path)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	buf, err = Resize(buf, recipe.Options)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	if recipe.Output == nil {
+		result.Buffer = buf
+		return result
+	}
+
+	result.Output = recipe.Output(path)
+	result.Err = Write(result.Output, buf)
+	return result
+}
@@ -0,0 +1,67 @@
+package bimg
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBatchProcessFiles(t *testing.T) {
+	paths := []string{"testdata/test.jpg", "testdata/test.png", "testdata/missing.jpg", "testdata/test.webp"}
+
+	var progress []int
+	batch := Batch{
+		Progress: func(done, total int, result BatchResult) {
+			if total != len(paths) {
+				t.Errorf("Invalid total: %d", total)
+			}
+			progress = append(progress, done)
+		},
+	}
+
+	results := batch.ProcessFiles(paths, Recipe{Options: Options{Width: 100, Type: JPEG}}, 2)
+	if len(results) != len(paths) || len(progress) != len(paths) || progress[len(progress)-1] != len(paths) {
+		t.Fatalf("Invalid results: %d results, progress %v", len(results), progress)
+	}
+
+	for i, result := range results {
+		if result.Path != paths[i] {
+			t.Errorf("Invalid result order: %s != %s", result.Path, paths[i])
+		}
+		if strings.Contains(result.Path, "missing") {
+			if result.Err == nil {
+				t.Error("Expected error for a missing file")
+			}
+			continue
+		}
+		if result.Err != nil {
+			t.Errorf("Cannot process %s: %#v", result.Path, result.Err)
+			continue
+		}
+		if size, _ := Size(result.Buffer); size.Width != 100 {
+			t.Errorf("Invalid image width for %s: %d", result.Path, size.Width)
+		}
+	}
+}
+
+func TestBatchProcessFilesOutput(t *testing.T) {
+	recipe := Recipe{
+		Options: Options{Width: 100, Type: PNG},
+		Output: func(path string) string {
+			return "testdata/test_batch_" + strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)) + "_out.png"
+		},
+	}
+
+	results := (&Batch{}).ProcessFiles([]string{"testdata/test.jpg"}, recipe, 0)
+	if results[0].Err != nil {
+		t.Fatalf("Cannot process the image: %#v", results[0].Err)
+	}
+	if results[0].Output != "testdata/test_batch_test_out.png" || results[0].Buffer != nil {
+		t.Errorf("Invalid result: %#v", results[0])
+	}
+
+	buf, err := Read(results[0].Output)
+	if err != nil || DetermineImageType(buf) != PNG {
+		t.Errorf("Invalid output file: %#v", err)
+	}
+}
@@ -0,0 +1,103 @@
+// Package bench provides reproducible benchmarks of common bimg pipelines,
+// reporting the libvips memory next to the Go allocations, so performance
+// regressions in the cgo layer are caught. The libvips memory peak of each
+// pipeline is measured in its own process, as libvips never resets it.
+//
+//	go test -run NONE -bench . -benchmem ./bench
+package bench
+
+import (
+	"fmt"
+
+	"github.com/h2non/bimg"
+)
+
+// Source describes the generated input image of a pipeline.
+type Source struct {
+	Type   bimg.ImageType
+	Width  int
+	Height int
+}
+
+// Pipeline represents a benchmarked image operation.
+type Pipeline struct {
+	Name   string
+	Source Source
+	// Output is the image type the pipeline encodes to, skipped when the
+	// libvips build cannot save it.
+	Output bimg.ImageType
+	Run    func(buf []byte) ([]byte, error)
+}
+
+// Source4K is the 4K UHD JPEG source of the thumbnail pipelines.
+var Source4K = Source{Type: bimg.JPEG, Width: 3840, Height: 2160}
+
+// Pipelines are the benchmarked pipelines.
+var Pipelines = []Pipeline{
+	{
+		Name:   "JPEG4KThumbnail800",
+		Source: Source4K,
+		Output: bimg.JPEG,
+		Run: func(buf []byte) ([]byte, error) {
+			return bimg.Resize(buf, bimg.Options{Width: 800})
+		},
+	},
+	{
+		Name:   "JPEG4KThumbnail800NoShrinkOnLoad",
+		Source: Source4K,
+		Output: bimg.JPEG,
+		Run: func(buf []byte) ([]byte, error) {
+			return bimg.Resize(buf, bimg.Options{Width: 800, Load: bimg.LoadOptions{NoShrinkOnLoad: true}})
+		},
+	},
+	{
+		Name:   "JPEG4KThumbnailAPI800",
+		Source: Source4K,
+		Output: bimg.JPEG,
+		Run: func(buf []byte) ([]byte, error) {
+			return bimg.NewImage(buf).Thumbnail(800)
+		},
+	},
+	{
+		Name:   "WebPToAVIF",
+		Source: Source{Type: bimg.WEBP, Width: 1920, Height: 1080},
+		Output: bimg.AVIF,
+		Run: func(buf []byte) ([]byte, error) {
+			return bimg.NewImage(buf).Convert(bimg.AVIF)
+		},
+	},
+	{
+		Name:   "WebPToAVIFNoShrinkOnLoad",
+		Source: Source{Type: bimg.WEBP, Width: 1920, Height: 1080},
+		Output: bimg.AVIF,
+		Run: func(buf []byte) ([]byte, error) {
+			return bimg.Resize(buf, bimg.Options{Width: 800, Type: bimg.AVIF, Load: bimg.LoadOptions{NoShrinkOnLoad: true}})
+		},
+	},
+	{
+		Name:   "WebPToAVIFShrinkOnLoad",
+		Source: Source{Type: bimg.WEBP, Width: 1920, Height: 1080},
+		Output: bimg.AVIF,
+		Run: func(buf []byte) ([]byte, error) {
+			return bimg.Resize(buf, bimg.Options{Width: 800, Type: bimg.AVIF})
+		},
+	},
+}
+
+// Generate scales the fixture image to the size and type of the source.
+// The same fixture and libvips version always generate the same image, so
+// results are comparable across runs.
+func Generate(fixture []byte, s Source) ([]byte, error) {
+	if !bimg.IsTypeSupportedSave(s.Type) {
+		return nil, fmt.Errorf("Unsupported source type: %s", bimg.ImageTypeName(s.Type))
+	}
+	return bimg.Resize(fixture, bimg.Options{
+		Width:         s.Width,
+		Height:        s.Height,
+		Force:         true,
+		Enlarge:       true,
+		Quality:       90,
+		Type:          s.Type,
+		StripMetadata: true,
+	})
+}
@@ -0,0 +1,180 @@
+package bench
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/h2non/bimg"
+)
+
+// peakEnv defines the pipeline and source image file whose libvips memory
+// peak is measured by TestPipelinePeak, in a child process.
+const peakEnv = "BIMG_BENCH_PEAK"
+
+var sources = struct {
+	sync.Mutex
+	buffers map[Source][]byte
+}{buffers: map[Source][]byte{}}
+
+// source generates the source image once per benchmark binary.
+func source(tb testing.TB, s Source) []byte {
+	sources.Lock()
+	defer sources.Unlock()
+	if buf, ok := sources.buffers[s]; ok {
+		return buf
+	}
+
+	fixture, err := ioutil.ReadFile("../testdata/test.jpg")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if !bimg.IsTypeSupportedSave(s.Type) {
+		tb.Skipf("Unsupported source type: %s", bimg.ImageTypeName(s.Type))
+	}
+	buf, err := Generate(fixture, s)
+	if err != nil {
+		tb.Fatalf("Cannot generate the source image: %s", err)
+	}
+	sources.buffers[s] = buf
+	return buf
+}
+
+func TestGenerate(t *testing.T) {
+	buf := source(t, Source4K)
+	size, err := bimg.Size(buf)
+	if err != nil {
+		t.Fatalf("Cannot read the source size: %s", err)
+	}
+	if size.Width != Source4K.Width || size.Height != Source4K.Height || bimg.DetermineImageType(buf) != bimg.JPEG {
+		t.Fatalf("Invalid source image: %dx%d", size.Width, size.Height)
+	}
+}
+
+func TestPipelines(t *testing.T) {
+	for _, p := range Pipelines {
+		if !bimg.IsTypeSupportedSave(p.Output) {
+			continue
+		}
+		buf, err := p.Run(source(t, p.Source))
+		if err != nil {
+			t.Fatalf("Cannot run the %s pipeline: %s", p.Name, err)
+		}
+		if bimg.DetermineImageType(buf) != p.Output {
+			t.Errorf("Invalid %s output type: %s", p.Name, bimg.ImageTypeName(bimg.DetermineImageType(buf)))
+		}
+	}
+}
+
+// TestPipelinePeak runs a single pipeline, once, in the child process
+// started by pipelinePeak, then prints the libvips memory highwater.
+func TestPipelinePeak(t *testing.T) {
+	env := os.Getenv(peakEnv)
+	if env == "" {
+		t.Skip("Only run by the pipeline benchmarks")
+	}
+	parts := strings.SplitN(env, ":", 2)
+	buf, err := ioutil.ReadFile(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range Pipelines {
+		if p.Name != parts[0] {
+			continue
+		}
+		if _, err := p.Run(buf); err != nil {
+			t.Fatalf("Cannot run the %s pipeline: %s", p.Name, err)
+		}
+		fmt.Printf("vips-peak-B %d\n", bimg.VipsMemory().MemoryHighwater)
+		return
+	}
+	t.Fatalf("Unknown pipeline: %s", parts[0])
+}
+
+// pipelinePeak returns the libvips memory highwater of a process only
+// running the pipeline, unaffected by the source generation and the other
+// pipelines.
+func pipelinePeak(p Pipeline, source []byte) (int64, error) {
+	file, err := ioutil.TempFile("", "bimg-bench")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(source)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestPipelinePeak$")
+	cmd.Env = append(os.Environ(), peakEnv+"="+p.Name+":"+file.Name())
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %s", err, out)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) == 2 && fields[0] == "vips-peak-B" {
+			return strconv.ParseInt(fields[1], 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("No memory peak reported: %s", out)
+}
+
+// Run benchmarks the pipeline on the source image. Besides the Go
+// allocations, it reports the libvips memory peak of the pipeline, the
+// libvips memory retained per operation, which grows on leaks, and the
+// output size.
+func Run(b *testing.B, p Pipeline, source []byte) {
+	if !bimg.IsTypeSupportedSave(p.Output) {
+		b.Skipf("Unsupported output type: %s", bimg.ImageTypeName(p.Output))
+	}
+
+	// Warm up the decoders and drop the cached operations, so only the
+	// pipeline memory is measured.
+	if _, err := p.Run(source); err != nil {
+		b.Fatalf("Cannot run the %s pipeline: %s", p.Name, err)
+	}
+	bimg.VipsCacheDropAll()
+	before := bimg.VipsMemory()
+
+	var size int
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		buf, err := p.Run(source)
+		if err != nil {
+			b.Fatalf("Cannot run the %s pipeline: %s", p.Name, err)
+		}
+		size = len(buf)
+	}
+	b.StopTimer()
+
+	bimg.VipsCacheDropAll()
+	after := bimg.VipsMemory()
+	peak, err := pipelinePeak(p, source)
+	if err != nil {
+		b.Fatalf("Cannot measure the %s memory peak: %s", p.Name, err)
+	}
+	b.ReportMetric(float64(peak), "vips-peak-B")
+	b.ReportMetric(float64(after.Memory-before.Memory)/float64(b.N), "vips-retained-B/op")
+	b.ReportMetric(float64(size), "out-B")
+}
+
+func BenchmarkPipelines(b *testing.B) {
+	for _, p := range Pipelines {
+		p := p
+		b.Run(p.Name, func(b *testing.B) {
+			Run(b, p, source(b, p.Source))
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"io"
+	"runtime"
+	"unsafe"
+)
+
+// maxBufferSize is the largest Buffer, sliced from C memory through an
+// array type that must fit in the 32-bit platforms.
+const maxBufferSize = 1 << 30
+
+// Buffer is an image buffer held in C memory, handed over between Go and
+// libvips without copying it. The output images of ResizeBuffer are
+// encoded straight into one, and input images read into one are decoded
+// in place, while the Go garbage collector never moves or scans them.
+// ResizeBuffer and Processor avoid the output copy. Resize and the Image
+// methods return buffers without an owner to free them, so they still copy
+// their output from libvips into Go memory.
+//
+// The caller owns the buffer and must release it via Free once unused,
+// as the Go garbage collector does not. Its bytes must not be used
+// afterwards.
+type Buffer struct {
+	ptr  unsafe.Pointer
+	size int
+}
+
+// NewBuffer allocates a Buffer of the given size in C memory.
+func NewBuffer(size int) (*Buffer, error) {
+	if size <= 0 || size > maxBufferSize {
+		return nil, errors.New("Invalid buffer size")
+	}
+	return &Buffer{ptr: unsafe.Pointer(C.g_malloc(C.gsize(size))), size: size}, nil
+}
+
+// ReadBuffer reads an image of the given size from the reader into a new
+// Buffer, avoiding its copy into Go memory.
+func ReadBuffer(r io.Reader, size int) (*Buffer, error) {
+	b, err := NewBuffer(size)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(r, b.Bytes()); err != nil {
+		b.Free()
+		return nil, err
+	}
+	return b, nil
+}
+
+// Bytes returns the buffer bytes, backed by C memory. They are valid until
+// the buffer is freed.
+func (b *Buffer) Bytes() []byte {
+	if b.ptr == nil {
+		return nil
+	}
+	return (*[maxBufferSize]byte)(b.ptr)[:b.size:b.size]
+}
+
+// Len returns the buffer size in bytes.
+func (b *Buffer) Len() int {
+	return b.size
+}
+
+// Free releases the buffer memory. It is safe to call it more than once.
+func (b *Buffer) Free() {
+	if b.ptr != nil {
+		C.g_free(C.gpointer(b.ptr))
+	}
+	b.ptr, b.size = nil, 0
+}
+
+// own takes the ownership of a buffer allocated by libvips, releasing the
+// previous one.
+func (b *Buffer) own(ptr unsafe.Pointer, size int) {
+	b.Free()
+	b.ptr, b.size = ptr, size
+}
+
+// holds reports whether buf is the whole buffer memory.
+func (b *Buffer) holds(buf []byte) bool {
+	return b.ptr != nil && len(buf) > 0 && len(buf) == b.size && unsafe.Pointer(&buf[0]) == b.ptr
+}
+
+// bufferFrom copies a Go image buffer into a new Buffer.
+func bufferFrom(buf []byte) (*Buffer, error) {
+	b, err := NewBuffer(len(buf))
+	if err != nil {
+		return nil, err
+	}
+	copy(b.Bytes(), buf)
+	return b, nil
+}
+
+// ResizeBuffer transforms the image buffer like Resize, but encodes the
+// output image straight into a Buffer owned by the caller, rather than
+// copying it from libvips into Go memory. Outputs not encoded by libvips,
+// such as the lossless JPEG transforms, or served by the package cache,
+// are copied into the Buffer. Outputs are limited to 1GB.
+func ResizeBuffer(buf []byte, o Options) (*Buffer, error) {
+	out, output, err := resizeHandoff(buf, o)
+	if err != nil {
+		return nil, err
+	}
+	if output == nil {
+		return bufferFrom(out)
+	}
+	return output, nil
+}
+
+// resizeHandoff transforms the image buffer like Resize, encoding the
+// output straight into a new Buffer when possible. It returns the Buffer
+// holding the output, or nil when the output is held by Go memory.
+func resizeHandoff(buf []byte, o Options) ([]byte, *Buffer, error) {
+	if GetCache() != nil {
+		out, err := Resize(buf, o)
+		return out, nil, err
+	}
+
+	output := &Buffer{}
+	o.output = output
+	out, err := resizer(buf, o)
+	runtime.KeepAlive(buf)
+	if err != nil {
+		output.Free()
+		return nil, nil, err
+	}
+	if !output.holds(out) {
+		output.Free()
+		return out, nil, nil
+	}
+	return out, output, nil
+}
@@ -0,0 +1,61 @@
+package bimg
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestResizeBuffer(t *testing.T) {
+	buf := readFile("test.jpg")
+	input, err := ReadBuffer(bytes.NewReader(buf), len(buf))
+	if err != nil {
+		t.Fatalf("Cannot read the buffer: %s", err)
+	}
+	defer input.Free()
+	if !bytes.Equal(input.Bytes(), buf) {
+		t.Fatal("Invalid buffer bytes")
+	}
+
+	output, err := ResizeBuffer(input.Bytes(), Options{Width: 300, Type: PNG})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	defer output.Free()
+
+	if DetermineImageType(output.Bytes()) != PNG {
+		t.Fatal("Image is not png")
+	}
+	if size, _ := Size(output.Bytes()); size.Width != 300 {
+		t.Errorf("Invalid image width: %d", size.Width)
+	}
+}
+
+func TestResizeBufferCopied(t *testing.T) {
+	// Lossless transforms are not encoded by libvips
+	output, err := ResizeBuffer(readFile("test.jpg"), Options{Rotate: D180, Type: JPEG, LosslessJPEG: true})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	defer output.Free()
+	if DetermineImageType(output.Bytes()) != JPEG || output.Len() == 0 {
+		t.Fatal("Invalid output buffer")
+	}
+}
+
+func TestBufferFree(t *testing.T) {
+	if _, err := NewBuffer(0); err == nil {
+		t.Error("Expected an error for an empty buffer")
+	}
+	b, err := NewBuffer(16)
+	if err != nil {
+		t.Fatalf("Cannot allocate the buffer: %s", err)
+	}
+	b.Free()
+	b.Free()
+	if b.Bytes() != nil || b.Len() != 0 {
+		t.Error("Freed buffer must be empty")
+	}
+	if _, err := ReadBuffer(bytes.NewReader([]byte("short")), 16); err == nil {
+		t.Error("Expected an error for a short read")
+	}
+}
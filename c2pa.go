@@ -0,0 +1,444 @@
+package bimg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// C2PAAsset describes an encoded image to sign with a C2PA manifest store.
+type C2PAAsset struct {
+	// Buffer is the encoded image, without manifest store.
+	Buffer []byte
+	Type   ImageType
+	// Offset is the position the manifest store is inserted at, along
+	// with its container overhead, see C2PAEmbeddedSize. The data hash
+	// assertion must exclude this range, and for WebP images the RIFF
+	// size at bytes 4 to 8 too.
+	Offset int
+	// Ingredient is the manifest store of the source image, if any.
+	Ingredient []byte
+}
+
+// C2PASigner returns the signed C2PA manifest store of the encoded image,
+// a JUMBF superbox, e.g. built with a C2PA SDK and a signing service.
+type C2PASigner func(asset C2PAAsset) ([]byte, error)
+
+// C2PAOptions represents the C2PA content credentials of a saved image.
+// They are supported by JPEG, PNG and WebP images.
+type C2PAOptions struct {
+	// Keep copies the manifest store of the source image to the output.
+	// Its hard binding no longer matches the edited image, which is
+	// usually signed again with the source manifest as ingredient.
+	Keep bool
+	// Manifest defines a manifest store to attach as is.
+	Manifest []byte
+	// Sign builds the manifest store to attach. It overrides Manifest and
+	// Keep.
+	Sign C2PASigner
+}
+
+func (o C2PAOptions) isZero() bool {
+	return !o.Keep && o.Manifest == nil && o.Sign == nil
+}
+
+const (
+	// jpegXTHeader is the size of the APP11 marker, length, common
+	// identifier, box instance and sequence number of a JPEG XT segment.
+	jpegXTHeader = 12
+	// jpegXTMaxData is the maximum box data of a JPEG XT segment.
+	jpegXTMaxData = 0xFFFF - jpegXTHeader + 2
+)
+
+// ReadC2PA returns the C2PA manifest store of a JPEG, PNG or WebP image
+// buffer, or nil when it has none.
+func ReadC2PA(buf []byte) ([]byte, error) {
+	switch DetermineImageType(buf) {
+	case JPEG:
+		return jpegC2PA(buf), nil
+	case PNG:
+		chunks, err := readPNGChunks(buf)
+		if err != nil {
+			return nil, err
+		}
+		for _, chunk := range chunks {
+			if chunk.kind == "caBX" {
+				return chunk.data, nil
+			}
+		}
+		return nil, nil
+	case WEBP:
+		var manifest []byte
+		err := walkWebPChunks(buf, func(kind string, data []byte) {
+			if kind == "C2PA" && manifest == nil {
+				manifest = data
+			}
+		})
+		return manifest, err
+	}
+	return nil, wrapError(ErrUnsupportedFormat, "C2PA manifests are supported by JPEG, PNG and WebP images only")
+}
+
+// EmbedC2PA attaches the C2PA manifest store to a JPEG, PNG or WebP image
+// buffer, replacing the existing one.
+func EmbedC2PA(buf, manifest []byte) ([]byte, error) {
+	if !isC2PAStore(manifest) {
+		return nil, ErrInvalidC2PAManifest
+	}
+	imageType := DetermineImageType(buf)
+	asset, offset, err := stripC2PA(buf, imageType)
+	if err != nil {
+		return nil, err
+	}
+	return insertC2PA(asset, imageType, offset, manifest), nil
+}
+
+// C2PAEmbeddedSize returns the number of bytes a manifest store of the
+// given size adds to an image of the given type.
+func C2PAEmbeddedSize(imageType ImageType, size int) int {
+	switch imageType {
+	case JPEG:
+		// The first segment holds the box header, then the continuation
+		// segments repeat it
+		segments := 1
+		if rest := size - jpegXTMaxData; rest > 0 {
+			chunk := jpegXTMaxData - 8
+			segments += (rest + chunk - 1) / chunk
+		}
+		return size + segments*jpegXTHeader + (segments-1)*8
+	case PNG:
+		return size + 12
+	case WEBP:
+		return size + 8 + size&1
+	}
+	return 0
+}
+
+// applyC2PA attaches the manifest store defined by the options to the
+// encoded image.
+func applyC2PA(out, source []byte, o SaveOptions) ([]byte, error) {
+	if o.C2PA.isZero() {
+		return out, nil
+	}
+
+	var ingredient []byte
+	if o.C2PA.Keep || o.C2PA.Sign != nil {
+		// Sources without manifest support have no ingredient
+		ingredient, _ = ReadC2PA(source)
+	}
+
+	asset, offset, err := stripC2PA(out, o.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := o.C2PA.Manifest
+	if manifest == nil && o.C2PA.Keep {
+		manifest = ingredient
+	}
+	if o.C2PA.Sign != nil {
+		manifest, err = o.C2PA.Sign(C2PAAsset{Buffer: asset, Type: o.Type, Offset: offset, Ingredient: ingredient})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if manifest == nil {
+		return asset, nil
+	}
+	if !isC2PAStore(manifest) {
+		return nil, ErrInvalidC2PAManifest
+	}
+	return insertC2PA(asset, o.Type, offset, manifest), nil
+}
+
+// isC2PAStore reports whether the buffer is a JUMBF superbox described as
+// a C2PA manifest store.
+func isC2PAStore(buf []byte) bool {
+	return len(buf) >= 20 && string(buf[4:8]) == "jumb" && string(buf[12:16]) == "jumd" && string(buf[16:20]) == "c2pa"
+}
+
+// stripC2PA removes the manifest store of the image buffer and returns the
+// offset to insert a new one at. WebP images are converted to the extended
+// format, which supports metadata chunks.
+func stripC2PA(buf []byte, imageType ImageType) ([]byte, int, error) {
+	switch imageType {
+	case JPEG:
+		return stripJPEGC2PA(buf)
+	case PNG:
+		chunks, err := readPNGChunks(buf)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(chunks) == 0 || chunks[0].kind != "IHDR" {
+			return nil, 0, invalidHeader(PNG)
+		}
+		var out bytes.Buffer
+		out.Write(pngSignature)
+		for _, chunk := range chunks {
+			if chunk.kind != "caBX" {
+				writePNGChunk(&out, chunk.kind, chunk.data)
+			}
+		}
+		return out.Bytes(), len(pngSignature) + 12 + len(chunks[0].data), nil
+	case WEBP:
+		return stripWebPC2PA(buf)
+	}
+	return nil, 0, wrapError(ErrUnsupportedFormat, "C2PA manifests are not supported by "+ImageTypeName(imageType)+" images")
+}
+
+// insertC2PA inserts the manifest store at the offset of the stripped
+// image buffer.
+func insertC2PA(buf []byte, imageType ImageType, offset int, manifest []byte) []byte {
+	var box bytes.Buffer
+	switch imageType {
+	case JPEG:
+		writeJPEGXT(&box, manifest, jpegFreeBoxInstance(buf))
+	case PNG:
+		writePNGChunk(&box, "caBX", manifest)
+	case WEBP:
+		writeWebPChunk(&box, "C2PA", manifest)
+	}
+
+	out := make([]byte, 0, len(buf)+box.Len())
+	out = append(out, buf[:offset]...)
+	out = append(out, box.Bytes()...)
+	out = append(out, buf[offset:]...)
+	if imageType == WEBP {
+		binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	}
+	return out
+}
+
+// jpegSegment represents a JPEG marker segment before the image scan.
+type jpegSegment struct {
+	marker     byte
+	start, end int
+	data       []byte
+}
+
+// readJPEGSegments returns the marker segments up to the start of scan.
+func readJPEGSegments(buf []byte) []jpegSegment {
+	var segments []jpegSegment
+	for i := 2; i+4 <= len(buf) && buf[i] == 0xFF; {
+		marker := buf[i+1]
+		size := int(binary.BigEndian.Uint16(buf[i+2:]))
+		if marker == 0xDA || size < 2 || i+2+size > len(buf) {
+			break
+		}
+		segments = append(segments, jpegSegment{marker: marker, start: i, end: i + 2 + size, data: buf[i+4 : i+2+size]})
+		i += 2 + size
+	}
+	return segments
+}
+
+// jpegC2PABoxes returns the JPEG XT box instances holding a C2PA manifest
+// store, the first one first.
+func jpegC2PABoxes(segments []jpegSegment) []uint16 {
+	var boxes []uint16
+	for _, s := range segments {
+		if isJPEGXT(s) && binary.BigEndian.Uint32(s.data[4:]) == 1 && isC2PAStore(s.data[8:]) {
+			boxes = append(boxes, binary.BigEndian.Uint16(s.data[2:]))
+		}
+	}
+	return boxes
+}
+
+// isJPEGXT reports whether the segment is a JPEG XT box segment.
+func isJPEGXT(s jpegSegment) bool {
+	return s.marker == 0xEB && len(s.data) >= 8 && string(s.data[:2]) == "JP"
+}
+
+// jpegC2PA joins the JPEG XT segments of the first C2PA manifest store.
+func jpegC2PA(buf []byte) []byte {
+	segments := readJPEGSegments(buf)
+	boxes := jpegC2PABoxes(segments)
+	if len(boxes) == 0 {
+		return nil
+	}
+	var manifest []byte
+	for _, s := range segments {
+		if !isJPEGXT(s) || binary.BigEndian.Uint16(s.data[2:]) != boxes[0] {
+			continue
+		}
+		if manifest == nil {
+			manifest = append(manifest, s.data[8:]...)
+		} else if len(s.data) > 16 {
+			// Skip the repeated box header
+			manifest = append(manifest, s.data[16:]...)
+		}
+	}
+	return manifest
+}
+
+// stripJPEGC2PA removes the C2PA JPEG XT segments. Manifest stores are
+// inserted after the JFIF and EXIF segments.
+func stripJPEGC2PA(buf []byte) ([]byte, int, error) {
+	if len(buf) < 4 || buf[0] != 0xFF || buf[1] != 0xD8 {
+		return nil, 0, invalidHeader(JPEG)
+	}
+	segments := readJPEGSegments(buf)
+	boxes := jpegC2PABoxes(segments)
+
+	out := make([]byte, 0, len(buf))
+	out = append(out, buf[:2]...)
+	offset, last := 2, 2
+	leading := true
+	for _, s := range segments {
+		if isJPEGXT(s) && containsBox(boxes, binary.BigEndian.Uint16(s.data[2:])) {
+			last = s.end
+			continue
+		}
+		out = append(out, buf[s.start:s.end]...)
+		last = s.end
+		if leading && (s.marker == 0xE0 || s.marker == 0xE1) {
+			offset = len(out)
+		} else {
+			leading = false
+		}
+	}
+	return append(out, buf[last:]...), offset, nil
+}
+
+func containsBox(boxes []uint16, box uint16) bool {
+	for _, b := range boxes {
+		if b == box {
+			return true
+		}
+	}
+	return false
+}
+
+// jpegFreeBoxInstance returns a JPEG XT box instance number not used by
+// the image.
+func jpegFreeBoxInstance(buf []byte) uint16 {
+	used := uint16(0)
+	for _, s := range readJPEGSegments(buf) {
+		if isJPEGXT(s) {
+			if box := binary.BigEndian.Uint16(s.data[2:]); box > used {
+				used = box
+			}
+		}
+	}
+	return used + 1
+}
+
+// writeJPEGXT splits the JUMBF box in APP11 JPEG XT segments.
+func writeJPEGXT(w *bytes.Buffer, box []byte, instance uint16) {
+	data := box
+	for sequence := uint32(1); len(data) > 0; sequence++ {
+		header := []byte(nil)
+		size := jpegXTMaxData
+		if sequence > 1 {
+			header = box[:8]
+			size -= 8
+		}
+		if size > len(data) {
+			size = len(data)
+		}
+
+		var segment [jpegXTHeader]byte
+		segment[0], segment[1] = 0xFF, 0xEB
+		binary.BigEndian.PutUint16(segment[2:], uint16(jpegXTHeader-2+len(header)+size))
+		copy(segment[4:], "JP")
+		binary.BigEndian.PutUint16(segment[6:], instance)
+		binary.BigEndian.PutUint32(segment[8:], sequence)
+		w.Write(segment[:])
+		w.Write(header)
+		w.Write(data[:size])
+		data = data[size:]
+	}
+}
+
+// walkWebPChunks calls fn with every chunk of a WebP image buffer.
+func walkWebPChunks(buf []byte, fn func(kind string, data []byte)) error {
+	if len(buf) < 20 || string(buf[0:4]) != "RIFF" || string(buf[8:12]) != "WEBP" {
+		return invalidHeader(WEBP)
+	}
+	for i := 12; i+8 <= len(buf); {
+		size := int(binary.LittleEndian.Uint32(buf[i+4:]))
+		if size < 0 || i+8+size > len(buf) {
+			return wrapError(ErrTruncatedImage, "Truncated WebP chunk")
+		}
+		fn(string(buf[i:i+4]), buf[i+8:i+8+size])
+		// Chunks are padded to an even size
+		i += 8 + size + size&1
+	}
+	return nil
+}
+
+// writeWebPChunk appends a chunk, padded to an even size.
+func writeWebPChunk(w *bytes.Buffer, kind string, data []byte) {
+	var header [8]byte
+	copy(header[:], kind)
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(data)))
+	w.Write(header[:])
+	w.Write(data)
+	if len(data)&1 == 1 {
+		w.WriteByte(0)
+	}
+}
+
+// stripWebPC2PA removes the C2PA chunks, adding a VP8X chunk to simple
+// images. Manifest stores are inserted after the VP8X chunk.
+func stripWebPC2PA(buf []byte) ([]byte, int, error) {
+	var out bytes.Buffer
+	out.Write(buf[:12])
+	extended := false
+	var width, height int
+	var alpha bool
+	err := walkWebPChunks(buf, func(kind string, data []byte) {
+		switch kind {
+		case "VP8X":
+			extended = true
+		case "VP8 ":
+			if len(data) >= 10 {
+				width = int(binary.LittleEndian.Uint16(data[6:]) & 0x3fff)
+				height = int(binary.LittleEndian.Uint16(data[8:]) & 0x3fff)
+			}
+		case "VP8L":
+			if len(data) >= 5 {
+				bits := binary.LittleEndian.Uint32(data[1:])
+				width = int(bits&0x3fff) + 1
+				height = int(bits>>14&0x3fff) + 1
+				alpha = bits>>28&1 == 1
+			}
+		case "C2PA":
+			return
+		}
+		writeWebPChunk(&out, kind, data)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if !extended {
+		if width == 0 || height == 0 {
+			return nil, 0, invalidHeader(WEBP)
+		}
+		var vp8x [10]byte
+		if alpha {
+			vp8x[0] = 0x10
+		}
+		putUint24(vp8x[4:], uint32(width-1))
+		putUint24(vp8x[7:], uint32(height-1))
+
+		chunks := out.Bytes()[12:]
+		var simple bytes.Buffer
+		simple.Write(buf[:12])
+		writeWebPChunk(&simple, "VP8X", vp8x[:])
+		simple.Write(chunks)
+		out = simple
+	}
+
+	webp := out.Bytes()
+	binary.LittleEndian.PutUint32(webp[4:], uint32(len(webp)-8))
+	if string(webp[12:16]) != "VP8X" {
+		return nil, 0, fmt.Errorf("Invalid WebP chunk order: %s", webp[12:16])
+	}
+	return webp, 12 + 8 + int(binary.LittleEndian.Uint32(webp[16:])), nil
+}
+
+// putUint24 writes a little endian 24-bit integer.
+func putUint24(b []byte, v uint32) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
@@ -0,0 +1,173 @@
+package bimg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// testC2PAStore returns a fake C2PA manifest store of the given size.
+func testC2PAStore(size int) []byte {
+	store := make([]byte, size)
+	binary.BigEndian.PutUint32(store, uint32(size))
+	copy(store[4:], "jumb")
+	binary.BigEndian.PutUint32(store[8:], 25)
+	copy(store[12:], "jumdc2pa")
+	for i := 20; i < size; i++ {
+		store[i] = byte(i)
+	}
+	return store
+}
+
+func testC2PAImages(t *testing.T) map[ImageType][]byte {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	var jpg, pngBuf bytes.Buffer
+	if err := jpeg.Encode(&jpg, img, nil); err != nil {
+		t.Fatalf("Cannot encode the image: %s", err)
+	}
+	if err := png.Encode(&pngBuf, img); err != nil {
+		t.Fatalf("Cannot encode the image: %s", err)
+	}
+
+	// Simple lossless WebP header of a 16x8 image with alpha
+	var webp bytes.Buffer
+	vp8l := []byte{0x2f, 0, 0, 0, 0, 1, 2, 3}
+	binary.LittleEndian.PutUint32(vp8l[1:], 15|7<<14|1<<28)
+	webp.WriteString("RIFF\x00\x00\x00\x00WEBP")
+	writeWebPChunk(&webp, "VP8L", vp8l)
+	buf := webp.Bytes()
+	binary.LittleEndian.PutUint32(buf[4:], uint32(len(buf)-8))
+
+	return map[ImageType][]byte{JPEG: jpg.Bytes(), PNG: pngBuf.Bytes(), WEBP: buf}
+}
+
+func TestEmbedC2PA(t *testing.T) {
+	for imageType, buf := range testC2PAImages(t) {
+		for _, size := range []int{101, 150000} {
+			store := testC2PAStore(size)
+			out, err := EmbedC2PA(buf, store)
+			if err != nil {
+				t.Fatalf("Cannot embed the %s manifest: %s", ImageTypeName(imageType), err)
+			}
+			// Embedding twice replaces the manifest
+			out, err = EmbedC2PA(out, store)
+			if err != nil {
+				t.Fatalf("Cannot embed the %s manifest: %s", ImageTypeName(imageType), err)
+			}
+
+			manifest, err := ReadC2PA(out)
+			if err != nil || !bytes.Equal(manifest, store) {
+				t.Errorf("Invalid %s manifest of %d bytes: %v", ImageTypeName(imageType), size, err)
+			}
+			// The manifest is inserted at the offset of the stripped image
+			asset, offset, err := stripC2PA(out, imageType)
+			if err != nil {
+				t.Fatalf("Cannot strip the %s manifest: %s", ImageTypeName(imageType), err)
+			}
+			embedded := C2PAEmbeddedSize(imageType, size)
+			start := 0
+			if imageType == WEBP {
+				// Skip the RIFF size
+				start = 8
+			}
+			if len(out) != len(asset)+embedded || !bytes.Equal(out[start:offset], asset[start:offset]) ||
+				!bytes.Equal(out[offset+embedded:], asset[offset:]) {
+				t.Errorf("Invalid %s manifest position", ImageTypeName(imageType))
+			}
+		}
+	}
+}
+
+func TestEmbedC2PADecode(t *testing.T) {
+	images := testC2PAImages(t)
+	store := testC2PAStore(100)
+
+	out, _ := EmbedC2PA(images[JPEG], store)
+	if _, err := jpeg.Decode(bytes.NewReader(out)); err != nil {
+		t.Errorf("Cannot decode the JPEG image: %s", err)
+	}
+	out, _ = EmbedC2PA(images[PNG], store)
+	if _, err := png.Decode(bytes.NewReader(out)); err != nil {
+		t.Errorf("Cannot decode the PNG image: %s", err)
+	}
+
+	// Simple WebP images are converted to the extended format
+	out, _ = EmbedC2PA(images[WEBP], store)
+	if string(out[12:16]) != "VP8X" || out[20] != 0x10 || out[24] != 15 || out[27] != 7 {
+		t.Errorf("Invalid VP8X chunk: %v", out[12:30])
+	}
+	if string(out[30:34]) != "C2PA" || int(binary.LittleEndian.Uint32(out[4:])) != len(out)-8 {
+		t.Error("Invalid WebP C2PA chunk")
+	}
+}
+
+func TestC2PAErrors(t *testing.T) {
+	images := testC2PAImages(t)
+	if _, err := EmbedC2PA(images[JPEG], []byte("not a manifest store")); !errors.Is(err, ErrInvalidC2PAManifest) {
+		t.Errorf("Expected an invalid manifest store error: %v", err)
+	}
+	if _, err := EmbedC2PA(readFile("test.gif"), testC2PAStore(100)); err == nil {
+		t.Error("Expected an error for an unsupported format")
+	}
+	if manifest, err := ReadC2PA(images[PNG]); manifest != nil || err != nil {
+		t.Errorf("Expected no manifest: %v", err)
+	}
+}
+
+func TestSaveAllC2PA(t *testing.T) {
+	source, _ := EmbedC2PA(readFile("test.jpg"), testC2PAStore(300))
+	signed := testC2PAStore(200)
+
+	var asset C2PAAsset
+	sign := func(a C2PAAsset) ([]byte, error) {
+		asset = a
+		return signed, nil
+	}
+	bufs, err := saveAll(source, Region{}, []SaveOptions{{C2PA: C2PAOptions{Keep: true}}, {Type: PNG, C2PA: C2PAOptions{Sign: sign}}})
+	if err != nil {
+		t.Fatalf("Cannot save the image: %#v", err)
+	}
+
+	if manifest, _ := ReadC2PA(bufs[0]); !bytes.Equal(manifest, testC2PAStore(300)) {
+		t.Error("The source manifest was not kept")
+	}
+	if manifest, _ := ReadC2PA(bufs[1]); !bytes.Equal(manifest, signed) {
+		t.Error("The signed manifest was not attached")
+	}
+	if asset.Type != PNG || asset.Offset != 33 || !bytes.Equal(asset.Ingredient, testC2PAStore(300)) {
+		t.Errorf("Invalid signed asset: %s at %d", ImageTypeName(asset.Type), asset.Offset)
+	}
+	if !bytes.Equal(bufs[1][:asset.Offset], asset.Buffer[:asset.Offset]) {
+		t.Error("Invalid signed asset buffer")
+	}
+}
+
+func TestResizeC2PA(t *testing.T) {
+	source, _ := EmbedC2PA(readFile("test.jpg"), testC2PAStore(300))
+
+	buf, err := Resize(source, Options{Width: 300, C2PA: C2PAOptions{Keep: true}})
+	if err != nil {
+		t.Fatalf("Cannot resize the image: %#v", err)
+	}
+	if manifest, _ := ReadC2PA(buf); !bytes.Equal(manifest, testC2PAStore(300)) {
+		t.Error("The source manifest was not kept")
+	}
+
+	buf, err = NewImage(source).Process(Options{Width: 300, Type: PNG, C2PA: C2PAOptions{Manifest: testC2PAStore(200)}})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if manifest, _ := ReadC2PA(buf); !bytes.Equal(manifest, testC2PAStore(200)) {
+		t.Error("The manifest was not attached")
+	}
+
+	// Without options, the source manifest is dropped
+	buf, _ = Resize(source, Options{Width: 300})
+	if manifest, _ := ReadC2PA(buf); manifest != nil {
+		t.Error("Unexpected source manifest")
+	}
+}
@@ -0,0 +1,203 @@
+package bimg
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Cache stores processed images by key, see CacheKey. Implementations
+// must be safe for concurrent use and must not retain the passed buffers,
+// nor return buffers shared with other callers.
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, buf []byte)
+}
+
+var (
+	cache      Cache
+	cacheMutex sync.RWMutex
+)
+
+// SetCache sets the package-level result cache used by Resize and
+// Image.Process. A nil cache disables caching, the default.
+func SetCache(c Cache) {
+	cacheMutex.Lock()
+	cache = c
+	cacheMutex.Unlock()
+}
+
+// GetCache returns the package-level result cache.
+func GetCache() Cache {
+	cacheMutex.RLock()
+	defer cacheMutex.RUnlock()
+	return cache
+}
+
+// cacheRecipe represents the options of a cache key, including the
+// private ones set by the Image methods.
+type cacheRecipe struct {
+	Options
+	AutoRotateOnly   bool            `json:"autoRotateOnly"`
+	Orientation      ExifOrientation `json:"orientation"`
+	CanvasWidth      int             `json:"canvasWidth"`
+	CanvasHeight     int             `json:"canvasHeight"`
+	CanvasGravity    Gravity         `json:"canvasGravity"`
+	CanvasBackground RGBAProvider    `json:"canvasBackground"`
+}
+
+// CacheKey returns the cache key of the given input image and options:
+// the SHA-256 of the input followed by the SHA-256 of the recipe. It fails
+// when the options cannot be encoded, e.g. with NaN values or a custom
+// RGBAProvider, in which case the image must not be cached.
+func CacheKey(buf []byte, o Options) (string, error) {
+	recipe, err := json.Marshal(cacheRecipe{
+		Options:          o,
+		AutoRotateOnly:   o.autoRotateOnly,
+		Orientation:      o.orientation,
+		CanvasWidth:      o.canvas.width,
+		CanvasHeight:     o.canvas.height,
+		CanvasGravity:    o.canvas.gravity,
+		CanvasBackground: o.canvas.background,
+	})
+	if err != nil {
+		return "", err
+	}
+	input := sha256.Sum256(buf)
+	options := sha256.Sum256(recipe)
+	return hex.EncodeToString(input[:]) + "-" + hex.EncodeToString(options[:]), nil
+}
+
+// cachedResize processes the image through the given cache. Images whose
+// options cannot be keyed are not cached.
+func cachedResize(c Cache, buf []byte, o Options) ([]byte, error) {
+	key, err := CacheKey(buf, o)
+	if err != nil {
+		return resizer(buf, o)
+	}
+	if out, ok := c.Get(key); ok {
+		return out, nil
+	}
+	out, err := resizer(buf, o)
+	if err == nil {
+		c.Set(key, out)
+	}
+	return out, err
+}
+
+// LRUCache is an in-memory Cache evicting the least recently used images
+// beyond its size.
+type LRUCache struct {
+	maxBytes int
+	size     int
+	mutex    sync.Mutex
+	entries  *list.List
+	keys     map[string]*list.Element
+}
+
+type lruEntry struct {
+	key string
+	buf []byte
+}
+
+// NewLRUCache creates an in-memory cache holding up to maxBytes of images.
+func NewLRUCache(maxBytes int) *LRUCache {
+	return &LRUCache{
+		maxBytes: maxBytes,
+		entries:  list.New(),
+		keys:     make(map[string]*list.Element),
+	}
+}
+
+// Get returns a copy of the cached image.
+func (c *LRUCache) Get(key string) ([]byte, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	e, ok := c.keys[key]
+	if !ok {
+		return nil, false
+	}
+	c.entries.MoveToFront(e)
+	return copyBuffer(e.Value.(*lruEntry).buf), true
+}
+
+// Set stores a copy of the image, unless it exceeds the cache size.
+func (c *LRUCache) Set(key string, buf []byte) {
+	if len(buf) > c.maxBytes {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if e, ok := c.keys[key]; ok {
+		c.size -= len(e.Value.(*lruEntry).buf)
+		c.entries.Remove(e)
+	}
+	c.keys[key] = c.entries.PushFront(&lruEntry{key: key, buf: copyBuffer(buf)})
+	c.size += len(buf)
+	for c.size > c.maxBytes {
+		e := c.entries.Back()
+		entry := e.Value.(*lruEntry)
+		c.entries.Remove(e)
+		delete(c.keys, entry.key)
+		c.size -= len(entry.buf)
+	}
+}
+
+// Len returns the number of cached images.
+func (c *LRUCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.entries.Len()
+}
+
+// DiskCache is a Cache storing the images as files in a directory.
+// Failed reads and writes are treated as cache misses.
+type DiskCache string
+
+func (d DiskCache) path(key string) string {
+	if len(key) < 2 {
+		return filepath.Join(string(d), key)
+	}
+	return filepath.Join(string(d), key[:2], key)
+}
+
+// Get reads the cached image file.
+func (d DiskCache) Get(key string) ([]byte, bool) {
+	buf, err := ioutil.ReadFile(d.path(key))
+	if err != nil {
+		return nil, false
+	}
+	return buf, true
+}
+
+// Set writes the image file atomically, so concurrent readers never see
+// partial files.
+func (d DiskCache) Set(key string, buf []byte) {
+	name := d.path(key)
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return
+	}
+	file, err := ioutil.TempFile(filepath.Dir(name), ".tmp-")
+	if err != nil {
+		return
+	}
+	_, err = file.Write(buf)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), name)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+}
+
+func copyBuffer(buf []byte) []byte {
+	return append([]byte(nil), buf...)
+}
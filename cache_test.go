@@ -0,0 +1,122 @@
+package bimg
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+)
+
+type countingCache struct {
+	Cache
+	hits int
+}
+
+func (c *countingCache) Get(key string) ([]byte, bool) {
+	buf, ok := c.Cache.Get(key)
+	if ok {
+		c.hits++
+	}
+	return buf, ok
+}
+
+func TestCacheKey(t *testing.T) {
+	cacheKey := func(buf []byte, o Options) string {
+		key, err := CacheKey(buf, o)
+		if err != nil {
+			t.Fatalf("Cannot get the cache key: %s", err)
+		}
+		return key
+	}
+
+	buf := []byte("image")
+	key := cacheKey(buf, Options{Width: 100})
+	if key != cacheKey(buf, Options{Width: 100}) {
+		t.Error("Cache keys should be stable")
+	}
+	if key == cacheKey(buf, Options{Width: 200}) {
+		t.Error("Cache keys should depend on the options")
+	}
+	if key == cacheKey([]byte("other"), Options{Width: 100}) {
+		t.Error("Cache keys should depend on the input")
+	}
+
+	// Private options set by the Image methods
+	empty := cacheKey(buf, Options{})
+	for _, o := range []Options{
+		{autoRotateOnly: true},
+		{orientation: OrientationRightTop},
+		{canvas: canvas{width: 100, height: 100}},
+	} {
+		if cacheKey(buf, o) == empty {
+			t.Errorf("Cache keys should depend on the private options: %#v", o)
+		}
+	}
+
+	if _, err := CacheKey(buf, Options{Gamma: math.NaN()}); err == nil {
+		t.Error("Options with NaN values should have no cache key")
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	c := NewLRUCache(10)
+	c.Set("a", []byte("1234"))
+	c.Set("b", []byte("1234"))
+	c.Get("a")
+	c.Set("c", []byte("1234"))
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("The least recently used image should be evicted")
+	}
+	buf, ok := c.Get("a")
+	if !ok || string(buf) != "1234" {
+		t.Errorf("Invalid cached image: %q", buf)
+	}
+	buf[0] = 'x'
+	if buf, _ := c.Get("a"); string(buf) != "1234" {
+		t.Error("Cached images should not be shared")
+	}
+
+	c.Set("d", make([]byte, 11))
+	if _, ok := c.Get("d"); ok || c.Len() != 2 {
+		t.Errorf("Images larger than the cache should not be stored: %d", c.Len())
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bimg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := DiskCache(dir)
+	if _, ok := c.Get("abcdef"); ok {
+		t.Error("Empty cache should miss")
+	}
+	c.Set("abcdef", []byte("image"))
+	if buf, ok := c.Get("abcdef"); !ok || string(buf) != "image" {
+		t.Errorf("Invalid cached image: %q", buf)
+	}
+}
+
+func TestResizeCache(t *testing.T) {
+	c := &countingCache{Cache: NewLRUCache(10 << 20)}
+	SetCache(c)
+	defer SetCache(nil)
+
+	buf, _ := Read("testdata/test.jpg")
+	o := Options{Width: 300, Type: PNG}
+	first, err := Resize(buf, o)
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	second, err := NewImage(buf).Process(o)
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if c.hits != 1 || !bytes.Equal(first, second) {
+		t.Errorf("The second transform should be cached: %d hits", c.hits)
+	}
+}
@@ -0,0 +1,280 @@
+// Command bimg processes images from the command line, so the bimg
+// capabilities are usable from shell scripts and CI.
+//
+// Usage:
+//
+//	bimg resize -w 800 -h 600 input.jpg output.jpg
+//	bimg crop -w 300 -h 300 -gravity smart input.jpg output.jpg
+//	bimg convert -type webp -q 80 input.jpg output.webp
+//	bimg watermark -text "(c) 2021" input.jpg output.jpg
+//	bimg metadata input.jpg
+//	bimg batch -recipe recipe.json -pattern "*.jpg" -out dist/ src/
+//
+// Recipe files are JSON encoded bimg.Options, which flags override.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/h2non/bimg"
+)
+
+const usage = `Usage: bimg <command> [flags] <input> [<output>]
+
+Commands:
+  resize     Resize the image
+  crop       Resize and crop the image to the given size
+  convert    Convert the image to another format
+  watermark  Add a text watermark
+  metadata   Print the image metadata as JSON
+  batch      Convert a directory tree
+
+Run "bimg <command> -help" for the command flags.
+`
+
+var gravities = map[string]bimg.Gravity{
+	"centre": bimg.GravityCentre,
+	"center": bimg.GravityCentre,
+	"north":  bimg.GravityNorth,
+	"east":   bimg.GravityEast,
+	"south":  bimg.GravitySouth,
+	"west":   bimg.GravityWest,
+	"smart":  bimg.GravitySmart,
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintln(os.Stderr, "bimg:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return flag.ErrHelp
+	}
+
+	command, args := args[0], args[1:]
+	switch command {
+	case "resize", "crop", "convert", "watermark":
+		return transform(command, args)
+	case "metadata":
+		return metadata(args, stdout)
+	case "batch":
+		return batch(args, stdout)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q", command)
+}
+
+// optionFlags defines the flags shared by the transform commands.
+type optionFlags struct {
+	recipe  string
+	width   int
+	height  int
+	quality int
+	format  string
+	gravity string
+	embed   bool
+	force   bool
+	strip   bool
+	text    string
+	opacity float64
+	font    string
+}
+
+func (f *optionFlags) register(set *flag.FlagSet) {
+	set.StringVar(&f.recipe, "recipe", "", "JSON recipe file with the bimg options")
+	set.IntVar(&f.width, "w", 0, "output width")
+	set.IntVar(&f.height, "h", 0, "output height")
+	set.IntVar(&f.quality, "q", 0, "output quality")
+	set.StringVar(&f.format, "type", "", "output type: jpeg, png, webp, tiff, gif, heif or avif")
+	set.StringVar(&f.gravity, "gravity", "", "crop gravity: centre, north, east, south, west or smart")
+	set.BoolVar(&f.embed, "embed", false, "embed the image in the output size")
+	set.BoolVar(&f.force, "force", false, "resize without keeping the aspect ratio")
+	set.BoolVar(&f.strip, "strip", false, "strip the image metadata")
+	set.StringVar(&f.text, "text", "", "watermark text")
+	set.Float64Var(&f.opacity, "opacity", 0, "watermark opacity, from 0 to 1")
+	set.StringVar(&f.font, "font", "", "watermark font, e.g. \"sans bold 12\"")
+}
+
+// options builds the bimg options from the recipe file, overridden by the
+// flags set in the command line.
+func (f *optionFlags) options(set *flag.FlagSet) (bimg.Options, error) {
+	var o bimg.Options
+	if f.recipe != "" {
+		buf, err := ioutil.ReadFile(f.recipe)
+		if err != nil {
+			return o, err
+		}
+		if err := json.Unmarshal(buf, &o); err != nil {
+			return o, fmt.Errorf("invalid recipe %s: %s", f.recipe, err)
+		}
+	}
+
+	var err error
+	set.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "w":
+			o.Width = f.width
+		case "h":
+			o.Height = f.height
+		case "q":
+			o.Quality = f.quality
+		case "type":
+			o.Type = bimg.ParseImageType(f.format)
+			if o.Type == bimg.UNKNOWN {
+				err = fmt.Errorf("unknown image type %q", f.format)
+			}
+		case "gravity":
+			gravity, ok := gravities[strings.ToLower(f.gravity)]
+			if !ok {
+				err = fmt.Errorf("unknown gravity %q", f.gravity)
+			}
+			o.Gravity = gravity
+		case "embed":
+			o.Embed = f.embed
+		case "force":
+			o.Force = f.force
+		case "strip":
+			o.StripMetadata = f.strip
+		case "text":
+			o.Watermark.Text = f.text
+		case "opacity":
+			o.Watermark.Opacity = float32(f.opacity)
+		case "font":
+			o.Watermark.Font = f.font
+		}
+	})
+	return o, err
+}
+
+func transform(command string, args []string) error {
+	set := flag.NewFlagSet(command, flag.ContinueOnError)
+	var flags optionFlags
+	flags.register(set)
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+	if set.NArg() != 2 {
+		return fmt.Errorf("%s expects an input and an output file", command)
+	}
+
+	o, err := flags.options(set)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "crop":
+		o.Crop = true
+	case "convert":
+		if o.Type == bimg.UNKNOWN {
+			return errors.New("convert requires an output -type")
+		}
+	case "watermark":
+		if o.Watermark.Text == "" {
+			return errors.New("watermark requires a -text")
+		}
+	}
+
+	buf, err := bimg.Read(set.Arg(0))
+	if err != nil {
+		return err
+	}
+	out, err := bimg.NewImage(buf).Process(o)
+	if err != nil {
+		return err
+	}
+	return bimg.Write(set.Arg(1), out)
+}
+
+func metadata(args []string, stdout io.Writer) error {
+	set := flag.NewFlagSet("metadata", flag.ContinueOnError)
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+	if set.NArg() != 1 {
+		return errors.New("metadata expects an input file")
+	}
+
+	buf, err := bimg.Read(set.Arg(0))
+	if err != nil {
+		return err
+	}
+	meta, err := bimg.Metadata(buf)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(meta)
+}
+
+func batch(args []string, stdout io.Writer) error {
+	set := flag.NewFlagSet("batch", flag.ContinueOnError)
+	var flags optionFlags
+	flags.register(set)
+	out := set.String("out", "", "output directory")
+	pattern := set.String("pattern", "", "comma separated file name patterns, e.g. \"*.jpg,*.png\"")
+	concurrency := set.Int("concurrency", 0, "number of workers, defaults to the number of CPUs")
+	force := set.Bool("all", false, "convert up to date files too")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+	if set.NArg() != 1 || *out == "" {
+		return errors.New("batch expects a source directory and an -out directory")
+	}
+
+	o, err := flags.options(set)
+	if err != nil {
+		return err
+	}
+
+	opts := bimg.WalkOptions{
+		Concurrency: *concurrency,
+		Force:       *force,
+		Progress: func(done, total int, result bimg.BatchResult) {
+			status := "ok"
+			if result.Err != nil {
+				status = result.Err.Error()
+			}
+			fmt.Fprintf(stdout, "[%d/%d] %s: %s\n", done, total, result.Path, status)
+		},
+	}
+	if *pattern != "" {
+		opts.Patterns = strings.Split(*pattern, ",")
+	}
+
+	results, err := bimg.ConvertTree(set.Arg(0), *out, bimg.Recipe{Options: o}, opts)
+	if err != nil {
+		return err
+	}
+	if n := countFailures(results); n > 0 {
+		return fmt.Errorf("%d of %d files failed", n, len(results))
+	}
+	return nil
+}
+
+func countFailures(results []bimg.BatchResult) int {
+	n := 0
+	for _, result := range results {
+		if result.Err != nil {
+			n++
+		}
+	}
+	return n
+}
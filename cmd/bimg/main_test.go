@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/h2non/bimg"
+)
+
+func TestOptionFlags(t *testing.T) {
+	recipe, err := ioutil.TempFile("", "recipe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(recipe.Name())
+	recipe.WriteString(`{"Width": 100, "Height": 50, "Quality": 70}`)
+	recipe.Close()
+
+	set := flag.NewFlagSet("resize", flag.ContinueOnError)
+	var flags optionFlags
+	flags.register(set)
+	if err := set.Parse([]string{"-recipe", recipe.Name(), "-w", "200", "-type", "webp", "-gravity", "smart"}); err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := flags.options(set)
+	if err != nil {
+		t.Fatalf("Cannot build the options: %s", err)
+	}
+	if o.Width != 200 || o.Height != 50 || o.Quality != 70 || o.Type != bimg.WEBP || o.Gravity != bimg.GravitySmart {
+		t.Errorf("Invalid options: %#v", o)
+	}
+
+	set = flag.NewFlagSet("resize", flag.ContinueOnError)
+	flags.register(set)
+	set.Parse([]string{"-type", "bmp"})
+	if _, err := flags.options(set); err == nil {
+		t.Error("Expected error for an unknown type")
+	}
+}
+
+func TestRunTransform(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bimg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "out.png")
+	if err := run([]string{"convert", "-type", "png", "-w", "100", "../../testdata/test.jpg", output}, ioutil.Discard); err != nil {
+		t.Fatalf("Cannot convert the image: %s", err)
+	}
+	buf, _ := bimg.Read(output)
+	if bimg.DetermineImageType(buf) != bimg.PNG {
+		t.Error("Invalid output image type")
+	}
+
+	if err := run([]string{"convert", "../../testdata/test.jpg", output}, ioutil.Discard); err == nil {
+		t.Error("Expected error for a missing type")
+	}
+	if err := run([]string{"unknown"}, ioutil.Discard); err == nil {
+		t.Error("Expected error for an unknown command")
+	}
+}
+
+func TestRunMetadata(t *testing.T) {
+	var out bytes.Buffer
+	if err := run([]string{"metadata", "../../testdata/test.jpg"}, &out); err != nil {
+		t.Fatalf("Cannot read the metadata: %s", err)
+	}
+
+	var meta bimg.ImageMetadata
+	if err := json.Unmarshal(out.Bytes(), &meta); err != nil {
+		t.Fatalf("Invalid metadata output: %s", err)
+	}
+	if meta.Size.Width != 1680 || meta.Type != "jpeg" {
+		t.Errorf("Invalid metadata: %#v", meta)
+	}
+}
@@ -0,0 +1,62 @@
+package bimg
+
+import "math"
+
+// D65 reference white, as used by libvips.
+const (
+	whiteX = 95.047
+	whiteY = 100.0
+	whiteZ = 108.883
+)
+
+// Lab returns the CIE L*a*b* coordinates of the color, assuming sRGB
+// primaries and a D65 white point.
+func (c Color) Lab() (l, a, b float64) {
+	r, g, bl := linearize(c.R), linearize(c.G), linearize(c.B)
+
+	x := (0.4124*r + 0.3576*g + 0.1805*bl) * 100 / whiteX
+	y := (0.2126*r + 0.7152*g + 0.0722*bl) * 100 / whiteY
+	z := (0.0193*r + 0.1192*g + 0.9505*bl) * 100 / whiteZ
+
+	fx, fy, fz := labF(x), labF(y), labF(z)
+	return 116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)
+}
+
+// RGBAProvider is implemented by the colors usable as a background with an
+// alpha channel, such as Color, which is opaque, and RGBA.
+type RGBAProvider interface {
+	RGBA() RGBA
+}
+
+// RGBA returns the color as an opaque RGBA color.
+func (c Color) RGBA() RGBA {
+	return RGBA{R: c.R, G: c.G, B: c.B, A: 255}
+}
+
+// RGBA returns the color itself.
+func (c RGBA) RGBA() RGBA {
+	return c
+}
+
+// ColorDistance returns the CIE76 distance (delta E) between two colors.
+// Differences below 2.3 are barely noticeable by the human eye.
+func ColorDistance(c1, c2 Color) float64 {
+	l1, a1, b1 := c1.Lab()
+	l2, a2, b2 := c2.Lab()
+	return math.Sqrt((l1-l2)*(l1-l2) + (a1-a2)*(a1-a2) + (b1-b2)*(b1-b2))
+}
+
+func linearize(v uint8) float64 {
+	c := float64(v) / 255
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+func labF(t float64) float64 {
+	if t > 216.0/24389.0 {
+		return math.Cbrt(t)
+	}
+	return (24389.0/27.0*t + 16) / 116
+}
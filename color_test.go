@@ -0,0 +1,34 @@
+package bimg
+
+import (
+	"math"
+	"testing"
+)
+
+func TestColorLab(t *testing.T) {
+	tt := []struct {
+		color   Color
+		l, a, b float64
+	}{
+		{Color{0, 0, 0}, 0, 0, 0},
+		{Color{255, 255, 255}, 100, 0, 0},
+		{Color{255, 0, 0}, 53.24, 80.09, 67.20},
+		{Color{0, 0, 255}, 32.30, 79.19, -107.86},
+	}
+
+	for _, tc := range tt {
+		l, a, b := tc.color.Lab()
+		if math.Abs(l-tc.l) > 0.1 || math.Abs(a-tc.a) > 0.1 || math.Abs(b-tc.b) > 0.1 {
+			t.Errorf("Invalid Lab for %#v: %.2f %.2f %.2f", tc.color, l, a, b)
+		}
+	}
+}
+
+func TestColorDistance(t *testing.T) {
+	if d := ColorDistance(ColorBlack, Color{255, 255, 255}); math.Abs(d-100) > 0.1 {
+		t.Errorf("Invalid black to white distance: %f", d)
+	}
+	if d := ColorDistance(Color{10, 20, 30}, Color{10, 20, 30}); d != 0 {
+		t.Errorf("Invalid distance between equal colors: %f", d)
+	}
+}
@@ -0,0 +1,52 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import "math"
+
+// Similarity represents the result of comparing two images.
+type Similarity struct {
+	// SSIM is the structural similarity index of the luminance, from -1 to 1.
+	// Identical images score 1, values above 0.95 are hardly distinguishable.
+	SSIM float64
+	// PSNR is the peak signal-to-noise ratio in decibels. It is +Inf for
+	// identical images, values above 40 are usually considered lossless.
+	PSNR float64
+	// MSE is the mean squared error of the RGB channels, in 0-255 scale.
+	MSE float64
+}
+
+// Compare measures the similarity between two images. Both are compared as
+// sRGB with transparent areas as white. The second image is resized to the
+// dimensions of the first one if they differ.
+func Compare(a, b *Image) (Similarity, error) {
+	defer C.vips_thread_shutdown()
+
+	imageA, _, err := loadImage(a.buf())
+	if err != nil {
+		return Similarity{}, err
+	}
+	imageB, _, err := loadImage(b.buf())
+	if err != nil {
+		C.g_object_unref(C.gpointer(imageA))
+		return Similarity{}, err
+	}
+
+	mse, ssim, err := vipsCompare(imageA, imageB)
+	if err != nil {
+		return Similarity{}, err
+	}
+
+	return Similarity{SSIM: ssim, PSNR: psnr(mse), MSE: mse}, nil
+}
+
+func psnr(mse float64) float64 {
+	if mse == 0 {
+		return math.Inf(1)
+	}
+	return 10 * math.Log10(255*255/mse)
+}
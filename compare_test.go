@@ -0,0 +1,53 @@
+package bimg
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCompareIdentical(t *testing.T) {
+	img := initImage("test.jpg")
+
+	s, err := Compare(img, img)
+	if err != nil {
+		t.Fatalf("Cannot compare the images: %#v", err)
+	}
+	if s.MSE != 0 || !math.IsInf(s.PSNR, 1) || math.Abs(s.SSIM-1) > 1e-6 {
+		t.Errorf("Invalid similarity for identical images: %#v", s)
+	}
+}
+
+func TestCompareQuality(t *testing.T) {
+	img := initImage("test.jpg")
+
+	buf, err := NewImage(img.Image()).Process(Options{Quality: 10})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	low, err := Compare(img, NewImage(buf))
+	if err != nil {
+		t.Fatalf("Cannot compare the images: %#v", err)
+	}
+
+	buf, err = NewImage(img.Image()).Process(Options{Quality: 95})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	high, err := Compare(img, NewImage(buf))
+	if err != nil {
+		t.Fatalf("Cannot compare the images: %#v", err)
+	}
+
+	if low.SSIM >= high.SSIM || low.PSNR >= high.PSNR || low.MSE <= high.MSE {
+		t.Errorf("Lower quality must be less similar: %#v %#v", low, high)
+	}
+}
+
+func TestComparePSNR(t *testing.T) {
+	if got := psnr(255 * 255); got != 0 {
+		t.Errorf("Invalid PSNR: %f", got)
+	}
+	if got := psnr(0); !math.IsInf(got, 1) {
+		t.Errorf("Invalid PSNR: %f", got)
+	}
+}
@@ -0,0 +1,232 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+)
+
+const (
+	// DefaultWatermarkStrength is the strength of the invisible watermarks
+	// without one, which survives JPEG compression down to quality 75.
+	DefaultWatermarkStrength = 20.0
+
+	// dctHeaderBits is the size of the payload length header.
+	dctHeaderBits = 16
+	// dctHeaderRepeat is the number of blocks holding each header bit.
+	dctHeaderRepeat = 8
+	// dctMinRepeat is the minimum number of blocks holding each payload bit.
+	dctMinRepeat = 4
+)
+
+// dctBasis holds the two mid frequency DCT basis functions of the 8x8
+// blocks whose coefficients carry the watermark bits. They are quantized
+// alike by JPEG encoders.
+var dctBasis = func() (basis [2][64]float64) {
+	frequencies := [2][2]int{{3, 2}, {2, 3}}
+	for k, f := range frequencies {
+		for y := 0; y < 8; y++ {
+			for x := 0; x < 8; x++ {
+				basis[k][y*8+x] = 0.25 *
+					math.Cos(float64((2*x+1)*f[0])*math.Pi/16) *
+					math.Cos(float64((2*y+1)*f[1])*math.Pi/16)
+			}
+		}
+	}
+	return basis
+}()
+
+// embedWatermarkDCT hides the payload in the frequencies of the
+// auto-rotated image. Only the pixel changes are applied to the image, so
+// its depth and metadata are kept.
+func embedWatermarkDCT(img *Image, payload []byte, strength float64, o SaveOptions) ([]byte, error) {
+	defer C.vips_thread_shutdown()
+
+	if len(payload) == 0 {
+		return nil, errors.New("Watermark payload cannot be empty")
+	}
+	if strength < 0 || strength > 100 {
+		return nil, fmt.Errorf("Invalid watermark strength: %g", strength)
+	}
+	if strength == 0 {
+		strength = DefaultWatermarkStrength
+	}
+
+	image, imageType, err := loadOrientedImage(img)
+	if err != nil {
+		return nil, err
+	}
+	// vipsRGBA releases the image
+	C.g_object_ref(C.gpointer(image))
+	pixels, width, height, err := watermarkPixels(image)
+	if err != nil {
+		C.g_object_unref(C.gpointer(image))
+		return nil, err
+	}
+
+	original := append([]byte(nil), pixels...)
+	if err := dctEmbed(pixels, width, height, payload, strength); err != nil {
+		C.g_object_unref(C.gpointer(image))
+		return nil, err
+	}
+	delta := make([]float32, width*height*3)
+	for i := range delta {
+		p := i/3*4 + i%3
+		delta[i] = float32(int(pixels[p]) - int(original[p]))
+	}
+
+	image, err = vipsAddDelta(image, delta, width, height)
+	if err != nil {
+		return nil, err
+	}
+	defer C.g_object_unref(C.gpointer(image))
+	return encodeSaveOptions(image, imageType, img.buf(), o)
+}
+
+// extractWatermarkDCT reads the payload hidden by embedWatermarkDCT.
+func extractWatermarkDCT(img *Image) ([]byte, error) {
+	defer C.vips_thread_shutdown()
+
+	image, _, err := loadOrientedImage(img)
+	if err != nil {
+		return nil, err
+	}
+	pixels, width, height, err := watermarkPixels(image)
+	if err != nil {
+		return nil, err
+	}
+	return dctExtract(pixels, width, height)
+}
+
+// watermarkPixels returns the 8-bit RGBA pixels of the image at its size.
+// It releases the image.
+func watermarkPixels(image *C.VipsImage) ([]byte, int, int, error) {
+	size := int(image.Xsize)
+	if image.Ysize > image.Xsize {
+		size = int(image.Ysize)
+	}
+	return vipsRGBA(image, size)
+}
+
+// dctEmbed writes the payload length, the payload and its CRC-32 in the
+// luma of the 8x8 blocks of the RGBA pixels. Every bit orders the two
+// coefficients of several blocks, the header bits first.
+func dctEmbed(pixels []byte, width, height int, payload []byte, strength float64) error {
+	columns, blocks := width/8, (width/8)*(height/8)
+	bits := dctFrame(payload)
+	capacity := (blocks - dctHeaderBits*dctHeaderRepeat) / dctMinRepeat / 8
+	if len(payload) > 0xffff || len(bits) > capacity*8 {
+		return fmt.Errorf("Watermark payload too large for the image, %d bytes at most", max(capacity-4))
+	}
+
+	header := make([]bool, dctHeaderBits)
+	for i := range header {
+		header[i] = len(payload)>>uint(dctHeaderBits-1-i)&1 == 1
+	}
+
+	for block := 0; block < blocks; block++ {
+		var bit bool
+		if index := block - dctHeaderBits*dctHeaderRepeat; index < 0 {
+			bit = header[block%dctHeaderBits]
+		} else {
+			bit = bits[index%len(bits)]
+		}
+
+		sign := -1.0
+		if bit {
+			sign = 1
+		}
+		left, top := block%columns*8, block/columns*8
+		d := dctDifference(pixels, width, left, top)
+		if sign*d >= strength {
+			continue
+		}
+
+		delta := sign * (strength - sign*d) / 2
+		for y := 0; y < 8; y++ {
+			for x := 0; x < 8; x++ {
+				change := delta * (dctBasis[0][y*8+x] - dctBasis[1][y*8+x])
+				p := pixels[((top+y)*width+left+x)*4:]
+				for band := 0; band < 3; band++ {
+					p[band] = uint8(math.Max(0, math.Min(255, math.Round(float64(p[band])+change))))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// dctExtract reads the payload written by dctEmbed, summing the coefficient
+// differences of the blocks of every bit.
+func dctExtract(pixels []byte, width, height int) ([]byte, error) {
+	columns, blocks := width/8, (width/8)*(height/8)
+	payloadBlocks := blocks - dctHeaderBits*dctHeaderRepeat
+	if payloadBlocks < dctMinRepeat*8 {
+		return nil, errors.New("No watermark found")
+	}
+
+	header := make([]float64, dctHeaderBits)
+	for block := 0; block < dctHeaderBits*dctHeaderRepeat; block++ {
+		header[block%dctHeaderBits] += dctDifference(pixels, width, block%columns*8, block/columns*8)
+	}
+	length := 0
+	for _, sum := range header {
+		length = length<<1 | boolToInt(sum > 0)
+	}
+
+	sums := make([]float64, (length+4)*8)
+	if len(sums)*dctMinRepeat > payloadBlocks {
+		return nil, errors.New("No watermark found")
+	}
+	for index := 0; index < payloadBlocks; index++ {
+		block := index + dctHeaderBits*dctHeaderRepeat
+		sums[index%len(sums)] += dctDifference(pixels, width, block%columns*8, block/columns*8)
+	}
+
+	frame := make([]byte, length+4)
+	for i, sum := range sums {
+		if sum > 0 {
+			frame[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	payload, checksum := frame[:length], frame[length:]
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(checksum) {
+		return nil, errors.New("No watermark found")
+	}
+	return payload, nil
+}
+
+// dctFrame returns the bits of the payload followed by its CRC-32.
+func dctFrame(payload []byte) []bool {
+	frame := make([]byte, len(payload)+4)
+	copy(frame, payload)
+	binary.BigEndian.PutUint32(frame[len(payload):], crc32.ChecksumIEEE(payload))
+
+	bits := make([]bool, len(frame)*8)
+	for i := range bits {
+		bits[i] = frame[i/8]&(0x80>>uint(i%8)) != 0
+	}
+	return bits
+}
+
+// dctDifference returns the difference of the two watermark coefficients
+// of the luma of the block.
+func dctDifference(pixels []byte, width, left, top int) float64 {
+	d := 0.0
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			p := pixels[((top+y)*width+left+x)*4:]
+			luma := 0.299*float64(p[0]) + 0.587*float64(p[1]) + 0.114*float64(p[2])
+			d += luma * (dctBasis[0][y*8+x] - dctBasis[1][y*8+x])
+		}
+	}
+	return d
+}
@@ -0,0 +1,83 @@
+package bimg
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestImageEmbedWatermarkDCT(t *testing.T) {
+	payload := []byte("press-preview-0042")
+	buf, err := initImage("test.jpg").EmbedWatermarkDCT(payload, 0)
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if err := assertSize(buf, 1680, 1050); err != nil {
+		t.Fatal(err)
+	}
+	Write("testdata/test_watermark_dct_out.jpg", buf)
+
+	extracted, err := NewImage(buf).ExtractWatermarkDCT()
+	if err != nil {
+		t.Fatalf("Cannot extract the watermark: %#v", err)
+	}
+	if !bytes.Equal(extracted, payload) {
+		t.Errorf("Invalid watermark payload: %q", extracted)
+	}
+
+	if _, err := initImage("test.jpg").ExtractWatermarkDCT(); err == nil {
+		t.Error("Expected no watermark in the original image")
+	}
+}
+
+func TestImageEmbedWatermarkDCTReencoded(t *testing.T) {
+	payload := []byte("leak-7")
+	buf, err := initImage("test_icc_prophoto.jpg").EmbedWatermarkDCT(payload, 0, SaveOptions{Type: PNG})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if DetermineImageType(buf) != PNG {
+		t.Fatal("Image is not png")
+	}
+	metadata, err := Metadata(buf)
+	if err != nil {
+		t.Fatalf("Cannot read the metadata: %#v", err)
+	}
+	if !metadata.Profile {
+		t.Error("The ICC profile must be kept")
+	}
+
+	// The watermark survives the JPEG compression
+	jpeg, err := Resize(buf, Options{Type: JPEG, Quality: 80})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	extracted, err := NewImage(jpeg).ExtractWatermarkDCT()
+	if err != nil {
+		t.Fatalf("Cannot extract the watermark: %#v", err)
+	}
+	if !bytes.Equal(extracted, payload) {
+		t.Errorf("Invalid watermark payload: %q", extracted)
+	}
+
+	// But not the rescaling
+	size, _ := Size(buf)
+	scaled, err := Resize(jpeg, Options{Width: size.Width / 2})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if _, err := NewImage(scaled).ExtractWatermarkDCT(); err == nil {
+		t.Error("Expected no watermark in the rescaled image")
+	}
+}
+
+func TestDCTEmbedErrors(t *testing.T) {
+	if _, err := initImage("test.jpg").EmbedWatermarkDCT(nil, 0); err == nil {
+		t.Error("Expected an error for an empty payload")
+	}
+	if _, err := initImage("test.jpg").EmbedWatermarkDCT([]byte("a"), 120); err == nil {
+		t.Error("Expected an error for an invalid strength")
+	}
+	if err := dctEmbed(make([]byte, 64*64*4), 64, 64, []byte("a"), 20); err == nil {
+		t.Error("Expected an error for a too small image")
+	}
+}
@@ -0,0 +1,33 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+// saveDeepZoom builds a tile pyramid from the region of the given image
+// buffer, the whole image when empty.
+func saveDeepZoom(buf []byte, region Region, o DeepZoomOptions) ([]byte, error) {
+	defer C.vips_thread_shutdown()
+
+	image, _, err := loadImageOptions(buf, LoadOptions{Region: region})
+	if err != nil {
+		return nil, err
+	}
+	return vipsDeepZoom(image, deepZoomDefaults(o))
+}
+
+// deepZoomDefaults fills the unset tile pyramid options with their defaults.
+func deepZoomDefaults(o DeepZoomOptions) DeepZoomOptions {
+	if o.Suffix == "" {
+		o.Suffix = ".jpeg"
+	}
+	if o.TileSize == 0 {
+		o.TileSize = 256
+		if o.Layout == DeepZoomLayoutDZ {
+			o.TileSize = 254
+		}
+	}
+	return o
+}
@@ -0,0 +1,70 @@
+package bimg
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestSaveDeepZoomZip(t *testing.T) {
+	buf, err := saveDeepZoom(readFile("test.jpg"), Region{}, DeepZoomOptions{})
+	if err != nil {
+		t.Fatalf("Cannot save the tile pyramid: %#v", err)
+	}
+	if !bytes.HasPrefix(buf, []byte("PK\x03\x04")) {
+		t.Fatal("Tile pyramid is not a zip archive")
+	}
+}
+
+func TestSaveDeepZoomPath(t *testing.T) {
+	dir := path.Join("testdata", "test_dz_out")
+	defer os.RemoveAll(dir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	o := DeepZoomOptions{Layout: DeepZoomLayoutGoogle, Path: path.Join(dir, "image"), Suffix: ".png"}
+	buf, err := saveDeepZoom(readFile("test.jpg"), Region{}, o)
+	if err != nil {
+		t.Fatalf("Cannot save the tile pyramid: %#v", err)
+	}
+	if buf != nil {
+		t.Fatal("Tile pyramid must be written to disk")
+	}
+	if _, err := os.Stat(path.Join(dir, "image", "0", "0", "0.png")); err != nil {
+		t.Fatalf("Missing root tile: %s", err)
+	}
+}
+
+func TestDeepZoomDefaults(t *testing.T) {
+	o := deepZoomDefaults(DeepZoomOptions{})
+	if o.Suffix != ".jpeg" || o.TileSize != 254 {
+		t.Errorf("Invalid defaults: %#v", o)
+	}
+
+	o = deepZoomDefaults(DeepZoomOptions{Layout: DeepZoomLayoutIIIF, Suffix: ".webp"})
+	if o.Suffix != ".webp" || o.TileSize != 256 {
+		t.Errorf("Invalid defaults: %#v", o)
+	}
+}
+
+func TestSaveDeepZoomIIIF(t *testing.T) {
+	layouts := []struct {
+		layout DeepZoomLayout
+		minor  int
+	}{
+		{DeepZoomLayoutIIIF, 10},
+		{DeepZoomLayoutIIIF3, 11},
+	}
+	for _, l := range layouts {
+		_, err := saveDeepZoom(readFile("test.jpg"), Region{}, DeepZoomOptions{Layout: l.layout})
+		supported := VipsMajorVersion > 8 || (VipsMajorVersion == 8 && VipsMinorVersion >= l.minor)
+		if supported && err != nil {
+			t.Errorf("Cannot save the tile pyramid: %#v", err)
+		}
+		if !supported && err == nil {
+			t.Errorf("Expected an error for layout %d with libvips %s", l.layout, VipsVersion)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import "fmt"
+
+// DiffOptions represents the Diff options.
+type DiffOptions struct {
+	// Threshold defines the channel difference, in 0-255 scale, above
+	// which pixels are considered different. Zero highlights any change.
+	Threshold float64
+	// Color defines the highlight color. Defaults to red.
+	Color Color
+	// Type defines the diff image type. Defaults to PNG.
+	Type ImageType
+}
+
+// DiffResult represents the difference between two images.
+type DiffResult struct {
+	// Score is the ratio of differing pixels, from 0 for identical images
+	// to 1.
+	Score float64
+	// Image highlights the differing pixels over a faded greyscale copy of
+	// the first image.
+	Image *Image
+}
+
+// Diff highlights the pixels which differ between two images, e.g. for
+// visual regression testing of rendering pipelines. Both are compared as
+// sRGB with transparent areas as white, and the second image is resized
+// to the dimensions of the first one if they differ.
+func Diff(a, b *Image, o DiffOptions) (DiffResult, error) {
+	defer C.vips_thread_shutdown()
+
+	if o.Threshold < 0 || o.Threshold >= 255 {
+		return DiffResult{}, fmt.Errorf("Invalid diff threshold: %g", o.Threshold)
+	}
+	if o.Color == (Color{}) {
+		o.Color = Color{255, 0, 0}
+	}
+
+	imageA, _, err := loadImage(a.buf())
+	if err != nil {
+		return DiffResult{}, err
+	}
+	imageB, _, err := loadImage(b.buf())
+	if err != nil {
+		C.g_object_unref(C.gpointer(imageA))
+		return DiffResult{}, err
+	}
+
+	image, score, err := vipsDiff(imageA, imageB, o.Threshold, o.Color)
+	if err != nil {
+		return DiffResult{}, err
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	save := applySaveDefaults(SaveOptions{Type: o.Type}, PNG)
+	if !IsTypeSupportedSave(save.Type) {
+		return DiffResult{}, wrapError(ErrUnsupportedFormat, "Unsupported image output type: "+ImageTypeName(save.Type))
+	}
+	buf, err := encodeImage(image, save, save.Quality)
+	if err != nil {
+		return DiffResult{}, err
+	}
+	return DiffResult{Score: score, Image: NewImage(buf)}, nil
+}
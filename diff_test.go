@@ -0,0 +1,48 @@
+package bimg
+
+import "testing"
+
+func TestDiffIdentical(t *testing.T) {
+	img := initImage("test.jpg")
+
+	diff, err := Diff(img, img, DiffOptions{})
+	if err != nil {
+		t.Fatalf("Cannot diff the images: %#v", err)
+	}
+	if diff.Score != 0 {
+		t.Errorf("Identical images should not differ: %g", diff.Score)
+	}
+	if err := assertSize(diff.Image.Image(), 1680, 1050); err != nil {
+		t.Error(err)
+	}
+	if diff.Image.Type() != "png" {
+		t.Errorf("Invalid diff type: %s", diff.Image.Type())
+	}
+}
+
+func TestDiffThreshold(t *testing.T) {
+	img := initImage("test.jpg")
+	buf, err := img.Process(Options{Quality: 10})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+
+	strict, err := Diff(img, NewImage(buf), DiffOptions{})
+	if err != nil {
+		t.Fatalf("Cannot diff the images: %#v", err)
+	}
+	loose, err := Diff(img, NewImage(buf), DiffOptions{Threshold: 64, Type: JPEG})
+	if err != nil {
+		t.Fatalf("Cannot diff the images: %#v", err)
+	}
+	if strict.Score <= 0 || loose.Score >= strict.Score {
+		t.Errorf("Invalid diff scores: %g, %g", strict.Score, loose.Score)
+	}
+	if loose.Image.Type() != "jpeg" {
+		t.Errorf("Invalid diff type: %s", loose.Image.Type())
+	}
+
+	if _, err := Diff(img, img, DiffOptions{Threshold: 300}); err == nil {
+		t.Error("Invalid thresholds should fail")
+	}
+}
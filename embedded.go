@@ -0,0 +1,122 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// EmbeddedThumbnail returns the preview image embedded in the image
+// metadata, the EXIF thumbnail or the HEIF thumbnail, without decoding
+// the full resolution image. It fails with ErrNoEmbeddedThumbnail when
+// there is none.
+func (i *Image) EmbeddedThumbnail() ([]byte, error) {
+	return embeddedThumbnail(i.buf())
+}
+
+func embeddedThumbnail(buf []byte) ([]byte, error) {
+	// JPEG segments are parsed without libvips
+	if vipsImageType(buf) == JPEG {
+		if thumbnail := exifThumbnail(jpegExif(buf)); thumbnail != nil {
+			return append([]byte(nil), thumbnail...), nil
+		}
+		return nil, ErrNoEmbeddedThumbnail
+	}
+
+	defer C.vips_thread_shutdown()
+
+	image, imageType, err := loadImage(buf)
+	if err != nil {
+		return nil, err
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	exif := vipsExifData(image)
+	if thumbnail := exifThumbnail(bytes.TrimPrefix(exif, []byte("Exif\x00\x00"))); thumbnail != nil {
+		return thumbnail, nil
+	}
+
+	if imageType == HEIF || imageType == AVIF {
+		thumbnail, err := vipsLoadHeifThumbnail(buf, LoadOptions{})
+		if err != nil {
+			return nil, err
+		}
+		// libvips loads the primary image when there is no thumbnail
+		if thumbnail.Xsize >= image.Xsize {
+			C.g_object_unref(C.gpointer(thumbnail))
+			return nil, ErrNoEmbeddedThumbnail
+		}
+		defer C.g_object_unref(C.gpointer(thumbnail))
+
+		save := applySaveDefaults(SaveOptions{Type: JPEG}, JPEG)
+		return encodeImage(thumbnail, save, save.Quality)
+	}
+
+	return nil, ErrNoEmbeddedThumbnail
+}
+
+// exifThumbnail returns the JPEG thumbnail referenced by the second IFD of
+// an EXIF TIFF structure, sharing its memory, or nil.
+func exifThumbnail(tiff []byte) []byte {
+	order := tiffByteOrder(tiff)
+	if order == nil {
+		return nil
+	}
+
+	ifd0 := int(order.Uint32(tiff[4:]))
+	if ifd0 < 8 || ifd0+2 > len(tiff) {
+		return nil
+	}
+	next := ifd0 + 2 + 12*int(order.Uint16(tiff[ifd0:]))
+	if next+4 > len(tiff) {
+		return nil
+	}
+	ifd1 := int(order.Uint32(tiff[next:]))
+	if ifd1 < 8 || ifd1+2 > len(tiff) {
+		return nil
+	}
+
+	offset, length := 0, 0
+	entries := int(order.Uint16(tiff[ifd1:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd1 + 2 + i*12
+		if entry+12 > len(tiff) {
+			return nil
+		}
+		// JPEGInterchangeFormat and JPEGInterchangeFormatLength tags
+		switch order.Uint16(tiff[entry:]) {
+		case 0x0201:
+			offset = int(order.Uint32(tiff[entry+8:]))
+		case 0x0202:
+			length = int(order.Uint32(tiff[entry+8:]))
+		}
+	}
+
+	if offset <= 0 || length < 4 || offset+length > len(tiff) {
+		return nil
+	}
+	thumbnail := tiff[offset : offset+length]
+	if thumbnail[0] != 0xFF || thumbnail[1] != 0xD8 {
+		return nil
+	}
+	return thumbnail
+}
+
+// tiffByteOrder returns the byte order of a TIFF structure, or nil.
+func tiffByteOrder(tiff []byte) binary.ByteOrder {
+	if len(tiff) < 8 {
+		return nil
+	}
+	switch string(tiff[:2]) {
+	case "II":
+		return binary.LittleEndian
+	case "MM":
+		return binary.BigEndian
+	}
+	return nil
+}
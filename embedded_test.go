@@ -0,0 +1,65 @@
+package bimg
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestImageEmbeddedThumbnail(t *testing.T) {
+	img := initImage("test_exif_full.jpg")
+	thumbnail, err := img.EmbeddedThumbnail()
+	if err != nil {
+		t.Fatalf("Cannot read the embedded thumbnail: %#v", err)
+	}
+	if DetermineImageType(thumbnail) != JPEG {
+		t.Fatalf("Invalid thumbnail type: %s", ImageTypeName(DetermineImageType(thumbnail)))
+	}
+
+	size, err := NewImage(thumbnail).Size()
+	if err != nil {
+		t.Fatalf("Cannot read the thumbnail size: %#v", err)
+	}
+	full, _ := img.Size()
+	if size.Width >= full.Width || size.Height >= full.Height {
+		t.Errorf("Invalid thumbnail size: %#v", size)
+	}
+
+	if _, err := initImage("test.jpg").EmbeddedThumbnail(); !errors.Is(err, ErrNoEmbeddedThumbnail) {
+		t.Errorf("Images without thumbnail should fail with ErrNoEmbeddedThumbnail: %#v", err)
+	}
+	if _, err := initImage("test.png").EmbeddedThumbnail(); !errors.Is(err, ErrNoEmbeddedThumbnail) {
+		t.Errorf("Images without thumbnail should fail with ErrNoEmbeddedThumbnail: %#v", err)
+	}
+}
+
+func TestImageEmbeddedThumbnailHeif(t *testing.T) {
+	if !IsTypeSupported(HEIF) {
+		t.Skip("HEIF is not supported")
+	}
+	img := initImage("test.heic")
+	thumbnail, err := img.EmbeddedThumbnail()
+	if errors.Is(err, ErrNoEmbeddedThumbnail) {
+		return
+	}
+	if err != nil {
+		t.Fatalf("Cannot read the embedded thumbnail: %#v", err)
+	}
+	size, _ := NewImage(thumbnail).Size()
+	full, _ := img.Size()
+	if size.Width >= full.Width {
+		t.Errorf("Invalid thumbnail size: %#v", size)
+	}
+}
+
+func TestExifThumbnail(t *testing.T) {
+	tiff := jpegExif(readFile("test_exif_full.jpg"))
+	if exifThumbnail(tiff) == nil {
+		t.Fatal("Missing EXIF thumbnail")
+	}
+	for i := 0; i < len(tiff); i += 97 {
+		exifThumbnail(tiff[:i])
+	}
+	if exifThumbnail([]byte("not a tiff structure")) != nil {
+		t.Error("Invalid structures should not have thumbnails")
+	}
+}
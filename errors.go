@@ -0,0 +1,134 @@
+package bimg
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrUnsupportedFormat is returned when the image format cannot be
+	// loaded or saved, which HTTP servers usually map to 415.
+	ErrUnsupportedFormat = errors.New("Unsupported image format")
+
+	// ErrTruncatedImage is returned when the image buffer is empty or
+	// truncated, which HTTP servers usually map to 422.
+	ErrTruncatedImage = errors.New("Truncated image")
+
+	// ErrCorruptImage is returned when the image data is corrupted, such as
+	// JPEG markers out of order, which HTTP servers usually map to 422.
+	ErrCorruptImage = errors.New("Corrupt image")
+
+	// ErrDimensionsTooLarge is returned when the image, or the requested
+	// output, exceeds the maximum supported size.
+	ErrDimensionsTooLarge = errors.New("Maximum image size exceeded")
+
+	// ErrInputTooLarge is returned when the input buffer, or its number of
+	// pages, exceeds the configured limits.
+	ErrInputTooLarge = errors.New("Maximum input size exceeded")
+
+	// ErrNoEmbeddedThumbnail is returned when the image metadata does not
+	// embed a thumbnail.
+	ErrNoEmbeddedThumbnail = errors.New("No embedded thumbnail")
+
+	// ErrInvalidC2PAManifest is returned when a C2PA manifest store to
+	// attach is not a JUMBF superbox described as a C2PA manifest store.
+	ErrInvalidC2PAManifest = errors.New("Invalid C2PA manifest store")
+)
+
+// truncatedMessages lists the libvips and codec messages of truncated
+// images.
+var truncatedMessages = []string{
+	"premature end",
+	"truncated",
+	"unexpected end",
+	"not enough image data",
+	"read error",
+}
+
+// corruptMessages lists the libvips and codec messages of corrupted
+// images.
+var corruptMessages = []string{
+	"out of order",
+	"corrupt",
+}
+
+// unsupportedMessages lists the libvips messages of unknown formats.
+var unsupportedMessages = []string{
+	"not a known file format",
+	"not in a known format",
+}
+
+// ErrVipsOperation describes a failed libvips operation. It matches
+// ErrTruncatedImage, ErrCorruptImage or ErrUnsupportedFormat via errors.Is
+// when the libvips message tells so, and is an internal error otherwise.
+type ErrVipsOperation struct {
+	// Op is the libvips domain which reported the error, such as
+	// "VipsJpeg" or "extract_area".
+	Op string
+	// Code is the result code of the failed libvips call, -1 by the
+	// libvips convention.
+	Code int
+	// Message is the libvips error buffer.
+	Message string
+}
+
+// Error returns the libvips error message.
+func (e *ErrVipsOperation) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the matching sentinel error, if any.
+func (e *ErrVipsOperation) Unwrap() error {
+	message := strings.ToLower(e.Message)
+	for _, s := range unsupportedMessages {
+		if strings.Contains(message, s) {
+			return ErrUnsupportedFormat
+		}
+	}
+	for _, s := range truncatedMessages {
+		if strings.Contains(message, s) {
+			return ErrTruncatedImage
+		}
+	}
+	for _, s := range corruptMessages {
+		if strings.Contains(message, s) {
+			return ErrCorruptImage
+		}
+	}
+	return nil
+}
+
+// newVipsOperationError creates an ErrVipsOperation from the libvips
+// error buffer, reading the operation name from its first line.
+func newVipsOperationError(code int, message string) *ErrVipsOperation {
+	op := message
+	if i := strings.IndexByte(op, '\n'); i >= 0 {
+		op = op[:i]
+	}
+	if i := strings.Index(op, ": "); i >= 0 {
+		op = op[:i]
+	} else {
+		op = ""
+	}
+	return &ErrVipsOperation{Op: op, Code: code, Message: message}
+}
+
+// typedError keeps a descriptive message for a sentinel error.
+type typedError struct {
+	message string
+	err     error
+}
+
+func (e *typedError) Error() string {
+	return e.message
+}
+
+func (e *typedError) Unwrap() error {
+	return e.err
+}
+
+// wrapError returns an error with the given message which matches err
+// via errors.Is.
+func wrapError(err error, message string) error {
+	return &typedError{message: message, err: err}
+}
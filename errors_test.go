@@ -0,0 +1,68 @@
+package bimg
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestVipsOperationError(t *testing.T) {
+	cases := []struct {
+		message string
+		op      string
+		err     error
+	}{
+		{"VipsJpeg: Premature end of JPEG file\n", "VipsJpeg", ErrTruncatedImage},
+		{"VipsForeignLoad: buffer is not in a known format\n", "VipsForeignLoad", ErrUnsupportedFormat},
+		{"VipsJpeg: Corrupt JPEG data: 12 extraneous bytes before marker 0xd9\n", "VipsJpeg", ErrCorruptImage},
+		{"VipsJpeg: Invalid JPEG file structure: SOS before SOF\nout of order\n", "VipsJpeg", ErrCorruptImage},
+		{"extract_area: bad extract area\n", "extract_area", nil},
+		{"unknown error", "", nil},
+	}
+
+	for _, c := range cases {
+		err := newVipsOperationError(-1, c.message)
+		if err.Op != c.op {
+			t.Errorf("Invalid operation for %q: %q != %q", c.message, err.Op, c.op)
+		}
+		if err.Code != -1 {
+			t.Errorf("Invalid code for %q: %d", c.message, err.Code)
+		}
+		if err.Error() != c.message {
+			t.Errorf("Invalid message: %q", err.Error())
+		}
+		if c.err != nil && !errors.Is(err, c.err) {
+			t.Errorf("Error %q should match %v", c.message, c.err)
+		}
+		if c.err == nil && (errors.Is(err, ErrTruncatedImage) || errors.Is(err, ErrCorruptImage) || errors.Is(err, ErrUnsupportedFormat)) {
+			t.Errorf("Error %q should not match any sentinel error", c.message)
+		}
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	_, err := Resize([]byte{}, Options{})
+	if !errors.Is(err, ErrTruncatedImage) {
+		t.Errorf("Empty buffer should fail with ErrTruncatedImage: %#v", err)
+	}
+
+	_, err = Resize([]byte("not an image"), Options{})
+	if !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Unknown buffer should fail with ErrUnsupportedFormat: %#v", err)
+	}
+
+	_, err = initImage("test.jpg").Process(Options{Type: UNKNOWN + 100})
+	if !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Unknown output type should fail with ErrUnsupportedFormat: %#v", err)
+	}
+
+	_, err = initImage("test.jpg").Extract(0, 0, maxSize+1, 10)
+	if !errors.Is(err, ErrDimensionsTooLarge) {
+		t.Errorf("Oversized extract should fail with ErrDimensionsTooLarge: %#v", err)
+	}
+
+	var opErr *ErrVipsOperation
+	_, err = initImage("test.jpg").Extract(2000, 2000, 10, 10)
+	if !errors.As(err, &opErr) {
+		t.Errorf("Invalid extract should fail with ErrVipsOperation: %#v", err)
+	}
+}
@@ -0,0 +1,281 @@
+package bimg
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Fetcher represents a source of images, such as a remote server.
+type Fetcher interface {
+	Get(ctx context.Context, url string) ([]byte, error)
+}
+
+// FetcherFunc is an adapter to allow the use of ordinary functions
+// as Fetcher.
+type FetcherFunc func(ctx context.Context, url string) ([]byte, error)
+
+// Get calls f(ctx, url).
+func (f FetcherFunc) Get(ctx context.Context, url string) ([]byte, error) {
+	return f(ctx, url)
+}
+
+// FetchError is returned when a remote server responds with an error status.
+type FetchError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *FetchError) Error() string {
+	return fmt.Sprintf("Cannot fetch %s: status %d", e.URL, e.StatusCode)
+}
+
+// NewImageFromURL fetches an image with the given fetcher, defaulting to
+// an HTTPFetcher.
+func NewImageFromURL(ctx context.Context, url string, fetcher Fetcher) (*Image, error) {
+	if fetcher == nil {
+		fetcher = &HTTPFetcher{}
+	}
+	buf, err := fetcher.Get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return NewImage(buf), nil
+}
+
+// HTTPFetcher fetches images over HTTP and HTTPS. Responses which are not
+// images, by their Content-Type or content, are rejected.
+type HTTPFetcher struct {
+	Client *http.Client
+	// MaxBytes limits the response size, defaulting to the package-level
+	// MaxInputBytes limit. Zero means unlimited.
+	MaxBytes int
+	// Header defines additional request headers.
+	Header http.Header
+}
+
+// Get fetches the image at the given URL.
+func (f *HTTPFetcher) Get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return f.do(req.WithContext(ctx))
+}
+
+func (f *HTTPFetcher) do(req *http.Request) ([]byte, error) {
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	for name, values := range f.Header {
+		req.Header[name] = values
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, &FetchError{URL: req.URL.String(), StatusCode: res.StatusCode}
+	}
+
+	contentType := res.Header.Get("Content-Type")
+	if contentType != "" && !strings.HasPrefix(contentType, "image/") &&
+		!strings.HasPrefix(contentType, "application/octet-stream") && !strings.HasPrefix(contentType, "application/pdf") {
+		return nil, wrapError(ErrUnsupportedFormat, "Unsupported content type: "+contentType)
+	}
+
+	maxBytes := f.MaxBytes
+	if maxBytes == 0 {
+		maxBytes = GetLimits().MaxInputBytes
+	}
+	if maxBytes > 0 && res.ContentLength > int64(maxBytes) {
+		return nil, wrapError(ErrInputTooLarge, fmt.Sprintf("Response of %d bytes exceeds the limit of %d bytes", res.ContentLength, maxBytes))
+	}
+
+	var body io.Reader = res.Body
+	if maxBytes > 0 {
+		body = io.LimitReader(res.Body, int64(maxBytes)+1)
+	}
+	buf, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	if maxBytes > 0 && len(buf) > maxBytes {
+		return nil, wrapError(ErrInputTooLarge, fmt.Sprintf("Response exceeds the limit of %d bytes", maxBytes))
+	}
+	if DetermineImageType(buf) == UNKNOWN {
+		return nil, ErrUnsupportedFormat
+	}
+	return buf, nil
+}
+
+// S3Fetcher fetches images from Amazon S3, or S3 compatible storages, given
+// "s3://bucket/key" URLs. Requests are signed with AWS Signature Version 4.
+type S3Fetcher struct {
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Endpoint defaults to https://s3.<region>.amazonaws.com. Buckets are
+	// addressed in the path.
+	Endpoint string
+	// HTTP defines the client and the size limits.
+	HTTP HTTPFetcher
+	// now returns the current time, replaced in tests
+	now func() time.Time
+}
+
+// Get fetches the image at the given s3:// URL.
+func (f *S3Fetcher) Get(ctx context.Context, rawurl string) ([]byte, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "s3" || u.Host == "" || len(u.Path) < 2 {
+		return nil, errors.New("S3 URLs must be formatted as s3://bucket/key")
+	}
+
+	endpoint := f.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + f.Region + ".amazonaws.com"
+	}
+	// Send the key encoded as it is signed
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(endpoint, "/")+"/"+u.Host+awsCanonicalPath(u.Path), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now
+	if f.now != nil {
+		now = f.now
+	}
+	if f.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", f.SessionToken)
+	}
+	signAWSV4(req, f.Region, "s3", f.AccessKeyID, f.SecretAccessKey, now())
+	return f.HTTP.do(req.WithContext(ctx))
+}
+
+// emptyPayloadHash is the SHA-256 of an empty request body.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// signAWSV4 signs a request without body with AWS Signature Version 4,
+// covering the host and every request header.
+func signAWSV4(req *http.Request, region, service, accessKeyID, secretAccessKey string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsCanonicalPath(req.URL.Path),
+		awsCanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// awsCanonicalPath encodes every segment of the path for AWS Signature
+// Version 4.
+func awsCanonicalPath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = awsURIEncode(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// awsCanonicalQuery encodes the query parameters for AWS Signature Version
+// 4, sorted by name and then by value.
+func awsCanonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	values := make(map[string][]string, len(query))
+	for name, v := range query {
+		encoded := awsURIEncode(name)
+		names = append(names, encoded)
+		for _, value := range v {
+			values[encoded] = append(values[encoded], awsURIEncode(value))
+		}
+	}
+	sort.Strings(names)
+
+	params := make([]string, 0, len(names))
+	for _, name := range names {
+		sort.Strings(values[name])
+		for _, value := range values[name] {
+			params = append(params, name+"="+value)
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// awsURIEncode percent-encodes every byte but the unreserved characters,
+// as AWS Signature Version 4 requires. Spaces are encoded as %20.
+func awsURIEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
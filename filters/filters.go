@@ -0,0 +1,68 @@
+// Package filters provides stylize filters for bimg images, built on the
+// bimg processing primitives.
+//
+//	buf, err := filters.Posterize(bimg.NewImage(buf), 4)
+package filters
+
+import (
+	"fmt"
+
+	"github.com/h2non/bimg"
+)
+
+// oilPaintLevels is the number of intensity levels of the oil paint filter.
+const oilPaintLevels = 20
+
+// Posterize reduces every colour band of the image to the given number of
+// levels, between 2 and 256.
+func Posterize(img *bimg.Image, levels int) ([]byte, error) {
+	if levels < 2 || levels > 256 {
+		return nil, fmt.Errorf("Invalid posterize levels: %d, expected 2-256", levels)
+	}
+	return img.Process(bimg.Options{LUT: posterizeLUT(levels)})
+}
+
+// Solarize inverts the colour band values above the threshold (0-255),
+// as the partial exposure of a photographic print.
+func Solarize(img *bimg.Image, threshold int) ([]byte, error) {
+	if threshold < 0 || threshold > 255 {
+		return nil, fmt.Errorf("Invalid solarize threshold: %d, expected 0-255", threshold)
+	}
+	return img.Process(bimg.Options{LUT: solarizeLUT(threshold)})
+}
+
+// OilPaint gives the image the look of an oil painting, smoothing it with
+// a median filter of the given radius in pixels and reducing its colours
+// to flat strokes.
+func OilPaint(img *bimg.Image, radius int) ([]byte, error) {
+	if radius < 1 {
+		return nil, fmt.Errorf("Invalid oil paint radius: %d", radius)
+	}
+	return img.Process(bimg.Options{
+		Denoise: bimg.Denoise{Size: 2*radius + 1},
+		LUT:     posterizeLUT(oilPaintLevels),
+	})
+}
+
+// posterizeLUT maps the values to the nearest of the evenly spaced levels.
+func posterizeLUT(levels int) []byte {
+	lut := make([]byte, 256)
+	for v := range lut {
+		level := v * levels / 256
+		lut[v] = byte((level*255 + (levels-1)/2) / (levels - 1))
+	}
+	return lut
+}
+
+// solarizeLUT inverts the values from the threshold.
+func solarizeLUT(threshold int) []byte {
+	lut := make([]byte, 256)
+	for v := range lut {
+		if v >= threshold {
+			lut[v] = byte(255 - v)
+		} else {
+			lut[v] = byte(v)
+		}
+	}
+	return lut
+}
@@ -0,0 +1,78 @@
+package filters
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/h2non/bimg"
+)
+
+func readImage(t *testing.T, file string) *bimg.Image {
+	buf, err := ioutil.ReadFile("../testdata/" + file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bimg.NewImage(buf)
+}
+
+func TestPosterizeLUT(t *testing.T) {
+	lut := posterizeLUT(2)
+	if lut[0] != 0 || lut[127] != 0 || lut[128] != 255 || lut[255] != 255 {
+		t.Errorf("Unexpected 2 levels table: %v", lut)
+	}
+
+	lut = posterizeLUT(4)
+	for v, expected := range map[int]byte{0: 0, 63: 0, 64: 85, 128: 170, 255: 255} {
+		if lut[v] != expected {
+			t.Errorf("Value %d is mapped to %d, expected %d", v, lut[v], expected)
+		}
+	}
+
+	lut = posterizeLUT(256)
+	for v := range lut {
+		if int(lut[v]) != v {
+			t.Fatalf("Value %d is mapped to %d with 256 levels", v, lut[v])
+		}
+	}
+}
+
+func TestSolarizeLUT(t *testing.T) {
+	lut := solarizeLUT(128)
+	if lut[0] != 0 || lut[127] != 127 || lut[128] != 127 || lut[255] != 0 {
+		t.Errorf("Unexpected table: %v", lut)
+	}
+}
+
+func TestFilters(t *testing.T) {
+	filters := map[string]func(*bimg.Image) ([]byte, error){
+		"posterize": func(img *bimg.Image) ([]byte, error) { return Posterize(img, 4) },
+		"solarize":  func(img *bimg.Image) ([]byte, error) { return Solarize(img, 128) },
+		"oil paint": func(img *bimg.Image) ([]byte, error) { return OilPaint(img, 2) },
+	}
+	for name, filter := range filters {
+		buf, err := filter(readImage(t, "test.png"))
+		if err != nil {
+			t.Fatalf("Cannot apply the %s filter: %#v", name, err)
+		}
+		size, err := bimg.Size(buf)
+		if err != nil {
+			t.Fatalf("Cannot read the %s image size: %#v", name, err)
+		}
+		if size.Width != 400 || size.Height != 300 {
+			t.Errorf("Unexpected %s image size: %dx%d", name, size.Width, size.Height)
+		}
+	}
+}
+
+func TestInvalidFilters(t *testing.T) {
+	img := readImage(t, "test.png")
+	if _, err := Posterize(img, 1); err == nil {
+		t.Error("Expected an error for 1 posterize level")
+	}
+	if _, err := Solarize(img, 256); err == nil {
+		t.Error("Expected an error for a 256 solarize threshold")
+	}
+	if _, err := OilPaint(img, 0); err == nil {
+		t.Error("Expected an error for a 0 oil paint radius")
+	}
+}
@@ -0,0 +1,72 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"time"
+)
+
+// Frames returns the frames of animated GIF, WebP and PNG (APNG) images,
+// encoded as PNG, and their delays, zero when undefined. Other images are
+// returned as their single frame.
+func (i *Image) Frames() ([]*Image, []time.Duration, error) {
+	defer C.vips_thread_shutdown()
+	defer i.traceOperation("Frames").end()
+
+	buf := i.buf()
+	image, imageType, err := loadImage(buf)
+	if err != nil {
+		return nil, nil, err
+	}
+	pages := vipsNPages(image)
+	C.g_object_unref(C.gpointer(image))
+
+	if imageType == PNG && isAPNG(buf) {
+		return decodeAPNG(buf)
+	}
+	if pages < 2 || (imageType != GIF && imageType != WEBP) {
+		return []*Image{NewImage(buf)}, []time.Duration{0}, nil
+	}
+
+	image, err = vipsLoadFrames(buf, imageType, LoadOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	// The frames are loaded as a single image of their stacked heights
+	width := int(image.Xsize)
+	pageHeight := vipsPageHeight(image)
+	if pageHeight*pages != int(image.Ysize) {
+		return nil, nil, fmt.Errorf("Invalid animation page height: %d", pageHeight)
+	}
+	if err := GetLimits().checkSize(width, pageHeight, pages); err != nil {
+		return nil, nil, err
+	}
+
+	save := applySaveDefaults(SaveOptions{Type: PNG}, PNG)
+	frames := make([]*Image, pages)
+	delays := make([]time.Duration, pages)
+	for page := 0; page < pages; page++ {
+		// vipsExtract releases the image
+		C.g_object_ref(C.gpointer(image))
+		frame, err := vipsExtract(image, 0, page*pageHeight, width, pageHeight)
+		if err != nil {
+			return nil, nil, err
+		}
+		out, err := encodeImage(frame, save, save.Quality)
+		C.g_object_unref(C.gpointer(frame))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		frames[page] = NewImage(out)
+		delays[page] = vipsFrameDelay(image, page)
+	}
+	return frames, delays, nil
+}
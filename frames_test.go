@@ -0,0 +1,62 @@
+package bimg
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestImageFrames(t *testing.T) {
+	frames, delays, err := initImage("test.gif").Frames()
+	if err != nil {
+		t.Fatalf("Cannot extract the frames: %#v", err)
+	}
+	if len(frames) != 24 || len(delays) != 24 {
+		t.Fatalf("Invalid number of frames: %d, %d", len(frames), len(delays))
+	}
+
+	for _, frame := range frames {
+		if frame.Type() != "png" {
+			t.Errorf("Invalid frame type: %s", frame.Type())
+		}
+		if err := assertSize(frame.Image(), 703, 681); err != nil {
+			t.Error(err)
+		}
+	}
+	for _, delay := range delays {
+		if delay != 500*time.Millisecond {
+			t.Errorf("Invalid frame delay: %s", delay)
+		}
+	}
+}
+
+func TestImageFramesLimits(t *testing.T) {
+	defer SetLimits(Limits{})
+
+	// The limits apply to every frame, not to the 24 stacked frames
+	SetLimits(Limits{MaxHeight: 700, MaxPixels: 500000})
+	if frames, _, err := initImage("test.gif").Frames(); err != nil || len(frames) != 24 {
+		t.Errorf("Cannot extract the frames within the limits: %#v", err)
+	}
+
+	SetLimits(Limits{MaxHeight: 600})
+	if _, _, err := initImage("test.gif").Frames(); !errors.Is(err, ErrDimensionsTooLarge) {
+		t.Errorf("Expected ErrDimensionsTooLarge, got %#v", err)
+	}
+
+	SetLimits(Limits{MaxPages: 10})
+	if _, _, err := initImage("test.gif").Frames(); !errors.Is(err, ErrInputTooLarge) {
+		t.Errorf("Expected ErrInputTooLarge, got %#v", err)
+	}
+}
+
+func TestImageFramesStill(t *testing.T) {
+	img := initImage("test.jpg")
+	frames, delays, err := img.Frames()
+	if err != nil {
+		t.Fatalf("Cannot extract the frames: %#v", err)
+	}
+	if len(frames) != 1 || delays[0] != 0 || len(frames[0].Image()) != len(img.Image()) {
+		t.Errorf("Still images should have a single frame: %d", len(frames))
+	}
+}
@@ -0,0 +1,96 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import "fmt"
+
+// PerlinOptions represents the NewPerlin texture options.
+type PerlinOptions struct {
+	// CellSize defines the size of the texture features, in pixels.
+	// Defaults to 256.
+	CellSize int
+	// Seed defines the random generator seed, so textures can be
+	// reproduced. Zero picks a random one. Requires libvips 8.13+.
+	Seed int
+	// Worley generates a cellular texture rather than Perlin noise.
+	Worley bool
+}
+
+// NewSolid creates a PNG image of the given size filled with the color,
+// which defaults to black.
+func NewSolid(width, height int, color RGBAProvider) (*Image, error) {
+	return NewGradient(width, height, color, color, 0)
+}
+
+// NewGradient creates a PNG image of the given size filled with a linear
+// gradient between the two colors, which default to black. The angle is in
+// degrees clockwise from a left to right gradient, so 90 goes from top to
+// bottom.
+func NewGradient(width, height int, from, to RGBAProvider, angle float64) (*Image, error) {
+	defer C.vips_thread_shutdown()
+
+	if err := checkGeneratedSize(width, height); err != nil {
+		return nil, err
+	}
+	if from == nil {
+		from = ColorBlack
+	}
+	if to == nil {
+		to = ColorBlack
+	}
+
+	image, err := vipsGradient(width, height, from.RGBA(), to.RGBA(), angle)
+	if err != nil {
+		return nil, err
+	}
+	return encodeGenerated(image)
+}
+
+// NewPerlin creates a greyscale PNG image of the given size filled with a
+// Perlin noise or Worley cellular texture, e.g. for backgrounds or masks.
+// Requires libvips 8.6+.
+func NewPerlin(width, height int, o PerlinOptions) (*Image, error) {
+	defer C.vips_thread_shutdown()
+
+	if err := checkGeneratedSize(width, height); err != nil {
+		return nil, err
+	}
+	if o.CellSize < 0 {
+		return nil, fmt.Errorf("Invalid texture cell size: %d", o.CellSize)
+	}
+	if o.CellSize == 0 {
+		o.CellSize = 256
+	}
+
+	image, err := vipsNoiseTexture(width, height, o)
+	if err != nil {
+		return nil, err
+	}
+	return encodeGenerated(image)
+}
+
+func checkGeneratedSize(width, height int) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("Invalid image size: %dx%d", width, height)
+	}
+	if width > maxSize || height > maxSize {
+		return wrapError(ErrDimensionsTooLarge, fmt.Sprintf("Image size %dx%d exceeds the maximum size", width, height))
+	}
+	return nil
+}
+
+// encodeGenerated encodes the generated image as PNG, and releases it.
+func encodeGenerated(image *C.VipsImage) (*Image, error) {
+	defer C.g_object_unref(C.gpointer(image))
+
+	save := applySaveDefaults(SaveOptions{}, PNG)
+	buf, err := encodeImage(image, save, save.Quality)
+	if err != nil {
+		return nil, err
+	}
+	return NewImage(buf), nil
+}
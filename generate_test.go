@@ -0,0 +1,80 @@
+package bimg
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestNewSolid(t *testing.T) {
+	img, err := NewSolid(100, 50, Color{255, 0, 0})
+	if err != nil {
+		t.Fatalf("Cannot generate the image: %#v", err)
+	}
+	if err := assertSize(img.Image(), 100, 50); err != nil {
+		t.Fatal(err)
+	}
+	if img.Type() != "png" {
+		t.Errorf("Invalid image type: %s", img.Type())
+	}
+
+	decoded, err := png.Decode(bytes.NewReader(img.Image()))
+	if err != nil {
+		t.Fatalf("Cannot decode the image: %s", err)
+	}
+	r, g, b, _ := decoded.At(50, 25).RGBA()
+	if r>>8 != 255 || g>>8 != 0 || b>>8 != 0 {
+		t.Errorf("Invalid pixel color: %d, %d, %d", r>>8, g>>8, b>>8)
+	}
+}
+
+func TestNewGradient(t *testing.T) {
+	img, err := NewGradient(256, 10, ColorBlack, Color{255, 255, 255}, 0)
+	if err != nil {
+		t.Fatalf("Cannot generate the image: %#v", err)
+	}
+	decoded, err := png.Decode(bytes.NewReader(img.Image()))
+	if err != nil {
+		t.Fatalf("Cannot decode the image: %s", err)
+	}
+	left := color.GrayModel.Convert(decoded.At(0, 5)).(color.Gray).Y
+	middle := color.GrayModel.Convert(decoded.At(128, 5)).(color.Gray).Y
+	right := color.GrayModel.Convert(decoded.At(255, 5)).(color.Gray).Y
+	if left > 5 || right < 250 || middle < 120 || middle > 135 {
+		t.Errorf("Invalid gradient values: %d, %d, %d", left, middle, right)
+	}
+
+	img, err = NewGradient(10, 256, RGBA{0, 0, 255, 0}, RGBA{0, 0, 255, 255}, 90)
+	if err != nil {
+		t.Fatalf("Cannot generate the image: %#v", err)
+	}
+	meta, err := img.Metadata()
+	if err != nil {
+		t.Fatalf("Cannot read the image metadata: %#v", err)
+	}
+	if !meta.Alpha {
+		t.Error("Translucent gradient has no alpha channel")
+	}
+}
+
+func TestNewPerlin(t *testing.T) {
+	for _, o := range []PerlinOptions{{}, {CellSize: 32, Worley: true}} {
+		img, err := NewPerlin(128, 64, o)
+		if err != nil {
+			t.Fatalf("Cannot generate the image: %#v", err)
+		}
+		if err := assertSize(img.Image(), 128, 64); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGeneratedSize(t *testing.T) {
+	if _, err := NewSolid(0, 10, ColorBlack); err == nil {
+		t.Error("Expected an error for an empty image")
+	}
+	if _, err := NewPerlin(10, maxSize+1, PerlinOptions{}); err == nil {
+		t.Error("Expected an error for a too large image")
+	}
+}
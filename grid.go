@@ -0,0 +1,154 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// GridOptions represents the MakeGrid options.
+type GridOptions struct {
+	// Padding defines the space between the cells and around the grid,
+	// in pixels.
+	Padding int
+	// Background defines the color of the padding and of the cell areas
+	// not covered by their image. Grids of images with alpha channel have
+	// a transparent background.
+	Background Color
+	// CellSize defines the size of every cell. Larger images are shrunk to
+	// fit keeping their aspect ratio, and every image is centred in its
+	// cell. Zero dimensions default to the largest image dimensions.
+	CellSize ImageSize
+	// Type defines the output image type. Defaults to PNG.
+	Type ImageType
+	// Quality defines the output quality. Defaults to 75.
+	Quality int
+}
+
+// MakeGrid composes the images into a grid of the given number of columns,
+// in the images order. It is useful for gallery previews, video contact
+// sheets and CSS sprites.
+func MakeGrid(images []*Image, cols int, o GridOptions) (*Image, error) {
+	defer C.vips_thread_shutdown()
+
+	if len(images) == 0 {
+		return nil, errors.New("No grid images given")
+	}
+	if cols <= 0 {
+		return nil, fmt.Errorf("Invalid number of grid columns: %d", cols)
+	}
+	if o.Padding < 0 || o.CellSize.Width < 0 || o.CellSize.Height < 0 {
+		return nil, errors.New("Invalid grid padding or cell size")
+	}
+	if cols > len(images) {
+		cols = len(images)
+	}
+
+	cells, cellWidth, cellHeight, err := gridCells(images, o.CellSize)
+	if err != nil {
+		return nil, err
+	}
+	width, height, err := gridSize(len(cells), cols, cellWidth, cellHeight, o.Padding)
+	if err != nil {
+		releaseImages(cells)
+		return nil, err
+	}
+	return composeGrid(cells, cols, cellWidth, cellHeight, width, height, o)
+}
+
+// gridCells loads the images shrunk to fit in the cell size, and returns
+// the cell size, whose zero dimensions default to the largest image ones.
+func gridCells(images []*Image, size ImageSize) ([]*C.VipsImage, int, int, error) {
+	cells := make([]*C.VipsImage, 0, len(images))
+	width, height := size.Width, size.Height
+	for _, img := range images {
+		cell, err := gridCell(img, size)
+		if err != nil {
+			releaseImages(cells)
+			return nil, 0, 0, err
+		}
+		cells = append(cells, cell)
+		if size.Width == 0 && int(cell.Xsize) > width {
+			width = int(cell.Xsize)
+		}
+		if size.Height == 0 && int(cell.Ysize) > height {
+			height = int(cell.Ysize)
+		}
+	}
+	return cells, width, height, nil
+}
+
+// gridSize returns the size of a grid of n cells, checked against the
+// limits.
+func gridSize(n, cols, cellWidth, cellHeight, padding int) (int, int, error) {
+	rows := (n + cols - 1) / cols
+	width := cols*cellWidth + (cols+1)*padding
+	height := rows*cellHeight + (rows+1)*padding
+	err := GetLimits().checkSize(width, height, 1)
+	if err == nil && (width > maxSize || height > maxSize) {
+		err = wrapError(ErrDimensionsTooLarge, fmt.Sprintf("Grid size %dx%d exceeds the maximum size", width, height))
+	}
+	return width, height, err
+}
+
+// composeGrid joins the cells, releasing them, into a grid of the given
+// size and encodes it.
+func composeGrid(cells []*C.VipsImage, cols, cellWidth, cellHeight, width, height int, o GridOptions) (*Image, error) {
+	image, err := vipsArrayJoin(cells, cols, o.Padding, cellWidth, cellHeight, o.Background)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.Padding > 0 {
+		image, err = vipsEmbed(image, o.Padding, o.Padding, width, height, ExtendBackground, o.Background)
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	save := applySaveDefaults(SaveOptions{Type: o.Type, Quality: o.Quality}, PNG)
+	if !IsTypeSupportedSave(save.Type) {
+		return nil, wrapError(ErrUnsupportedFormat, "Unsupported image output type: "+ImageTypeName(save.Type))
+	}
+	buf, err := encodeImage(image, save, save.Quality)
+	if err != nil {
+		return nil, err
+	}
+	return NewImage(buf), nil
+}
+
+// releaseImages releases the images, skipping the nil ones.
+func releaseImages(images []*C.VipsImage) {
+	for _, image := range images {
+		if image != nil {
+			C.g_object_unref(C.gpointer(image))
+		}
+	}
+}
+
+// gridCell loads an auto-rotated image, shrunk to fit in the cell size.
+func gridCell(img *Image, size ImageSize) (*C.VipsImage, error) {
+	image, _, err := loadOrientedImage(img)
+	if err != nil {
+		return nil, err
+	}
+
+	scale := 1.0
+	if size.Width > 0 {
+		scale = math.Min(scale, float64(size.Width)/float64(image.Xsize))
+	}
+	if size.Height > 0 {
+		scale = math.Min(scale, float64(size.Height)/float64(image.Ysize))
+	}
+	if scale < 1 {
+		return vipsResize(image, scale, scale)
+	}
+	return image, nil
+}
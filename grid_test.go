@@ -0,0 +1,64 @@
+package bimg
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMakeGrid(t *testing.T) {
+	images := []*Image{initImage("test.jpg"), initImage("test.png"), initImage("test.webp")}
+	grid, err := MakeGrid(images, 2, GridOptions{
+		Padding:    10,
+		Background: Color{255, 255, 255},
+		CellSize:   ImageSize{Width: 100, Height: 80},
+	})
+	if err != nil {
+		t.Fatalf("Cannot make the grid: %#v", err)
+	}
+	if grid.Type() != "png" {
+		t.Errorf("Invalid grid type: %s", grid.Type())
+	}
+	if err := assertSize(grid.Image(), 230, 190); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMakeGridDefaultCellSize(t *testing.T) {
+	a, err := initImage("test.jpg").Resize(200, 100)
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	b, err := initImage("test.jpg").ForceResize(100, 150)
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+
+	grid, err := MakeGrid([]*Image{NewImage(a), NewImage(b)}, 5, GridOptions{Type: JPEG})
+	if err != nil {
+		t.Fatalf("Cannot make the grid: %#v", err)
+	}
+	if err := assertSize(grid.Image(), 400, 150); err != nil {
+		t.Error(err)
+	}
+	if grid.Type() != "jpeg" {
+		t.Errorf("Invalid grid type: %s", grid.Type())
+	}
+
+	if _, err := MakeGrid(nil, 2, GridOptions{}); err == nil {
+		t.Error("Empty grids should fail")
+	}
+	if _, err := MakeGrid([]*Image{NewImage(a)}, 0, GridOptions{}); err == nil {
+		t.Error("Grids without columns should fail")
+	}
+}
+
+func TestMakeGridLimits(t *testing.T) {
+	SetLimits(Limits{MaxWidth: 200})
+	defer SetLimits(Limits{})
+
+	images := []*Image{initImage("test.jpg"), initImage("test.png")}
+	_, err := MakeGrid(images, 2, GridOptions{Padding: 10, CellSize: ImageSize{Width: 100, Height: 80}})
+	if !errors.Is(err, ErrDimensionsTooLarge) {
+		t.Errorf("Expected ErrDimensionsTooLarge, got %#v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import (
+	"os"
+	"runtime"
+	"sync/atomic"
+)
+
+// leakCheck enables the report of the images not explicitly closed.
+var leakCheck int32
+
+func init() {
+	if os.Getenv("BIMG_LEAK") != "" {
+		EnableLeakCheck()
+	}
+}
+
+// EnableLeakCheck enables the report of the images garbage collected while
+// holding a libvips image, as they were not closed via Image.Close, along
+// with the stack trace of their creation. Reports are written to the logger
+// set via SetLogger, or to stderr. It can also be enabled by defining the
+// BIMG_LEAK environment variable. It slows down the image loading, so it
+// is meant for debugging only.
+func EnableLeakCheck() {
+	atomic.StoreInt32(&leakCheck, 1)
+}
+
+// DisableLeakCheck disables the report of the images not explicitly closed.
+func DisableLeakCheck() {
+	atomic.StoreInt32(&leakCheck, 0)
+}
+
+// imageHandle is a libvips image held by an Image, loaded from its buffer
+// header to read the metadata.
+type imageHandle struct {
+	image     *C.VipsImage
+	imageType ImageType
+	// stack is the creation stack trace, when checking for leaks
+	stack []byte
+}
+
+func newImageHandle(buf []byte) (*imageHandle, error) {
+	defer C.vips_thread_shutdown()
+
+	image, imageType, err := vipsRead(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &imageHandle{image: image, imageType: imageType}
+	if atomic.LoadInt32(&leakCheck) == 1 {
+		h.stack = make([]byte, 4096)
+		h.stack = h.stack[:runtime.Stack(h.stack, false)]
+	}
+	return h, nil
+}
+
+func (h *imageHandle) metadata() ImageMetadata {
+	return vipsMetadata(h.image, h.imageType)
+}
+
+func (h *imageHandle) close() {
+	C.g_object_unref(C.gpointer(h.image))
+	h.image = nil
+}
+
+// header returns the handle of the image buffer, loading it on first use.
+// The image lock must be held.
+func (i *Image) header() (*imageHandle, error) {
+	if i.handle != nil {
+		return i.handle, nil
+	}
+
+	h, err := newImageHandle(i.buffer)
+	if err != nil {
+		return nil, err
+	}
+	i.handle = h
+	// Safety net for the images never closed
+	runtime.SetFinalizer(i, (*Image).finalize)
+	return h, nil
+}
+
+// release closes the image handle, if any. The image lock must be held.
+func (i *Image) release() {
+	if i.handle != nil {
+		i.handle.close()
+		i.handle = nil
+		runtime.SetFinalizer(i, nil)
+	}
+}
+
+func (i *Image) finalize() {
+	if i.handle == nil {
+		return
+	}
+	if i.handle.stack != nil {
+		logMessage(LogLevelWarning, "Image garbage collected without being closed, created at:\n"+string(i.handle.stack))
+	}
+	i.handle.close()
+	i.handle = nil
+}
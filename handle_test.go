@@ -0,0 +1,75 @@
+package bimg
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImageClose(t *testing.T) {
+	img := initImage("test.jpg")
+	if _, err := img.Size(); err != nil {
+		t.Fatalf("Cannot read the image size: %#v", err)
+	}
+	if img.handle == nil {
+		t.Fatal("Missing image handle")
+	}
+
+	img.Close()
+	if img.handle != nil {
+		t.Fatal("Image handle must be released")
+	}
+
+	size, err := img.Size()
+	if err != nil || size.Width != 1680 {
+		t.Errorf("Invalid size after close: %#v %#v", size, err)
+	}
+	img.Close()
+}
+
+func TestImageProcessReleasesHandle(t *testing.T) {
+	img := initImage("test.jpg")
+	img.Size()
+	if _, err := img.Resize(300, 200); err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if img.handle != nil {
+		t.Fatal("Image handle must be released after processing")
+	}
+	if size, _ := img.Size(); size.Width != 300 {
+		t.Errorf("Invalid image width: %d", size.Width)
+	}
+	img.Close()
+}
+
+func TestLeakCheck(t *testing.T) {
+	messages := make(chan string, 10)
+	SetLogger(func(level LogLevel, domain, message string) {
+		if domain == "bimg" {
+			messages <- message
+		}
+	})
+	defer SetLogger(nil)
+
+	EnableLeakCheck()
+	defer DisableLeakCheck()
+
+	func() {
+		img := initImage("test.jpg")
+		img.Size()
+	}()
+
+	for i := 0; i < 50; i++ {
+		runtime.GC()
+		select {
+		case message := <-messages:
+			if !strings.Contains(message, "TestLeakCheck") {
+				t.Errorf("Missing creation stack trace: %s", message)
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	t.Error("Missing leaked image report")
+}
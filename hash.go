@@ -0,0 +1,135 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// HashAlgo represents the perceptual hash algorithm.
+type HashAlgo int
+
+const (
+	// HashAverage compares every pixel of a 8x8 thumbnail against the mean (aHash).
+	HashAverage HashAlgo = iota
+	// HashDifference compares adjacent pixels of a 9x8 thumbnail (dHash).
+	HashDifference
+	// HashPerceptual compares the low frequencies of the DCT of a 32x32
+	// thumbnail against their median (pHash). It is the most robust one.
+	HashPerceptual
+)
+
+// HammingDistance returns the number of different bits between two hashes.
+// Hashes of near duplicate images usually differ in less than 10 bits.
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+func perceptualHash(buf []byte, algo HashAlgo) (uint64, error) {
+	switch algo {
+	case HashAverage:
+		pixels, err := hashPixels(buf, 8, 8)
+		if err != nil {
+			return 0, err
+		}
+		return averageHash(pixels), nil
+	case HashDifference:
+		pixels, err := hashPixels(buf, 9, 8)
+		if err != nil {
+			return 0, err
+		}
+		return differenceHash(pixels), nil
+	case HashPerceptual:
+		pixels, err := hashPixels(buf, 32, 32)
+		if err != nil {
+			return 0, err
+		}
+		return dctHash(pixels), nil
+	}
+	return 0, errors.New("Unsupported hash algorithm")
+}
+
+func hashPixels(buf []byte, width, height int) ([]byte, error) {
+	defer C.vips_thread_shutdown()
+
+	image, _, err := loadImage(buf)
+	if err != nil {
+		return nil, err
+	}
+	return vipsGreyPixels(image, width, height)
+}
+
+func averageHash(pixels []byte) uint64 {
+	sum := 0
+	for _, p := range pixels {
+		sum += int(p)
+	}
+	mean := float64(sum) / float64(len(pixels))
+
+	var hash uint64
+	for i, p := range pixels {
+		if float64(p) > mean {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+func differenceHash(pixels []byte) uint64 {
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if pixels[y*9+x] < pixels[y*9+x+1] {
+				hash |= 1 << uint(y*8+x)
+			}
+		}
+	}
+	return hash
+}
+
+func dctHash(pixels []byte) uint64 {
+	const size = 32
+
+	// Separable 2D DCT-II, only the 8x8 lowest frequencies are needed
+	rows := make([]float64, size*8)
+	for y := 0; y < size; y++ {
+		for u := 0; u < 8; u++ {
+			sum := 0.0
+			for x := 0; x < size; x++ {
+				sum += float64(pixels[y*size+x]) * math.Cos(math.Pi*float64(u)*(float64(x)+0.5)/size)
+			}
+			rows[y*8+u] = sum
+		}
+	}
+
+	coeffs := make([]float64, 64)
+	for v := 0; v < 8; v++ {
+		for u := 0; u < 8; u++ {
+			sum := 0.0
+			for y := 0; y < size; y++ {
+				sum += rows[y*8+u] * math.Cos(math.Pi*float64(v)*(float64(y)+0.5)/size)
+			}
+			coeffs[v*8+u] = sum
+		}
+	}
+
+	sorted := make([]float64, 64)
+	copy(sorted, coeffs)
+	sort.Float64s(sorted)
+	median := (sorted[31] + sorted[32]) / 2
+
+	var hash uint64
+	for i, c := range coeffs {
+		if c > median {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
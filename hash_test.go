@@ -0,0 +1,59 @@
+package bimg
+
+import "testing"
+
+func TestHammingDistance(t *testing.T) {
+	tt := []struct {
+		a, b     uint64
+		expected int
+	}{
+		{0, 0, 0},
+		{0, 1, 1},
+		{0xFF, 0x0F, 4},
+		{0, ^uint64(0), 64},
+	}
+
+	for _, tc := range tt {
+		if got := HammingDistance(tc.a, tc.b); got != tc.expected {
+			t.Errorf("expected: %d; got: %d", tc.expected, got)
+		}
+	}
+}
+
+func TestPerceptualHash(t *testing.T) {
+	algos := []HashAlgo{HashAverage, HashDifference, HashPerceptual}
+
+	for _, algo := range algos {
+		hash, err := initImage("test.jpg").PerceptualHash(algo)
+		if err != nil {
+			t.Fatalf("Cannot compute hash %d: %#v", algo, err)
+		}
+
+		// A resized copy must be a near duplicate
+		buf, err := initImage("test.jpg").Resize(400, 250)
+		if err != nil {
+			t.Fatalf("Cannot process the image: %#v", err)
+		}
+		resized, err := NewImage(buf).PerceptualHash(algo)
+		if err != nil {
+			t.Fatalf("Cannot compute hash %d: %#v", algo, err)
+		}
+		if d := HammingDistance(hash, resized); d > 10 {
+			t.Errorf("Hash %d distance too high for a resized copy: %d", algo, d)
+		}
+
+		other, err := initImage("northern_cardinal_bird.jpg").PerceptualHash(algo)
+		if err != nil {
+			t.Fatalf("Cannot compute hash %d: %#v", algo, err)
+		}
+		if d := HammingDistance(hash, other); d <= 10 {
+			t.Errorf("Hash %d distance too low for different images: %d", algo, d)
+		}
+	}
+}
+
+func TestPerceptualHashInvalid(t *testing.T) {
+	if _, err := initImage("test.jpg").PerceptualHash(HashAlgo(10)); err == nil {
+		t.Fatal("Expected error with unsupported algorithm")
+	}
+}
@@ -0,0 +1,332 @@
+// Package httpimage provides an http.Handler transforming images on the fly
+// from query parameters:
+//
+//	http.Handle("/images/", http.StripPrefix("/images", httpimage.NewHandler(httpimage.Dir("static"))))
+//
+//	GET /images/photo.jpg?w=800&h=600&mode=fill&crop=smart&fmt=auto&q=80
+package httpimage
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/h2non/bimg"
+)
+
+// Source fetches the source image of a request.
+type Source interface {
+	Fetch(r *http.Request) ([]byte, error)
+}
+
+// Dir serves the source images from a directory, using the request path.
+type Dir string
+
+// Fetch reads the image file of the request path, which cannot escape
+// the directory.
+func (d Dir) Fetch(r *http.Request) ([]byte, error) {
+	name := path.Clean("/" + r.URL.Path)
+	return bimg.Read(filepath.Join(string(d), filepath.FromSlash(name)))
+}
+
+// Remote serves the source images fetched from the request path relative
+// to the base URL, such as an S3 bucket or an upstream server.
+type Remote struct {
+	Base    string
+	Fetcher bimg.Fetcher
+}
+
+// Fetch fetches the image of the request path, defaulting to a
+// bimg.HTTPFetcher.
+func (s Remote) Fetch(r *http.Request) ([]byte, error) {
+	fetcher := s.Fetcher
+	if fetcher == nil {
+		fetcher = &bimg.HTTPFetcher{}
+	}
+	// Escape the path, so names such as "a?b.jpg" are not read as queries
+	name := (&url.URL{Path: path.Clean("/" + r.URL.Path)}).EscapedPath()
+	return fetcher.Get(r.Context(), strings.TrimSuffix(s.Base, "/")+name)
+}
+
+// Handler serves the source images transformed as requested by the query
+// parameters:
+//
+//	w, h  output width and height, in pixels
+//	mode  fit (default) keeps the image within the size, fill crops it to
+//	      the size, pad embeds it into the size and stretch ignores the
+//	      aspect ratio
+//	crop  fill gravity: centre (default), north, east, south, west or
+//	      smart. Implies the fill mode
+//	fmt   output type: jpeg, png, webp, avif, gif or auto, negotiated
+//	      with the Accept header. Defaults to the source type
+//	q     output quality, from 1 to 100
+type Handler struct {
+	Source Source
+	// Limits guards the source images, see bimg.Limits. Zero fields use
+	// the bimg package-level limits.
+	Limits bimg.Limits
+	// MaxAge defines the Cache-Control max-age. Defaults to one day.
+	MaxAge time.Duration
+	// Signer, when defined, rejects the requests without a valid URL
+	// signature, so the endpoint cannot be abused as free compute.
+	Signer *Signer
+	// Cache, when defined, stores the transformed images so hot
+	// transforms are served without processing them again.
+	Cache bimg.Cache
+}
+
+// NewHandler creates a new Handler serving the images of the given source.
+func NewHandler(source Source) *Handler {
+	return &Handler{Source: source}
+}
+
+var gravities = map[string]bimg.Gravity{
+	"":       bimg.GravityCentre,
+	"centre": bimg.GravityCentre,
+	"center": bimg.GravityCentre,
+	"north":  bimg.GravityNorth,
+	"east":   bimg.GravityEast,
+	"south":  bimg.GravitySouth,
+	"west":   bimg.GravityWest,
+	"smart":  bimg.GravitySmart,
+}
+
+// ServeHTTP serves the transformed image.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.Signer != nil {
+		if err := h.Signer.Verify(r); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	params, err := parseParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	buf, err := h.Source.Fetch(r)
+	if err != nil {
+		http.Error(w, http.StatusText(errorStatus(err)), errorStatus(err))
+		return
+	}
+
+	o, err := h.options(buf, params, r.Header.Get("Accept"))
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	etag := computeETag(buf, o)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(h.maxAge().Seconds())))
+	if params.format == "auto" {
+		w.Header().Set("Vary", "Accept")
+	}
+	if match := r.Header.Get("If-None-Match"); match != "" && match == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	out, err := h.process(buf, o)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentType(bimg.DetermineImageType(out)))
+	w.Header().Set("Content-Length", strconv.Itoa(len(out)))
+	if r.Method == http.MethodGet {
+		w.Write(out)
+	}
+}
+
+func (h *Handler) process(buf []byte, o bimg.Options) ([]byte, error) {
+	if h.Cache == nil {
+		return bimg.NewImage(buf).Process(o)
+	}
+	key, err := bimg.CacheKey(buf, o)
+	if err != nil {
+		return bimg.NewImage(buf).Process(o)
+	}
+	if out, ok := h.Cache.Get(key); ok {
+		return out, nil
+	}
+	out, err := bimg.NewImage(buf).Process(o)
+	if err == nil {
+		h.Cache.Set(key, out)
+	}
+	return out, err
+}
+
+func (h *Handler) maxAge() time.Duration {
+	if h.MaxAge == 0 {
+		return 24 * time.Hour
+	}
+	return h.MaxAge
+}
+
+// params represents the parsed query parameters.
+type params struct {
+	width   int
+	height  int
+	quality int
+	mode    string
+	gravity bimg.Gravity
+	format  string
+}
+
+func parseParams(r *http.Request) (params, error) {
+	query := r.URL.Query()
+	var p params
+	var err error
+
+	for name, value := range map[string]*int{"w": &p.width, "h": &p.height, "q": &p.quality} {
+		if s := query.Get(name); s != "" {
+			if *value, err = strconv.Atoi(s); err != nil || *value < 0 {
+				return p, fmt.Errorf("Invalid %s parameter: %s", name, s)
+			}
+		}
+	}
+	if p.width > bimg.MaxSize() || p.height > bimg.MaxSize() {
+		return p, fmt.Errorf("Maximum output size is %d pixels", bimg.MaxSize())
+	}
+	if p.quality > 100 {
+		return p, fmt.Errorf("Invalid q parameter: %d", p.quality)
+	}
+
+	crop := query.Get("crop")
+	gravity, ok := gravities[strings.ToLower(crop)]
+	if !ok {
+		return p, fmt.Errorf("Invalid crop parameter: %s", crop)
+	}
+	p.gravity = gravity
+
+	p.mode = strings.ToLower(query.Get("mode"))
+	switch p.mode {
+	case "":
+		p.mode = "fit"
+		if crop != "" {
+			p.mode = "fill"
+		}
+	case "fit", "fill", "pad", "stretch":
+	default:
+		return p, fmt.Errorf("Invalid mode parameter: %s", p.mode)
+	}
+
+	p.format = strings.ToLower(query.Get("fmt"))
+	if p.format != "" && p.format != "auto" && bimg.ParseImageType(p.format) == bimg.UNKNOWN {
+		return p, fmt.Errorf("Invalid fmt parameter: %s", p.format)
+	}
+	return p, nil
+}
+
+// options returns the bimg options of the parameters, reading the source
+// image header.
+func (h *Handler) options(buf []byte, p params, accept string) (bimg.Options, error) {
+	o := bimg.Options{
+		Quality: p.quality,
+		Load:    bimg.LoadOptions{Limits: h.Limits},
+	}
+
+	meta, err := bimg.Metadata(buf)
+	if err != nil {
+		return o, err
+	}
+
+	switch p.format {
+	case "":
+	case "auto":
+		o.Type = bimg.ChooseType(accept, meta.Alpha, false)
+	default:
+		o.Type = bimg.ParseImageType(p.format)
+	}
+
+	if p.width == 0 && p.height == 0 {
+		return o, nil
+	}
+
+	switch p.mode {
+	case "fit":
+		// Resize along the side bounding the image, keeping the aspect ratio
+		size := meta.OrientedSize
+		if p.height == 0 || (p.width > 0 && size.Width*p.height > size.Height*p.width) {
+			o.Width = p.width
+		} else {
+			o.Height = p.height
+		}
+	case "fill":
+		o.Width, o.Height = p.width, p.height
+		o.Crop = true
+		o.Gravity = p.gravity
+	case "pad":
+		o.Width, o.Height = p.width, p.height
+		o.Embed = true
+	case "stretch":
+		o.Width, o.Height = p.width, p.height
+		o.Force = true
+	}
+	return o, nil
+}
+
+// computeETag returns a strong ETag identifying the source image and the
+// transformation options.
+func computeETag(buf []byte, o bimg.Options) string {
+	hash := sha1.New()
+	hash.Write(buf)
+	fmt.Fprintf(hash, "%d:%d:%d:%t:%t:%t:%d:%d", o.Width, o.Height, o.Quality, o.Crop, o.Embed, o.Force, o.Gravity, o.Type)
+	return `"` + hex.EncodeToString(hash.Sum(nil)) + `"`
+}
+
+// ContentType returns the MIME type of an image type.
+func ContentType(t bimg.ImageType) string {
+	switch t {
+	case bimg.SVG:
+		return "image/svg+xml"
+	case bimg.PDF:
+		return "application/pdf"
+	case bimg.UNKNOWN:
+		return "application/octet-stream"
+	}
+	return "image/" + bimg.ImageTypeName(t)
+}
+
+// errorStatus maps an error to its HTTP status code: unsupported formats to
+// 415, invalid or oversized images to 422, missing sources to 404 and
+// failed upstream requests to 502.
+func errorStatus(err error) int {
+	var fetchErr *bimg.FetchError
+	switch {
+	case errors.Is(err, bimg.ErrUnsupportedFormat):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, bimg.ErrTruncatedImage), errors.Is(err, bimg.ErrCorruptImage), errors.Is(err, bimg.ErrDimensionsTooLarge),
+		errors.Is(err, bimg.ErrInputTooLarge):
+		return http.StatusUnprocessableEntity
+	case os.IsNotExist(err):
+		return http.StatusNotFound
+	case os.IsPermission(err):
+		return http.StatusForbidden
+	case errors.As(err, &fetchErr):
+		if fetchErr.StatusCode == http.StatusNotFound {
+			return http.StatusNotFound
+		}
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
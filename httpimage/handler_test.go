@@ -0,0 +1,180 @@
+package httpimage
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/h2non/bimg"
+)
+
+func serve(h http.Handler, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", target, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	return res
+}
+
+func TestHandler(t *testing.T) {
+	h := NewHandler(Dir("../testdata"))
+
+	cases := []struct {
+		target string
+		width  int
+		height int
+		mime   string
+	}{
+		{"/test.jpg?w=300", 300, 0, "image/jpeg"},
+		{"/test.jpg?w=300&h=100", 160, 100, "image/jpeg"},
+		{"/test.jpg?w=300&h=300&mode=fill&crop=smart", 300, 300, "image/jpeg"},
+		{"/test.jpg?w=300&h=300&mode=pad&fmt=png", 300, 300, "image/png"},
+		{"/test.jpg?w=300&h=300&mode=stretch&fmt=webp&q=80", 300, 300, "image/webp"},
+	}
+
+	for _, c := range cases {
+		res := serve(h, c.target, nil)
+		if res.Code != http.StatusOK {
+			t.Errorf("%s: invalid status %d: %s", c.target, res.Code, res.Body.String())
+			continue
+		}
+		if mime := res.Header().Get("Content-Type"); mime != c.mime {
+			t.Errorf("%s: invalid content type %s", c.target, mime)
+		}
+		if res.Header().Get("ETag") == "" || res.Header().Get("Cache-Control") != "public, max-age=86400" {
+			t.Errorf("%s: missing cache headers: %#v", c.target, res.Header())
+		}
+		size, err := bimg.Size(res.Body.Bytes())
+		if err != nil {
+			t.Errorf("%s: invalid image: %s", c.target, err)
+			continue
+		}
+		if size.Width != c.width || (c.height > 0 && size.Height != c.height) {
+			t.Errorf("%s: invalid size %dx%d", c.target, size.Width, size.Height)
+		}
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	h := NewHandler(Dir("../testdata"))
+	h.Limits = bimg.Limits{MaxPixels: 1000}
+
+	cases := map[string]int{
+		"/missing.jpg":                http.StatusNotFound,
+		"/../handler.go":              http.StatusNotFound,
+		"/test.jpg?w=abc":             http.StatusBadRequest,
+		"/test.jpg?mode=zoom":         http.StatusBadRequest,
+		"/test.jpg?crop=middle":       http.StatusBadRequest,
+		"/test.jpg?fmt=bmp":           http.StatusBadRequest,
+		"/test.jpg?w=100":             http.StatusUnprocessableEntity,
+		"/parameter_trim.png?w=99999": http.StatusBadRequest,
+	}
+	for target, status := range cases {
+		if res := serve(h, target, nil); res.Code != status {
+			t.Errorf("%s: invalid status %d, expected %d", target, res.Code, status)
+		}
+	}
+}
+
+func TestHandlerNegotiation(t *testing.T) {
+	h := NewHandler(Dir("../testdata"))
+
+	res := serve(h, "/test.jpg?w=100&fmt=auto", http.Header{"Accept": {"image/webp,*/*"}})
+	if res.Header().Get("Content-Type") != "image/webp" || res.Header().Get("Vary") != "Accept" {
+		t.Errorf("Invalid negotiated response: %#v", res.Header())
+	}
+
+	etag := res.Header().Get("ETag")
+	res = serve(h, "/test.jpg?w=100&fmt=auto", http.Header{"Accept": {"image/webp,*/*"}, "If-None-Match": {etag}})
+	if res.Code != http.StatusNotModified {
+		t.Errorf("Invalid status for a matching ETag: %d", res.Code)
+	}
+}
+
+func TestContentType(t *testing.T) {
+	cases := map[bimg.ImageType]string{bimg.JPEG: "image/jpeg", bimg.SVG: "image/svg+xml", bimg.UNKNOWN: "application/octet-stream"}
+	for imageType, mime := range cases {
+		if ContentType(imageType) != mime {
+			t.Errorf("Invalid content type for %s: %s", bimg.ImageTypeName(imageType), ContentType(imageType))
+		}
+	}
+}
+
+func TestHandlerRemote(t *testing.T) {
+	upstream := httptest.NewServer(http.FileServer(http.Dir("../testdata")))
+	defer upstream.Close()
+
+	h := NewHandler(Remote{Base: upstream.URL + "/"})
+
+	res := serve(h, "/test.jpg?w=100", nil)
+	if res.Code != http.StatusOK {
+		t.Fatalf("Invalid response status: %d", res.Code)
+	}
+	if res.Header().Get("Content-Type") != "image/jpeg" {
+		t.Errorf("Invalid content type: %s", res.Header().Get("Content-Type"))
+	}
+
+	if res := serve(h, "/missing.jpg", nil); res.Code != http.StatusNotFound {
+		t.Errorf("Invalid status for a missing upstream image: %d", res.Code)
+	}
+}
+
+func TestHandlerRemoteEscaping(t *testing.T) {
+	buf, err := bimg.Read("../testdata/test.jpg")
+	if err != nil {
+		t.Fatalf("Cannot read the image: %s", err)
+	}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/a?b #c.jpg" || r.URL.RawQuery != "" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(buf)
+	}))
+	defer upstream.Close()
+
+	h := NewHandler(Remote{Base: upstream.URL})
+	if res := serve(h, "/a%3Fb%20%23c.jpg?w=100", nil); res.Code != http.StatusOK {
+		t.Errorf("Invalid response status: %d", res.Code)
+	}
+}
+
+func TestHandlerDirEscaping(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bimg-httpimage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	buf, err := bimg.Read("../testdata/test.jpg")
+	if err != nil {
+		t.Fatalf("Cannot read the image: %s", err)
+	}
+	if err := bimg.Write(filepath.Join(dir, "my photo.jpg"), buf); err != nil {
+		t.Fatalf("Cannot write the image: %s", err)
+	}
+
+	h := NewHandler(Dir(dir))
+	if res := serve(h, "/my%20photo.jpg?w=100", nil); res.Code != http.StatusOK {
+		t.Errorf("Invalid response status: %d", res.Code)
+	}
+}
+
+func TestHandlerCache(t *testing.T) {
+	h := NewHandler(Dir("../testdata"))
+	cache := bimg.NewLRUCache(10 << 20)
+	h.Cache = cache
+
+	first := serve(h, "/test.jpg?w=100", nil)
+	second := serve(h, "/test.jpg?w=100", nil)
+	if first.Code != http.StatusOK || second.Code != http.StatusOK {
+		t.Fatalf("Invalid response status: %d, %d", first.Code, second.Code)
+	}
+	if cache.Len() != 1 || first.Body.Len() != second.Body.Len() {
+		t.Errorf("The transformed image should be cached once: %d", cache.Len())
+	}
+}
@@ -0,0 +1,70 @@
+package httpimage
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigner(t *testing.T) {
+	s := NewSigner([]byte("secret"), []byte("salt"))
+	now := time.Unix(1600000000, 0)
+	s.now = func() time.Time { return now }
+
+	signed := s.Sign("/test.jpg", url.Values{"w": {"300"}}, now.Add(time.Hour))
+	if err := s.Verify(httptest.NewRequest("GET", signed, nil)); err != nil {
+		t.Errorf("Valid signature rejected: %s", err)
+	}
+
+	cases := map[string]error{
+		strings.Replace(signed, "w=300", "w=3000", 1):     ErrInvalidSignature,
+		strings.Replace(signed, "/test.jpg", "/a.jpg", 1): ErrInvalidSignature,
+		"/test.jpg?w=300":                               ErrInvalidSignature,
+		"/test.jpg?w=300&sig=%%%":                       ErrInvalidSignature,
+		s.Sign("/test.jpg", nil, now.Add(-time.Second)): ErrExpiredSignature,
+	}
+	for target, expected := range cases {
+		req := &http.Request{Method: "GET", URL: mustParse(t, target)}
+		if err := s.Verify(req); err != expected {
+			t.Errorf("%s: expected %v, got %v", target, expected, err)
+		}
+	}
+
+	other := NewSigner([]byte("secret"), []byte("other"))
+	if err := other.Verify(httptest.NewRequest("GET", signed, nil)); err != ErrInvalidSignature {
+		t.Errorf("Signature must depend on the salt: %v", err)
+	}
+
+	// No expiry
+	signed = s.Sign("/test.jpg", nil, time.Time{})
+	if strings.Contains(signed, "exp=") {
+		t.Errorf("Unexpected expiry: %s", signed)
+	}
+	if err := s.Verify(httptest.NewRequest("GET", signed, nil)); err != nil {
+		t.Errorf("Valid signature rejected: %s", err)
+	}
+}
+
+func TestHandlerSigner(t *testing.T) {
+	h := NewHandler(Dir("../testdata"))
+	h.Signer = NewSigner([]byte("secret"), nil)
+
+	if res := serve(h, "/test.jpg?w=100", nil); res.Code != http.StatusForbidden {
+		t.Errorf("Unsigned request must be forbidden: %d", res.Code)
+	}
+	signed := h.Signer.Sign("/test.jpg", url.Values{"w": {"100"}}, time.Now().Add(time.Minute))
+	if res := serve(h, signed, nil); res.Code != http.StatusOK {
+		t.Errorf("Signed request must be served: %d", res.Code)
+	}
+}
+
+func mustParse(t *testing.T, target string) *url.URL {
+	u, err := url.Parse(target)
+	if err != nil {
+		t.Fatalf("Invalid URL %s: %s", target, err)
+	}
+	return u
+}
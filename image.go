@@ -1,13 +1,35 @@
 package bimg
 
+import (
+	"errors"
+	"sync"
+)
+
 // Image provides a simple method DSL to transform a given image as byte buffer.
+// It is safe for concurrent use: reads such as Size or Metadata can run while
+// a transformation does, seeing the image before it. Concurrent
+// transformations all start from the current image, the last one to
+// finish replacing it.
 type Image struct {
+	mu     sync.Mutex
 	buffer []byte
+	region Region
+	handle *imageHandle
+	trace  *opTrace
 }
 
 // NewImage creates a new Image struct with method DSL.
 func NewImage(buf []byte) *Image {
-	return &Image{buf}
+	return &Image{buffer: buf}
+}
+
+// NewImageFromBufferRegion creates a new Image struct which only decodes
+// the given region of the image buffer on its next processing operation,
+// making tile servers over huge images feasible. See LoadOptions.Region.
+// Metadata, Size and the image analyses, such as Stats or BlurHash, still
+// describe the whole image buffer.
+func NewImageFromBufferRegion(buf []byte, region Region) *Image {
+	return &Image{buffer: buf, region: region}
 }
 
 // Resize resizes the image to fixed width and height.
@@ -17,7 +39,7 @@ func (i *Image) Resize(width, height int) ([]byte, error) {
 		Height: height,
 		Embed:  true,
 	}
-	return i.Process(options)
+	return i.process("Resize", options)
 }
 
 // ForceResize resizes with custom size (aspect ratio won't be maintained).
@@ -27,7 +49,7 @@ func (i *Image) ForceResize(width, height int) ([]byte, error) {
 		Height: height,
 		Force:  true,
 	}
-	return i.Process(options)
+	return i.process("ForceResize", options)
 }
 
 // ResizeAndCrop resizes the image to fixed width and height with additional crop transformation.
@@ -38,7 +60,7 @@ func (i *Image) ResizeAndCrop(width, height int) ([]byte, error) {
 		Embed:  true,
 		Crop:   true,
 	}
-	return i.Process(options)
+	return i.process("ResizeAndCrop", options)
 }
 
 // SmartCrop produces a thumbnail aiming at focus on the interesting part.
@@ -49,7 +71,113 @@ func (i *Image) SmartCrop(width, height int) ([]byte, error) {
 		Crop:    true,
 		Gravity: GravitySmart,
 	}
-	return i.Process(options)
+	return i.process("SmartCrop", options)
+}
+
+// CropToAspect crops the largest area of the image with the given aspect
+// ratio, e.g. 16:9, anchored by the gravity. GravitySmart selects the most
+// interesting area instead.
+func (i *Image) CropToAspect(ratioW, ratioH int, gravity Gravity) ([]byte, error) {
+	if ratioW <= 0 || ratioH <= 0 {
+		return nil, errors.New("Aspect ratio must be higher than zero")
+	}
+
+	metadata, err := i.Metadata()
+	if err != nil {
+		return nil, err
+	}
+	size := metadata.OrientedSize
+	i.mu.Lock()
+	if region := i.region; region != (Region{}) {
+		size = orientedSize(ImageSize{Width: region.Width, Height: region.Height}, metadata.Orientation)
+	}
+	i.mu.Unlock()
+
+	width, height := aspectCropSize(size.Width, size.Height, ratioW, ratioH)
+	options := Options{
+		Width:   width,
+		Height:  height,
+		Crop:    true,
+		Gravity: gravity,
+	}
+	return i.process("CropToAspect", options)
+}
+
+// SeamCarve reduces the image to the given size by removing its least
+// detailed paths of pixels, which keeps the main subjects undistorted on
+// aggressive aspect ratio changes, where cropping or squashing both look
+// bad. It is experimental and slow on large images, which should be scaled
+// down first: images over 4 megapixels are rejected. The image cannot be
+// enlarged.
+func (i *Image) SeamCarve(width, height int) ([]byte, error) {
+	defer i.traceOperation("SeamCarve").end()
+
+	image, err := seamCarve(i, width, height)
+	if err != nil {
+		return nil, err
+	}
+
+	i.setBuffer(image)
+	return image, nil
+}
+
+// StampQRCode draws a QR code of the content over the image, e.g. on
+// tickets and shipping labels, sized and positioned by the options.
+func (i *Image) StampQRCode(content string, o QRCodeOptions) ([]byte, error) {
+	defer i.traceOperation("StampQRCode").end()
+
+	image, err := stampQRCode(i, content, o)
+	if err != nil {
+		return nil, err
+	}
+
+	i.setBuffer(image)
+	return image, nil
+}
+
+// EmbedWatermarkDCT hides the payload in the image frequencies as an
+// invisible watermark, e.g. to trace leaked press or preview images. It
+// survives JPEG compression and small color changes, but not resizes,
+// crops nor screenshots, as it is read from the 8x8 pixel blocks aligned
+// on the top-left corner of the image at its watermarked size. Higher
+// strengths, up to 100, are more robust and visible, zero uses
+// DefaultWatermarkStrength. The image depth and metadata are kept, and it
+// is encoded with the given save options, if any.
+func (i *Image) EmbedWatermarkDCT(payload []byte, strength float64, o ...SaveOptions) ([]byte, error) {
+	defer i.traceOperation("EmbedWatermarkDCT").end()
+
+	var save SaveOptions
+	if len(o) > 0 {
+		save = o[0]
+	}
+	image, err := embedWatermarkDCT(i, payload, strength, save)
+	if err != nil {
+		return nil, err
+	}
+
+	i.setBuffer(image)
+	return image, nil
+}
+
+// RunPipeline applies the pipeline operations to the image in a single
+// libvips call, then encodes it with the given options. See Pipeline.
+func (i *Image) RunPipeline(p *Pipeline, o SaveOptions) ([]byte, error) {
+	defer i.traceOperation("RunPipeline").end()
+
+	image, err := runPipeline(i, p, o)
+	if err != nil {
+		return nil, err
+	}
+
+	i.setBuffer(image)
+	return image, nil
+}
+
+// ExtractWatermarkDCT returns the payload hidden by EmbedWatermarkDCT, or
+// an error when the image has none. Resized or cropped copies of the
+// watermarked image must be restored to its size and alignment first.
+func (i *Image) ExtractWatermarkDCT() ([]byte, error) {
+	return extractWatermarkDCT(i)
 }
 
 // Extract area from the by X/Y axis in the current image.
@@ -65,7 +193,7 @@ func (i *Image) Extract(top, left, width, height int) ([]byte, error) {
 		options.Top = -1
 	}
 
-	return i.Process(options)
+	return i.process("Extract", options)
 }
 
 // Enlarge enlarges the image by width and height. Aspect ratio is maintained.
@@ -75,7 +203,32 @@ func (i *Image) Enlarge(width, height int) ([]byte, error) {
 		Height:  height,
 		Enlarge: true,
 	}
-	return i.Process(options)
+	return i.process("Enlarge", options)
+}
+
+// CanvasResize pads or crops the image, without scaling it, to the exact
+// given size. The gravity anchors the image in the canvas, GravitySmart
+// being handled as GravityCentre, and the padding is filled with the
+// background color, opaque black by default.
+func (i *Image) CanvasResize(width, height int, gravity Gravity, background RGBAProvider) ([]byte, error) {
+	if width <= 0 || height <= 0 {
+		return nil, errors.New("Canvas width and height must be higher than zero")
+	}
+	options := Options{canvas: canvas{width: width, height: height, gravity: gravity, background: background}}
+	return i.process("CanvasResize", options)
+}
+
+// Embed resizes the image to fit width and height, then embeds it centred,
+// filling the remaining area as the embed options tell.
+func (i *Image) Embed(width, height int, o EmbedOptions) ([]byte, error) {
+	options := Options{
+		Width:      width,
+		Height:     height,
+		Embed:      true,
+		Extend:     o.Extend,
+		Background: o.Background,
+	}
+	return i.process("Embed", options)
 }
 
 // EnlargeAndCrop enlarges the image by width and height with additional crop transformation.
@@ -86,7 +239,7 @@ func (i *Image) EnlargeAndCrop(width, height int) ([]byte, error) {
 		Enlarge: true,
 		Crop:    true,
 	}
-	return i.Process(options)
+	return i.process("EnlargeAndCrop", options)
 }
 
 // Crop crops the image to the exact size specified.
@@ -97,7 +250,7 @@ func (i *Image) Crop(width, height int, gravity Gravity) ([]byte, error) {
 		Gravity: gravity,
 		Crop:    true,
 	}
-	return i.Process(options)
+	return i.process("Crop", options)
 }
 
 // CropByWidth crops an image by width only param (auto height).
@@ -106,7 +259,7 @@ func (i *Image) CropByWidth(width int) ([]byte, error) {
 		Width: width,
 		Crop:  true,
 	}
-	return i.Process(options)
+	return i.process("CropByWidth", options)
 }
 
 // CropByHeight crops an image by height (auto width).
@@ -115,7 +268,7 @@ func (i *Image) CropByHeight(height int) ([]byte, error) {
 		Height: height,
 		Crop:   true,
 	}
-	return i.Process(options)
+	return i.process("CropByHeight", options)
 }
 
 // Thumbnail creates a thumbnail of the image by the a given width by aspect ratio 4:4.
@@ -126,121 +279,516 @@ func (i *Image) Thumbnail(pixels int) ([]byte, error) {
 		Crop:    true,
 		Quality: 95,
 	}
-	return i.Process(options)
+	return i.process("Thumbnail", options)
 }
 
 // Watermark adds text as watermark on the given image.
 func (i *Image) Watermark(w Watermark) ([]byte, error) {
 	options := Options{Watermark: w}
-	return i.Process(options)
+	return i.process("Watermark", options)
 }
 
 // WatermarkImage adds image as watermark on the given image.
 func (i *Image) WatermarkImage(w WatermarkImage) ([]byte, error) {
 	options := Options{WatermarkImage: w}
-	return i.Process(options)
+	return i.process("WatermarkImage", options)
 }
 
 // Zoom zooms the image by the given factor.
 // You should probably call Extract() before.
 func (i *Image) Zoom(factor int) ([]byte, error) {
 	options := Options{Zoom: factor}
-	return i.Process(options)
+	return i.process("Zoom", options)
 }
 
 // Rotate rotates the image by given angle degrees (0, 90, 180 or 270).
 func (i *Image) Rotate(a Angle) ([]byte, error) {
 	options := Options{Rotate: a}
-	return i.Process(options)
+	return i.process("Rotate", options)
 }
 
 // AutoRotate automatically rotates the image with no additional transformation based on the EXIF oritentation metadata, if available.
 func (i *Image) AutoRotate() ([]byte, error) {
-	return i.Process(Options{autoRotateOnly: true})
+	return i.process("AutoRotate", Options{autoRotateOnly: true})
 }
 
 // Flip flips the image about the vertical Y axis.
 func (i *Image) Flip() ([]byte, error) {
 	options := Options{Flip: true}
-	return i.Process(options)
+	return i.process("Flip", options)
 }
 
 // Flop flops the image about the horizontal X axis.
 func (i *Image) Flop() ([]byte, error) {
 	options := Options{Flop: true}
-	return i.Process(options)
+	return i.process("Flop", options)
 }
 
 // Convert converts image to another format.
 func (i *Image) Convert(t ImageType) ([]byte, error) {
 	options := Options{Type: t}
-	return i.Process(options)
+	return i.process("Convert", options)
 }
 
 // Colourspace performs a color space conversion bsaed on the given interpretation.
+// Lab, LCh, XYZ and scRGB are only preserved by TIFF output, other formats
+// convert them back to sRGB on save.
 func (i *Image) Colourspace(c Interpretation) ([]byte, error) {
 	options := Options{Interpretation: c}
-	return i.Process(options)
+	return i.process("Colourspace", options)
+}
+
+// Interlace re-encodes the image as progressive JPEG or interlaced (Adam7)
+// PNG, which renders incrementally while loading over slow connections.
+func (i *Image) Interlace() ([]byte, error) {
+	options := Options{Interlace: true}
+	return i.process("Interlace", options)
 }
 
 // Trim removes the background from the picture. It can result in a 0x0 output
 // if the image is all background.
 func (i *Image) Trim() ([]byte, error) {
 	options := Options{Trim: true}
-	return i.Process(options)
+	return i.process("Trim", options)
+}
+
+// FindTrim returns the area kept by Trim, without modifying the image, so
+// it can be stored or adjusted before being applied via the Load.Region
+// option. It uses the Background, Threshold, TrimLab and TrimOptions
+// options, and is expressed in the image stored orientation.
+func (i *Image) FindTrim(o Options) (Region, error) {
+	return findTrim(i.buf(), o)
 }
 
 // Gamma returns the gamma filtered image buffer.
 func (i *Image) Gamma(exponent float64) ([]byte, error) {
 	options := Options{Gamma: exponent}
-	return i.Process(options)
+	return i.process("Gamma", options)
+}
+
+// Invert returns the negative of the image. If skipAlpha is true the
+// alpha channel, if any, is preserved as is.
+func (i *Image) Invert(skipAlpha bool) ([]byte, error) {
+	options := Options{Invert: true, InvertAlpha: !skipAlpha}
+	return i.process("Invert", options)
+}
+
+// UnsharpMask sharpens the image using the unsharp mask semantics of common
+// photo editors: radius in pixels, amount as a percentage (e.g. 150) and
+// threshold as the minimum brightness difference (0-255) to be sharpened.
+func (i *Image) UnsharpMask(radius, amount, threshold float64) ([]byte, error) {
+	options := Options{UnsharpMask: UnsharpMask{Radius: radius, Amount: amount, Threshold: threshold}}
+	return i.process("UnsharpMask", options)
+}
+
+// Median applies a median filter of the given window size (odd, in pixels),
+// removing salt and pepper noise while preserving edges.
+func (i *Image) Median(size int) ([]byte, error) {
+	options := Options{Denoise: Denoise{Size: size}}
+	return i.process("Median", options)
+}
+
+// Despeckle removes small specks and dust, typically found in scanned
+// documents, using a 3x3 median filter.
+func (i *Image) Despeckle() ([]byte, error) {
+	options := Options{Denoise: Denoise{Size: 3}}
+	return i.process("Despeckle", options)
+}
+
+// Denoise reduces the image noise based on the given options.
+func (i *Image) Denoise(d Denoise) ([]byte, error) {
+	options := Options{Denoise: d}
+	return i.process("Denoise", options)
+}
+
+// Sobel returns a greyscale edge map of the image using the Sobel operator.
+// Requires libvips 8.9+.
+func (i *Image) Sobel() ([]byte, error) {
+	options := Options{EdgeDetection: EdgeDetection{Detector: EdgeSobel}}
+	return i.process("Sobel", options)
+}
+
+// Canny returns a greyscale edge map of the image using the Canny algorithm
+// with the given gaussian smoothing sigma. Requires libvips 8.9+.
+func (i *Image) Canny(sigma float64) ([]byte, error) {
+	options := Options{EdgeDetection: EdgeDetection{Detector: EdgeCanny, Sigma: sigma}}
+	return i.process("Canny", options)
+}
+
+// Vignette fades the image corners into the given colour.
+func (i *Image) Vignette(v Vignette) ([]byte, error) {
+	options := Options{Vignette: v}
+	return i.process("Vignette", options)
+}
+
+// Pixelate pixelates the given area of the image in square blocks of
+// blockSize pixels, e.g. to redact faces or license plates found by a
+// detector. The area is relative to the auto-rotated image.
+func (i *Image) Pixelate(region Region, blockSize int) ([]byte, error) {
+	options := Options{Pixelate: Pixelate{Region: region, BlockSize: blockSize}}
+	return i.process("Pixelate", options)
+}
+
+// PixelateAll pixelates the whole image in square blocks of blockSize
+// pixels, for a mosaic effect.
+func (i *Image) PixelateAll(blockSize int) ([]byte, error) {
+	options := Options{Pixelate: Pixelate{BlockSize: blockSize}}
+	return i.process("PixelateAll", options)
+}
+
+// BlurRegion applies a gaussian blur of the given sigma to an area of the
+// image only, e.g. to blur faces found by a detector. The area is relative
+// to the auto-rotated image.
+func (i *Image) BlurRegion(sigma float64, region Region) ([]byte, error) {
+	options := Options{GaussianBlur: GaussianBlur{Sigma: sigma, Region: region}}
+	return i.process("BlurRegion", options)
+}
+
+// BlurMask applies a gaussian blur of the given sigma where the mask image
+// is light, e.g. to blur a background. The mask is scaled to the image size
+// and its grey areas blend the blurred and original pixels.
+func (i *Image) BlurMask(sigma float64, mask *Image) ([]byte, error) {
+	options := Options{GaussianBlur: GaussianBlur{Sigma: sigma, Mask: mask.Image()}}
+	return i.process("BlurMask", options)
+}
+
+// MotionBlur smears the image along a line of distance pixels at the given
+// angle, in degrees counter-clockwise from the horizontal axis. The
+// distance is relative to the auto-rotated image.
+func (i *Image) MotionBlur(angle float64, distance int) ([]byte, error) {
+	options := Options{MotionBlur: MotionBlur{Angle: angle, Distance: distance}}
+	return i.process("MotionBlur", options)
+}
+
+// RadialBlur smears the image outwards from the center point, relative to
+// the auto-rotated image, for a zoom effect. The amount (0-1) is the length
+// of the smear as a fraction of the distance to the center.
+func (i *Image) RadialBlur(center Point, amount float64) ([]byte, error) {
+	options := Options{RadialBlur: RadialBlur{Center: center, Amount: amount}}
+	return i.process("RadialBlur", options)
+}
+
+// AddGrain adds film grain noise to the image, e.g. to match denoised or
+// generated images with photographic footage.
+func (i *Image) AddGrain(opts GrainOptions) ([]byte, error) {
+	options := Options{Grain: opts}
+	return i.process("AddGrain", options)
+}
+
+// RoundCorners makes the image corners transparent using the given radius
+// in pixels. JPEG images are converted to PNG to preserve the transparency.
+func (i *Image) RoundCorners(radius int) ([]byte, error) {
+	options := Options{CornerRadius: radius}
+	return i.process("RoundCorners", options)
+}
+
+// AddBorder adds a border of the given widths around the image, filled
+// with the background color for ExtendBackground, or by extending the
+// image edges for the other extend modes.
+func (i *Image) AddBorder(top, right, bottom, left int, background RGBAProvider, extend Extend) ([]byte, error) {
+	options := Options{Border: Border{Top: top, Right: right, Bottom: bottom, Left: left, Background: background, Extend: extend}}
+	return i.process("AddBorder", options)
+}
+
+// CircleMask crops the image to a centred square and makes everything outside
+// of the inscribed circle transparent, as commonly used for avatars.
+// JPEG images are converted to PNG to preserve the transparency.
+func (i *Image) CircleMask() ([]byte, error) {
+	options := Options{Circle: true}
+	return i.process("CircleMask", options)
+}
+
+// AddAlpha adds an opaque alpha channel to the image, if it has none.
+// JPEG images are converted to PNG to preserve the alpha channel.
+func (i *Image) AddAlpha() ([]byte, error) {
+	options := Options{AddAlpha: true}
+	return i.process("AddAlpha", options)
+}
+
+// ExtractAlpha returns a new greyscale PNG image with the alpha channel of
+// the current image. Images without alpha channel produce a fully opaque
+// (white) mask. The current image is not modified.
+func (i *Image) ExtractAlpha() (*Image, error) {
+	defer i.traceOperation("ExtractAlpha").end()
+
+	buf, err := Resize(i.buf(), Options{ExtractAlpha: true, Type: PNG, Interpretation: InterpretationBW})
+	if err != nil {
+		return nil, err
+	}
+	return NewImage(buf), nil
+}
+
+// ApplyAlphaMask replaces the image alpha channel with the luminance of the
+// given mask image, such as the output of a segmentation model. The mask is
+// scaled to the image size when needed.
+func (i *Image) ApplyAlphaMask(mask *Image) ([]byte, error) {
+	options := Options{AlphaMask: mask.Image()}
+	return i.process("ApplyAlphaMask", options)
+}
+
+// ToneMap converts a high dynamic range image, such as OpenEXR or Radiance
+// HDR, into a displayable 8-bit image.
+func (i *Image) ToneMap(t ToneMap) ([]byte, error) {
+	options := Options{ToneMap: t}
+	return i.process("ToneMap", options)
+}
+
+// ExtractBand keeps n consecutive bands (channels) of the image starting at
+// the given zero-based index. Less than three bands are saved as greyscale.
+func (i *Image) ExtractBand(index, n int) ([]byte, error) {
+	if n <= 0 {
+		n = 1
+	}
+	options := Options{ExtractBand: ExtractBand{Index: index, N: n}}
+	if n < 3 {
+		options.Interpretation = InterpretationBW
+	}
+	return i.process("ExtractBand", options)
+}
+
+// Premultiply multiplies the colour channels by the alpha channel, as
+// expected by compositing tools working with premultiplied alpha.
+func (i *Image) Premultiply() ([]byte, error) {
+	options := Options{Premultiply: true}
+	return i.process("Premultiply", options)
+}
+
+// Unpremultiply divides the colour channels by the alpha channel, reverting
+// a previous Premultiply.
+func (i *Image) Unpremultiply() ([]byte, error) {
+	options := Options{Unpremultiply: true}
+	return i.process("Unpremultiply", options)
+}
+
+// RemoveBackground makes the image background transparent using the alpha
+// mask computed by the given provider.
+func (i *Image) RemoveBackground(p MaskProvider) ([]byte, error) {
+	mask, err := p.Mask(i)
+	if err != nil {
+		return nil, err
+	}
+	options := Options{AlphaMask: mask.Image()}
+	return i.process("RemoveBackground", options)
+}
+
+// Threshold converts the image into black and white, where every pixel
+// brighter than the given value (0-255) becomes white.
+func (i *Image) Threshold(value float64) ([]byte, error) {
+	options := Options{Binarize: Binarize{Enabled: true, Threshold: value}}
+	return i.process("Threshold", options)
+}
+
+// AdaptiveThreshold converts the image into black and white comparing every
+// pixel against the mean of its neighbourhood of the given radius minus offset.
+// It produces cleaner results than Threshold on unevenly lit documents.
+func (i *Image) AdaptiveThreshold(radius int, offset float64) ([]byte, error) {
+	options := Options{Binarize: Binarize{Adaptive: true, Radius: radius, Offset: offset}}
+	return i.process("AdaptiveThreshold", options)
 }
 
 // Process processes the image based on the given transformation options,
 // talking with libvips bindings accordingly and returning the resultant
 // image buffer.
 func (i *Image) Process(o Options) ([]byte, error) {
-	image, err := Resize(i.buffer, o)
+	return i.process("Process", o)
+}
+
+// process runs Process, tracing it as the given Image method.
+func (i *Image) process(operation string, o Options) ([]byte, error) {
+	i.mu.Lock()
+	buf, region, trace := i.buffer, i.region, i.trace
+	i.mu.Unlock()
+
+	if o.Load.Region == (Region{}) {
+		o.Load.Region = region
+	}
+	if trace != nil {
+		o.trace = trace.begin(operation)
+		defer o.trace.end()
+	}
+	image, err := Resize(buf, o)
 	if err != nil {
 		return nil, err
 	}
-	i.buffer = image
+
+	i.setBuffer(image)
 	return image, nil
 }
 
+// SetTrace enables or disables the tracing of the image operations based
+// on Process, clearing the recorded spans. Traced operations evaluate every
+// pipeline step in memory, so the time and memory of decoding, resizing
+// and encoding can be told apart, at the cost of a slower processing.
+// The lossless JPEG transforms and the operations not based on Process,
+// such as SaveAll, MultiResize or RunPipeline, only record the span of the
+// whole operation. The analyses, such as Metadata or Stats, are not
+// traced. The last 1000 spans are kept.
+func (i *Image) SetTrace(enabled bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.trace = nil
+	if enabled {
+		i.trace = &opTrace{}
+	}
+}
+
+// Trace returns the spans recorded since tracing was enabled, in order, up
+// to the last 1000.
+func (i *Image) Trace() []TraceSpan {
+	i.mu.Lock()
+	trace := i.trace
+	i.mu.Unlock()
+	if trace == nil {
+		return nil
+	}
+
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	return append([]TraceSpan(nil), trace.spans...)
+}
+
 // Metadata returns the image metadata (size, alpha channel, profile, EXIF rotation).
 func (i *Image) Metadata() (ImageMetadata, error) {
-	return Metadata(i.buffer)
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	h, err := i.header()
+	if err != nil {
+		return ImageMetadata{}, err
+	}
+	return h.metadata(), nil
+}
+
+// DominantColors returns up to n of the most representative colors of the
+// image, sorted by frequency. Useful for placeholder backgrounds or themes.
+func (i *Image) DominantColors(n int) ([]RGBA, error) {
+	return dominantColors(i.buf(), n)
+}
+
+// BlurHash returns the BlurHash placeholder of the image, using the given
+// number of horizontal and vertical components (between 1 and 9).
+func (i *Image) BlurHash(xComp, yComp int) (string, error) {
+	return blurHash(i.buf(), xComp, yComp)
+}
+
+// ThumbHash returns the ThumbHash placeholder of the image, which also
+// encodes the aspect ratio and alpha channel.
+func (i *Image) ThumbHash() ([]byte, error) {
+	return thumbHash(i.buf())
+}
+
+// PerceptualHash returns a 64-bit hash of the image contents which stays
+// similar for similar images. Compare them with HammingDistance.
+func (i *Image) PerceptualHash(algo HashAlgo) (uint64, error) {
+	return perceptualHash(i.buf(), algo)
+}
+
+// SharpnessScore measures how sharp the image is, as the variance of its
+// laplacian. Blurry images score low, usually below 100. The image is scaled
+// down to 1000 pixels first, so scores are comparable across sizes.
+func (i *Image) SharpnessScore() (float64, error) {
+	return sharpnessScore(i.buf())
+}
+
+// Stats returns the per band statistics (min, max, mean and standard
+// deviation) and the luminance entropy of the image.
+func (i *Image) Stats() (ImageStats, error) {
+	return imageStats(i.buf())
+}
+
+// SaveDZ generates a Deep Zoom, Zoomify, Google Maps or IIIF tile pyramid
+// from the image. The pyramid is written to the options path, or returned
+// as a zip archive when no path is given.
+func (i *Image) SaveDZ(o DeepZoomOptions) ([]byte, error) {
+	defer i.traceOperation("SaveDZ").end()
+	buf, region := i.source()
+	return saveDeepZoom(buf, region, o)
+}
+
+// SaveAll encodes the image once per given save options, e.g. to AVIF,
+// WebP and JPEG, decoding it only once. Outputs are returned in order.
+func (i *Image) SaveAll(opts ...SaveOptions) ([][]byte, error) {
+	defer i.traceOperation("SaveAll").end()
+	buf, region := i.source()
+	return saveAll(buf, region, opts)
+}
+
+// MultiResize encodes the image at every given size, decoding it only once
+// to the largest size required, from which every output is resized.
+// Outputs are returned in order. Only the image region is decoded, as in
+// Process.
+func (i *Image) MultiResize(sizes []ResizeOptions) ([][]byte, error) {
+	defer i.traceOperation("MultiResize").end()
+
+	buf, region := i.source()
+	return multiResize(buf, region, sizes)
 }
 
 // Interpretation gets the image interpretation type.
 // See: https://libvips.github.io/libvips/API/current/VipsImage.html#VipsInterpretation
 func (i *Image) Interpretation() (Interpretation, error) {
-	return ImageInterpretation(i.buffer)
+	return ImageInterpretation(i.buf())
 }
 
 // ColourspaceIsSupported checks if the current image
 // color space is supported.
 func (i *Image) ColourspaceIsSupported() (bool, error) {
-	return ColourspaceIsSupported(i.buffer)
+	return ColourspaceIsSupported(i.buf())
 }
 
 // Type returns the image type format (jpeg, png, webp, tiff).
 func (i *Image) Type() string {
-	return DetermineImageTypeName(i.buffer)
+	return DetermineImageTypeName(i.buf())
 }
 
 // Size returns the image size as form of width and height pixels.
 func (i *Image) Size() (ImageSize, error) {
-	return Size(i.buffer)
+	metadata, err := i.Metadata()
+	if err != nil {
+		return ImageSize{}, err
+	}
+	return metadata.Size, nil
+}
+
+// Close releases the libvips resources held by the image, loaded to read
+// its metadata. They are otherwise released once the image is garbage
+// collected. The image can still be used afterwards.
+func (i *Image) Close() {
+	i.mu.Lock()
+	i.release()
+	i.mu.Unlock()
 }
 
 // Image returns the current resultant image buffer.
 func (i *Image) Image() []byte {
+	return i.buf()
+}
+
+// buf returns the current image buffer.
+func (i *Image) buf() []byte {
+	i.mu.Lock()
+	defer i.mu.Unlock()
 	return i.buffer
 }
 
+// source returns the current image buffer and the region to decode.
+func (i *Image) source() ([]byte, Region) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.buffer, i.region
+}
+
+// setBuffer replaces the image buffer with an operation output, which
+// covers the whole image and invalidates the loaded libvips image.
+func (i *Image) setBuffer(buf []byte) {
+	i.mu.Lock()
+	i.buffer = buf
+	i.region = Region{}
+	i.release()
+	i.mu.Unlock()
+}
+
 // Length returns the size in bytes of the image buffer.
 func (i *Image) Length() int {
-	return len(i.buffer)
+	return len(i.buf())
 }
@@ -1,8 +1,14 @@
 package bimg
 
 import (
+	"bytes"
 	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
 	"path"
+	"sync"
 	"testing"
 )
 
@@ -278,6 +284,54 @@ func TestImageWatermarkWithImage(t *testing.T) {
 	Write("testdata/test_watermark_image_out.jpg", buf)
 }
 
+func TestImageWatermarkImageAlphaBlend(t *testing.T) {
+	base, _ := NewSolid(100, 100, RGBA{})
+	logo, _ := NewSolid(50, 50, RGBA{R: 255, A: 128})
+
+	buf, err := base.WatermarkImage(WatermarkImage{Left: 25, Top: 25, Buf: logo.Image(), Opacity: 0.5, AlphaBlend: true})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	decoded, err := png.Decode(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatalf("Cannot decode the image: %s", err)
+	}
+
+	// The logo alpha is multiplied by the opacity, keeping its color
+	inside := color.NRGBAModel.Convert(decoded.At(50, 50)).(color.NRGBA)
+	if inside.R < 250 || inside.G > 5 || inside.A < 62 || inside.A > 66 {
+		t.Errorf("Invalid blended pixel: %#v", inside)
+	}
+	if outside := color.NRGBAModel.Convert(decoded.At(10, 10)).(color.NRGBA); outside.A != 0 {
+		t.Errorf("Invalid transparent pixel: %#v", outside)
+	}
+	Write("testdata/test_watermark_alpha_blend_out.png", buf)
+}
+
+func TestImageWatermarkImagePercent(t *testing.T) {
+	base, _ := NewSolid(400, 200, RGBA{R: 255, G: 255, B: 255, A: 255})
+	logo, _ := NewSolid(200, 100, RGBA{R: 255, A: 255})
+
+	buf, err := base.WatermarkImage(WatermarkImage{Buf: logo.Image(), WidthPercent: 25})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	decoded, err := png.Decode(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatalf("Cannot decode the image: %s", err)
+	}
+
+	// The logo is resized to 100x50
+	gray := func(x, y int) uint8 { return color.GrayModel.Convert(decoded.At(x, y)).(color.Gray).Y }
+	if gray(95, 45) == 255 || gray(105, 45) != 255 || gray(95, 55) != 255 {
+		t.Error("Invalid watermark size")
+	}
+
+	if _, err := base.WatermarkImage(WatermarkImage{Buf: logo.Image(), WidthPercent: 120}); err == nil {
+		t.Error("Expected an error for an invalid percentage")
+	}
+}
+
 func TestImageWatermarkNoReplicate(t *testing.T) {
 	image := initImage("test.jpg")
 	_, err := image.Crop(800, 600, GravityNorth)
@@ -362,15 +416,26 @@ func TestImageAutoRotate(t *testing.T) {
 
 	tests := []struct {
 		file        string
-		orientation int
+		orientation ExifOrientation
+		width       int
+		height      int
 	}{
-		{"exif/Landscape_1.jpg", 1},
-		{"exif/Landscape_2.jpg", 1},
-		{"exif/Landscape_3.jpg", 1},
-		{"exif/Landscape_4.jpg", 1},
-		{"exif/Landscape_5.jpg", 1},
-		{"exif/Landscape_6.jpg", 1},
-		{"exif/Landscape_7.jpg", 1},
+		{"exif/Landscape_1.jpg", 1, 1600, 1200},
+		{"exif/Landscape_2.jpg", 1, 1600, 1200},
+		{"exif/Landscape_3.jpg", 1, 1600, 1200},
+		{"exif/Landscape_4.jpg", 1, 1600, 1200},
+		{"exif/Landscape_5.jpg", 1, 1600, 1200},
+		{"exif/Landscape_6.jpg", 1, 1600, 1200},
+		{"exif/Landscape_7.jpg", 1, 1600, 1200},
+		{"exif/Landscape_8.jpg", 1, 1600, 1200},
+		{"exif/Portrait_1.jpg", 1, 1200, 1600},
+		{"exif/Portrait_2.jpg", 1, 1200, 1600},
+		{"exif/Portrait_3.jpg", 1, 1200, 1600},
+		{"exif/Portrait_4.jpg", 1, 1200, 1600},
+		{"exif/Portrait_5.jpg", 1, 1200, 1600},
+		{"exif/Portrait_6.jpg", 1, 1200, 1600},
+		{"exif/Portrait_7.jpg", 1, 1200, 1600},
+		{"exif/Portrait_8.jpg", 1, 1200, 1600},
 	}
 
 	for index, test := range tests {
@@ -388,6 +453,30 @@ func TestImageAutoRotate(t *testing.T) {
 		if meta.Orientation != test.orientation {
 			t.Errorf("Invalid image orientation for %s: %d != %d", test.file, meta.Orientation, test.orientation)
 		}
+		if meta.Size.Width != test.width || meta.Size.Height != test.height {
+			t.Errorf("Invalid image size for %s: %dx%d", test.file, meta.Size.Width, meta.Size.Height)
+		}
+	}
+}
+
+func TestImageResizeResetsOrientation(t *testing.T) {
+	for orientation := 2; orientation <= 8; orientation++ {
+		file := fmt.Sprintf("exif/Landscape_%d.jpg", orientation)
+		buf, err := initImage(file).Resize(800, 600)
+		if err != nil {
+			t.Errorf("Cannot process the image: %#v", err)
+		}
+
+		meta, err := Metadata(buf)
+		if err != nil {
+			t.Errorf("Cannot read image metadata: %#v", err)
+		}
+		if meta.Orientation > 1 {
+			t.Errorf("Invalid image orientation for %s: %d", file, meta.Orientation)
+		}
+		if meta.Size.Width != 800 || meta.Size.Height != 600 {
+			t.Errorf("Invalid image size for %s: %dx%d", file, meta.Size.Width, meta.Size.Height)
+		}
 	}
 }
 
@@ -577,6 +666,1164 @@ func TestImageLength(t *testing.T) {
 	}
 }
 
+func TestImageInvert(t *testing.T) {
+	buf, err := initImage("test.jpg").Invert(true)
+	if err != nil {
+		t.Errorf("Cannot process the image: %#v", err)
+	}
+
+	err = assertSize(buf, 1680, 1050)
+	if err != nil {
+		t.Error(err)
+	}
+
+	Write("testdata/test_invert_out.jpg", buf)
+}
+
+func TestImageInvertSkipAlpha(t *testing.T) {
+	buf, err := initImage("transparent.png").Invert(true)
+	if err != nil {
+		t.Errorf("Cannot process the image: %#v", err)
+	}
+
+	meta, err := Metadata(buf)
+	if err != nil {
+		t.Errorf("Cannot read image metadata: %#v", err)
+	}
+	if !meta.Alpha {
+		t.Error("The alpha channel was not preserved")
+	}
+
+	Write("testdata/test_invert_alpha_out.png", buf)
+}
+
+func TestImageInvertAlpha(t *testing.T) {
+	image := initImage("transparent.png")
+	buf, err := image.Invert(false)
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+
+	in, err := image.Stats()
+	if err != nil {
+		t.Fatalf("Cannot read the image stats: %#v", err)
+	}
+	out, err := NewImage(buf).Stats()
+	if err != nil {
+		t.Fatalf("Cannot read the image stats: %#v", err)
+	}
+	if len(out.Bands) != 4 {
+		t.Fatalf("The alpha channel was not preserved: %d bands", len(out.Bands))
+	}
+
+	// The inverted alpha channel swaps the opaque and transparent areas
+	alpha, inverted := in.Bands[3], out.Bands[3]
+	if inverted.Min != 255-alpha.Max || inverted.Max != 255-alpha.Min || math.Abs(inverted.Mean-(255-alpha.Mean)) > 0.5 {
+		t.Errorf("The alpha channel was not inverted: %#v, from %#v", inverted, alpha)
+	}
+
+	Write("testdata/test_invert_with_alpha_out.png", buf)
+}
+
+func TestImageThreshold(t *testing.T) {
+	buf, err := initImage("test.jpg").Threshold(128)
+	if err != nil {
+		t.Errorf("Cannot process the image: %#v", err)
+	}
+
+	err = assertSize(buf, 1680, 1050)
+	if err != nil {
+		t.Error(err)
+	}
+
+	Write("testdata/test_threshold_out.jpg", buf)
+}
+
+func TestImageThresholdZero(t *testing.T) {
+	// A zero threshold is applied rather than ignored
+	buf, err := initImage("test.jpg").Threshold(0)
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+
+	metadata, err := Metadata(buf)
+	if err != nil {
+		t.Fatalf("Cannot read the metadata: %#v", err)
+	}
+	if metadata.Channels != 1 {
+		t.Errorf("Invalid number of channels: %d", metadata.Channels)
+	}
+}
+
+func TestImageAdaptiveThreshold(t *testing.T) {
+	buf, err := initImage("test.jpg").AdaptiveThreshold(10, 5)
+	if err != nil {
+		t.Errorf("Cannot process the image: %#v", err)
+	}
+
+	err = assertSize(buf, 1680, 1050)
+	if err != nil {
+		t.Error(err)
+	}
+
+	Write("testdata/test_adaptive_threshold_out.jpg", buf)
+}
+
+func TestImageUnsharpMask(t *testing.T) {
+	buf, err := initImage("test.jpg").UnsharpMask(1.5, 150, 3)
+	if err != nil {
+		t.Errorf("Cannot process the image: %#v", err)
+	}
+
+	err = assertSize(buf, 1680, 1050)
+	if err != nil {
+		t.Error(err)
+	}
+
+	Write("testdata/test_unsharp_mask_out.jpg", buf)
+}
+
+func TestImageMedian(t *testing.T) {
+	buf, err := initImage("test.jpg").Median(5)
+	if err != nil {
+		t.Errorf("Cannot process the image: %#v", err)
+	}
+
+	err = assertSize(buf, 1680, 1050)
+	if err != nil {
+		t.Error(err)
+	}
+
+	Write("testdata/test_median_out.jpg", buf)
+}
+
+func TestImageDenoise(t *testing.T) {
+	buf, err := initImage("test.jpg").Denoise(Denoise{Size: 3, Passes: 2, Sigma: 0.5})
+	if err != nil {
+		t.Errorf("Cannot process the image: %#v", err)
+	}
+
+	err = assertSize(buf, 1680, 1050)
+	if err != nil {
+		t.Error(err)
+	}
+
+	Write("testdata/test_denoise_out.jpg", buf)
+}
+
+func TestImageSobel(t *testing.T) {
+	if VipsMajorVersion <= 8 && VipsMinorVersion < 9 {
+		t.Skip("Skip test in libvips < 8.9")
+	}
+
+	buf, err := initImage("test.jpg").Sobel()
+	if err != nil {
+		t.Errorf("Cannot process the image: %#v", err)
+	}
+
+	err = assertSize(buf, 1680, 1050)
+	if err != nil {
+		t.Error(err)
+	}
+
+	Write("testdata/test_sobel_out.jpg", buf)
+}
+
+func TestImageCanny(t *testing.T) {
+	if VipsMajorVersion <= 8 && VipsMinorVersion < 9 {
+		t.Skip("Skip test in libvips < 8.9")
+	}
+
+	buf, err := initImage("test.jpg").Canny(1.4)
+	if err != nil {
+		t.Errorf("Cannot process the image: %#v", err)
+	}
+
+	err = assertSize(buf, 1680, 1050)
+	if err != nil {
+		t.Error(err)
+	}
+
+	Write("testdata/test_canny_out.jpg", buf)
+}
+
+func TestImageVignette(t *testing.T) {
+	buf, err := initImage("test.jpg").Vignette(Vignette{Strength: 0.8, Radius: 0.4})
+	if err != nil {
+		t.Errorf("Cannot process the image: %#v", err)
+	}
+
+	err = assertSize(buf, 1680, 1050)
+	if err != nil {
+		t.Error(err)
+	}
+
+	Write("testdata/test_vignette_out.jpg", buf)
+}
+
+func TestImageVignetteWithAlpha(t *testing.T) {
+	buf, err := initImage("transparent.png").Vignette(Vignette{Strength: 1, Color: Color{255, 255, 255}})
+	if err != nil {
+		t.Errorf("Cannot process the image: %#v", err)
+	}
+
+	meta, err := Metadata(buf)
+	if err != nil {
+		t.Errorf("Cannot read image metadata: %#v", err)
+	}
+	if !meta.Alpha {
+		t.Error("The alpha channel was not preserved")
+	}
+
+	Write("testdata/test_vignette_alpha_out.png", buf)
+}
+
+func TestImageRoundCorners(t *testing.T) {
+	buf, err := initImage("test.jpg").RoundCorners(100)
+	if err != nil {
+		t.Errorf("Cannot process the image: %#v", err)
+	}
+
+	if DetermineImageType(buf) != PNG {
+		t.Fatal("Image is not png")
+	}
+
+	meta, err := Metadata(buf)
+	if err != nil {
+		t.Errorf("Cannot read image metadata: %#v", err)
+	}
+	if !meta.Alpha {
+		t.Error("Image has no alpha channel")
+	}
+	if meta.Size.Width != 1680 || meta.Size.Height != 1050 {
+		t.Errorf("Invalid image size: %dx%d", meta.Size.Width, meta.Size.Height)
+	}
+
+	Write("testdata/test_round_corners_out.png", buf)
+}
+
+func TestImageAddBorder(t *testing.T) {
+	tests := []struct {
+		background RGBAProvider
+		extend     Extend
+		alpha      bool
+	}{
+		{Color{255, 255, 255}, ExtendBackground, false},
+		{RGBA{255, 0, 0, 128}, ExtendBackground, true},
+		{nil, ExtendMirror, false},
+	}
+
+	for _, test := range tests {
+		buf, err := initImage("test.jpg").AddBorder(10, 20, 30, 40, test.background, test.extend)
+		if err != nil {
+			t.Fatalf("Cannot process the image: %#v", err)
+		}
+
+		meta, err := Metadata(buf)
+		if err != nil {
+			t.Fatalf("Cannot read image metadata: %#v", err)
+		}
+		if meta.Size.Width != 1740 || meta.Size.Height != 1090 {
+			t.Errorf("Invalid image size: %dx%d", meta.Size.Width, meta.Size.Height)
+		}
+		if meta.Alpha != test.alpha {
+			t.Errorf("Unexpected alpha channel for %#v: %t", test.background, meta.Alpha)
+		}
+	}
+
+	if _, err := initImage("test.jpg").AddBorder(-1, 0, 0, 0, nil, ExtendBlack); err == nil {
+		t.Error("Expected an error for a negative border")
+	}
+}
+
+func TestImageCanvasResize(t *testing.T) {
+	tests := []struct {
+		width, height int
+		gravity       Gravity
+		background    RGBAProvider
+		alpha         bool
+	}{
+		{1080, 1080, GravityCentre, Color{255, 255, 255}, false},
+		{2000, 1200, GravityNorth, nil, false},
+		{1080, 1200, GravityEast, RGBA{0, 0, 0, 0}, true},
+		{1680, 1050, GravitySmart, nil, false},
+	}
+
+	for _, test := range tests {
+		buf, err := initImage("test.jpg").CanvasResize(test.width, test.height, test.gravity, test.background)
+		if err != nil {
+			t.Fatalf("Cannot process the image: %#v", err)
+		}
+
+		meta, err := Metadata(buf)
+		if err != nil {
+			t.Fatalf("Cannot read image metadata: %#v", err)
+		}
+		if meta.Size.Width != test.width || meta.Size.Height != test.height {
+			t.Errorf("Invalid image size: %dx%d", meta.Size.Width, meta.Size.Height)
+		}
+		if meta.Alpha != test.alpha {
+			t.Errorf("Unexpected alpha channel for %#v: %t", test.background, meta.Alpha)
+		}
+	}
+
+	if _, err := initImage("test.jpg").CanvasResize(0, 100, GravityCentre, nil); err == nil {
+		t.Error("Expected an error for an empty canvas")
+	}
+}
+
+func TestImageCropToAspect(t *testing.T) {
+	tests := []struct {
+		file          string
+		ratioW        int
+		ratioH        int
+		gravity       Gravity
+		width, height int
+	}{
+		{"test.jpg", 1, 1, GravityCentre, 1050, 1050},
+		{"test.jpg", 16, 9, GravityNorth, 1680, 945},
+		{"test.jpg", 9, 16, GravitySmart, 590, 1050},
+		{"exif/Portrait_6.jpg", 1, 1, GravityCentre, 1200, 1200},
+	}
+
+	for _, test := range tests {
+		buf, err := initImage(test.file).CropToAspect(test.ratioW, test.ratioH, test.gravity)
+		if err != nil {
+			t.Fatalf("Cannot process the image: %#v", err)
+		}
+		if err := assertSize(buf, test.width, test.height); err != nil {
+			t.Errorf("%s %d:%d: %s", test.file, test.ratioW, test.ratioH, err)
+		}
+	}
+
+	if _, err := initImage("test.jpg").CropToAspect(0, 1, GravityCentre); err == nil {
+		t.Error("Expected an error for an invalid aspect ratio")
+	}
+}
+
+func TestImagePixelate(t *testing.T) {
+	region := Region{Left: 40, Top: 40, Width: 160, Height: 80}
+	buf, err := initImage("test.png").Pixelate(region, 20)
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if err := assertSize(buf, 400, 300); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatalf("Cannot decode the image: %s", err)
+	}
+	assertUniformBlock(t, img, image.Rect(40, 40, 60, 60))
+	assertUniformBlock(t, img, image.Rect(180, 100, 200, 120))
+
+	buf, err = initImage("test.png").PixelateAll(25)
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	img, err = png.Decode(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatalf("Cannot decode the image: %s", err)
+	}
+	assertUniformBlock(t, img, image.Rect(375, 275, 400, 300))
+}
+
+func TestImagePixelateResize(t *testing.T) {
+	// The region and the blocks are given in the source image pixels
+	o := Options{Width: 200, Pixelate: Pixelate{Region: Region{Left: 40, Top: 40, Width: 160, Height: 80}, BlockSize: 40}}
+	buf, err := initImage("test.png").Process(o)
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if err := assertSize(buf, 200, 150); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatalf("Cannot decode the image: %s", err)
+	}
+	assertUniformBlock(t, img, image.Rect(26, 26, 34, 34))
+	assertUniformBlock(t, img, image.Rect(86, 46, 94, 54))
+}
+
+func TestImageBlurRegion(t *testing.T) {
+	region := Region{Left: 100, Top: 100, Width: 100, Height: 100}
+	buf, err := initImage("test.png").BlurRegion(10, region)
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if err := assertSize(buf, 400, 300); err != nil {
+		t.Fatal(err)
+	}
+
+	original, err := png.Decode(bytes.NewReader(readFile("test.png")))
+	if err != nil {
+		t.Fatalf("Cannot decode the image: %s", err)
+	}
+	img, err := png.Decode(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatalf("Cannot decode the image: %s", err)
+	}
+	for _, p := range []image.Point{{10, 10}, {99, 150}, {250, 250}} {
+		if img.At(p.X, p.Y) != original.At(p.X, p.Y) {
+			t.Errorf("Pixel %v outside of the region has changed", p)
+		}
+	}
+}
+
+func TestImageBlurMask(t *testing.T) {
+	mask, err := NewImage(readFile("test.png")).Process(Options{Width: 100, Height: 100, Force: true})
+	if err != nil {
+		t.Fatalf("Cannot process the mask: %#v", err)
+	}
+
+	buf, err := initImage("test.jpg").BlurMask(5, NewImage(mask))
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if err := assertSize(buf, 1680, 1050); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImageMotionBlur(t *testing.T) {
+	buf, err := initImage("test.jpg").MotionBlur(30, 15)
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if err := assertSize(buf, 1680, 1050); err != nil {
+		t.Fatal(err)
+	}
+	Write("testdata/test_motion_blur_out.jpg", buf)
+
+	if _, err := initImage("test.jpg").MotionBlur(30, 1000); err == nil {
+		t.Error("Expected an error for a long distance")
+	}
+
+	// The distance is relative to the source image, not the thumbnail
+	buf, err = initImage("test.jpg").Process(Options{Width: 10, MotionBlur: MotionBlur{Distance: 100}})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if err := assertSize(buf, 10, 6); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestImageRadialBlur(t *testing.T) {
+	buf, err := initImage("test.png").RadialBlur(Point{X: 200, Y: 150}, 0.2)
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if err := assertSize(buf, 400, 300); err != nil {
+		t.Fatal(err)
+	}
+	Write("testdata/test_radial_blur_out.png", buf)
+}
+
+func TestImageAddGrain(t *testing.T) {
+	for _, opts := range []GrainOptions{
+		{Amount: 8},
+		{Amount: 12, Monochrome: true, Size: 3},
+	} {
+		buf, err := initImage("test.png").AddGrain(opts)
+		if err != nil {
+			t.Fatalf("Cannot process the image: %#v", err)
+		}
+		if err := assertSize(buf, 400, 300); err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Equal(buf, readFile("test.png")) {
+			t.Errorf("No grain was added with %#v", opts)
+		}
+	}
+}
+
+func TestImageBlurRegionResize(t *testing.T) {
+	// The region is given in the source image pixels
+	blur := GaussianBlur{Sigma: 10, Region: Region{Left: 100, Top: 100, Width: 100, Height: 100}}
+	buf, err := initImage("test.png").Process(Options{Width: 200, GaussianBlur: blur})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	resized, err := initImage("test.png").Process(Options{Width: 200})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatalf("Cannot decode the image: %s", err)
+	}
+	original, err := png.Decode(bytes.NewReader(resized))
+	if err != nil {
+		t.Fatalf("Cannot decode the image: %s", err)
+	}
+	for _, p := range []image.Point{{10, 10}, {150, 20}, {20, 130}} {
+		if img.At(p.X, p.Y) != original.At(p.X, p.Y) {
+			t.Errorf("Pixel %v outside of the region has changed", p)
+		}
+	}
+	changed := 0
+	for y := 50; y < 100; y++ {
+		for x := 50; x < 100; x++ {
+			if img.At(x, y) != original.At(x, y) {
+				changed++
+			}
+		}
+	}
+	if changed == 0 {
+		t.Error("The region has not been blurred")
+	}
+}
+
+func assertUniformBlock(t *testing.T, img image.Image, block image.Rectangle) {
+	expected := img.At(block.Min.X, block.Min.Y)
+	for y := block.Min.Y; y < block.Max.Y; y++ {
+		for x := block.Min.X; x < block.Max.X; x++ {
+			if img.At(x, y) != expected {
+				t.Fatalf("Block %v is not uniform at %d,%d", block, x, y)
+			}
+		}
+	}
+}
+
+func TestImageCircleMask(t *testing.T) {
+	buf, err := initImage("test.jpg").CircleMask()
+	if err != nil {
+		t.Errorf("Cannot process the image: %#v", err)
+	}
+
+	err = assertSize(buf, 1050, 1050)
+	if err != nil {
+		t.Error(err)
+	}
+
+	meta, err := Metadata(buf)
+	if err != nil {
+		t.Errorf("Cannot read image metadata: %#v", err)
+	}
+	if !meta.Alpha {
+		t.Error("Image has no alpha channel")
+	}
+
+	Write("testdata/test_circle_mask_out.png", buf)
+}
+
+func TestImageAddAlpha(t *testing.T) {
+	buf, err := initImage("test.jpg").AddAlpha()
+	if err != nil {
+		t.Errorf("Cannot process the image: %#v", err)
+	}
+
+	meta, err := Metadata(buf)
+	if err != nil {
+		t.Errorf("Cannot read image metadata: %#v", err)
+	}
+	if !meta.Alpha {
+		t.Error("Image has no alpha channel")
+	}
+
+	Write("testdata/test_add_alpha_out.png", buf)
+}
+
+func TestImageExtractAlpha(t *testing.T) {
+	image := initImage("transparent.png")
+	mask, err := image.ExtractAlpha()
+	if err != nil {
+		t.Errorf("Cannot process the image: %#v", err)
+	}
+
+	meta, err := mask.Metadata()
+	if err != nil {
+		t.Errorf("Cannot read image metadata: %#v", err)
+	}
+	if meta.Alpha || meta.Channels != 1 {
+		t.Errorf("Invalid mask channels: %d", meta.Channels)
+	}
+
+	size, _ := image.Size()
+	if meta.Size != size {
+		t.Errorf("Invalid mask size: %dx%d", meta.Size.Width, meta.Size.Height)
+	}
+
+	Write("testdata/test_extract_alpha_out.png", mask.Image())
+}
+
+func TestImageApplyAlphaMask(t *testing.T) {
+	mask, err := initImage("transparent.png").ExtractAlpha()
+	if err != nil {
+		t.Errorf("Cannot process the image: %#v", err)
+	}
+
+	buf, err := initImage("test.jpg").ApplyAlphaMask(mask)
+	if err != nil {
+		t.Errorf("Cannot process the image: %#v", err)
+	}
+
+	meta, err := Metadata(buf)
+	if err != nil {
+		t.Errorf("Cannot read image metadata: %#v", err)
+	}
+	if !meta.Alpha {
+		t.Error("Image has no alpha channel")
+	}
+	if meta.Size.Width != 1680 || meta.Size.Height != 1050 {
+		t.Errorf("Invalid image size: %dx%d", meta.Size.Width, meta.Size.Height)
+	}
+
+	Write("testdata/test_apply_alpha_mask_out.png", buf)
+}
+
+func TestImageResizeKeepsAlpha(t *testing.T) {
+	buf, err := initImage("transparent.png").Resize(300, 0)
+	if err != nil {
+		t.Errorf("Cannot process the image: %#v", err)
+	}
+
+	meta, err := Metadata(buf)
+	if err != nil {
+		t.Errorf("Cannot read image metadata: %#v", err)
+	}
+	if !meta.Alpha {
+		t.Error("Image has no alpha channel")
+	}
+	if meta.Size.Width != 300 {
+		t.Errorf("Invalid image width: %d", meta.Size.Width)
+	}
+
+	Write("testdata/test_resize_alpha_out.png", buf)
+}
+
+func TestImagePremultiply(t *testing.T) {
+	buf, err := initImage("transparent.png").Premultiply()
+	if err != nil {
+		t.Errorf("Cannot process the image: %#v", err)
+	}
+
+	meta, err := Metadata(buf)
+	if err != nil {
+		t.Errorf("Cannot read image metadata: %#v", err)
+	}
+	if !meta.Alpha {
+		t.Error("Image has no alpha channel")
+	}
+
+	buf, err = NewImage(buf).Unpremultiply()
+	if err != nil {
+		t.Errorf("Cannot process the image: %#v", err)
+	}
+	if DetermineImageType(buf) != PNG {
+		t.Fatal("Image is not png")
+	}
+
+	Write("testdata/test_premultiply_out.png", buf)
+}
+
+func TestImageExtractBand(t *testing.T) {
+	buf, err := initImage("test.jpg").ExtractBand(1, 1)
+	if err != nil {
+		t.Errorf("Cannot process the image: %#v", err)
+	}
+
+	meta, err := Metadata(buf)
+	if err != nil {
+		t.Errorf("Cannot read image metadata: %#v", err)
+	}
+	if meta.Channels != 1 {
+		t.Errorf("Invalid number of channels: %d", meta.Channels)
+	}
+
+	Write("testdata/test_extract_band_out.jpg", buf)
+}
+
+func TestImageColourspaceLab(t *testing.T) {
+	if !IsTypeSupportedSave(TIFF) {
+		t.Skipf("Format %#v is not supported", ImageTypes[TIFF])
+	}
+
+	buf, err := initImage("test.jpg").Process(Options{Interpretation: InterpretationLAB, Type: TIFF})
+	if err != nil {
+		t.Errorf("Cannot process the image: %#v", err)
+	}
+
+	interpretation, err := ImageInterpretation(buf)
+	if err != nil {
+		t.Errorf("Cannot read the image interpretation: %#v", err)
+	}
+	if interpretation != InterpretationLAB {
+		t.Errorf("Invalid interpretation: %d", interpretation)
+	}
+}
+
+func TestImageColourspaceHSV(t *testing.T) {
+	buf, err := initImage("test.jpg").Colourspace(InterpretationHSV)
+	if err != nil {
+		t.Errorf("Cannot process the image: %#v", err)
+	}
+
+	err = assertSize(buf, 1680, 1050)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestImageTrimEdges(t *testing.T) {
+	if !(VipsMajorVersion >= 8 && VipsMinorVersion >= 6) {
+		t.Skipf("Skipping this test, libvips doesn't meet version requirement %s >= 8.6", VipsVersion)
+	}
+
+	tests := []struct {
+		trim          TrimOptions
+		width, height int
+	}{
+		{TrimOptions{}, 400, 257},
+		{TrimOptions{Top: true, Bottom: true}, 400, 257},
+		{TrimOptions{Left: true, Right: true}, 400, 300},
+		{TrimOptions{Padding: 1000}, 400, 300},
+	}
+
+	for _, test := range tests {
+		buf, err := initImage("test.png").Process(Options{
+			Trim:        true,
+			TrimOptions: test.trim,
+			Background:  Color{0.0, 0.0, 0.0},
+			Threshold:   10.0,
+		})
+		if err != nil {
+			t.Fatalf("Cannot process the image: %#v", err)
+		}
+		if err := assertSize(buf, test.width, test.height); err != nil {
+			t.Errorf("%#v: %s", test.trim, err)
+		}
+	}
+}
+
+func TestImageTrimAutoBackground(t *testing.T) {
+	if !(VipsMajorVersion >= 8 && VipsMinorVersion >= 6) {
+		t.Skipf("Skipping this test, libvips doesn't meet version requirement %s >= 8.6", VipsVersion)
+	}
+
+	embedded, err := initImage("test.jpg").Process(Options{
+		Width:      400,
+		Height:     400,
+		Embed:      true,
+		Extend:     ExtendBackground,
+		Background: Color{200, 100, 50},
+		Type:       PNG,
+	})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+
+	buf, err := NewImage(embedded).Process(Options{
+		Trim:        true,
+		TrimOptions: TrimOptions{AutoBackground: true},
+	})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if err := assertSize(buf, 400, 250); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestImageTrimLab(t *testing.T) {
+	if !(VipsMajorVersion >= 8 && VipsMinorVersion >= 6) {
+		t.Skipf("Skipping this test, libvips doesn't meet version requirement %s >= 8.6", VipsVersion)
+	}
+
+	options := Options{
+		Trim:       true,
+		TrimLab:    true,
+		Background: Color{0, 0, 0},
+		Threshold:  10,
+	}
+	buf, err := initImage("test.png").Process(options)
+	if err != nil {
+		t.Errorf("Cannot process the image: %#v", err)
+	}
+
+	size, err := Size(buf)
+	if err != nil {
+		t.Errorf("Cannot read the image size: %#v", err)
+	}
+	if size.Width == 0 || size.Height == 0 || size.Width*size.Height >= 400*300 {
+		t.Errorf("The image wasn't trimmed: %dx%d", size.Width, size.Height)
+	}
+
+	Write("testdata/test_trim_lab_out.png", buf)
+}
+
+func TestImageBitDepth(t *testing.T) {
+	buf, err := initImage("test.jpg").Process(Options{Type: PNG, BitDepth: 16})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	// PNG bit depth is stored right after the IHDR width and height
+	if buf[24] != 16 {
+		t.Fatalf("Invalid bit depth: %d", buf[24])
+	}
+
+	// 16-bit inputs retain their depth end-to-end
+	buf, err = NewImage(buf).Process(Options{Width: 300, BitDepth: 16})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if buf[24] != 16 {
+		t.Errorf("Invalid bit depth: %d", buf[24])
+	}
+
+	buf, err = NewImage(buf).Resize(200, 0)
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if buf[24] != 8 {
+		t.Errorf("Invalid bit depth: %d", buf[24])
+	}
+
+	if _, err := initImage("test.png").Process(Options{BitDepth: 12}); err == nil {
+		t.Error("Expected error with unsupported bit depth")
+	}
+}
+
+func TestImageToneMap(t *testing.T) {
+	if !IsTypeSupported(HDR) {
+		t.Skipf("Format %#v is not supported", ImageTypes[HDR])
+	}
+
+	operators := []ToneMap{
+		{Operator: ToneMapReinhard},
+		{Operator: ToneMapClip, Exposure: -2, Gamma: 2.2},
+	}
+
+	for _, operator := range operators {
+		buf, err := initImage("test.hdr").ToneMap(operator)
+		if err != nil {
+			t.Fatalf("Cannot process the image: %#v", err)
+		}
+
+		if DetermineImageType(buf) != PNG {
+			t.Fatal("Image is not png")
+		}
+		err = assertSize(buf, 64, 48)
+		if err != nil {
+			t.Error(err)
+		}
+
+		Write(fmt.Sprintf("testdata/test_tonemap_%d_out.png", operator.Operator), buf)
+	}
+}
+
+func TestImageKeepMetadata(t *testing.T) {
+	buf, err := initImage("test_exif_full.jpg").Process(Options{KeepMetadata: KeepEXIF})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	meta, err := Metadata(buf)
+	if err != nil {
+		t.Fatalf("Cannot read image metadata: %#v", err)
+	}
+	if meta.EXIF.Make != "Apple" {
+		t.Errorf("EXIF metadata was not kept: %s", meta.EXIF.Make)
+	}
+
+	buf, err = initImage("test_exif_full.jpg").Process(Options{KeepMetadata: KeepICC})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	meta, err = Metadata(buf)
+	if err != nil {
+		t.Fatalf("Cannot read image metadata: %#v", err)
+	}
+	if meta.EXIF.Make != "" {
+		t.Errorf("EXIF metadata was not stripped: %s", meta.EXIF.Make)
+	}
+
+	buf, err = initImage("test_icc_prophoto.jpg").Process(Options{KeepMetadata: KeepICC})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	meta, err = Metadata(buf)
+	if err != nil {
+		t.Fatalf("Cannot read image metadata: %#v", err)
+	}
+	if !meta.Profile {
+		t.Error("ICC profile was not kept")
+	}
+}
+
+func TestImageInterlace(t *testing.T) {
+	buf, err := initImage("test.jpg").Interlace()
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if !isProgressiveJPEG(buf) {
+		t.Error("Image is not a progressive jpeg")
+	}
+
+	buf, err = initImage("test.jpg").Process(Options{Width: 300})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if isProgressiveJPEG(buf) {
+		t.Error("Image must be a baseline jpeg by default")
+	}
+
+	buf, err = initImage("test.png").Interlace()
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	// PNG interlace method is the last IHDR field
+	if buf[28] != 1 {
+		t.Errorf("Image is not an interlaced png: %d", buf[28])
+	}
+}
+
+// isProgressiveJPEG looks for the progressive start of frame marker.
+func isProgressiveJPEG(buf []byte) bool {
+	for i := 2; i+3 < len(buf) && buf[i] == 0xFF; {
+		marker := buf[i+1]
+		if marker == 0xC2 {
+			return true
+		}
+		if marker == 0xDA {
+			break
+		}
+		i += 2 + int(buf[i+2])<<8 + int(buf[i+3])
+	}
+	return false
+}
+
+func TestImageJPEGOptions(t *testing.T) {
+	subsampled, err := initImage("test.jpg").Process(Options{Width: 300, Quality: 95, JPEG: JPEGOptions{Subsample: Subsample420}})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+
+	full, err := initImage("test.jpg").Process(Options{Width: 300, Quality: 95, JPEG: JPEGOptions{Subsample: Subsample444}})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if len(full) <= len(subsampled) {
+		t.Errorf("4:4:4 image must be bigger than 4:2:0: %d <= %d", len(full), len(subsampled))
+	}
+
+	opts := JPEGOptions{TrellisQuant: true, OvershootDeringing: true, OptimizeScans: true, QuantTable: 3}
+	buf, err := initImage("test.jpg").Process(Options{Width: 300, Interlace: true, JPEG: opts})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	size, _ := Size(buf)
+	if size.Width != 300 {
+		t.Errorf("Invalid image width: %d", size.Width)
+	}
+
+	Write("testdata/test_jpeg_options_out.jpg", buf)
+}
+
+func TestImagePNGOptions(t *testing.T) {
+	full, err := initImage("test.png").Process(Options{Type: PNG})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+
+	opts := Options{Type: PNG, Palette: true, PNG: PNGOptions{Colors: 16, Dither: -1, Filter: PNGFilterNone}}
+	buf, err := initImage("test.png").Process(opts)
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if len(buf) >= len(full) {
+		t.Errorf("Palette image must be smaller: %d >= %d", len(buf), len(full))
+	}
+	// PNG bit depth is the first IHDR field after the image size
+	if buf[24] != 4 {
+		t.Errorf("Invalid palette bit depth: %d", buf[24])
+	}
+
+	Write("testdata/test_png_options_out.png", buf)
+}
+
+func TestImageWebPOptions(t *testing.T) {
+	if !IsTypeSupportedSave(WEBP) {
+		t.Skipf("Format %#v is not supported", ImageTypes[WEBP])
+	}
+
+	opts := WebPOptions{AlphaQuality: 50, Effort: 6, SmartSubsample: true}
+	buf, err := initImage("transparent.png").Process(Options{Type: WEBP, Quality: 80, WebP: opts})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if DetermineImageType(buf) != WEBP {
+		t.Fatal("Image is not webp")
+	}
+
+	buf, err = initImage("test.png").Process(Options{Type: WEBP, Lossless: true, Quality: 60, WebP: WebPOptions{NearLossless: true}})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if DetermineImageType(buf) != WEBP {
+		t.Fatal("Image is not webp")
+	}
+
+	Write("testdata/test_webp_options_out.webp", buf)
+}
+
+func TestImageGIFOptions(t *testing.T) {
+	if !IsTypeSupportedSave(GIF) {
+		t.Skipf("Format %#v is not supported", ImageTypes[GIF])
+	}
+
+	full, err := initImage("test.png").Process(Options{Type: GIF})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+
+	buf, err := initImage("test.png").Process(Options{Type: GIF, GIF: GIFOptions{Colors: 16, Dither: -1, Effort: 1}})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if len(buf) >= len(full) {
+		t.Errorf("16 colors image must be smaller: %d >= %d", len(buf), len(full))
+	}
+
+	Write("testdata/test_gif_options_out.gif", buf)
+}
+
+func TestImageLosslessJPEG(t *testing.T) {
+	for i := 1; i <= 8; i++ {
+		name := fmt.Sprintf("exif/Landscape_%d.jpg", i)
+		buf, err := initImage(name).Process(Options{LosslessJPEG: true})
+		if err != nil {
+			t.Fatalf("Cannot process the image: %#v", err)
+		}
+		if err := assertSize(buf, 1600, 1200); err != nil {
+			t.Errorf("%s: %s", name, err)
+		}
+		metadata, err := Metadata(buf)
+		if err != nil {
+			t.Fatalf("Cannot read the metadata: %#v", err)
+		}
+		if metadata.Orientation != 1 {
+			t.Errorf("%s: invalid orientation: %d", name, metadata.Orientation)
+		}
+		assertLosslessPixels(t, name, buf, Options{})
+	}
+
+	o := Options{Rotate: D90, Top: 16, Left: 32, AreaWidth: 400, AreaHeight: 300}
+	lossless := o
+	lossless.LosslessJPEG = true
+	buf, err := initImage("exif/Landscape_1.jpg").Process(lossless)
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if err := assertSize(buf, 400, 300); err != nil {
+		t.Error(err)
+	}
+	assertLosslessPixels(t, "exif/Landscape_1.jpg", buf, o)
+
+	// Mirroring the partial blocks of test.jpg falls back to re-encoding
+	buf, err = initImage("test.jpg").Process(Options{LosslessJPEG: true, Rotate: D180})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if err := assertSize(buf, 1680, 1050); err != nil {
+		t.Error(err)
+	}
+	assertLosslessPixels(t, "test.jpg", buf, Options{Rotate: D180})
+}
+
+// assertLosslessPixels compares the pixels of a lossless JPEG transform
+// output with the image re-encoded by libvips using the same options,
+// allowing for the JPEG compression noise.
+func assertLosslessPixels(t *testing.T, name string, buf []byte, o Options) {
+	t.Helper()
+	expected, err := initImage(name).Process(o)
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	diff, err := Diff(NewImage(expected), NewImage(buf), DiffOptions{Threshold: 24})
+	if err != nil {
+		t.Fatalf("Cannot diff the images: %#v", err)
+	}
+	if diff.Score > 0.01 {
+		t.Errorf("%s: the transformed pixels differ: %g", name, diff.Score)
+	}
+}
+
+func TestImageFromBufferRegion(t *testing.T) {
+	for _, file := range []string{"test.jpg", "test.png"} {
+		buf, _ := Read("testdata/" + file)
+		region := Region{Left: 100, Top: 50, Width: 300, Height: 200}
+
+		img := NewImageFromBufferRegion(buf, region)
+		// Encoding and the operations not based on Process decode the
+		// region as well
+		saved, err := img.SaveAll(SaveOptions{})
+		if err != nil {
+			t.Fatalf("Cannot save the image: %#v", err)
+		}
+		if err := assertSize(saved[0], 300, 200); err != nil {
+			t.Errorf("%s: %s", file, err)
+		}
+		out, err := NewImageFromBufferRegion(buf, region).RunPipeline(NewPipeline().Flip(), SaveOptions{})
+		if err != nil {
+			t.Fatalf("Cannot process the image: %#v", err)
+		}
+		if err := assertSize(out, 300, 200); err != nil {
+			t.Errorf("%s: %s", file, err)
+		}
+
+		out, err = img.Process(Options{})
+		if err != nil {
+			t.Fatalf("Cannot process the image: %#v", err)
+		}
+		if err := assertSize(out, 300, 200); err != nil {
+			t.Errorf("%s: %s", file, err)
+		}
+
+		// The region is only applied once
+		out, err = img.Resize(150, 100)
+		if err != nil {
+			t.Fatalf("Cannot process the image: %#v", err)
+		}
+		if err := assertSize(out, 150, 100); err != nil {
+			t.Errorf("%s: %s", file, err)
+		}
+	}
+}
+
+func TestImageConcurrentReads(t *testing.T) {
+	img := initImage("test.jpg")
+	defer img.Close()
+
+	var wg sync.WaitGroup
+	for n := 0; n < 4; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 5; i++ {
+				size, err := img.Size()
+				if err != nil {
+					t.Errorf("Cannot read the image size: %#v", err)
+					return
+				}
+				if size.Width != 1680 && size.Width != 300 {
+					t.Errorf("Invalid image width: %d", size.Width)
+				}
+				img.Metadata()
+			}
+		}()
+	}
+
+	if _, err := img.Resize(300, 200); err != nil {
+		t.Errorf("Cannot process the image: %#v", err)
+	}
+	wg.Wait()
+}
+
 func initImage(file string) *Image {
 	buf, _ := imageBuf(file)
 	return NewImage(buf)
@@ -0,0 +1,102 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+)
+
+// JoinOptions represents the Join and JoinMany options.
+type JoinOptions struct {
+	// Align defines how images of different heights, when joined
+	// horizontally, or widths, when joined vertically, are aligned.
+	// Defaults to AlignLow, the top or left edges.
+	Align Align
+	// Shim defines the space between the images, in pixels.
+	Shim int
+	// Background defines the color of the shim and of the areas not
+	// covered by the smaller images. Images with alpha channel are joined
+	// on a transparent background.
+	Background Color
+	// Crop crops the output to the smallest image instead of extending
+	// the smaller images with the background.
+	Crop bool
+	// Type defines the output image type. Defaults to the first image type.
+	Type ImageType
+	// Quality defines the output quality. Defaults to 75.
+	Quality int
+}
+
+// Join concatenates two images horizontally, b on the right of a, or
+// vertically, b below a. It is useful for panoramas, before and after
+// comparisons and receipts.
+func Join(a, b *Image, direction Direction, o JoinOptions) (*Image, error) {
+	return JoinMany([]*Image{a, b}, direction, o)
+}
+
+// JoinMany concatenates the images horizontally, from left to right, or
+// vertically, from top to bottom.
+func JoinMany(images []*Image, direction Direction, o JoinOptions) (*Image, error) {
+	defer C.vips_thread_shutdown()
+
+	if len(images) == 0 {
+		return nil, errors.New("No images to join")
+	}
+	if direction != Horizontal && direction != Vertical {
+		return nil, fmt.Errorf("Invalid join direction: %d", direction)
+	}
+	if o.Shim < 0 {
+		return nil, fmt.Errorf("Invalid join shim: %d", o.Shim)
+	}
+
+	image, imageType, err := loadOrientedImage(images[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, img := range images[1:] {
+		next, _, err := loadOrientedImage(img)
+		if err != nil {
+			C.g_object_unref(C.gpointer(image))
+			return nil, err
+		}
+		image, err = vipsJoin(image, next, direction, o)
+		if err != nil {
+			return nil, err
+		}
+		err = GetLimits().checkSize(int(image.Xsize), int(image.Ysize), 1)
+		if err == nil && (int(image.Xsize) > maxSize || int(image.Ysize) > maxSize) {
+			err = wrapError(ErrDimensionsTooLarge, "Joined image exceeds the maximum size")
+		}
+		if err != nil {
+			C.g_object_unref(C.gpointer(image))
+			return nil, err
+		}
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	save := applySaveDefaults(SaveOptions{Type: o.Type, Quality: o.Quality}, imageType)
+	if !IsTypeSupportedSave(save.Type) {
+		return nil, wrapError(ErrUnsupportedFormat, "Unsupported image output type: "+ImageTypeName(save.Type))
+	}
+	buf, err := encodeImage(image, save, save.Quality)
+	if err != nil {
+		return nil, err
+	}
+	return NewImage(buf), nil
+}
+
+// loadOrientedImage loads an auto-rotated image.
+func loadOrientedImage(img *Image) (*C.VipsImage, ImageType, error) {
+	buf, region := img.source()
+	image, imageType, err := loadImageOptions(buf, LoadOptions{Region: region})
+	if err != nil {
+		return nil, imageType, err
+	}
+	image, _, err = rotateAndFlipImage(image, Options{})
+	return image, imageType, err
+}
@@ -0,0 +1,83 @@
+package bimg
+
+import (
+	"errors"
+	"testing"
+)
+
+func joinFixtures(t *testing.T) (*Image, *Image) {
+	a, err := initImage("test.jpg").ForceResize(200, 100)
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	b, err := initImage("test.jpg").ForceResize(100, 150)
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	return NewImage(a), NewImage(b)
+}
+
+func TestJoin(t *testing.T) {
+	a, b := joinFixtures(t)
+
+	cases := []struct {
+		direction Direction
+		options   JoinOptions
+		width     int
+		height    int
+	}{
+		{Horizontal, JoinOptions{}, 300, 150},
+		{Horizontal, JoinOptions{Shim: 10, Align: AlignCentre}, 310, 150},
+		{Horizontal, JoinOptions{Crop: true}, 300, 100},
+		{Vertical, JoinOptions{Background: Color{255, 255, 255}}, 200, 250},
+		{Vertical, JoinOptions{Crop: true, Align: AlignHigh}, 100, 250},
+	}
+
+	for _, c := range cases {
+		joined, err := Join(a, b, c.direction, c.options)
+		if err != nil {
+			t.Fatalf("Cannot join the images: %#v", err)
+		}
+		if err := assertSize(joined.Image(), c.width, c.height); err != nil {
+			t.Errorf("Invalid size for %#v: %s", c.options, err)
+		}
+		if joined.Type() != "jpeg" {
+			t.Errorf("Invalid joined type: %s", joined.Type())
+		}
+	}
+}
+
+func TestJoinMany(t *testing.T) {
+	a, b := joinFixtures(t)
+
+	joined, err := JoinMany([]*Image{a, b, a}, Horizontal, JoinOptions{Shim: 5, Type: PNG})
+	if err != nil {
+		t.Fatalf("Cannot join the images: %#v", err)
+	}
+	if err := assertSize(joined.Image(), 510, 150); err != nil {
+		t.Error(err)
+	}
+	if joined.Type() != "png" {
+		t.Errorf("Invalid joined type: %s", joined.Type())
+	}
+
+	if _, err := JoinMany(nil, Horizontal, JoinOptions{}); err == nil {
+		t.Error("Joining no images should fail")
+	}
+	if _, err := Join(a, b, Direction(10), JoinOptions{}); err == nil {
+		t.Error("Invalid directions should fail")
+	}
+}
+
+func TestJoinLimits(t *testing.T) {
+	a, b := joinFixtures(t)
+	SetLimits(Limits{MaxWidth: 400})
+	defer SetLimits(Limits{})
+
+	if _, err := JoinMany([]*Image{a, b}, Horizontal, JoinOptions{}); err != nil {
+		t.Errorf("Cannot join the images within the limits: %#v", err)
+	}
+	if _, err := JoinMany([]*Image{a, b, a}, Horizontal, JoinOptions{}); !errors.Is(err, ErrDimensionsTooLarge) {
+		t.Errorf("Expected ErrDimensionsTooLarge, got %#v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"sync"
+)
+
+var (
+	limits      Limits
+	limitsMutex sync.RWMutex
+)
+
+// SetLimits sets the package-level input limits, applied to every loaded
+// image unless overridden per load via Options.Load.
+func SetLimits(l Limits) {
+	limitsMutex.Lock()
+	limits = l
+	limitsMutex.Unlock()
+}
+
+// GetLimits returns the package-level input limits.
+func GetLimits() Limits {
+	limitsMutex.RLock()
+	defer limitsMutex.RUnlock()
+	return limits
+}
+
+// withDefaults fills the zero limits with the given defaults.
+func (l Limits) withDefaults(d Limits) Limits {
+	if l.MaxPixels == 0 {
+		l.MaxPixels = d.MaxPixels
+	}
+	if l.MaxWidth == 0 {
+		l.MaxWidth = d.MaxWidth
+	}
+	if l.MaxHeight == 0 {
+		l.MaxHeight = d.MaxHeight
+	}
+	if l.MaxPages == 0 {
+		l.MaxPages = d.MaxPages
+	}
+	if l.MaxInputBytes == 0 {
+		l.MaxInputBytes = d.MaxInputBytes
+	}
+	return l
+}
+
+// checkInput checks the input buffer size.
+func (l Limits) checkInput(buf []byte) error {
+	if l.MaxInputBytes > 0 && len(buf) > l.MaxInputBytes {
+		return wrapError(ErrInputTooLarge, fmt.Sprintf("Image buffer of %d bytes exceeds the limit of %d bytes", len(buf), l.MaxInputBytes))
+	}
+	return nil
+}
+
+// checkImage checks the image header dimensions and pages.
+func (l Limits) checkImage(image *C.VipsImage) error {
+	return l.checkSize(int(image.Xsize), int(image.Ysize), vipsNPages(image))
+}
+
+func (l Limits) checkSize(width, height, pages int) error {
+	if l.MaxWidth > 0 && width > l.MaxWidth {
+		return wrapError(ErrDimensionsTooLarge, fmt.Sprintf("Image width %d exceeds the limit of %d", width, l.MaxWidth))
+	}
+	if l.MaxHeight > 0 && height > l.MaxHeight {
+		return wrapError(ErrDimensionsTooLarge, fmt.Sprintf("Image height %d exceeds the limit of %d", height, l.MaxHeight))
+	}
+	if l.MaxPixels > 0 && int64(width)*int64(height) > int64(l.MaxPixels) {
+		return wrapError(ErrDimensionsTooLarge, fmt.Sprintf("Image size %dx%d exceeds the limit of %d pixels", width, height, l.MaxPixels))
+	}
+	if l.MaxPages > 0 && pages > l.MaxPages {
+		return wrapError(ErrInputTooLarge, fmt.Sprintf("Image with %d pages exceeds the limit of %d pages", pages, l.MaxPages))
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package bimg
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLimitsWithDefaults(t *testing.T) {
+	l := Limits{MaxWidth: 100}.withDefaults(Limits{MaxWidth: 200, MaxPixels: 1000})
+	if l.MaxWidth != 100 || l.MaxPixels != 1000 || l.MaxHeight != 0 {
+		t.Errorf("Invalid limits: %#v", l)
+	}
+}
+
+func TestLimitsCheckSize(t *testing.T) {
+	cases := []struct {
+		limits Limits
+		err    error
+	}{
+		{Limits{}, nil},
+		{Limits{MaxWidth: 100, MaxHeight: 100, MaxPixels: 10000, MaxPages: 1}, nil},
+		{Limits{MaxWidth: 99}, ErrDimensionsTooLarge},
+		{Limits{MaxHeight: 49}, ErrDimensionsTooLarge},
+		{Limits{MaxPixels: 4999}, ErrDimensionsTooLarge},
+	}
+
+	for _, c := range cases {
+		err := c.limits.checkSize(100, 50, 1)
+		if c.err == nil && err != nil {
+			t.Errorf("Unexpected error for %#v: %s", c.limits, err)
+		}
+		if c.err != nil && !errors.Is(err, c.err) {
+			t.Errorf("Expected %v for %#v, got %#v", c.err, c.limits, err)
+		}
+	}
+
+	if err := (Limits{MaxPages: 2}).checkSize(10, 10, 3); !errors.Is(err, ErrInputTooLarge) {
+		t.Errorf("Expected ErrInputTooLarge, got %#v", err)
+	}
+}
+
+func TestResizeLimits(t *testing.T) {
+	buf := initImage("test.jpg").Image()
+
+	_, err := Resize(buf, Options{Width: 100, Load: LoadOptions{Limits: Limits{MaxPixels: 1000000}}})
+	if !errors.Is(err, ErrDimensionsTooLarge) {
+		t.Errorf("Expected ErrDimensionsTooLarge, got %#v", err)
+	}
+
+	_, err = Resize(buf, Options{Width: 100, Load: LoadOptions{Limits: Limits{MaxWidth: 1680, MaxHeight: 1050}}})
+	if err != nil {
+		t.Errorf("Cannot process the image: %#v", err)
+	}
+
+	_, err = Resize(readFile("test.gif"), Options{Width: 100, Load: LoadOptions{Limits: Limits{MaxPages: 2}}})
+	if !errors.Is(err, ErrInputTooLarge) {
+		t.Errorf("Expected ErrInputTooLarge, got %#v", err)
+	}
+
+	// Regions are checked against the whole image
+	region := Region{Width: 100, Height: 100}
+	_, err = Resize(buf, Options{Width: 50, Load: LoadOptions{Region: region, Limits: Limits{MaxPixels: 1000000}}})
+	if !errors.Is(err, ErrDimensionsTooLarge) {
+		t.Errorf("Expected ErrDimensionsTooLarge, got %#v", err)
+	}
+
+	SetLimits(Limits{MaxInputBytes: 1024})
+	defer SetLimits(Limits{})
+
+	_, err = Resize(buf, Options{Width: 100})
+	if !errors.Is(err, ErrInputTooLarge) {
+		t.Errorf("Expected ErrInputTooLarge, got %#v", err)
+	}
+
+	_, err = Resize(buf, Options{Width: 100, Load: LoadOptions{Limits: Limits{MaxInputBytes: len(buf)}}})
+	if err != nil {
+		t.Errorf("Cannot process the image: %#v", err)
+	}
+}
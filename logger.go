@@ -0,0 +1,89 @@
+package bimg
+
+import "C"
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// LogLevel represents the level of a libvips or glib log message.
+type LogLevel int
+
+const (
+	// LogLevelError represents fatal errors, the process aborts afterwards.
+	LogLevelError LogLevel = 1 << 2
+	// LogLevelCritical represents critical warnings, usually programming errors.
+	LogLevelCritical LogLevel = 1 << 3
+	// LogLevelWarning represents warnings, e.g. for corrupted images.
+	LogLevelWarning LogLevel = 1 << 4
+	// LogLevelMessage represents informational messages.
+	LogLevelMessage LogLevel = 1 << 5
+	// LogLevelInfo represents verbose informational messages.
+	LogLevelInfo LogLevel = 1 << 6
+	// LogLevelDebug represents debug messages.
+	LogLevelDebug LogLevel = 1 << 7
+)
+
+// String returns the log level name.
+func (l LogLevel) String() string {
+	switch l {
+	case LogLevelError:
+		return "error"
+	case LogLevelCritical:
+		return "critical"
+	case LogLevelWarning:
+		return "warning"
+	case LogLevelMessage:
+		return "message"
+	case LogLevelInfo:
+		return "info"
+	case LogLevelDebug:
+		return "debug"
+	}
+	return "unknown"
+}
+
+var (
+	loggerMutex sync.RWMutex
+	logger      func(level LogLevel, domain, message string)
+)
+
+// SetLogger routes the libvips and glib log messages, written to stderr by
+// default, to the given function, e.g. to log the warnings of corrupted
+// images along with the request. The function may be called from libvips
+// threads and must be safe for concurrent use. Pass nil to restore the
+// default output.
+func SetLogger(fn func(level LogLevel, domain, message string)) {
+	loggerMutex.Lock()
+	defer loggerMutex.Unlock()
+
+	logger = fn
+	vipsSetLogHandler(fn != nil)
+}
+
+// logMessage writes a bimg log message to the logger, or to stderr when
+// there is none.
+func logMessage(level LogLevel, message string) {
+	loggerMutex.RLock()
+	fn := logger
+	loggerMutex.RUnlock()
+
+	if fn != nil {
+		fn(level, "bimg", message)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "bimg-%s: %s\n", level, message)
+}
+
+//export bimgLog
+func bimgLog(domain *C.char, level C.int, message *C.char) {
+	loggerMutex.RLock()
+	fn := logger
+	loggerMutex.RUnlock()
+
+	if fn != nil {
+		fn(LogLevel(level), C.GoString(domain), C.GoString(message))
+	}
+}
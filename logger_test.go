@@ -0,0 +1,38 @@
+package bimg
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSetLogger(t *testing.T) {
+	var mu sync.Mutex
+	var messages []string
+	SetLogger(func(level LogLevel, domain, message string) {
+		mu.Lock()
+		messages = append(messages, level.String()+" "+domain+": "+message)
+		mu.Unlock()
+	})
+	defer SetLogger(nil)
+
+	buf, _ := Read("testdata/corrupt.jpg")
+	if _, err := Resize(buf, Options{Width: 800, Height: 600}); err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(messages) == 0 {
+		t.Fatal("Missing corrupted image warnings")
+	}
+	if !strings.HasPrefix(messages[0], "warning VIPS: ") {
+		t.Errorf("Invalid log message: %s", messages[0])
+	}
+}
+
+func TestLogLevelString(t *testing.T) {
+	if LogLevelWarning.String() != "warning" || LogLevel(0).String() != "unknown" {
+		t.Error("Invalid log level names")
+	}
+}
@@ -0,0 +1,174 @@
+package bimg
+
+import (
+	"encoding/binary"
+	"reflect"
+)
+
+// orientation maps the source image axes to the output ones.
+type orientation [2][2]int
+
+var (
+	orientationIdentity = orientation{{1, 0}, {0, 1}}
+	orientationD90      = orientation{{0, -1}, {1, 0}}
+	orientationD180     = orientation{{-1, 0}, {0, -1}}
+	orientationD270     = orientation{{0, 1}, {-1, 0}}
+	orientationMirrorX  = orientation{{-1, 0}, {0, 1}}
+	orientationMirrorY  = orientation{{1, 0}, {0, -1}}
+)
+
+// then returns the orientation resulting of applying n after m.
+func (m orientation) then(n orientation) orientation {
+	var r orientation
+	for i := 0; i < 2; i++ {
+		for j := 0; j < 2; j++ {
+			r[i][j] = n[i][0]*m[0][j] + n[i][1]*m[1][j]
+		}
+	}
+	return r
+}
+
+// losslessTransform represents a lossless JPEG transform: a transpose
+// followed by mirrors, then a crop in the output coordinates.
+type losslessTransform struct {
+	Transpose   bool
+	MirrorX     bool
+	MirrorY     bool
+	Left        int
+	Top         int
+	Width       int
+	Height      int
+	CopyMarkers bool
+	Progressive bool
+}
+
+func (m orientation) transform() losslessTransform {
+	t := losslessTransform{Transpose: m[0][0] == 0}
+	if t.Transpose {
+		// Remove the transpose, leaving the mirrors
+		m = orientation{{m[0][1], m[0][0]}, {m[1][1], m[1][0]}}
+	}
+	t.MirrorX = m[0][0] < 0
+	t.MirrorY = m[1][1] < 0
+	return t
+}
+
+func angleOrientation(angle Angle) orientation {
+	switch angle {
+	case D90:
+		return orientationD90
+	case D180:
+		return orientationD180
+	case D270:
+		return orientationD270
+	}
+	return orientationIdentity
+}
+
+// isLosslessOperation reports whether the options only rotate, flip or
+// extract an area of a JPEG image, which can be done without decoding it.
+func isLosslessOperation(o Options) bool {
+	if !o.LosslessJPEG || o.Type != JPEG || (o.AreaWidth > 0) != (o.AreaHeight > 0) || o.Load.Region != (Region{}) {
+		return false
+	}
+	lossless := Options{
+		Rotate:         o.Rotate,
+		Flip:           o.Flip,
+		Flop:           o.Flop,
+		NoAutoRotate:   o.NoAutoRotate,
+		Top:            o.Top,
+		Left:           o.Left,
+		AreaWidth:      o.AreaWidth,
+		AreaHeight:     o.AreaHeight,
+		Type:           o.Type,
+		Quality:        o.Quality,
+		Compression:    o.Compression,
+		Interlace:      o.Interlace,
+		StripMetadata:  o.StripMetadata,
+		Interpretation: InterpretationSRGB,
+		LosslessJPEG:   o.LosslessJPEG,
+		C2PA:           o.C2PA,
+		Load:           o.Load,
+		autoRotateOnly: o.autoRotateOnly,
+		output:         o.output,
+		trace:          o.trace,
+	}
+	return reflect.DeepEqual(o, lossless)
+}
+
+// jpegRegion crops a JPEG image buffer to the blocks covering the region,
+// without decoding it, and returns the region relative to the cropped
+// image. The buffer is returned as is when it cannot be cropped.
+func jpegRegion(buf []byte, r Region) ([]byte, Region) {
+	if r.Left < 0 || r.Top < 0 || r.Width <= 0 || r.Height <= 0 {
+		return buf, r
+	}
+
+	// 16 pixels are a multiple of the JPEG blocks of the common subsamplings
+	left, top := r.Left&^15, r.Top&^15
+	t := losslessTransform{
+		Left:        left,
+		Top:         top,
+		Width:       r.Left + r.Width - left,
+		Height:      r.Top + r.Height - top,
+		CopyMarkers: true,
+	}
+	out, ok, err := losslessJPEG(buf, t)
+	if !ok || err != nil {
+		return buf, r
+	}
+	return out, Region{Left: r.Left - left, Top: r.Top - top, Width: r.Width, Height: r.Height}
+}
+
+// resetJPEGOrientation sets the EXIF orientation of a JPEG image buffer
+// to the default one, in place.
+func resetJPEGOrientation(buf []byte) {
+	if tiff := jpegExif(buf); tiff != nil {
+		resetTIFFOrientation(tiff)
+	}
+}
+
+// jpegExif returns the TIFF structure of the EXIF segment of a JPEG image
+// buffer, sharing its memory, or nil.
+func jpegExif(buf []byte) []byte {
+	for i := 2; i+4 <= len(buf) && buf[i] == 0xFF; {
+		marker := buf[i+1]
+		size := int(binary.BigEndian.Uint16(buf[i+2:]))
+		// Start of scan, no more metadata
+		if marker == 0xDA || i+2+size > len(buf) {
+			return nil
+		}
+		segment := buf[i+4 : i+2+size]
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return segment[6:]
+		}
+		i += 2 + size
+	}
+	return nil
+}
+
+// resetTIFFOrientation sets the orientation tag of the first IFD of a TIFF
+// structure, as embedded in the EXIF metadata, to the default one.
+func resetTIFFOrientation(tiff []byte) {
+	order := tiffByteOrder(tiff)
+	if order == nil {
+		return
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return
+		}
+		// Orientation tag, stored as a short
+		if order.Uint16(tiff[entry:]) == 0x0112 && order.Uint16(tiff[entry+2:]) == 3 {
+			order.PutUint16(tiff[entry+8:], 1)
+			return
+		}
+	}
+}
@@ -0,0 +1,250 @@
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include <setjmp.h>
+#include <jpeglib.h>
+
+/**
+ * Lossless JPEG transforms, operating on the DCT coefficients
+ * like jpegtran does, so the image is never decoded nor re-encoded.
+ */
+
+enum lossless_result {
+	LOSSLESS_OK = 0,
+	LOSSLESS_ERROR = 1,
+	LOSSLESS_UNSUPPORTED = 2
+};
+
+struct lossless_error_mgr {
+	struct jpeg_error_mgr pub;
+	jmp_buf jmp;
+};
+
+static void
+lossless_error_exit(j_common_ptr cinfo) {
+	struct lossless_error_mgr *err = (struct lossless_error_mgr *) cinfo->err;
+	longjmp(err->jmp, 1);
+}
+
+static void
+lossless_transpose_quant_tables(j_compress_ptr dst) {
+	int t, u, v;
+	for (t = 0; t < NUM_QUANT_TBLS; t++) {
+		JQUANT_TBL *table = dst->quant_tbl_ptrs[t];
+		if (table == NULL) {
+			continue;
+		}
+		for (v = 0; v < DCTSIZE; v++) {
+			for (u = v + 1; u < DCTSIZE; u++) {
+				UINT16 value = table->quantval[v * DCTSIZE + u];
+				table->quantval[v * DCTSIZE + u] = table->quantval[u * DCTSIZE + v];
+				table->quantval[u * DCTSIZE + v] = value;
+			}
+		}
+	}
+}
+
+static void
+lossless_copy_block(JCOEF *src, JCOEF *dst, int transpose, int mirror_x, int mirror_y) {
+	int u, v;
+	for (v = 0; v < DCTSIZE; v++) {
+		for (u = 0; u < DCTSIZE; u++) {
+			JCOEF value = transpose ? src[u * DCTSIZE + v] : src[v * DCTSIZE + u];
+			// Mirroring negates the odd frequencies of the mirrored axis
+			if ((mirror_x && (u & 1)) != (mirror_y && (v & 1))) {
+				value = -value;
+			}
+			dst[v * DCTSIZE + u] = value;
+		}
+	}
+}
+
+static void
+lossless_write_markers(j_decompress_ptr src, j_compress_ptr dst) {
+	jpeg_saved_marker_ptr marker;
+	for (marker = src->marker_list; marker != NULL; marker = marker->next) {
+		// Skip the markers written by libjpeg itself
+		if (dst->write_JFIF_header && marker->marker == JPEG_APP0 &&
+			marker->data_length >= 5 && memcmp(marker->data, "JFIF", 5) == 0) {
+			continue;
+		}
+		if (dst->write_Adobe_marker && marker->marker == JPEG_APP0 + 14 &&
+			marker->data_length >= 5 && memcmp(marker->data, "Adobe", 5) == 0) {
+			continue;
+		}
+		jpeg_write_marker(dst, marker->marker, marker->data, marker->data_length);
+	}
+}
+
+/**
+ * Transposes, mirrors and crops a JPEG image. The transpose is applied first,
+ * then the horizontal and vertical mirrors, then the crop, which must be
+ * aligned on the MCU grid of the output image. Mirrored sides must be MCU
+ * aligned too, as partial MCUs cannot be moved. LOSSLESS_UNSUPPORTED is
+ * returned otherwise, leaving the caller to fallback to a lossy transform.
+ */
+int
+lossless_jpeg_transform(unsigned char *buf, size_t len, int transpose, int mirror_x, int mirror_y,
+	int crop_x, int crop_y, int crop_width, int crop_height, int copy_markers, int progressive,
+	unsigned char **out, size_t *out_len) {
+	struct jpeg_decompress_struct src;
+	struct jpeg_compress_struct dst;
+	struct lossless_error_mgr err;
+	jvirt_barray_ptr *src_coefs;
+	jvirt_barray_ptr dst_coefs[MAX_COMPONENTS];
+	unsigned long length = 0;
+	int c, i, max_h = 1, max_v = 1;
+	int width, height, mcu_width, mcu_height;
+
+	*out = NULL;
+	memset(&src, 0, sizeof(src));
+	memset(&dst, 0, sizeof(dst));
+	src.err = jpeg_std_error(&err.pub);
+	dst.err = &err.pub;
+	err.pub.error_exit = lossless_error_exit;
+
+	if (setjmp(err.jmp)) {
+		jpeg_destroy_compress(&dst);
+		jpeg_destroy_decompress(&src);
+		if (*out != NULL) {
+			free(*out);
+			*out = NULL;
+		}
+		return LOSSLESS_ERROR;
+	}
+
+	jpeg_create_decompress(&src);
+	jpeg_create_compress(&dst);
+	jpeg_mem_src(&src, buf, len);
+
+	if (copy_markers) {
+		jpeg_save_markers(&src, JPEG_COM, 0xFFFF);
+		for (i = 0; i < 16; i++) {
+			jpeg_save_markers(&src, JPEG_APP0 + i, 0xFFFF);
+		}
+	}
+
+	jpeg_read_header(&src, TRUE);
+
+	for (c = 0; c < src.num_components; c++) {
+		if (src.comp_info[c].h_samp_factor > max_h) {
+			max_h = src.comp_info[c].h_samp_factor;
+		}
+		if (src.comp_info[c].v_samp_factor > max_v) {
+			max_v = src.comp_info[c].v_samp_factor;
+		}
+	}
+
+	// Output geometry, before cropping
+	width = transpose ? src.image_height : src.image_width;
+	height = transpose ? src.image_width : src.image_height;
+	mcu_width = (transpose ? max_v : max_h) * DCTSIZE;
+	mcu_height = (transpose ? max_h : max_v) * DCTSIZE;
+
+	if (crop_width <= 0 || crop_height <= 0) {
+		crop_x = crop_y = 0;
+		crop_width = width;
+		crop_height = height;
+	}
+
+	if ((mirror_x && width % mcu_width != 0) ||
+		(mirror_y && height % mcu_height != 0) ||
+		crop_x < 0 || crop_y < 0 ||
+		crop_x % mcu_width != 0 || crop_y % mcu_height != 0 ||
+		crop_x + crop_width > width || crop_y + crop_height > height) {
+		jpeg_destroy_compress(&dst);
+		jpeg_destroy_decompress(&src);
+		return LOSSLESS_UNSUPPORTED;
+	}
+
+	// Output coefficients must be requested before reading the input ones
+	for (c = 0; c < src.num_components; c++) {
+		jpeg_component_info *comp = &src.comp_info[c];
+		int h = transpose ? comp->v_samp_factor : comp->h_samp_factor;
+		int v = transpose ? comp->h_samp_factor : comp->v_samp_factor;
+		long blocks_x = (crop_width * h + mcu_width - 1) / mcu_width;
+		long blocks_y = (crop_height * v + mcu_height - 1) / mcu_height;
+
+		dst_coefs[c] = (*src.mem->request_virt_barray)((j_common_ptr) &src, JPOOL_IMAGE, FALSE,
+			(JDIMENSION) ((blocks_x + h - 1) / h * h),
+			(JDIMENSION) ((blocks_y + v - 1) / v * v),
+			(JDIMENSION) v);
+	}
+
+	src_coefs = jpeg_read_coefficients(&src);
+
+	for (c = 0; c < src.num_components; c++) {
+		jpeg_component_info *comp = &src.comp_info[c];
+		int h = transpose ? comp->v_samp_factor : comp->h_samp_factor;
+		int v = transpose ? comp->h_samp_factor : comp->v_samp_factor;
+		int src_blocks_x = (comp->width_in_blocks + comp->h_samp_factor - 1) / comp->h_samp_factor * comp->h_samp_factor;
+		int src_blocks_y = (comp->height_in_blocks + comp->v_samp_factor - 1) / comp->v_samp_factor * comp->v_samp_factor;
+		int blocks_x = (crop_width * h + mcu_width - 1) / mcu_width;
+		int blocks_y = (crop_height * v + mcu_height - 1) / mcu_height;
+		int offset_x = crop_x / mcu_width * h;
+		int offset_y = crop_y / mcu_height * v;
+		int full_x = width / mcu_width * h;
+		int full_y = height / mcu_height * v;
+		int x, y;
+
+		blocks_x = (blocks_x + h - 1) / h * h;
+		blocks_y = (blocks_y + v - 1) / v * v;
+
+		for (y = 0; y < blocks_y; y++) {
+			JBLOCKARRAY dst_row = (*src.mem->access_virt_barray)((j_common_ptr) &src, dst_coefs[c], y, 1, TRUE);
+			for (x = 0; x < blocks_x; x++) {
+				int fx = offset_x + x;
+				int fy = offset_y + y;
+				int sx, sy;
+
+				if (mirror_x) {
+					fx = full_x - 1 - fx;
+				}
+				if (mirror_y) {
+					fy = full_y - 1 - fy;
+				}
+				sx = transpose ? fy : fx;
+				sy = transpose ? fx : fy;
+
+				// Padding blocks past the input edges
+				if (sx < 0 || sy < 0 || sx >= src_blocks_x || sy >= src_blocks_y) {
+					memset(dst_row[0][x], 0, sizeof(JBLOCK));
+					continue;
+				}
+
+				JBLOCKARRAY src_row = (*src.mem->access_virt_barray)((j_common_ptr) &src, src_coefs[c], sy, 1, FALSE);
+				lossless_copy_block(src_row[0][sx], dst_row[0][x], transpose, mirror_x, mirror_y);
+			}
+		}
+	}
+
+	jpeg_copy_critical_parameters(&src, &dst);
+	dst.image_width = crop_width;
+	dst.image_height = crop_height;
+	if (transpose) {
+		for (c = 0; c < dst.num_components; c++) {
+			int h = dst.comp_info[c].h_samp_factor;
+			dst.comp_info[c].h_samp_factor = dst.comp_info[c].v_samp_factor;
+			dst.comp_info[c].v_samp_factor = h;
+		}
+		lossless_transpose_quant_tables(&dst);
+	}
+	dst.optimize_coding = TRUE;
+	if (progressive) {
+		jpeg_simple_progression(&dst);
+	}
+
+	jpeg_mem_dest(&dst, out, &length);
+	jpeg_write_coefficients(&dst, dst_coefs);
+	if (copy_markers) {
+		lossless_write_markers(&src, &dst);
+	}
+
+	jpeg_finish_compress(&dst);
+	jpeg_destroy_compress(&dst);
+	jpeg_finish_decompress(&src);
+	jpeg_destroy_decompress(&src);
+
+	*out_len = length;
+	return LOSSLESS_OK;
+}
@@ -0,0 +1,38 @@
+// +build libjpeg
+
+package bimg
+
+/*
+#cgo pkg-config: libjpeg
+#include "lossless.h"
+*/
+import "C"
+
+import "unsafe"
+
+// losslessJPEG applies a lossless transform to a JPEG image buffer. It
+// returns false when the transform cannot be done losslessly, e.g. when
+// the crop is not aligned on the JPEG blocks.
+func losslessJPEG(buf []byte, t losslessTransform) ([]byte, bool, error) {
+	if len(buf) == 0 {
+		return nil, false, wrapError(ErrTruncatedImage, "Image buffer is empty")
+	}
+
+	var ptr *C.uchar
+	length := C.size_t(0)
+	res := C.lossless_jpeg_transform((*C.uchar)(unsafe.Pointer(&buf[0])), C.size_t(len(buf)),
+		C.int(boolToInt(t.Transpose)), C.int(boolToInt(t.MirrorX)), C.int(boolToInt(t.MirrorY)),
+		C.int(t.Left), C.int(t.Top), C.int(t.Width), C.int(t.Height),
+		C.int(boolToInt(t.CopyMarkers)), C.int(boolToInt(t.Progressive)), &ptr, &length)
+
+	switch res {
+	case C.LOSSLESS_UNSUPPORTED:
+		return nil, false, nil
+	case C.LOSSLESS_ERROR:
+		return nil, false, wrapError(ErrCorruptImage, "Cannot transform the JPEG image")
+	}
+
+	out := C.GoBytes(unsafe.Pointer(ptr), C.int(length))
+	C.free(unsafe.Pointer(ptr))
+	return out, true, nil
+}
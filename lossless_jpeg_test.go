@@ -0,0 +1,77 @@
+// +build libjpeg
+
+package bimg
+
+import "testing"
+
+func TestLosslessJPEG(t *testing.T) {
+	buf := readFile("exif/Landscape_1.jpg")
+
+	out, ok, err := losslessJPEG(buf, losslessTransform{Transpose: true, MirrorX: true})
+	if err != nil || !ok {
+		t.Fatalf("Cannot transform the image: %t %#v", ok, err)
+	}
+	if err := assertSize(out, 1200, 1600); err != nil {
+		t.Error(err)
+	}
+
+	out, ok, err = losslessJPEG(buf, losslessTransform{Left: 32, Top: 16, Width: 100, Height: 50})
+	if err != nil || !ok {
+		t.Fatalf("Cannot transform the image: %t %#v", ok, err)
+	}
+	if err := assertSize(out, 100, 50); err != nil {
+		t.Error(err)
+	}
+
+	// Unaligned crops cannot be lossless
+	_, ok, err = losslessJPEG(buf, losslessTransform{Left: 10, Width: 100, Height: 50})
+	if err != nil || ok {
+		t.Errorf("Unaligned crop must not be lossless: %t %#v", ok, err)
+	}
+
+	_, _, err = losslessJPEG([]byte("invalid"), losslessTransform{})
+	if err == nil {
+		t.Error("Expected error for an invalid image")
+	}
+}
+
+func TestLosslessJPEGBitExact(t *testing.T) {
+	buf := readFile("exif/Landscape_1.jpg")
+
+	// Rotating by 90 then 270 degrees keeps the DCT coefficients, so the
+	// decoded pixels must be identical
+	out, ok, err := losslessJPEG(buf, losslessTransform{Transpose: true, MirrorX: true})
+	if err != nil || !ok {
+		t.Fatalf("Cannot transform the image: %t %#v", ok, err)
+	}
+	out, ok, err = losslessJPEG(out, losslessTransform{Transpose: true, MirrorY: true})
+	if err != nil || !ok {
+		t.Fatalf("Cannot transform the image: %t %#v", ok, err)
+	}
+
+	diff, err := Diff(NewImage(buf), NewImage(out), DiffOptions{})
+	if err != nil {
+		t.Fatalf("Cannot compare the images: %#v", err)
+	}
+	if diff.Score != 0 {
+		t.Errorf("Lossless transform changed the pixels: %g", diff.Score)
+	}
+}
+
+func TestJPEGRegion(t *testing.T) {
+	buf := readFile("test.jpg")
+
+	out, region := jpegRegion(buf, Region{Left: 100, Top: 50, Width: 300, Height: 200})
+	if region != (Region{Left: 4, Top: 2, Width: 300, Height: 200}) {
+		t.Errorf("Invalid region: %#v", region)
+	}
+	if err := assertSize(out, 304, 202); err != nil {
+		t.Error(err)
+	}
+
+	// Out of bounds regions are left to libvips
+	out, region = jpegRegion(buf, Region{Left: 1600, Width: 300, Height: 200})
+	if len(out) != len(buf) || region.Left != 1600 {
+		t.Errorf("Out of bounds region must not be cropped: %#v", region)
+	}
+}
@@ -0,0 +1,9 @@
+// +build !libjpeg
+
+package bimg
+
+// losslessJPEG never transforms the image when built without the libjpeg
+// build tag, so the images are re-encoded by libvips.
+func losslessJPEG(buf []byte, t losslessTransform) ([]byte, bool, error) {
+	return nil, false, nil
+}
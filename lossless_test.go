@@ -0,0 +1,74 @@
+package bimg
+
+import (
+	"testing"
+)
+
+func TestOrientationTransform(t *testing.T) {
+	tt := []struct {
+		angle    Angle
+		flip     bool
+		expected losslessTransform
+	}{
+		{D0, false, losslessTransform{}},
+		{D0, true, losslessTransform{MirrorX: true}},
+		{D180, false, losslessTransform{MirrorX: true, MirrorY: true}},
+		{D180, true, losslessTransform{MirrorY: true}},
+		{D90, true, losslessTransform{Transpose: true}},
+		{D90, false, losslessTransform{Transpose: true, MirrorX: true}},
+		{D270, true, losslessTransform{Transpose: true, MirrorX: true, MirrorY: true}},
+		{D270, false, losslessTransform{Transpose: true, MirrorY: true}},
+	}
+
+	for _, tc := range tt {
+		m := angleOrientation(tc.angle)
+		if tc.flip {
+			m = m.then(orientationMirrorX)
+		}
+		if got := m.transform(); got != tc.expected {
+			t.Errorf("%d %t: expected: %#v; got: %#v", tc.angle, tc.flip, tc.expected, got)
+		}
+	}
+}
+
+func TestIsLosslessOperation(t *testing.T) {
+	base := Options{Type: JPEG, Quality: 75, Compression: 6, Interpretation: InterpretationSRGB, LosslessJPEG: true}
+
+	tt := []struct {
+		update   func(o *Options)
+		expected bool
+	}{
+		{func(o *Options) {}, true},
+		{func(o *Options) { o.Rotate = D90; o.Flop = true }, true},
+		{func(o *Options) { o.AreaWidth = 100; o.AreaHeight = 100 }, true},
+		{func(o *Options) { o.AreaWidth = 100 }, false},
+		{func(o *Options) { o.LosslessJPEG = false }, false},
+		{func(o *Options) { o.Type = PNG }, false},
+		{func(o *Options) { o.Width = 100 }, false},
+		{func(o *Options) { o.Interpretation = InterpretationBW }, false},
+		// Processor, ResizeBuffer and traced options
+		{func(o *Options) { o.output = &Buffer{} }, true},
+		{func(o *Options) { o.trace = &traceRun{} }, true},
+	}
+
+	for i, tc := range tt {
+		o := base
+		tc.update(&o)
+		if got := isLosslessOperation(o); got != tc.expected {
+			t.Errorf("%d: expected: %t; got: %t", i, tc.expected, got)
+		}
+	}
+}
+
+func TestResetJPEGOrientation(t *testing.T) {
+	buf := readFile("exif/Landscape_6.jpg")
+	resetJPEGOrientation(buf)
+
+	metadata, err := Metadata(buf)
+	if err != nil {
+		t.Fatalf("Cannot read the metadata: %#v", err)
+	}
+	if metadata.Orientation != 1 {
+		t.Errorf("Invalid orientation: %d", metadata.Orientation)
+	}
+}
@@ -0,0 +1,68 @@
+package bimg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// MaskProvider represents a source of alpha masks, such as a segmentation
+// model, used to cut out the foreground of an image.
+// The returned mask luminance defines the image opacity: white pixels are
+// kept and black pixels become transparent. The mask is scaled to the image
+// size when needed.
+type MaskProvider interface {
+	Mask(image *Image) (*Image, error)
+}
+
+// MaskProviderFunc is an adapter to allow the use of ordinary functions
+// as MaskProvider.
+type MaskProviderFunc func(image *Image) (*Image, error)
+
+// Mask calls f(image).
+func (f MaskProviderFunc) Mask(image *Image) (*Image, error) {
+	return f(image)
+}
+
+// HTTPMaskProvider is a MaskProvider calling an external model server.
+// The image buffer is sent as the body of a POST request to URL and the
+// response body is expected to be the mask image in any supported format.
+type HTTPMaskProvider struct {
+	URL    string
+	Client *http.Client
+}
+
+// Mask requests the mask of the given image to the model server.
+func (p HTTPMaskProvider) Mask(image *Image) (*Image, error) {
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	contentType := "application/octet-stream"
+	if t := image.Type(); t != "unknown" {
+		contentType = "image/" + t
+	}
+
+	res, err := client.Post(p.URL, contentType, bytes.NewReader(image.Image()))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("mask provider responded with status %d", res.StatusCode)
+	}
+
+	buf, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if DetermineImageType(buf) == UNKNOWN {
+		return nil, errors.New("mask provider returned an unsupported image")
+	}
+
+	return NewImage(buf), nil
+}
@@ -0,0 +1,66 @@
+package bimg
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestImageRemoveBackground(t *testing.T) {
+	provider := MaskProviderFunc(func(image *Image) (*Image, error) {
+		return initImage("transparent.png").ExtractAlpha()
+	})
+
+	buf, err := initImage("test.jpg").RemoveBackground(provider)
+	if err != nil {
+		t.Errorf("Cannot process the image: %#v", err)
+	}
+
+	meta, err := Metadata(buf)
+	if err != nil {
+		t.Errorf("Cannot read image metadata: %#v", err)
+	}
+	if !meta.Alpha {
+		t.Error("Image has no alpha channel")
+	}
+
+	Write("testdata/test_remove_background_out.png", buf)
+}
+
+func TestHTTPMaskProvider(t *testing.T) {
+	mask, err := initImage("transparent.png").ExtractAlpha()
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "image/jpeg" || len(body) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write(mask.Image())
+	}))
+	defer server.Close()
+
+	buf, err := initImage("test.jpg").RemoveBackground(HTTPMaskProvider{URL: server.URL})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if DetermineImageType(buf) != PNG {
+		t.Fatal("Image is not png")
+	}
+}
+
+func TestHTTPMaskProviderError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	_, err := initImage("test.jpg").RemoveBackground(HTTPMaskProvider{URL: server.URL})
+	if err == nil {
+		t.Fatal("Expected error from the mask provider")
+	}
+}
@@ -6,6 +6,12 @@ package bimg
 */
 import "C"
 
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
 // Common EXIF fields for data extraction
 const (
 	Make                    = "exif-ifd0-Make"
@@ -67,24 +73,37 @@ type ImageSize struct {
 	Height int
 }
 
-// ImageMetadata represents the basic metadata fields
+// GPSCoordinates represents the EXIF GPS position in decimal degrees,
+// negative in the southern and western hemispheres.
+type GPSCoordinates struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// ImageMetadata represents the basic metadata fields.
+// OrientedSize is the image size once the EXIF orientation is applied.
+// EXIFRaw holds every EXIF tag keyed by its libvips name, such as the
+// Make constant, and GPS is nil when the image is not geotagged.
 type ImageMetadata struct {
-	Orientation int
-	Channels    int
-	Alpha       bool
-	Profile     bool
-	Type        string
-	Space       string
-	Colourspace string
-	Size        ImageSize
-	EXIF        EXIF
+	Orientation  ExifOrientation
+	Channels     int
+	Alpha        bool
+	Profile      bool
+	Type         string
+	Space        string
+	Colourspace  string
+	Size         ImageSize
+	OrientedSize ImageSize
+	EXIF         EXIF
+	EXIFRaw      map[string]string
+	GPS          *GPSCoordinates
 }
 
 // EXIF image metadata
 type EXIF struct {
 	Make                    string
 	Model                   string
-	Orientation             int
+	Orientation             ExifOrientation
 	XResolution             string
 	YResolution             string
 	ResolutionUnit          int
@@ -148,6 +167,14 @@ func Size(buf []byte) (ImageSize, error) {
 	}, nil
 }
 
+// orientedSize returns the image size after applying the EXIF orientation.
+func orientedSize(size ImageSize, orientation ExifOrientation) ImageSize {
+	if orientation.NeedsSwapDimensions() {
+		return ImageSize{Width: size.Height, Height: size.Width}
+	}
+	return size
+}
+
 // ColourspaceIsSupported checks if the image colourspace is supported by libvips.
 func ColourspaceIsSupported(buf []byte) (bool, error) {
 	return vipsColourspaceIsSupportedBuffer(buf)
@@ -169,21 +196,54 @@ func Metadata(buf []byte) (ImageMetadata, error) {
 	}
 	defer C.g_object_unref(C.gpointer(image))
 
+	return vipsMetadata(image, imageType), nil
+}
+
+// ReadMetadata returns the image metadata reading only the image header and
+// its EXIF data, without decoding any pixels. It is meant for validating
+// uploads, where the dimensions must be known before paying the decoding
+// cost. The input size limits set via SetLimits are enforced, but not the
+// image dimensions ones, so callers can report them.
+func ReadMetadata(buf []byte) (ImageMetadata, error) {
+	defer C.vips_thread_shutdown()
+
+	if len(buf) == 0 {
+		return ImageMetadata{}, wrapError(ErrTruncatedImage, "Image buffer is empty")
+	}
+	if err := GetLimits().checkInput(buf); err != nil {
+		return ImageMetadata{}, err
+	}
+
+	// Sequential access prevents the loaders from decoding the whole
+	// image into memory, and the header is all they read until the
+	// pixels are requested.
+	image, imageType, err := vipsReadOptions(buf, LoadOptions{Access: AccessSequential})
+	if err != nil {
+		return ImageMetadata{}, err
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	return vipsMetadata(image, imageType), nil
+}
+
+// vipsMetadata reads the metadata of a loaded image.
+func vipsMetadata(image *C.VipsImage, imageType ImageType) ImageMetadata {
 	size := ImageSize{
 		Width:  int(image.Xsize),
 		Height: int(image.Ysize),
 	}
 
-	orientation := vipsExifIntTag(image, Orientation)
+	orientation := vipsExifOrientation(image)
 
 	metadata := ImageMetadata{
-		Size:        size,
-		Channels:    int(image.Bands),
-		Orientation: orientation,
-		Alpha:       vipsHasAlpha(image),
-		Profile:     vipsHasProfile(image),
-		Space:       vipsSpace(image),
-		Type:        ImageTypeName(imageType),
+		Size:         size,
+		OrientedSize: orientedSize(size, orientation),
+		Channels:     int(image.Bands),
+		Orientation:  orientation,
+		Alpha:        vipsHasAlpha(image),
+		Profile:      vipsHasProfile(image),
+		Space:        vipsSpace(image),
+		Type:         ImageTypeName(imageType),
 		EXIF: EXIF{
 			Make:                    vipsExifStringTag(image, Make),
 			Model:                   vipsExifStringTag(image, Model),
@@ -239,5 +299,67 @@ func Metadata(buf []byte) (ImageMetadata, error) {
 		},
 	}
 
-	return metadata, nil
+	metadata.EXIFRaw = vipsExifTags(image)
+	metadata.GPS = gpsCoordinates(metadata.EXIF)
+
+	return metadata
+}
+
+// gpsCoordinates parses the EXIF GPS latitude and longitude, applying
+// their hemisphere reference. It returns nil when they are missing or
+// out of range.
+func gpsCoordinates(exif EXIF) *GPSCoordinates {
+	latitude, ok := gpsDegrees(exif.GPSLatitude, exif.GPSLatitudeRef, "S")
+	if !ok || math.Abs(latitude) > 90 {
+		return nil
+	}
+	longitude, ok := gpsDegrees(exif.GPSLongitude, exif.GPSLongitudeRef, "W")
+	if !ok || math.Abs(longitude) > 180 {
+		return nil
+	}
+	return &GPSCoordinates{Latitude: latitude, Longitude: longitude}
+}
+
+// gpsDegrees converts the degrees, minutes and seconds rationals of an EXIF
+// GPS tag, such as "55/1 43/1 5287/100", to decimal degrees. The result is
+// negative when ref starts with the given negative hemisphere.
+func gpsDegrees(value, ref, negative string) (float64, bool) {
+	parts := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+	if len(parts) == 0 || len(parts) > 3 {
+		return 0, false
+	}
+
+	degrees := 0.0
+	for i, part := range parts {
+		n, ok := parseRational(part)
+		if !ok {
+			return 0, false
+		}
+		degrees += n / math.Pow(60, float64(i))
+	}
+
+	if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(ref)), negative) {
+		degrees = -degrees
+	}
+	return degrees, true
+}
+
+// parseRational parses an EXIF rational, such as "5287/100", or a decimal.
+func parseRational(s string) (float64, bool) {
+	i := strings.IndexByte(s, '/')
+	if i < 0 {
+		n, err := strconv.ParseFloat(s, 64)
+		return n, err == nil
+	}
+	num, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, false
+	}
+	den, err := strconv.ParseFloat(s[i+1:], 64)
+	if err != nil || den == 0 {
+		return 0, false
+	}
+	return num / den, true
 }
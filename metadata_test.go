@@ -1,9 +1,12 @@
 package bimg
 
 import (
+	"errors"
 	"io/ioutil"
+	"math"
 	"os"
 	"path"
+	"strings"
 	"testing"
 )
 
@@ -33,7 +36,7 @@ func TestMetadata(t *testing.T) {
 	files := []struct {
 		name        string
 		format      string
-		orientation int
+		orientation ExifOrientation
 		alpha       bool
 		profile     bool
 		space       string
@@ -405,3 +408,124 @@ func readFile(file string) []byte {
 	buf, _ := ioutil.ReadAll(data)
 	return buf
 }
+
+func TestMetadataOrientedSize(t *testing.T) {
+	files := []struct {
+		name          string
+		width, height int
+	}{
+		{"exif/Landscape_1.jpg", 1600, 1200},
+		{"exif/Landscape_5.jpg", 1600, 1200},
+		{"exif/Landscape_8.jpg", 1600, 1200},
+		{"exif/Portrait_6.jpg", 1200, 1600},
+		{"test.jpg", 1680, 1050},
+	}
+
+	for _, file := range files {
+		metadata, err := Metadata(readFile(file.name))
+		if err != nil {
+			t.Fatalf("Cannot read the image: %s -> %s", file.name, err)
+		}
+		if metadata.OrientedSize.Width != file.width || metadata.OrientedSize.Height != file.height {
+			t.Errorf("Unexpected oriented size of %s: %dx%d", file.name, metadata.OrientedSize.Width, metadata.OrientedSize.Height)
+		}
+	}
+}
+
+func TestOrientedSize(t *testing.T) {
+	size := ImageSize{Width: 300, Height: 200}
+	for orientation := OrientationUndefined; orientation <= OrientationLeftBottom; orientation++ {
+		got := orientedSize(size, orientation)
+		swapped := orientation >= 5
+		if swapped && (got.Width != 200 || got.Height != 300) || !swapped && got != size {
+			t.Errorf("Unexpected size for orientation %d: %dx%d", orientation, got.Width, got.Height)
+		}
+	}
+}
+
+func TestReadMetadata(t *testing.T) {
+	files := []string{"test.jpg", "test.png", "test.webp", "test.gif", "exif/Portrait_6.jpg"}
+
+	for _, file := range files {
+		buf := readFile(file)
+		metadata, err := ReadMetadata(buf)
+		if err != nil {
+			t.Fatalf("Cannot read the image: %s -> %s", file, err)
+		}
+		expected, err := Metadata(buf)
+		if err != nil {
+			t.Fatalf("Cannot read the image: %s -> %s", file, err)
+		}
+		if metadata.Type != expected.Type || metadata.Size != expected.Size ||
+			metadata.OrientedSize != expected.OrientedSize || metadata.EXIF != expected.EXIF {
+			t.Errorf("Unexpected metadata of %s: %#v", file, metadata)
+		}
+	}
+
+	if _, err := ReadMetadata(nil); !errors.Is(err, ErrTruncatedImage) {
+		t.Errorf("Expected a truncated image error, got: %v", err)
+	}
+	if _, err := ReadMetadata([]byte("not an image")); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Expected an unsupported format error, got: %v", err)
+	}
+}
+
+func TestMetadataEXIFRaw(t *testing.T) {
+	metadata, err := Metadata(readFile("test_exif_full.jpg"))
+	if err != nil {
+		t.Fatalf("Cannot read the image: %s", err)
+	}
+
+	if metadata.EXIFRaw[Make] != "Apple" || metadata.EXIFRaw[Model] != "iPhone XS" {
+		t.Errorf("Unexpected raw EXIF tags: %#v", metadata.EXIFRaw)
+	}
+	for name, value := range metadata.EXIFRaw {
+		if !strings.HasPrefix(name, "exif-ifd") || strings.Contains(value, " (") {
+			t.Errorf("Unexpected raw EXIF tag: %s = %s", name, value)
+		}
+	}
+
+	if metadata.GPS == nil {
+		t.Fatal("Missing GPS coordinates")
+	}
+	if math.Abs(metadata.GPS.Latitude-55.731353) > 1e-6 || math.Abs(metadata.GPS.Longitude-37.598808) > 1e-6 {
+		t.Errorf("Unexpected GPS coordinates: %#v", metadata.GPS)
+	}
+
+	metadata, err = Metadata(readFile("test.jpg"))
+	if err != nil {
+		t.Fatalf("Cannot read the image: %s", err)
+	}
+	if metadata.GPS != nil {
+		t.Errorf("Unexpected GPS coordinates: %#v", metadata.GPS)
+	}
+}
+
+func TestGPSCoordinates(t *testing.T) {
+	tests := []struct {
+		exif      EXIF
+		latitude  float64
+		longitude float64
+		valid     bool
+	}{
+		{EXIF{GPSLatitude: "55/1 43/1 5287/100", GPSLatitudeRef: "N", GPSLongitude: "37/1 35/1 5571/100", GPSLongitudeRef: "E"}, 55.731353, 37.598808, true},
+		{EXIF{GPSLatitude: "33/1 51/1 0/1", GPSLatitudeRef: "S", GPSLongitude: "151/1 12/1 36/1", GPSLongitudeRef: "W"}, -33.85, -151.21, true},
+		{EXIF{GPSLatitude: "12.5", GPSLatitudeRef: "N", GPSLongitude: "7.25", GPSLongitudeRef: "E"}, 12.5, 7.25, true},
+		{EXIF{GPSLatitude: "95/1 0/1 0/1", GPSLatitudeRef: "N", GPSLongitude: "7/1", GPSLongitudeRef: "E"}, 0, 0, false},
+		{EXIF{GPSLatitude: "55/0", GPSLatitudeRef: "N", GPSLongitude: "7/1", GPSLongitudeRef: "E"}, 0, 0, false},
+		{EXIF{}, 0, 0, false},
+	}
+
+	for _, test := range tests {
+		gps := gpsCoordinates(test.exif)
+		if !test.valid {
+			if gps != nil {
+				t.Errorf("Unexpected GPS coordinates for %s: %#v", test.exif.GPSLatitude, gps)
+			}
+			continue
+		}
+		if gps == nil || math.Abs(gps.Latitude-test.latitude) > 1e-6 || math.Abs(gps.Longitude-test.longitude) > 1e-6 {
+			t.Errorf("Unexpected GPS coordinates for %s: %#v", test.exif.GPSLatitude, gps)
+		}
+	}
+}
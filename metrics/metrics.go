@@ -0,0 +1,209 @@
+// Package metrics exposes bimg operation and libvips memory metrics in the
+// Prometheus text exposition format, without depending on the Prometheus
+// client library.
+//
+//	http.Handle("/metrics", metrics.NewCollector("bimg"))
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/h2non/bimg"
+)
+
+// buckets are the upper bounds, in seconds, of the latency histograms,
+// matching the Prometheus client defaults.
+var buckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// operationKey represents the labels of an operation counter.
+type operationKey struct {
+	operation string
+	imageType string
+	status    string
+}
+
+// durationKey represents the labels of an operation latency histogram.
+type durationKey struct {
+	operation string
+	imageType string
+}
+
+// histogram represents the bucket counts of a latency histogram, the last
+// one counting the observations above every bucket.
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Collector collects the bimg operations counters and latencies, and the
+// libvips memory and cache gauges. It serves them to Prometheus as an
+// http.Handler.
+type Collector struct {
+	namespace string
+
+	mu         sync.Mutex
+	operations map[operationKey]uint64
+	durations  map[durationKey]*histogram
+}
+
+// NewCollector creates a collector using the given metrics namespace, and
+// defines it as the bimg operations observer, replacing any previous one.
+func NewCollector(namespace string) *Collector {
+	c := &Collector{
+		namespace:  namespace,
+		operations: make(map[operationKey]uint64),
+		durations:  make(map[durationKey]*histogram),
+	}
+	bimg.SetObserver(c.Observe)
+	return c
+}
+
+// Observe records an image operation. It is called by bimg once the
+// collector is created.
+func (c *Collector) Observe(e bimg.OperationEvent) {
+	imageType := bimg.ImageTypeName(e.Type)
+	status := "ok"
+	if e.Err != nil {
+		status = "error"
+	}
+	seconds := e.Duration.Seconds()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.operations[operationKey{e.Operation, imageType, status}]++
+
+	key := durationKey{e.Operation, imageType}
+	h, ok := c.durations[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(buckets)+1)}
+		c.durations[key] = h
+	}
+	i := sort.SearchFloat64s(buckets, seconds)
+	h.counts[i]++
+	h.sum += seconds
+	h.count++
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	out := &countWriter{w: bufio.NewWriter(w)}
+
+	c.mu.Lock()
+	c.writeOperations(out)
+	c.writeDurations(out)
+	c.mu.Unlock()
+
+	stats := bimg.VipsMemory()
+	c.writeGauge(out, "vips_memory_bytes", "Memory currently tracked by libvips.", float64(stats.Memory))
+	c.writeGauge(out, "vips_memory_highwater_bytes", "Highest memory tracked by libvips.", float64(stats.MemoryHighwater))
+	c.writeGauge(out, "vips_allocations", "Number of active libvips allocations.", float64(stats.Allocations))
+	c.writeGauge(out, "vips_open_files", "Number of files opened by libvips.", float64(stats.Files))
+	c.writeGauge(out, "vips_cache_entries", "Number of operations in the libvips cache.", float64(stats.CacheEntries))
+
+	if out.err == nil {
+		out.err = out.w.Flush()
+	}
+	return out.n, out.err
+}
+
+func (c *Collector) writeOperations(out *countWriter) {
+	name := c.name("operations_total")
+	out.printf("# HELP %s Number of image operations, by operation, image type and status.\n", name)
+	out.printf("# TYPE %s counter\n", name)
+
+	keys := make([]operationKey, 0, len(c.operations))
+	for key := range c.operations {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.operation != b.operation {
+			return a.operation < b.operation
+		}
+		if a.imageType != b.imageType {
+			return a.imageType < b.imageType
+		}
+		return a.status < b.status
+	})
+	for _, key := range keys {
+		out.printf("%s{operation=%s,type=%s,status=%s} %d\n", name,
+			quote(key.operation), quote(key.imageType), quote(key.status), c.operations[key])
+	}
+}
+
+func (c *Collector) writeDurations(out *countWriter) {
+	name := c.name("operation_duration_seconds")
+	out.printf("# HELP %s Duration of image operations, by operation and image type.\n", name)
+	out.printf("# TYPE %s histogram\n", name)
+
+	keys := make([]durationKey, 0, len(c.durations))
+	for key := range c.durations {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].operation != keys[j].operation {
+			return keys[i].operation < keys[j].operation
+		}
+		return keys[i].imageType < keys[j].imageType
+	})
+	for _, key := range keys {
+		h := c.durations[key]
+		labels := fmt.Sprintf("operation=%s,type=%s", quote(key.operation), quote(key.imageType))
+		var cumulative uint64
+		for i, bound := range buckets {
+			cumulative += h.counts[i]
+			out.printf("%s_bucket{%s,le=\"%g\"} %d\n", name, labels, bound, cumulative)
+		}
+		out.printf("%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+		out.printf("%s_sum{%s} %g\n", name, labels, h.sum)
+		out.printf("%s_count{%s} %d\n", name, labels, h.count)
+	}
+}
+
+func (c *Collector) writeGauge(out *countWriter, suffix, help string, value float64) {
+	name := c.name(suffix)
+	out.printf("# HELP %s %s\n# TYPE %s gauge\n%s %g\n", name, help, name, name, value)
+}
+
+// name prefixes the metric name with the collector namespace.
+func (c *Collector) name(suffix string) string {
+	if c.namespace == "" {
+		return suffix
+	}
+	return c.namespace + "_" + suffix
+}
+
+// quote quotes a label value, escaping the backslashes, double quotes and
+// line feeds.
+func quote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
+
+// countWriter counts the written bytes and keeps the first write error.
+type countWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countWriter) printf(format string, args ...interface{}) {
+	if cw.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(cw.w, format, args...)
+	cw.n += int64(n)
+	cw.err = err
+}
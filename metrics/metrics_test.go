@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/h2non/bimg"
+)
+
+func TestCollector(t *testing.T) {
+	collector := NewCollector("bimg")
+	defer bimg.SetObserver(nil)
+
+	buf, err := ioutil.ReadFile("../testdata/test.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bimg.NewImage(buf).Resize(100, 100); err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+
+	res := httptest.NewRecorder()
+	collector.ServeHTTP(res, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.HasPrefix(res.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("Invalid content type: %s", res.Header().Get("Content-Type"))
+	}
+
+	body := res.Body.String()
+	for _, line := range []string{
+		"# TYPE bimg_operations_total counter",
+		`bimg_operations_total{operation="resize",type="jpeg",status="ok"} 1`,
+		"# TYPE bimg_operation_duration_seconds histogram",
+		`bimg_operation_duration_seconds_bucket{operation="resize",type="jpeg",le="+Inf"} 1`,
+		`bimg_operation_duration_seconds_count{operation="resize",type="jpeg"} 1`,
+		"# TYPE bimg_vips_memory_bytes gauge",
+		"# TYPE bimg_vips_cache_entries gauge",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Missing metric line: %s", line)
+		}
+	}
+}
+
+func TestCollectorHistogram(t *testing.T) {
+	c := &Collector{operations: map[operationKey]uint64{}, durations: map[durationKey]*histogram{}}
+	for _, d := range []float64{0.001, 0.005, 0.3, 20} {
+		c.Observe(bimg.OperationEvent{Operation: "save", Type: bimg.PNG, Duration: seconds(d)})
+	}
+
+	var out strings.Builder
+	if _, err := c.WriteTo(&out); err != nil {
+		t.Fatalf("Cannot write the metrics: %s", err)
+	}
+	for _, line := range []string{
+		`operation_duration_seconds_bucket{operation="save",type="png",le="0.005"} 2`,
+		`operation_duration_seconds_bucket{operation="save",type="png",le="0.25"} 2`,
+		`operation_duration_seconds_bucket{operation="save",type="png",le="0.5"} 3`,
+		`operation_duration_seconds_bucket{operation="save",type="png",le="10"} 3`,
+		`operation_duration_seconds_bucket{operation="save",type="png",le="+Inf"} 4`,
+		`operation_duration_seconds_count{operation="save",type="png"} 4`,
+		`operations_total{operation="save",type="png",status="ok"} 4`,
+	} {
+		if !strings.Contains(out.String(), "\n"+line+"\n") {
+			t.Errorf("Missing metric line: %s", line)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,154 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"path/filepath"
+)
+
+// MontageItem represents an image of a montage and its caption.
+type MontageItem struct {
+	Image *Image
+	Label string
+}
+
+// MontageOptions represents the Montage options. The grid options define
+// the layout, CellSize excluding the captions.
+type MontageOptions struct {
+	GridOptions
+	// Font defines the caption font. Defaults to WatermarkFont.
+	Font string
+	// DPI defines the caption resolution. Defaults to 72.
+	DPI int
+	// LabelColor defines the caption color. When it equals the background,
+	// the default, white or black is chosen for contrast.
+	LabelColor Color
+}
+
+// Montage composes the images into a grid of the given number of columns
+// and renders each caption centred under its image, e.g. for dataset
+// review tooling.
+func Montage(items []MontageItem, cols int, o MontageOptions) (*Image, error) {
+	defer C.vips_thread_shutdown()
+
+	if len(items) == 0 {
+		return nil, errors.New("No montage images given")
+	}
+	if cols <= 0 {
+		return nil, fmt.Errorf("Invalid number of montage columns: %d", cols)
+	}
+	if o.Padding < 0 || o.CellSize.Width < 0 || o.CellSize.Height < 0 {
+		return nil, errors.New("Invalid montage padding or cell size")
+	}
+	if cols > len(items) {
+		cols = len(items)
+	}
+	if o.Font == "" {
+		o.Font = WatermarkFont
+	}
+	if o.DPI == 0 {
+		o.DPI = 72
+	}
+	if o.LabelColor == o.Background {
+		o.LabelColor = contrastColor(o.Background)
+	}
+
+	images := make([]*Image, len(items))
+	for i, item := range items {
+		images[i] = item.Image
+	}
+	cells, cellWidth, cellHeight, err := gridCells(images, o.CellSize)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make([]*C.VipsImage, len(items))
+	release := func() {
+		releaseImages(cells)
+		releaseImages(labels)
+	}
+	labelHeight := 0
+	for i, item := range items {
+		if item.Label == "" {
+			continue
+		}
+		// Labels are Pango markup, as file names may contain "&" or "<"
+		mask, err := vipsText(template.HTMLEscapeString(item.Label), o.Font, cellWidth, o.DPI)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		labels[i] = mask
+		if int(mask.Ysize) > labelHeight {
+			labelHeight = int(mask.Ysize)
+		}
+	}
+
+	// Check the composed size before building it
+	width, height, err := gridSize(len(cells), cols, cellWidth, cellHeight+labelHeight, o.Padding)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	for i := range cells {
+		cell, err := montageCell(cells[i], labels[i], cellWidth, cellHeight, labelHeight, o)
+		cells[i], labels[i] = cell, nil
+		if err != nil {
+			release()
+			return nil, err
+		}
+	}
+	return composeGrid(cells, cols, cellWidth, cellHeight+labelHeight, width, height, o.GridOptions)
+}
+
+// MontageFiles composes the image files into a montage captioned with
+// their file names.
+func MontageFiles(paths []string, cols int, o MontageOptions) (*Image, error) {
+	items := make([]MontageItem, len(paths))
+	for i, path := range paths {
+		buf, err := Read(path)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = MontageItem{Image: NewImage(buf), Label: filepath.Base(path)}
+	}
+	return Montage(items, cols, o)
+}
+
+// montageCell centres the image in the cell and appends its caption,
+// releasing both.
+func montageCell(image, mask *C.VipsImage, width, height, labelHeight int, o MontageOptions) (*C.VipsImage, error) {
+	left := (width - int(image.Xsize)) / 2
+	top := (height - int(image.Ysize)) / 2
+	if mask == nil {
+		return vipsEmbed(image, left, top, width, height+labelHeight, ExtendBackground, o.Background)
+	}
+
+	image, err := vipsEmbed(image, left, top, width, height, ExtendBackground, o.Background)
+	if err != nil {
+		C.g_object_unref(C.gpointer(mask))
+		return nil, err
+	}
+	label, err := vipsLabel(mask, width, labelHeight, o.LabelColor, o.Background)
+	if err != nil {
+		C.g_object_unref(C.gpointer(image))
+		return nil, err
+	}
+	return vipsJoin(image, label, Vertical, JoinOptions{Background: o.Background})
+}
+
+// contrastColor returns white for dark colors and black otherwise.
+func contrastColor(c Color) Color {
+	if 0.299*float64(c.R)+0.587*float64(c.G)+0.114*float64(c.B) < 128 {
+		return Color{255, 255, 255}
+	}
+	return Color{}
+}
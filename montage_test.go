@@ -0,0 +1,90 @@
+package bimg
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestMontage(t *testing.T) {
+	items := []MontageItem{
+		{Image: initImage("test.jpg"), Label: "first"},
+		{Image: initImage("test.png"), Label: "second"},
+		{Image: initImage("test.webp")},
+	}
+	montage, err := Montage(items, 3, MontageOptions{
+		GridOptions: GridOptions{Padding: 4, CellSize: ImageSize{Width: 120, Height: 90}},
+	})
+	if err != nil {
+		t.Fatalf("Cannot make the montage: %#v", err)
+	}
+
+	size, err := montage.Size()
+	if err != nil {
+		t.Fatalf("Cannot read the montage size: %#v", err)
+	}
+	if size.Width != 3*120+4*4 {
+		t.Errorf("Invalid montage width: %d", size.Width)
+	}
+	if size.Height <= 90+2*4 {
+		t.Errorf("The montage should include the captions: %d", size.Height)
+	}
+	if montage.Type() != "png" {
+		t.Errorf("Invalid montage type: %s", montage.Type())
+	}
+}
+
+func TestMontageLabelMarkup(t *testing.T) {
+	// Labels are rendered as text, not parsed as Pango markup
+	items := []MontageItem{
+		{Image: initImage("test.jpg"), Label: "cats & dogs <b>.jpg"},
+		{Image: initImage("test.png"), Label: "<span foreground='red'>x"},
+	}
+	montage, err := Montage(items, 2, MontageOptions{GridOptions: GridOptions{CellSize: ImageSize{Width: 120, Height: 90}}})
+	if err != nil {
+		t.Fatalf("Cannot make the montage: %#v", err)
+	}
+	if size, _ := montage.Size(); size.Width != 2*120 || size.Height <= 90 {
+		t.Errorf("Invalid montage size: %#v", size)
+	}
+}
+
+func TestMontageFiles(t *testing.T) {
+	paths := []string{filepath.Join("testdata", "test.jpg"), filepath.Join("testdata", "missing.jpg")}
+	if _, err := MontageFiles(paths, 2, MontageOptions{}); err == nil {
+		t.Error("Missing files should fail")
+	}
+
+	montage, err := MontageFiles(paths[:1], 2, MontageOptions{GridOptions: GridOptions{CellSize: ImageSize{Width: 100, Height: 100}}})
+	if err != nil {
+		t.Fatalf("Cannot make the montage: %#v", err)
+	}
+	size, _ := montage.Size()
+	if size.Width != 100 || size.Height <= 100 {
+		t.Errorf("Invalid montage size: %#v", size)
+	}
+}
+
+func TestMontageLimits(t *testing.T) {
+	SetLimits(Limits{MaxWidth: 500})
+	defer SetLimits(Limits{})
+
+	items := []MontageItem{{Image: initImage("test.png")}, {Image: initImage("test.png")}}
+	o := MontageOptions{GridOptions: GridOptions{CellSize: ImageSize{Width: 200, Height: 200}, Padding: 10}}
+	if _, err := Montage(items, 2, o); err != nil {
+		t.Fatalf("Cannot compose the montage: %#v", err)
+	}
+	o.Padding = 50
+	if _, err := Montage(items, 2, o); !errors.Is(err, ErrDimensionsTooLarge) {
+		t.Errorf("Expected a limits error: %#v", err)
+	}
+}
+
+func TestContrastColor(t *testing.T) {
+	if contrastColor(Color{}) != (Color{255, 255, 255}) {
+		t.Error("Dark backgrounds should use white captions")
+	}
+	if contrastColor(Color{240, 240, 200}) != (Color{}) {
+		t.Error("Light backgrounds should use black captions")
+	}
+}
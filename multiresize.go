@@ -0,0 +1,209 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ResizeOptions represents an output size of MultiResize.
+type ResizeOptions struct {
+	// Width and Height define the output size. The image fits within it
+	// keeping its aspect ratio, and a zero side is inferred from the other.
+	// Images are not enlarged.
+	Width  int
+	Height int
+	// Crop fills the whole size, cropping the centre of the image.
+	Crop bool
+	// Save defines the encoding options. Its type defaults to the image
+	// type.
+	Save SaveOptions
+
+	enlarge bool
+}
+
+// multiResize decodes the image buffer region once, shrinking it on load to
+// the largest output size, and resizes then encodes every size from it.
+func multiResize(buf []byte, region Region, sizes []ResizeOptions) ([][]byte, error) {
+	defer C.vips_thread_shutdown()
+
+	if len(sizes) == 0 {
+		return nil, errors.New("No resize sizes given")
+	}
+	for _, s := range sizes {
+		if s.Width < 0 || s.Height < 0 || (s.Width == 0 && s.Height == 0) || (s.Crop && (s.Width == 0 || s.Height == 0)) {
+			return nil, fmt.Errorf("Invalid resize size: %dx%d", s.Width, s.Height)
+		}
+	}
+
+	images, err := resizeAll(buf, region, func(width, height int) ([]ResizeOptions, error) {
+		return sizes, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([][]byte, len(images))
+	for i, img := range images {
+		out[i] = img.buf
+	}
+	return out, nil
+}
+
+// resizedImage represents an encoded output of resizeAll.
+type resizedImage struct {
+	buf           []byte
+	width, height int
+}
+
+// resizeAll decodes the image buffer region once, shrinking it on load to
+// the largest output size, and resizes then encodes every size from it. The
+// sizes are returned by sizesOf for the auto-rotated image size. Following
+// sizes with the same dimensions share their resize.
+func resizeAll(buf []byte, region Region, sizesOf func(width, height int) ([]ResizeOptions, error)) ([]resizedImage, error) {
+	image, imageType, err := loadImageOptions(buf, LoadOptions{Region: region})
+	if err != nil {
+		return nil, err
+	}
+
+	// Sizes are relative to the auto-rotated image
+	width, height := int(image.Xsize), int(image.Ysize)
+	if vipsExifOrientation(image) > 4 {
+		width, height = height, width
+	}
+	sizes, err := sizesOf(width, height)
+	if err != nil {
+		C.g_object_unref(C.gpointer(image))
+		return nil, err
+	}
+
+	// Shrink on load to the largest output, which every size is derived from.
+	// Regions are decoded as is, as the shrunk image would not be cropped.
+	scales := make([]float64, len(sizes))
+	largest := 0.0
+	for i, s := range sizes {
+		scales[i] = multiResizeScale(width, height, s)
+		largest = math.Max(largest, scales[i])
+
+		outWidth, outHeight := resizedSize(width, height, scales[i])
+		if err := GetLimits().checkSize(outWidth, outHeight, 1); err != nil {
+			C.g_object_unref(C.gpointer(image))
+			return nil, err
+		}
+	}
+	if shrink := int(math.Floor(1 / largest)); shrink >= 2 && region == (Region{}) && (imageType == JPEG || imageType == WEBP) {
+		image, _, err = shrinkOnLoad(buf, image, imageType, 1/largest, shrink, LoadOptions{})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	image, _, err = rotateAndFlipImage(image, Options{})
+	if err != nil {
+		return nil, err
+	}
+	// Decode once, as every resize reads the whole image
+	image, err = vipsCopyMemory(image)
+	if err != nil {
+		return nil, err
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	// sameResize reports whether the sizes i and j give the same image
+	sameResize := func(i, j int) bool {
+		a, b := sizes[i], sizes[j]
+		return scales[i] == scales[j] && a.Width == b.Width && a.Height == b.Height && a.Crop == b.Crop
+	}
+
+	out := make([]resizedImage, len(sizes))
+	var resized *C.VipsImage
+	defer func() {
+		if resized != nil {
+			C.g_object_unref(C.gpointer(resized))
+		}
+	}()
+	for i, s := range sizes {
+		if i == 0 || !sameResize(i, i-1) {
+			if resized != nil {
+				C.g_object_unref(C.gpointer(resized))
+			}
+			resized, err = resizeOptions(image, width, height, scales[i], s)
+			if err != nil {
+				return nil, err
+			}
+			// Resize once for the following sizes of the same dimensions
+			if i+1 < len(sizes) && sameResize(i, i+1) {
+				resized, err = vipsCopyMemory(resized)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		encoded, err := encodeSaveOptions(resized, imageType, buf, s.Save)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = resizedImage{buf: encoded, width: int(resized.Xsize), height: int(resized.Ysize)}
+	}
+	return out, nil
+}
+
+// multiResizeScale returns the scale of the image to the size, at most 1
+// unless enlarging.
+func multiResizeScale(width, height int, s ResizeOptions) float64 {
+	x, y := float64(s.Width)/float64(width), float64(s.Height)/float64(height)
+	var scale float64
+	switch {
+	case s.Width == 0:
+		scale = y
+	case s.Height == 0:
+		scale = x
+	case s.Crop:
+		scale = math.Max(x, y)
+	default:
+		scale = math.Min(x, y)
+	}
+	if s.enlarge {
+		return scale
+	}
+	return math.Min(scale, 1)
+}
+
+// resizedSize returns the size of the source scaled by the given factor.
+func resizedSize(width, height int, scale float64) (int, int) {
+	return int(math.Max(1, math.Round(float64(width)*scale))), int(math.Max(1, math.Round(float64(height)*scale)))
+}
+
+// resizeOptions resizes the decoded image to the scale of the source size,
+// and crops it when required. It keeps the image reference for further
+// sizes.
+func resizeOptions(image *C.VipsImage, width, height int, scale float64, s ResizeOptions) (*C.VipsImage, error) {
+	outWidth, outHeight := resizedSize(width, height, scale)
+
+	// vipsResize releases the image
+	C.g_object_ref(C.gpointer(image))
+	resized, err := vipsResize(image,
+		float64(outWidth)/float64(image.Xsize), float64(outHeight)/float64(image.Ysize))
+	if err != nil {
+		return nil, err
+	}
+
+	resizedWidth, resizedHeight := int(resized.Xsize), int(resized.Ysize)
+	if s.Crop && (resizedWidth > s.Width || resizedHeight > s.Height) {
+		cropWidth, cropHeight := s.Width, s.Height
+		if cropWidth > resizedWidth {
+			cropWidth = resizedWidth
+		}
+		if cropHeight > resizedHeight {
+			cropHeight = resizedHeight
+		}
+		return vipsExtract(resized, (resizedWidth-cropWidth)/2, (resizedHeight-cropHeight)/2, cropWidth, cropHeight)
+	}
+	return resized, nil
+}
@@ -0,0 +1,92 @@
+package bimg
+
+import "testing"
+
+func TestImageMultiResize(t *testing.T) {
+	sizes := []ResizeOptions{
+		{Width: 800},
+		{Width: 300, Height: 300, Crop: true, Save: SaveOptions{Type: WEBP}},
+		{Width: 400, Height: 400},
+		{Height: 100, Save: SaveOptions{Type: PNG}},
+		{Width: 3000},
+	}
+	out, err := initImage("test.jpg").MultiResize(sizes)
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if len(out) != len(sizes) {
+		t.Fatalf("Invalid number of outputs: %d", len(out))
+	}
+
+	expected := []struct {
+		width, height int
+		imageType     ImageType
+	}{
+		{800, 500, JPEG},
+		{300, 300, WEBP},
+		{400, 250, JPEG},
+		{160, 100, PNG},
+		{1680, 1050, JPEG},
+	}
+	for i, e := range expected {
+		if DetermineImageType(out[i]) != e.imageType {
+			t.Errorf("Invalid image type of output %d", i)
+		}
+		if err := assertSize(out[i], e.width, e.height); err != nil {
+			t.Errorf("Output %d: %s", i, err)
+		}
+	}
+	Write("testdata/test_multiresize_out.webp", out[1])
+}
+
+func TestImageMultiResizeOrientation(t *testing.T) {
+	out, err := initImage("exif/Landscape_6.jpg").MultiResize([]ResizeOptions{{Width: 200}})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if err := assertSize(out[0], 200, 150); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestImageMultiResizeRegion(t *testing.T) {
+	region := Region{Left: 100, Top: 50, Width: 800, Height: 600}
+	sizes := []ResizeOptions{{Width: 400}, {Width: 200, Height: 200, Crop: true}}
+	out, err := NewImageFromBufferRegion(readFile("test.jpg"), region).MultiResize(sizes)
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if err := assertSize(out[0], 400, 300); err != nil {
+		t.Error(err)
+	}
+	if err := assertSize(out[1], 200, 200); err != nil {
+		t.Error(err)
+	}
+
+	// The region is resized as Process does
+	expected, err := NewImageFromBufferRegion(readFile("test.jpg"), region).Process(Options{Width: 400})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	diff, err := Diff(NewImage(expected), NewImage(out[0]), DiffOptions{Threshold: 32})
+	if err != nil {
+		t.Fatalf("Cannot compare the images: %#v", err)
+	}
+	if diff.Score > 0.01 {
+		t.Errorf("MultiResize and Process regions differ: %g", diff.Score)
+	}
+}
+
+func TestImageMultiResizeErrors(t *testing.T) {
+	cases := [][]ResizeOptions{
+		nil,
+		{{}},
+		{{Width: -1}},
+		{{Width: 100, Crop: true}},
+	}
+	for _, sizes := range cases {
+		if _, err := initImage("test.jpg").MultiResize(sizes); err == nil {
+			t.Errorf("Expected an error for sizes %v", sizes)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package bimg
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// OperationEvent represents a completed image operation, as reported to
+// the observer defined with SetObserver.
+type OperationEvent struct {
+	// Operation is either "resize", for the whole Resize and Process
+	// pipeline, or "save", for every image encoding.
+	Operation string
+	// Type is the input image type of resize operations, and the output
+	// image type of save operations.
+	Type     ImageType
+	Duration time.Duration
+	Err      error
+}
+
+type operationObserver struct {
+	fn func(OperationEvent)
+}
+
+var observer atomic.Value
+
+// SetObserver defines a function called after every image operation, e.g.
+// to collect metrics. It must be safe for concurrent use. Pass nil to
+// remove the observer.
+func SetObserver(fn func(OperationEvent)) {
+	observer.Store(operationObserver{fn})
+}
+
+func observeOperation(operation string, imageType ImageType, start time.Time, err *error) {
+	o, _ := observer.Load().(operationObserver)
+	if o.fn == nil {
+		return
+	}
+	o.fn(OperationEvent{
+		Operation: operation,
+		Type:      imageType,
+		Duration:  time.Since(start),
+		Err:       *err,
+	})
+}
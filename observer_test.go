@@ -0,0 +1,50 @@
+package bimg
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSetObserver(t *testing.T) {
+	var mu sync.Mutex
+	var events []OperationEvent
+	SetObserver(func(e OperationEvent) {
+		mu.Lock()
+		events = append(events, e)
+		mu.Unlock()
+	})
+	defer SetObserver(nil)
+
+	_, err := Resize(readFile("test.jpg"), Options{Width: 100, Type: PNG})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) < 2 {
+		t.Fatalf("Invalid number of events: %d", len(events))
+	}
+	save := events[len(events)-2]
+	if save.Operation != "save" || save.Type != PNG || save.Err != nil {
+		t.Errorf("Invalid save event: %#v", save)
+	}
+	resize := events[len(events)-1]
+	if resize.Operation != "resize" || resize.Type != JPEG || resize.Duration <= 0 {
+		t.Errorf("Invalid resize event: %#v", resize)
+	}
+}
+
+func TestSetObserverError(t *testing.T) {
+	var event OperationEvent
+	SetObserver(func(e OperationEvent) { event = e })
+	defer SetObserver(nil)
+
+	_, err := Resize([]byte("invalid"), Options{})
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+	if event.Operation != "resize" || event.Err != err {
+		t.Errorf("Invalid event: %#v", event)
+	}
+}
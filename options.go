@@ -5,7 +5,10 @@ package bimg
 #include "vips/vips.h"
 */
 import "C"
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 const (
 	// Quality defines the default JPEG quality to be used.
@@ -96,6 +99,20 @@ const (
 	D315 Angle = 315
 )
 
+// ResizeMode represents how the image is fitted in the Width and Height box.
+type ResizeMode int
+
+const (
+	// ResizeModeDefault fits the image as the Crop, Embed, Force and
+	// Enlarge options tell.
+	ResizeModeDefault ResizeMode = iota
+	// ResizeModeCover scales the image, enlarging it if needed, to fully
+	// cover the box, then crops the overflow by the Gravity option, like
+	// the CSS object-fit: cover. GravitySmart keeps the most interesting
+	// area. The output size always matches the box.
+	ResizeModeCover
+)
+
 // Direction represents the image direction value.
 type Direction int
 
@@ -106,6 +123,18 @@ const (
 	Vertical Direction = C.VIPS_DIRECTION_VERTICAL
 )
 
+// Align represents the alignment of joined images, or of text lines.
+type Align int
+
+const (
+	// AlignLow aligns the images on their top or left edges.
+	AlignLow Align = C.VIPS_ALIGN_LOW
+	// AlignCentre aligns the images on their centres.
+	AlignCentre Align = C.VIPS_ALIGN_CENTRE
+	// AlignHigh aligns the images on their bottom or right edges.
+	AlignHigh Align = C.VIPS_ALIGN_HIGH
+)
+
 // Interpretation represents the image interpretation type.
 // See: https://libvips.github.io/libvips/API/current/VipsImage.html#VipsInterpretation
 type Interpretation int
@@ -133,6 +162,10 @@ const (
 	InterpretationLAB Interpretation = C.VIPS_INTERPRETATION_LAB
 	// InterpretationXYZ points to its libvips interpretation equivalent type.
 	InterpretationXYZ Interpretation = C.VIPS_INTERPRETATION_XYZ
+	// InterpretationLCH points to its libvips interpretation equivalent type.
+	InterpretationLCH Interpretation = C.VIPS_INTERPRETATION_LCH
+	// InterpretationHSV points to its libvips interpretation equivalent type.
+	InterpretationHSV Interpretation = C.VIPS_INTERPRETATION_HSV
 )
 
 // Extend represents the image extend mode, used when the edges
@@ -157,6 +190,34 @@ const (
 	ExtendLast Extend = C.VIPS_EXTEND_LAST
 )
 
+// validateExtend checks the extend mode is one of the libvips strategies.
+func validateExtend(e Extend) error {
+	if e < ExtendBlack || e > ExtendBackground {
+		return fmt.Errorf("Invalid extend mode: %d", e)
+	}
+	return nil
+}
+
+// EmbedOptions represents how the area around an embedded image is filled.
+// Extend defines the strategy, e.g. ExtendMirror for the reflect padding of
+// machine learning inputs, and Background the color for ExtendBackground.
+type EmbedOptions struct {
+	Extend     Extend
+	Background Color
+}
+
+// EdgeDetector represents the edge detection algorithm.
+type EdgeDetector int
+
+const (
+	// EdgeNone disables the edge detection.
+	EdgeNone EdgeDetector = iota
+	// EdgeSobel detects edges using the Sobel operator.
+	EdgeSobel
+	// EdgeCanny detects edges using the Canny algorithm.
+	EdgeCanny
+)
+
 // WatermarkFont defines the default watermark font to be used.
 var WatermarkFont = "sans 10"
 
@@ -186,12 +247,31 @@ type WatermarkImage struct {
 	Top     int
 	Buf     []byte
 	Opacity float32
+	// AlphaBlend composites the watermark with the premultiplied over
+	// operator, its own alpha channel multiplied by Opacity, so the
+	// semi-transparent edges of logos render correctly and transparent
+	// images keep their transparency. Requires libvips 8.6+.
+	AlphaBlend bool
+	// WidthPercent and HeightPercent resize the watermark to the given
+	// percentage of the image width or height, keeping its aspect ratio,
+	// so logos stay proportionate across image sizes. When both are given
+	// the watermark fits in both.
+	WidthPercent  float64
+	HeightPercent float64
 }
 
 // GaussianBlur represents the gaussian image transformation values.
 type GaussianBlur struct {
 	Sigma   float64
 	MinAmpl float64
+	// Region restricts the blur to the given area, when not empty. The
+	// area is relative to the auto-rotated image before any resize, and so
+	// is the Sigma of region blurs.
+	Region Region
+	// Mask restricts the blur to the light areas of the given image, scaled
+	// to the image size. Grey areas blend the blurred and original pixels.
+	// The Region is ignored when a mask is given.
+	Mask []byte
 }
 
 // Sharpen represents the image sharp transformation options.
@@ -204,6 +284,522 @@ type Sharpen struct {
 	M2     float64
 }
 
+// UnsharpMask represents the sharpening options using the unsharp mask
+// semantics of common photo editors: Radius is the blur radius in pixels,
+// Amount the sharpening strength as a percentage (e.g. 150) and Threshold
+// the minimum brightness difference (0-255) between a pixel and its
+// surroundings required for it to be sharpened.
+type UnsharpMask struct {
+	Radius    float64
+	Amount    float64
+	Threshold float64
+}
+
+// Denoise represents the noise reduction options. Size is the median filter
+// window size in pixels (odd, defaults to 3), Passes how many times the
+// filter is applied (defaults to 1) and Sigma an optional gaussian blur applied
+// afterwards to smooth out the remaining fine grain.
+type Denoise struct {
+	Size   int
+	Passes int
+	Sigma  float64
+}
+
+// EdgeDetection represents the edge detection options. The output is a
+// greyscale edge map. Sigma defines the Canny gaussian smoothing and
+// defaults to 1.4.
+type EdgeDetection struct {
+	Detector EdgeDetector
+	Sigma    float64
+}
+
+// Vignette represents the vignette effect options. Strength (0-1) defines the
+// opacity of the Color at the image corners, and Radius (0-1) the fraction of
+// the distance from the centre to the corners where the darkening begins.
+type Vignette struct {
+	Strength float64
+	Radius   float64
+	Color    Color
+}
+
+// GrainOptions represents the film grain options. Amount defines the noise
+// standard deviation in 8-bit levels, e.g. 8 for a fine grain. Size defines
+// the grain size in pixels and defaults to 1. Monochrome adds the same noise
+// to every colour band, changing the luminance only.
+type GrainOptions struct {
+	Amount     float64
+	Monochrome bool
+	Size       int
+}
+
+// MotionBlur represents the motion blur effect options, which smears the
+// image along a line of Distance pixels at the given Angle, in degrees
+// counter-clockwise from the horizontal axis. The distance is relative to
+// the auto-rotated image before any resize, and limited to 256 pixels and
+// the image diagonal.
+type MotionBlur struct {
+	Angle    float64
+	Distance int
+}
+
+// Point represents a position in the image, in pixels from its top-left
+// corner.
+type Point struct {
+	X int
+	Y int
+}
+
+// RadialBlur represents the zoom blur effect options, which smears the
+// image outwards from the Center point, relative to the auto-rotated image
+// before any resize. Amount (0-1) defines the length of the smear as a
+// fraction of the distance to the centre.
+type RadialBlur struct {
+	Center Point
+	Amount float64
+}
+
+// Border represents the border added around the image, in pixels per side.
+// Extend defines how the border is filled, with the Background color for
+// ExtendBackground, which defaults to opaque black. A translucent
+// background adds an alpha channel to the image.
+type Border struct {
+	Top        int
+	Right      int
+	Bottom     int
+	Left       int
+	Background RGBAProvider
+	Extend     Extend
+}
+
+// Pixelate represents the pixelation of an area of the image, such as a
+// face or a license plate to redact, in square blocks of BlockSize pixels.
+// The area and the block size are relative to the auto-rotated image
+// before any resize, and the whole image is pixelated when the area is
+// empty.
+type Pixelate struct {
+	Region    Region
+	BlockSize int
+}
+
+// Binarize represents the threshold (black and white) transformation options.
+// When Enabled, pixels brighter than Threshold become white and everything
+// else black. When Adaptive is enabled, every pixel is compared against the gaussian
+// weighted mean of its Radius neighbourhood minus Offset instead, which copes better with uneven
+// lighting such as photographed documents.
+type Binarize struct {
+	Enabled   bool
+	Threshold float64
+	Adaptive  bool
+	Radius    int
+	Offset    float64
+}
+
+// Subsample represents the JPEG chroma subsampling mode.
+type Subsample int
+
+const (
+	// SubsampleAuto uses 4:2:0 chroma subsampling below quality 90, and
+	// 4:4:4 otherwise.
+	SubsampleAuto Subsample = iota
+	// Subsample420 always uses 4:2:0 chroma subsampling.
+	Subsample420
+	// Subsample444 disables chroma subsampling.
+	Subsample444
+)
+
+// JPEGOptions represents the advanced JPEG encoder options. Trellis
+// quantization, overshoot deringing, scans optimization and quantization
+// tables require libvips 8.6+ built with mozjpeg. libvips does not support
+// 4:2:2 chroma subsampling.
+type JPEGOptions struct {
+	Subsample          Subsample
+	TrellisQuant       bool
+	OvershootDeringing bool
+	OptimizeScans      bool
+	// QuantTable selects one of the mozjpeg quantization tables, from 0 to 8.
+	QuantTable int
+	// NoOptimizeCoding disables the Huffman tables optimization, which is
+	// enabled by default.
+	NoOptimizeCoding bool
+}
+
+// PNGFilter represents the PNG row filters used by the encoder. Filters
+// can be combined, e.g. PNGFilterSub | PNGFilterUp.
+type PNGFilter int
+
+const (
+	// PNGFilterNone disables row filtering.
+	PNGFilterNone PNGFilter = 0x08
+	// PNGFilterSub uses the sub row filter.
+	PNGFilterSub PNGFilter = 0x10
+	// PNGFilterUp uses the up row filter.
+	PNGFilterUp PNGFilter = 0x20
+	// PNGFilterAvg uses the average row filter.
+	PNGFilterAvg PNGFilter = 0x40
+	// PNGFilterPaeth uses the paeth row filter.
+	PNGFilterPaeth PNGFilter = 0x80
+	// PNGFilterAll lets the encoder pick the best filter for each row.
+	PNGFilterAll PNGFilter = 0xF8
+)
+
+// PNGOptions represents the advanced PNG encoder options. Colors and
+// Dither only apply when Palette is enabled, which requires libvips built
+// with libimagequant or quantizr.
+type PNGOptions struct {
+	// Filter defines the row filters. Defaults to PNGFilterAll.
+	Filter PNGFilter
+	// Colors defines the maximum number of palette colors, up to 256. It is
+	// rounded up to the nearest palette bit depth, 1, 2, 4 or 8 bits,
+	// unless BitDepth is defined.
+	Colors int
+	// Dither defines the amount of dithering, from 0 to 1. Zero uses the
+	// libvips default of 1, a negative value disables dithering.
+	Dither float64
+}
+
+// WebPOptions represents the advanced WebP encoder options. Lossless
+// encoding is enabled via Options.Lossless.
+type WebPOptions struct {
+	// NearLossless preprocesses the image to improve lossless compression,
+	// using Quality as the preprocessing level.
+	NearLossless bool
+	// AlphaQuality defines the alpha channel quality, from 1 to 100.
+	// Defaults to 100.
+	AlphaQuality int
+	// Effort defines the CPU effort, from 1 (fastest) to 6 (slowest).
+	// Defaults to 4. Requires libvips 8.8+.
+	Effort int
+	// SmartSubsample enables the sharp RGB to YUV conversion.
+	SmartSubsample bool
+	// KMin and KMax define the minimum and maximum distance between
+	// animation key frames. KMax defaults to 1, so every frame is a key
+	// frame, and KMin to KMax - 1.
+	KMin int
+	KMax int
+}
+
+// TIFFCompression represents the TIFF compression method.
+type TIFFCompression int
+
+const (
+	// TIFFCompressionNone stores the image uncompressed.
+	TIFFCompressionNone TIFFCompression = iota
+	// TIFFCompressionJPEG uses lossy JPEG compression, honoring Quality.
+	TIFFCompressionJPEG
+	// TIFFCompressionDeflate uses zlib compression.
+	TIFFCompressionDeflate
+	// TIFFCompressionPackBits uses run length compression.
+	TIFFCompressionPackBits
+	// TIFFCompressionCCITTFax4 uses fax compression for 1-bit images.
+	TIFFCompressionCCITTFax4
+	// TIFFCompressionLZW uses LZW compression.
+	TIFFCompressionLZW
+	// TIFFCompressionWebP uses WebP compression, honoring Quality.
+	TIFFCompressionWebP
+	// TIFFCompressionZSTD uses Zstandard compression.
+	TIFFCompressionZSTD
+)
+
+// TIFFPredictor represents the TIFF compression predictor used by the
+// LZW, deflate and ZSTD compression methods.
+type TIFFPredictor int
+
+const (
+	// TIFFPredictorNone disables the predictor.
+	TIFFPredictorNone TIFFPredictor = 1
+	// TIFFPredictorHorizontal uses horizontal differencing, the default.
+	TIFFPredictorHorizontal TIFFPredictor = 2
+	// TIFFPredictorFloat uses floating point differencing.
+	TIFFPredictorFloat TIFFPredictor = 3
+)
+
+// TIFFOptions represents the TIFF encoder options.
+type TIFFOptions struct {
+	Compression TIFFCompression
+	Predictor   TIFFPredictor
+	// Tile writes a tiled image instead of strips. TileWidth and
+	// TileHeight default to 128.
+	Tile       bool
+	TileWidth  int
+	TileHeight int
+	// Pyramid writes a tiled, multi resolution image.
+	Pyramid bool
+	// BigTIFF writes a BigTIFF image, needed for outputs over 4GB.
+	BigTIFF bool
+}
+
+// SaveOptions represents the encoding options of a single output image,
+// used to encode the same image to multiple formats.
+type SaveOptions struct {
+	// Type defines the output image type. Defaults to the image type.
+	Type ImageType
+	// Quality defaults to 75.
+	Quality int
+	// Compression defines the PNG compression level. Defaults to 6.
+	Compression   int
+	Interlace     bool
+	StripMetadata bool
+	Lossless      bool
+	Palette       bool
+	Speed         int
+	BitDepth      int
+	KeepMetadata  Keep
+	// Interpretation defines the output color space. Defaults to sRGB.
+	Interpretation Interpretation
+	JPEG           JPEGOptions
+	PNG            PNGOptions
+	WebP           WebPOptions
+	TIFF           TIFFOptions
+	GIF            GIFOptions
+	// AutoFormat chooses the output type with ChooseType, based on the
+	// Accept header and the image alpha channel, when Type is not defined.
+	AutoFormat bool
+	// Accept defines the client HTTP Accept header used by AutoFormat.
+	Accept string
+	// TargetSize defines the maximum output size in bytes. For JPEG, WebP,
+	// HEIF, AVIF and palette based PNG images the highest quality fitting
+	// it is searched for, using Quality as the upper bound. Saving fails
+	// when the image cannot fit.
+	TargetSize int
+	// C2PA attaches, keeps or signs the C2PA content credentials of JPEG,
+	// PNG and WebP outputs, as Options.C2PA does.
+	C2PA C2PAOptions
+}
+
+// GIFOptions represents the GIF encoder options. They require libvips
+// 8.12+ built with cgif.
+type GIFOptions struct {
+	// Colors defines the maximum number of palette colors, from 2 to 256.
+	// It is rounded up to the nearest power of two. Defaults to 256.
+	Colors int
+	// Dither defines the amount of dithering, from 0 to 1. Zero uses the
+	// libvips default of 1, a negative value disables dithering.
+	Dither float64
+	// Effort defines the quantization CPU effort, from 1 (fastest) to 10
+	// (slowest). Defaults to 7.
+	Effort int
+	// InterframeMaxError defines the maximum pixel error, from 0 to 32,
+	// below which pixels are reused from the previous frame, making
+	// animations smaller. Requires libvips 8.13+.
+	InterframeMaxError float64
+}
+
+// DeepZoomLayout represents the tile pyramid layout. Its values match the
+// dz_layout enum of vips.h.
+type DeepZoomLayout int
+
+const (
+	// DeepZoomLayoutDZ uses the Microsoft Deep Zoom layout.
+	DeepZoomLayoutDZ DeepZoomLayout = iota
+	// DeepZoomLayoutZoomify uses the Zoomify layout.
+	DeepZoomLayoutZoomify
+	// DeepZoomLayoutGoogle uses the Google Maps layout.
+	DeepZoomLayoutGoogle
+	// DeepZoomLayoutIIIF uses the IIIF image API 2 layout. Requires
+	// libvips 8.10+.
+	DeepZoomLayoutIIIF
+	// DeepZoomLayoutIIIF3 uses the IIIF image API 3 layout. Requires
+	// libvips 8.11+.
+	DeepZoomLayoutIIIF3
+)
+
+// DeepZoomOptions represents the tile pyramid options.
+type DeepZoomOptions struct {
+	Layout DeepZoomLayout
+	// Path defines the output base name, e.g. "out/image" writes
+	// "out/image.dzi" and the "out/image_files" directory. When empty,
+	// the pyramid is returned as an in memory zip, which requires
+	// libvips 8.7+.
+	Path string
+	// Suffix defines the tiles file suffix and save options, e.g.
+	// ".png" or ".jpg[Q=90]". Defaults to ".jpeg".
+	Suffix string
+	// TileSize defines the tiles size in pixels. Defaults to 254 for
+	// the Deep Zoom layout and 256 otherwise.
+	TileSize int
+	// Overlap defines the tiles overlap in pixels.
+	Overlap int
+}
+
+// Keep represents the image metadata kept on save, as a bitmask. Its
+// values match the keep enum of vips.h.
+type Keep int
+
+const (
+	// KeepEXIF keeps the EXIF metadata, including the orientation.
+	KeepEXIF Keep = 1
+	// KeepXMP keeps the XMP metadata.
+	KeepXMP Keep = 2
+	// KeepIPTC keeps the IPTC metadata.
+	KeepIPTC Keep = 4
+	// KeepICC keeps the ICC color profile.
+	KeepICC Keep = 8
+	// KeepOrientation keeps only the EXIF orientation tag.
+	KeepOrientation Keep = 16
+	// KeepAll keeps all the supported metadata.
+	KeepAll = KeepEXIF | KeepXMP | KeepIPTC | KeepICC | KeepOrientation
+)
+
+// ToneMapOperator represents the curve used to map high dynamic range
+// values into the displayable range.
+type ToneMapOperator int
+
+const (
+	// ToneMapNone disables tone mapping.
+	ToneMapNone ToneMapOperator = iota
+	// ToneMapClip scales by the exposure and clips values out of range.
+	ToneMapClip
+	// ToneMapReinhard compresses the highlights using the x / (1 + x) curve.
+	ToneMapReinhard
+)
+
+// ToneMap represents the high dynamic range to SDR tone mapping options.
+// Exposure is expressed in stops. Gamma defaults to the sRGB transfer curve.
+type ToneMap struct {
+	Operator ToneMapOperator
+	Exposure float64
+	Gamma    float64
+}
+
+// ExtractBand represents the band (channel) extraction options.
+// N consecutive bands are extracted starting at Index. A zero N disables it.
+type ExtractBand struct {
+	Index int
+	N     int
+}
+
+// Limits represents the input sanity limits, checked against the image
+// header before decoding it to reject decompression bombs. Zero fields
+// are not limited.
+type Limits struct {
+	MaxPixels     int
+	MaxWidth      int
+	MaxHeight     int
+	MaxPages      int
+	MaxInputBytes int
+}
+
+// FailOn represents the decode error policy, from salvaging as much of
+// truncated or corrupted images as possible to rejecting any warning.
+type FailOn int
+
+const (
+	// FailOnNone never fails, decoding as much of the image as possible.
+	FailOnNone FailOn = iota
+	// FailOnTruncated fails on truncated images only.
+	FailOnTruncated
+	// FailOnError fails on decode errors.
+	FailOnError
+	// FailOnWarning fails on any decode warning.
+	FailOnWarning
+)
+
+// Access represents how the image pixels are read by the loaders.
+type Access int
+
+const (
+	// AccessAuto streams the image for plain resize, crop and save
+	// operations, and uses random access otherwise.
+	AccessAuto Access = iota
+	// AccessRandom decodes the image for random access to its pixels.
+	AccessRandom
+	// AccessSequential streams the image from top to bottom, so large
+	// images are never fully decoded into memory. Operations reading the
+	// pixels out of order, such as rotations, fail.
+	AccessSequential
+)
+
+// RawDemosaic represents how the camera RAW sensor data is demosaiced.
+type RawDemosaic int
+
+const (
+	// RawDemosaicDefault interpolates the sensor data at full size, with
+	// the loader default algorithm.
+	RawDemosaicDefault RawDemosaic = iota
+	// RawDemosaicHalfSize decodes every 2x2 block of sensor data into a
+	// single pixel, skipping the interpolation, for fast previews. Loaders
+	// without this mode shrink the demosaiced image instead.
+	RawDemosaicHalfSize
+)
+
+// RawWhiteBalance represents the white balance of camera RAW images.
+type RawWhiteBalance int
+
+const (
+	// RawWhiteBalanceCamera keeps the white balance of the loader, as
+	// shot by the camera.
+	RawWhiteBalanceCamera RawWhiteBalance = iota
+	// RawWhiteBalanceAuto balances the colours so the image averages to
+	// grey.
+	RawWhiteBalanceAuto
+	// RawWhiteBalanceCustom applies the RawOptions multipliers.
+	RawWhiteBalanceCustom
+)
+
+// RawOptions represents the camera RAW decoding options.
+type RawOptions struct {
+	Demosaic     RawDemosaic
+	WhiteBalance RawWhiteBalance
+	// Multipliers are the positive red, green and blue gains, in linear
+	// light, of the custom white balance, applied over the camera one.
+	Multipliers [3]float64
+}
+
+// Region represents a rectangular area of the input image, in its stored
+// orientation, before any EXIF based rotation.
+type Region struct {
+	Left   int
+	Top    int
+	Width  int
+	Height int
+}
+
+// TrimOptions represents the Trim options. Only the enabled edges are
+// trimmed, or all of them when none is enabled.
+type TrimOptions struct {
+	Top    bool
+	Right  bool
+	Bottom bool
+	Left   bool
+	// Padding keeps the given number of pixels of background around the
+	// image content on the trimmed edges.
+	Padding int
+	// AutoBackground detects the background colour from the median of the
+	// image corners and edge middles, like ImageMagick -trim, instead of
+	// using the Background option.
+	AutoBackground bool
+}
+
+// LoadOptions represents the options applied when loading the input image.
+type LoadOptions struct {
+	// Limits overrides the package-level limits set via SetLimits.
+	// Zero fields fall back to the package-level ones.
+	Limits Limits
+	// FailOn defines the decode error policy. libvips before 8.12 fails
+	// on any warning for every policy but FailOnNone.
+	FailOn FailOn
+	// Access defines how the image pixels are read.
+	Access Access
+	// Region decodes only the given area of the image. Tiled TIFF images
+	// only read the tiles covering it, and JPEG images are cropped before
+	// decoding them. Other formats are decoded then cropped.
+	Region Region
+	// NoShrinkOnLoad decodes JPEG and WebP images at full size before
+	// downscaling them, rather than letting the decoder shrink them.
+	NoShrinkOnLoad bool
+	// NoCache drops the libvips cached operations derived from the
+	// decoded image once processed, rather than keeping them in the
+	// operation cache shared by the whole process, so the image memory
+	// is released with the request. The cache limits are lifted while
+	// processing, so its operations do not trim the cached operations of
+	// other requests, and restored once they are dropped.
+	NoCache bool
+	// Raw defines the demosaicing and white balance of camera RAW images.
+	Raw RawOptions
+
+	scope *cacheScope
+}
+
 // Options represents the supported image transformation options.
 type Options struct {
 	Height         int
@@ -250,7 +846,98 @@ type Options struct {
 	// 0-8 for AVIF encoding.
 	// 0-9 for PNG encoding.
 	Speed int
+	// Invert produces the negative of the image. The alpha channel is
+	// preserved unless InvertAlpha is also enabled.
+	Invert      bool
+	InvertAlpha bool
+	// LUT maps the colour band values through the lookup table of 256
+	// entries, after the invert. The alpha channel is preserved.
+	LUT           []byte
+	Binarize      Binarize
+	UnsharpMask   UnsharpMask
+	Denoise       Denoise
+	EdgeDetection EdgeDetection
+	Vignette      Vignette
+	MotionBlur    MotionBlur
+	RadialBlur    RadialBlur
+	// Pixelate pixelates an area of the image, after the effects.
+	Pixelate Pixelate
+	// Grain adds film grain noise to the image, after the vignette.
+	Grain GrainOptions
+	// ResizeMode defines how the image is fitted in the Width and Height
+	// box, overriding Crop, Embed, Force and Enlarge.
+	ResizeMode ResizeMode
+	// Border adds a border around the image, after its corners are masked.
+	Border Border
+	// CornerRadius rounds the image corners by the given radius in pixels,
+	// making them transparent.
+	CornerRadius int
+	// Circle crops the image to a centred square and masks it with a circle.
+	Circle bool
+	// AddAlpha adds an opaque alpha channel to images without one.
+	AddAlpha bool
+	// AlphaMask replaces the image alpha channel with the luminance of the
+	// given image buffer, scaled to the image size.
+	AlphaMask []byte
+	// ExtractAlpha outputs the image alpha channel as a greyscale image.
+	ExtractAlpha bool
+	// Premultiply multiplies the colour channels by the alpha channel.
+	// Unpremultiply does the opposite. Both are no-op for images without
+	// alpha channel.
+	Premultiply   bool
+	Unpremultiply bool
+	ExtractBand   ExtractBand
+	// TrimLab makes Trim compare colors by their CIE76 distance in Lab
+	// space, so Threshold is expressed in delta E units. See ColorDistance.
+	TrimLab bool
+	// TrimOptions defines the trimmed edges and their padding.
+	TrimOptions TrimOptions
+	// BitDepth defines the output bit depth per channel for PNG and TIFF,
+	// either 8 or 16. Use 16 to retain the depth of 16-bit inputs, which
+	// are otherwise reduced to 8-bit. Palette based PNG images also accept
+	// 1, 2 and 4. Other formats ignore it.
+	BitDepth int
+	// ToneMap converts high dynamic range images, such as OpenEXR or
+	// Radiance HDR, into 8-bit displayable images.
+	ToneMap ToneMap
+	// KeepMetadata selects the metadata kept on save, e.g. KeepICC to strip
+	// everything but the color profile. It overrides StripMetadata.
+	KeepMetadata Keep
+	// JPEG defines the advanced JPEG encoder options.
+	JPEG JPEGOptions
+	// PNG defines the advanced PNG encoder options.
+	PNG PNGOptions
+	// WebP defines the advanced WebP encoder options.
+	WebP WebPOptions
+	// TIFF defines the TIFF encoder options.
+	TIFF TIFFOptions
+	// GIF defines the GIF encoder options.
+	GIF GIFOptions
+	// C2PA attaches, keeps or signs the C2PA content credentials of JPEG,
+	// PNG and WebP outputs. Without it, the manifest store of the source
+	// image is dropped, as its hard binding no longer matches the output.
+	C2PA C2PAOptions
 
+	// LosslessJPEG rotates, flips and extracts areas of JPEG images without
+	// re-encoding them, when no other operation is requested. Mirrored
+	// sides and the area origin must be aligned on the JPEG blocks, 8 or
+	// 16 pixels, otherwise the image is processed as usual. It requires
+	// the libjpeg build tag.
+	LosslessJPEG bool
+
+	// Load defines the input image load options.
+	Load LoadOptions
 	// private fields
 	autoRotateOnly bool
+	orientation    ExifOrientation
+	canvas         canvas
+	output         *Buffer
+	trace          *traceRun
+}
+
+// canvas represents the CanvasResize options.
+type canvas struct {
+	width, height int
+	gravity       Gravity
+	background    RGBAProvider
 }
@@ -0,0 +1,92 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+// ExifOrientation represents the EXIF orientation of an image, which tells
+// how its pixels must be transformed to be displayed upright.
+type ExifOrientation int
+
+const (
+	// OrientationUndefined represents a missing or invalid orientation.
+	OrientationUndefined ExifOrientation = iota
+	// OrientationTopLeft represents an upright image.
+	OrientationTopLeft
+	// OrientationTopRight represents a horizontally mirrored image.
+	OrientationTopRight
+	// OrientationBottomRight represents an image rotated by 180 degrees.
+	OrientationBottomRight
+	// OrientationBottomLeft represents a vertically mirrored image.
+	OrientationBottomLeft
+	// OrientationLeftTop represents an image mirrored about its top-left
+	// to bottom-right diagonal.
+	OrientationLeftTop
+	// OrientationRightTop represents an image to rotate by 90 degrees
+	// clockwise.
+	OrientationRightTop
+	// OrientationRightBottom represents an image mirrored about its
+	// top-right to bottom-left diagonal.
+	OrientationRightBottom
+	// OrientationLeftBottom represents an image to rotate by 270 degrees
+	// clockwise.
+	OrientationLeftBottom
+)
+
+// NeedsSwapDimensions reports whether the image width and height are
+// swapped once the orientation is applied.
+func (o ExifOrientation) NeedsSwapDimensions() bool {
+	return o >= OrientationLeftTop && o <= OrientationLeftBottom
+}
+
+// TransformSteps returns the clockwise rotation, then whether the
+// horizontal flip, which display the image upright.
+func (o ExifOrientation) TransformSteps() (Angle, bool) {
+	switch o {
+	case OrientationTopRight:
+		return D0, true
+	case OrientationBottomRight:
+		return D180, false
+	case OrientationBottomLeft:
+		return D180, true
+	case OrientationLeftTop:
+		return D90, true
+	case OrientationRightTop:
+		return D90, false
+	case OrientationRightBottom:
+		return D270, true
+	case OrientationLeftBottom:
+		return D270, false
+	}
+	return D0, false
+}
+
+// ApplyOrientation rotates and flips the image as the given orientation
+// tells, ignoring its EXIF orientation, for callers managing the
+// orientation themselves. The EXIF orientation is then reset.
+func (i *Image) ApplyOrientation(o ExifOrientation) ([]byte, error) {
+	return i.process("ApplyOrientation", Options{orientation: o})
+}
+
+// applyOrientation rotates and flips the image upright for the given
+// orientation, and resets its EXIF orientation.
+func applyOrientation(image *C.VipsImage, o ExifOrientation) (*C.VipsImage, error) {
+	var err error
+	angle, flip := o.TransformSteps()
+
+	if angle != D0 {
+		image, err = vipsRotate(image, angle)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if flip {
+		image, err = vipsFlip(image, Horizontal)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return vipsResetOrientation(image)
+}
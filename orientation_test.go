@@ -0,0 +1,65 @@
+package bimg
+
+import "testing"
+
+func TestExifOrientationTransformSteps(t *testing.T) {
+	tests := []struct {
+		orientation ExifOrientation
+		angle       Angle
+		flip        bool
+		swap        bool
+	}{
+		{OrientationUndefined, D0, false, false},
+		{OrientationTopLeft, D0, false, false},
+		{OrientationTopRight, D0, true, false},
+		{OrientationBottomRight, D180, false, false},
+		{OrientationBottomLeft, D180, true, false},
+		{OrientationLeftTop, D90, true, true},
+		{OrientationRightTop, D90, false, true},
+		{OrientationRightBottom, D270, true, true},
+		{OrientationLeftBottom, D270, false, true},
+		{ExifOrientation(9), D0, false, false},
+	}
+
+	for _, test := range tests {
+		angle, flip := test.orientation.TransformSteps()
+		if angle != test.angle || flip != test.flip {
+			t.Errorf("Unexpected steps for orientation %d: %d, %t", test.orientation, angle, flip)
+		}
+		if swap := test.orientation.NeedsSwapDimensions(); swap != test.swap {
+			t.Errorf("Unexpected dimensions swap for orientation %d: %t", test.orientation, swap)
+		}
+	}
+}
+
+func TestImageApplyOrientation(t *testing.T) {
+	tests := []struct {
+		file          string
+		orientation   ExifOrientation
+		width, height int
+	}{
+		{"exif/Landscape_1.jpg", OrientationRightTop, 1200, 1600},
+		{"exif/Landscape_1.jpg", OrientationBottomRight, 1600, 1200},
+		{"exif/Landscape_6.jpg", OrientationRightTop, 1600, 1200},
+		{"exif/Landscape_6.jpg", OrientationTopLeft, 1200, 1600},
+	}
+
+	for _, test := range tests {
+		img := initImage(test.file)
+		buf, err := img.ApplyOrientation(test.orientation)
+		if err != nil {
+			t.Fatalf("Cannot process the image: %#v", err)
+		}
+
+		metadata, err := Metadata(buf)
+		if err != nil {
+			t.Fatalf("Cannot read the image: %#v", err)
+		}
+		if metadata.Size.Width != test.width || metadata.Size.Height != test.height {
+			t.Errorf("Unexpected size of %s: %dx%d", test.file, metadata.Size.Width, metadata.Size.Height)
+		}
+		if metadata.Orientation > OrientationTopLeft {
+			t.Errorf("Unexpected orientation of %s: %d", test.file, metadata.Orientation)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"sort"
+)
+
+// paletteSize is the size of the thumbnail used to sample the colors.
+const paletteSize = 100
+
+// paletteMinDistance is the minimum delta E between two dominant colors,
+// so near duplicates of the same hue are not returned.
+const paletteMinDistance = 10.0
+
+// RGBA represents a RGB color with alpha channel.
+type RGBA struct {
+	R, G, B, A uint8
+}
+
+type colorBucket struct {
+	key, r, g, b, count int
+}
+
+func (c colorBucket) color() Color {
+	return Color{uint8(c.r / c.count), uint8(c.g / c.count), uint8(c.b / c.count)}
+}
+
+// dominantColors returns up to n of the most frequent colors in the image,
+// quantized to 4 bits per channel. Mostly transparent pixels are ignored.
+func dominantColors(buf []byte, n int) ([]RGBA, error) {
+	if n <= 0 {
+		return nil, errors.New("Number of colors must be higher than zero")
+	}
+
+	defer C.vips_thread_shutdown()
+
+	image, _, err := loadImage(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	pixels, _, _, err := vipsRGBA(image, paletteSize)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make(map[int]*colorBucket)
+	for i := 0; i+3 < len(pixels); i += 4 {
+		r, g, b, a := int(pixels[i]), int(pixels[i+1]), int(pixels[i+2]), pixels[i+3]
+		if a < 128 {
+			continue
+		}
+		key := (r>>4)<<8 | (g>>4)<<4 | b>>4
+		bucket, ok := buckets[key]
+		if !ok {
+			bucket = &colorBucket{key: key}
+			buckets[key] = bucket
+		}
+		bucket.r += r
+		bucket.g += g
+		bucket.b += b
+		bucket.count++
+	}
+
+	sorted := make([]*colorBucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		sorted = append(sorted, bucket)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].count != sorted[j].count {
+			return sorted[i].count > sorted[j].count
+		}
+		// Keep a stable order across runs
+		return sorted[i].key < sorted[j].key
+	})
+
+	var picked []Color
+	for _, bucket := range sorted {
+		if len(picked) == n {
+			break
+		}
+		color := bucket.color()
+		similar := false
+		for _, c := range picked {
+			if ColorDistance(c, color) < paletteMinDistance {
+				similar = true
+				break
+			}
+		}
+		if !similar {
+			picked = append(picked, color)
+		}
+	}
+
+	colors := make([]RGBA, len(picked))
+	for i, c := range picked {
+		colors[i] = RGBA{c.R, c.G, c.B, 255}
+	}
+	return colors, nil
+}
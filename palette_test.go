@@ -0,0 +1,40 @@
+package bimg
+
+import "testing"
+
+func TestDominantColors(t *testing.T) {
+	colors, err := initImage("test.jpg").DominantColors(5)
+	if err != nil {
+		t.Fatalf("Cannot extract the colors: %#v", err)
+	}
+	if len(colors) == 0 || len(colors) > 5 {
+		t.Fatalf("Invalid number of colors: %d", len(colors))
+	}
+
+	for i, c := range colors {
+		if c.A != 255 {
+			t.Errorf("Color %d is not opaque: %#v", i, c)
+		}
+		for _, p := range colors[:i] {
+			if ColorDistance(Color{c.R, c.G, c.B}, Color{p.R, p.G, p.B}) < paletteMinDistance {
+				t.Errorf("Colors are too similar: %#v %#v", c, p)
+			}
+		}
+	}
+}
+
+func TestDominantColorsTransparent(t *testing.T) {
+	colors, err := initImage("transparent.png").DominantColors(3)
+	if err != nil {
+		t.Fatalf("Cannot extract the colors: %#v", err)
+	}
+	if len(colors) == 0 {
+		t.Fatal("No colors extracted")
+	}
+}
+
+func TestDominantColorsInvalid(t *testing.T) {
+	if _, err := initImage("test.jpg").DominantColors(0); err == nil {
+		t.Fatal("Expected error with zero colors")
+	}
+}
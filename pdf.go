@@ -0,0 +1,203 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"math"
+)
+
+// PDFOptions represents the WritePDF options.
+type PDFOptions struct {
+	// DPI defines the images resolution, which sizes the pages. Defaults
+	// to 72, a pixel per point.
+	DPI int
+	// PageSize defines a fixed page size in points, e.g. 595x842 for A4.
+	// Images are fitted and centred in the pages, inset by Margin.
+	// Defaults to the size of every image.
+	PageSize ImageSize
+	// Margin defines the space around the images in fixed size pages, in
+	// points.
+	Margin int
+	// Quality defines the pages JPEG quality. Defaults to 75.
+	Quality int
+}
+
+// pdfPage represents a page of the document, a JPEG image.
+type pdfPage struct {
+	jpeg          []byte
+	width, height int
+	colorSpace    string
+}
+
+// WritePDF writes the images as the pages of a PDF document, in order.
+// The pages are JPEG compressed, and transparent images are flattened on
+// white. Every image is converted only when its page is written.
+func WritePDF(w io.Writer, images []*Image, o PDFOptions) error {
+	if len(images) == 0 {
+		return errors.New("No PDF images given")
+	}
+	if o.DPI < 0 || o.Margin < 0 || o.PageSize.Width < 0 || o.PageSize.Height < 0 {
+		return errors.New("Invalid PDF resolution, page size or margin")
+	}
+	if o.DPI == 0 {
+		o.DPI = 72
+	}
+	if o.Quality == 0 {
+		o.Quality = Quality
+	}
+
+	pw := &pdfWriter{w: w}
+	pw.begin(len(images))
+	for _, img := range images {
+		page, err := pdfImage(img, o.Quality)
+		if err != nil {
+			return err
+		}
+		pw.writePage(page, o)
+	}
+	return pw.end()
+}
+
+// pdfImage converts the auto-rotated image to a JPEG page.
+func pdfImage(img *Image, quality int) (pdfPage, error) {
+	defer C.vips_thread_shutdown()
+
+	image, _, err := loadOrientedImage(img)
+	if err != nil {
+		return pdfPage{}, err
+	}
+	flattened, err := vipsFlattenBackground(image, Color{255, 255, 255})
+	if err != nil {
+		C.g_object_unref(C.gpointer(image))
+		return pdfPage{}, err
+	}
+	defer C.g_object_unref(C.gpointer(flattened))
+
+	buf, err := encodeImage(flattened, SaveOptions{Type: JPEG, Quality: quality, StripMetadata: true}, quality)
+	if err != nil {
+		return pdfPage{}, err
+	}
+	return newPDFPage(buf)
+}
+
+// newPDFPage reads the size and color space of the JPEG image.
+func newPDFPage(buf []byte) (pdfPage, error) {
+	config, err := jpeg.DecodeConfig(bytes.NewReader(buf))
+	if err != nil {
+		return pdfPage{}, err
+	}
+	page := pdfPage{jpeg: buf, width: config.Width, height: config.Height, colorSpace: "DeviceRGB"}
+	switch config.ColorModel {
+	case color.GrayModel:
+		page.colorSpace = "DeviceGray"
+	case color.CMYKModel:
+		page.colorSpace = "DeviceCMYK"
+	}
+	return page, nil
+}
+
+// pdfWriter writes a PDF document of pages made of a single image. Every
+// page uses three objects, after the catalog and the page tree.
+type pdfWriter struct {
+	w       io.Writer
+	offset  int
+	offsets []int
+	pages   int
+	err     error
+}
+
+func (pw *pdfWriter) printf(format string, args ...interface{}) {
+	if pw.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(pw.w, format, args...)
+	pw.offset += n
+	pw.err = err
+}
+
+func (pw *pdfWriter) write(buf []byte) {
+	if pw.err != nil {
+		return
+	}
+	n, err := pw.w.Write(buf)
+	pw.offset += n
+	pw.err = err
+}
+
+// object starts the next object, recording its offset.
+func (pw *pdfWriter) object() {
+	pw.offsets = append(pw.offsets, pw.offset)
+	pw.printf("%d 0 obj\n", len(pw.offsets))
+}
+
+func (pw *pdfWriter) begin(pages int) {
+	pw.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+	pw.object()
+	pw.printf("<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+
+	pw.object()
+	pw.printf("<< /Type /Pages /Count %d /Kids [", pages)
+	for i := 0; i < pages; i++ {
+		pw.printf(" %d 0 R", 3+i*3)
+	}
+	pw.printf(" ] >>\nendobj\n")
+}
+
+func (pw *pdfWriter) writePage(page pdfPage, o PDFOptions) {
+	// Image size in points
+	width := float64(page.width) * 72 / float64(o.DPI)
+	height := float64(page.height) * 72 / float64(o.DPI)
+	pageWidth, pageHeight := width, height
+	left, bottom := 0.0, 0.0
+
+	if o.PageSize.Width > 0 && o.PageSize.Height > 0 {
+		pageWidth, pageHeight = float64(o.PageSize.Width), float64(o.PageSize.Height)
+		boxWidth := math.Max(pageWidth-2*float64(o.Margin), 1)
+		boxHeight := math.Max(pageHeight-2*float64(o.Margin), 1)
+		scale := math.Min(boxWidth/width, boxHeight/height)
+		width, height = width*scale, height*scale
+		left, bottom = (pageWidth-width)/2, (pageHeight-height)/2
+	}
+
+	object := 3 + pw.pages*3
+	pw.pages++
+
+	pw.object()
+	pw.printf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] ", pageWidth, pageHeight)
+	pw.printf("/Resources << /XObject << /Im0 %d 0 R >> >> /Contents %d 0 R >>\nendobj\n", object+1, object+2)
+
+	pw.object()
+	pw.printf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /%s ", page.width, page.height, page.colorSpace)
+	if page.colorSpace == "DeviceCMYK" {
+		// Adobe CMYK JPEG images are stored inverted
+		pw.printf("/Decode [1 0 1 0 1 0 1 0] ")
+	}
+	pw.printf("/BitsPerComponent 8 /Filter /DCTDecode /Length %d >>\nstream\n", len(page.jpeg))
+	pw.write(page.jpeg)
+	pw.printf("\nendstream\nendobj\n")
+
+	content := fmt.Sprintf("q %.2f 0 0 %.2f %.2f %.2f cm /Im0 Do Q", width, height, left, bottom)
+	pw.object()
+	pw.printf("<< /Length %d >>\nstream\n%s\nendstream\nendobj\n", len(content), content)
+}
+
+// end writes the cross-reference table and the trailer.
+func (pw *pdfWriter) end() error {
+	xref := pw.offset
+	pw.printf("xref\n0 %d\n0000000000 65535 f \n", len(pw.offsets)+1)
+	for _, offset := range pw.offsets {
+		pw.printf("%010d 00000 n \n", offset)
+	}
+	pw.printf("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(pw.offsets)+1, xref)
+	return pw.err
+}
@@ -0,0 +1,73 @@
+package bimg
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPDFWriterXref(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 40, 20)), nil); err != nil {
+		t.Fatalf("Cannot encode the image: %s", err)
+	}
+	page, err := newPDFPage(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Cannot read the page: %s", err)
+	}
+	if page.width != 40 || page.height != 20 || page.colorSpace != "DeviceGray" {
+		t.Errorf("Invalid page: %dx%d %s", page.width, page.height, page.colorSpace)
+	}
+
+	var out bytes.Buffer
+	pw := &pdfWriter{w: &out}
+	pw.begin(2)
+	pw.writePage(page, PDFOptions{DPI: 144})
+	pw.writePage(page, PDFOptions{DPI: 72, PageSize: ImageSize{Width: 100, Height: 100}, Margin: 10})
+	if err := pw.end(); err != nil {
+		t.Fatalf("Cannot write the document: %s", err)
+	}
+
+	pdf := out.String()
+	if !strings.Contains(pdf, "/MediaBox [0 0 20.00 10.00]") || !strings.Contains(pdf, "q 80.00 0 0 40.00 10.00 30.00 cm") {
+		t.Error("Invalid page size or image placement")
+	}
+	for i, offset := range regexp.MustCompile(`(\d{10}) 00000 n`).FindAllStringSubmatch(pdf, -1) {
+		n, _ := strconv.Atoi(offset[1])
+		if !strings.HasPrefix(pdf[n:], fmt.Sprintf("%d 0 obj", i+1)) {
+			t.Errorf("Invalid cross-reference offset of object %d: %d", i+1, n)
+		}
+	}
+	if len(pw.offsets) != 8 {
+		t.Errorf("Invalid objects count: %d", len(pw.offsets))
+	}
+}
+
+func TestWritePDF(t *testing.T) {
+	images := []*Image{initImage("test.jpg"), initImage("transparent.png")}
+
+	var buf bytes.Buffer
+	if err := WritePDF(&buf, images, PDFOptions{}); err != nil {
+		t.Fatalf("Cannot write the PDF: %#v", err)
+	}
+	pdf := buf.Bytes()
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatal("Invalid PDF document")
+	}
+	if !bytes.Contains(pdf, []byte("/Count 2")) || !bytes.Contains(pdf, []byte("/MediaBox [0 0 1680.00 1050.00]")) {
+		t.Error("Invalid PDF pages")
+	}
+	if DetermineImageType(pdf) != PDF {
+		t.Error("Invalid image type")
+	}
+	Write("testdata/test_pdf_out.pdf", pdf)
+
+	if err := WritePDF(&buf, nil, PDFOptions{}); err == nil {
+		t.Error("Expected an error for no images")
+	}
+}
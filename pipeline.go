@@ -0,0 +1,151 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Pipeline represents a list of image operations run by libvips in a
+// single cgo call, rather than a call per operation, cutting the cgo
+// overhead of high QPS small image workloads. Operations are applied in
+// order.
+//
+//	p := bimg.NewPipeline().Rotate(bimg.D90).Flip().Gamma(2.2).Sharpen(sharpen)
+//	buf, err := bimg.NewImage(buf).RunPipeline(p, bimg.SaveOptions{})
+type Pipeline struct {
+	ops  []pipelineOp
+	args []float64
+	err  error
+}
+
+// pipelineOp represents a Pipeline operation. Its values match the
+// pipeline enum of vips.h.
+type pipelineOp int
+
+const (
+	pipelineRotate pipelineOp = iota + 1
+	pipelineFlip
+	pipelineGamma
+	pipelineSharpen
+	pipelineBlur
+	pipelineBrightness
+	pipelineContrast
+	pipelineResize
+)
+
+// pipelineArgs is the number of arguments of every operation, matching
+// PIPELINE_ARGS of vips.h.
+const pipelineArgs = 6
+
+// NewPipeline creates an empty Pipeline.
+func NewPipeline() *Pipeline {
+	return &Pipeline{}
+}
+
+func (p *Pipeline) add(op pipelineOp, args ...float64) *Pipeline {
+	var a [pipelineArgs]float64
+	copy(a[:], args)
+	p.ops = append(p.ops, op)
+	p.args = append(p.args, a[:]...)
+	return p
+}
+
+func (p *Pipeline) fail(err error) *Pipeline {
+	if p.err == nil {
+		p.err = err
+	}
+	return p
+}
+
+// Rotate rotates the image by the given angle, a multiple of 45 degrees.
+func (p *Pipeline) Rotate(angle Angle) *Pipeline {
+	if angle%45 != 0 {
+		return p.fail(fmt.Errorf("Invalid rotation angle: %d", angle))
+	}
+	return p.add(pipelineRotate, float64(angle))
+}
+
+// Flip mirrors the image horizontally, like Options.Flip.
+func (p *Pipeline) Flip() *Pipeline {
+	return p.add(pipelineFlip, float64(Horizontal))
+}
+
+// Flop mirrors the image vertically, like Options.Flop.
+func (p *Pipeline) Flop() *Pipeline {
+	return p.add(pipelineFlip, float64(Vertical))
+}
+
+// Gamma applies the gamma correction of the given exponent.
+func (p *Pipeline) Gamma(exponent float64) *Pipeline {
+	if exponent <= 0 {
+		return p.fail(fmt.Errorf("Invalid gamma exponent: %g", exponent))
+	}
+	return p.add(pipelineGamma, exponent)
+}
+
+// Sharpen sharpens the image.
+func (p *Pipeline) Sharpen(o Sharpen) *Pipeline {
+	return p.add(pipelineSharpen, float64(o.Radius), o.X1, o.Y2, o.Y3, o.M1, o.M2)
+}
+
+// GaussianBlur blurs the whole image. Blur regions and masks are not
+// supported.
+func (p *Pipeline) GaussianBlur(o GaussianBlur) *Pipeline {
+	if o.Region != (Region{}) || o.Mask != nil {
+		return p.fail(errors.New("Pipeline blurs do not support regions nor masks"))
+	}
+	return p.add(pipelineBlur, o.Sigma, o.MinAmpl)
+}
+
+// Brightness adds the given value to the image bands.
+func (p *Pipeline) Brightness(brightness float64) *Pipeline {
+	return p.add(pipelineBrightness, brightness)
+}
+
+// Contrast multiplies the image bands by the given factor.
+func (p *Pipeline) Contrast(contrast float64) *Pipeline {
+	return p.add(pipelineContrast, contrast)
+}
+
+// Resize scales the image by the given horizontal and vertical factors.
+func (p *Pipeline) Resize(hscale, vscale float64) *Pipeline {
+	if hscale <= 0 || vscale <= 0 {
+		return p.fail(fmt.Errorf("Invalid resize scale: %gx%g", hscale, vscale))
+	}
+	return p.add(pipelineResize, hscale, vscale)
+}
+
+// Len returns the number of operations of the pipeline.
+func (p *Pipeline) Len() int {
+	return len(p.ops)
+}
+
+// runPipeline applies the pipeline to the auto-rotated image and encodes
+// it. The output type defaults to the image type.
+func runPipeline(img *Image, p *Pipeline, o SaveOptions) ([]byte, error) {
+	defer C.vips_thread_shutdown()
+
+	if p == nil {
+		return nil, errors.New("No pipeline given")
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	image, imageType, err := loadOrientedImage(img)
+	if err != nil {
+		return nil, err
+	}
+	image, err = vipsPipeline(image, p)
+	if err != nil {
+		return nil, err
+	}
+	defer C.g_object_unref(C.gpointer(image))
+	return encodeSaveOptions(image, imageType, img.buf(), o)
+}
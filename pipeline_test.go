@@ -0,0 +1,85 @@
+package bimg
+
+import "testing"
+
+var testSharpen = Sharpen{Radius: 1, X1: 2, Y2: 10, Y3: 20, M1: 0, M2: 3}
+
+func TestImageRunPipeline(t *testing.T) {
+	p := NewPipeline().Rotate(D90).Flip().Gamma(2.2).Sharpen(testSharpen).Resize(0.5, 0.5)
+	if p.Len() != 5 {
+		t.Fatalf("Invalid number of operations: %d", p.Len())
+	}
+
+	buf, err := initImage("test.jpg").RunPipeline(p, SaveOptions{Type: PNG})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if DetermineImageType(buf) != PNG {
+		t.Fatal("Image is not png")
+	}
+	if err := assertSize(buf, 525, 840); err != nil {
+		t.Error(err)
+	}
+	Write("testdata/test_pipeline_out.png", buf)
+}
+
+func TestImageRunPipelineProcess(t *testing.T) {
+	// A pipeline gives the same image as the equivalent options
+	expected, err := initImage("test.jpg").Process(Options{Rotate: D180, Flip: true, Type: PNG})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	buf, err := initImage("test.jpg").RunPipeline(NewPipeline().Rotate(D180).Flip(), SaveOptions{Type: PNG})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	diff, err := Diff(NewImage(expected), NewImage(buf), DiffOptions{})
+	if err != nil {
+		t.Fatalf("Cannot compare the images: %#v", err)
+	}
+	if diff.Score != 0 {
+		t.Errorf("Pipeline and options images differ: %g", diff.Score)
+	}
+}
+
+func TestImageRunPipelineSaveOptions(t *testing.T) {
+	p := NewPipeline().Resize(0.5, 0.5)
+	buf, err := initImage("test.png").RunPipeline(p, SaveOptions{AutoFormat: true, Accept: "image/webp"})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if DetermineImageType(buf) != WEBP {
+		t.Errorf("Invalid image type: %s", ImageTypeName(DetermineImageType(buf)))
+	}
+
+	buf, err = initImage("test.jpg").RunPipeline(p, SaveOptions{TargetSize: 30000})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if len(buf) > 30000 {
+		t.Errorf("Image exceeds the target size: %d bytes", len(buf))
+	}
+}
+
+func TestImageRunPipelineErrors(t *testing.T) {
+	pipelines := []*Pipeline{
+		nil,
+		NewPipeline().Rotate(Angle(30)),
+		NewPipeline().Gamma(0),
+		NewPipeline().Resize(0, 1),
+		NewPipeline().GaussianBlur(GaussianBlur{Sigma: 1, Mask: []byte("mask")}),
+	}
+	for _, p := range pipelines {
+		if _, err := initImage("test.jpg").RunPipeline(p, SaveOptions{}); err == nil {
+			t.Error("Expected an error for an invalid pipeline")
+		}
+	}
+}
+
+func BenchmarkRunPipeline(b *testing.B) {
+	buf, _ := Resize(readFile("test.jpg"), Options{Width: 100})
+	p := NewPipeline().Rotate(D90).Flip().Gamma(2.2).Sharpen(testSharpen)
+	for n := 0; n < b.N; n++ {
+		NewImage(buf).RunPipeline(p, SaveOptions{})
+	}
+}
@@ -0,0 +1,270 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+const (
+	// blurHashSize is the size of the thumbnail used to compute a BlurHash.
+	blurHashSize = 32
+	// thumbHashSize is the maximum size supported by ThumbHash.
+	thumbHashSize = 100
+)
+
+const base83Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// blurHash computes the BlurHash placeholder of the given image buffer.
+// See: https://github.com/woltapp/blurhash
+func blurHash(buf []byte, xComp, yComp int) (string, error) {
+	if xComp < 1 || xComp > 9 || yComp < 1 || yComp > 9 {
+		return "", errors.New("BlurHash components must be between 1 and 9")
+	}
+
+	pixels, width, height, err := placeholderPixels(buf, blurHashSize)
+	if err != nil {
+		return "", err
+	}
+	return encodeBlurHash(pixels, width, height, xComp, yComp), nil
+}
+
+// thumbHash computes the ThumbHash placeholder of the given image buffer.
+// See: https://github.com/evanw/thumbhash
+func thumbHash(buf []byte) ([]byte, error) {
+	pixels, width, height, err := placeholderPixels(buf, thumbHashSize)
+	if err != nil {
+		return nil, err
+	}
+	return encodeThumbHash(pixels, width, height), nil
+}
+
+func placeholderPixels(buf []byte, size int) ([]byte, int, int, error) {
+	defer C.vips_thread_shutdown()
+
+	image, _, err := loadImage(buf)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return vipsRGBA(image, size)
+}
+
+func encodeBlurHash(pixels []byte, width, height, xComp, yComp int) string {
+	factors := make([][3]float64, 0, xComp*yComp)
+	for y := 0; y < yComp; y++ {
+		for x := 0; x < xComp; x++ {
+			factors = append(factors, blurHashFactor(pixels, width, height, x, y))
+		}
+	}
+
+	var hash strings.Builder
+	hash.WriteString(encodeBase83((xComp-1)+(yComp-1)*9, 1))
+
+	maximum := 1.0
+	if len(factors) > 1 {
+		actual := 0.0
+		for _, f := range factors[1:] {
+			actual = math.Max(actual, math.Max(math.Abs(f[0]), math.Max(math.Abs(f[1]), math.Abs(f[2]))))
+		}
+		quantised := int(math.Max(0, math.Min(82, math.Floor(actual*166-0.5))))
+		maximum = float64(quantised+1) / 166
+		hash.WriteString(encodeBase83(quantised, 1))
+	} else {
+		hash.WriteString(encodeBase83(0, 1))
+	}
+
+	dc := factors[0]
+	hash.WriteString(encodeBase83(linearToSRGB(dc[0])<<16|linearToSRGB(dc[1])<<8|linearToSRGB(dc[2]), 4))
+
+	for _, f := range factors[1:] {
+		quant := func(v float64) int {
+			return int(math.Max(0, math.Min(18, math.Floor(signPow(v/maximum, 0.5)*9+9.5))))
+		}
+		hash.WriteString(encodeBase83(quant(f[0])*19*19+quant(f[1])*19+quant(f[2]), 2))
+	}
+
+	return hash.String()
+}
+
+func blurHashFactor(pixels []byte, width, height, xComp, yComp int) [3]float64 {
+	var r, g, b float64
+	normalisation := 2.0
+	if xComp == 0 && yComp == 0 {
+		normalisation = 1
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			basis := normalisation *
+				math.Cos(math.Pi*float64(xComp*x)/float64(width)) *
+				math.Cos(math.Pi*float64(yComp*y)/float64(height))
+			i := 4 * (y*width + x)
+			r += basis * sRGBToLinear(pixels[i])
+			g += basis * sRGBToLinear(pixels[i+1])
+			b += basis * sRGBToLinear(pixels[i+2])
+		}
+	}
+
+	scale := 1 / float64(width*height)
+	return [3]float64{r * scale, g * scale, b * scale}
+}
+
+func encodeBase83(value, length int) string {
+	var s strings.Builder
+	for i := 1; i <= length; i++ {
+		digit := (value / int(math.Pow(83, float64(length-i)))) % 83
+		s.WriteByte(base83Chars[digit])
+	}
+	return s.String()
+}
+
+func sRGBToLinear(value uint8) float64 {
+	v := float64(value) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(value float64) int {
+	v := math.Max(0, math.Min(1, value))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(value, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(value), exp), value)
+}
+
+// round rounds half up, as the reference ThumbHash implementation does.
+func round(v float64) int {
+	return int(math.Floor(v + 0.5))
+}
+
+func encodeThumbHash(pixels []byte, width, height int) []byte {
+	n := width * height
+
+	// Determine the average color
+	var avgR, avgG, avgB, avgA float64
+	for i := 0; i < n; i++ {
+		alpha := float64(pixels[4*i+3]) / 255
+		avgR += alpha / 255 * float64(pixels[4*i])
+		avgG += alpha / 255 * float64(pixels[4*i+1])
+		avgB += alpha / 255 * float64(pixels[4*i+2])
+		avgA += alpha
+	}
+	if avgA > 0 {
+		avgR /= avgA
+		avgG /= avgA
+		avgB /= avgA
+	}
+
+	hasAlpha := avgA < float64(n)
+	limit := 7.0
+	if hasAlpha {
+		// Use fewer luminance bits if there's alpha
+		limit = 5
+	}
+	longest := math.Max(float64(width), float64(height))
+	lx := int(math.Max(1, float64(round(limit*float64(width)/longest))))
+	ly := int(math.Max(1, float64(round(limit*float64(height)/longest))))
+
+	// Convert the image from RGBA to LPQA, composited atop the average color
+	l := make([]float64, n)
+	p := make([]float64, n)
+	q := make([]float64, n)
+	a := make([]float64, n)
+	for i := 0; i < n; i++ {
+		alpha := float64(pixels[4*i+3]) / 255
+		r := avgR*(1-alpha) + alpha/255*float64(pixels[4*i])
+		g := avgG*(1-alpha) + alpha/255*float64(pixels[4*i+1])
+		b := avgB*(1-alpha) + alpha/255*float64(pixels[4*i+2])
+		l[i] = (r + g + b) / 3
+		p[i] = (r+g)/2 - b
+		q[i] = r - g
+		a[i] = alpha
+	}
+
+	// Encode using the DCT into DC (constant) and normalized AC (varying) terms
+	encodeChannel := func(channel []float64, nx, ny int) (float64, []float64, float64) {
+		var dc, scale float64
+		var ac []float64
+		fx := make([]float64, width)
+		for cy := 0; cy < ny; cy++ {
+			for cx := 0; cx*ny < nx*(ny-cy); cx++ {
+				f := 0.0
+				for x := 0; x < width; x++ {
+					fx[x] = math.Cos(math.Pi / float64(width) * float64(cx) * (float64(x) + 0.5))
+				}
+				for y := 0; y < height; y++ {
+					fy := math.Cos(math.Pi / float64(height) * float64(cy) * (float64(y) + 0.5))
+					for x := 0; x < width; x++ {
+						f += channel[x+y*width] * fx[x] * fy
+					}
+				}
+				f /= float64(n)
+				if cx > 0 || cy > 0 {
+					ac = append(ac, f)
+					scale = math.Max(scale, math.Abs(f))
+				} else {
+					dc = f
+				}
+			}
+		}
+		if scale > 0 {
+			for i := range ac {
+				ac[i] = 0.5 + 0.5/scale*ac[i]
+			}
+		}
+		return dc, ac, scale
+	}
+
+	lDC, lAC, lScale := encodeChannel(l, int(math.Max(3, float64(lx))), int(math.Max(3, float64(ly))))
+	pDC, pAC, pScale := encodeChannel(p, 3, 3)
+	qDC, qAC, qScale := encodeChannel(q, 3, 3)
+	acs := [][]float64{lAC, pAC, qAC}
+
+	// Write the constants
+	isLandscape := width > height
+	header24 := round(63*lDC) | round(31.5+31.5*pDC)<<6 | round(31.5+31.5*qDC)<<12 | round(31*lScale)<<18
+	header16 := lx
+	if isLandscape {
+		header16 = ly
+	}
+	header16 |= round(63*pScale)<<3 | round(63*qScale)<<9
+	if hasAlpha {
+		header24 |= 1 << 23
+	}
+	if isLandscape {
+		header16 |= 1 << 15
+	}
+	hash := []byte{byte(header24), byte(header24 >> 8), byte(header24 >> 16), byte(header16), byte(header16 >> 8)}
+	if hasAlpha {
+		aDC, aAC, aScale := encodeChannel(a, 5, 5)
+		hash = append(hash, byte(round(15*aDC)|round(15*aScale)<<4))
+		acs = append(acs, aAC)
+	}
+
+	// Write the varying factors
+	start := len(hash)
+	index := 0
+	for _, ac := range acs {
+		for _, f := range ac {
+			if start+index>>1 == len(hash) {
+				hash = append(hash, 0)
+			}
+			hash[start+index>>1] |= byte(round(15*f) << uint((index&1)<<2))
+			index++
+		}
+	}
+
+	return hash
+}
@@ -0,0 +1,60 @@
+package bimg
+
+import (
+	"bytes"
+	"testing"
+)
+
+func solidPixels(width, height int, r, g, b, a byte) []byte {
+	pixels := make([]byte, 0, 4*width*height)
+	for i := 0; i < width*height; i++ {
+		pixels = append(pixels, r, g, b, a)
+	}
+	return pixels
+}
+
+func TestEncodeBlurHash(t *testing.T) {
+	hash := encodeBlurHash(solidPixels(4, 3, 255, 0, 0, 255), 4, 3, 1, 1)
+	if hash != "00TI:j" {
+		t.Fatalf("Invalid BlurHash: %s", hash)
+	}
+}
+
+func TestEncodeThumbHash(t *testing.T) {
+	hash := encodeThumbHash(solidPixels(4, 4, 255, 255, 255, 255), 4, 4)
+	// Solid colors have no varying factors
+	expected := []byte{63, 8, 2, 7, 0}
+	if len(hash) != 24 || !bytes.Equal(hash[:5], expected) {
+		t.Fatalf("Invalid ThumbHash: %v", hash)
+	}
+	for _, b := range hash[5:] {
+		if b != 0 {
+			t.Fatalf("Invalid ThumbHash: %v", hash)
+		}
+	}
+}
+
+func TestImageBlurHash(t *testing.T) {
+	hash, err := initImage("test.jpg").BlurHash(4, 3)
+	if err != nil {
+		t.Fatalf("Cannot compute BlurHash: %#v", err)
+	}
+	// 1 size flag, 1 maximum value, 4 DC and 2 per AC component
+	if len(hash) != 6+2*11 {
+		t.Errorf("Invalid BlurHash length: %s", hash)
+	}
+
+	if _, err := initImage("test.jpg").BlurHash(0, 10); err == nil {
+		t.Error("Expected error with invalid components")
+	}
+}
+
+func TestImageThumbHash(t *testing.T) {
+	hash, err := initImage("transparent.png").ThumbHash()
+	if err != nil {
+		t.Fatalf("Cannot compute ThumbHash: %#v", err)
+	}
+	if hash[2]&0x80 == 0 {
+		t.Error("ThumbHash has no alpha flag")
+	}
+}
@@ -0,0 +1,86 @@
+package bimg
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// Processor processes images encoding them straight into C memory, as
+// ResizeBuffer does, reducing the copies, allocations and GC churn of
+// services handling many images per second. Outputs not encoded by
+// libvips, such as the cached ones, are copied into pooled buffers. Input
+// images are released from libvips before Process returns, while the
+// output buffers lifetime is explicitly managed via Release. It is safe
+// for concurrent use.
+type Processor struct {
+	buffers sync.Pool
+	options ProcessorOptions
+
+	mu      sync.Mutex
+	outputs map[unsafe.Pointer]*Buffer
+}
+
+// ProcessorOptions represents the Processor options.
+type ProcessorOptions struct {
+	// NoCache drops the operations of the processor images from the
+	// libvips operation cache, which is shared by the whole process, once
+	// every request is processed, releasing its memory. The giant images
+	// of a tenant do not evict the cached operations of the others. See
+	// LoadOptions.NoCache.
+	NoCache bool
+}
+
+// NewProcessor creates a new Processor with the given options.
+func NewProcessor(opts ...ProcessorOptions) *Processor {
+	p := &Processor{outputs: make(map[unsafe.Pointer]*Buffer)}
+	if len(opts) > 0 {
+		p.options = opts[0]
+	}
+	return p
+}
+
+// Process transforms the image buffer with the given options, like Resize.
+// The output buffer must be given back via Release once unused, as it may
+// be held by C memory.
+func (p *Processor) Process(buf []byte, o Options) ([]byte, error) {
+	if p.options.NoCache {
+		o.Load.NoCache = true
+	}
+	out, output, err := resizeHandoff(buf, o)
+	if err != nil {
+		return nil, err
+	}
+	if output != nil {
+		p.mu.Lock()
+		p.outputs[output.ptr] = output
+		p.mu.Unlock()
+		return out, nil
+	}
+
+	// Copy the other outputs, which may be shared, e.g. by the cache
+	var dst []byte
+	if b, ok := p.buffers.Get().(*[]byte); ok {
+		dst = *b
+	}
+	return append(dst[:0], out...), nil
+}
+
+// Release gives back an output buffer, freeing it or returning it to the
+// pool. It must not be used afterwards.
+func (p *Processor) Release(buf []byte) {
+	if cap(buf) == 0 {
+		return
+	}
+
+	p.mu.Lock()
+	output, ok := p.outputs[unsafe.Pointer(&buf[:1][0])]
+	delete(p.outputs, unsafe.Pointer(&buf[:1][0]))
+	p.mu.Unlock()
+	if ok {
+		output.Free()
+		return
+	}
+
+	buf = buf[:0]
+	p.buffers.Put(&buf)
+}
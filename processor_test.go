@@ -0,0 +1,130 @@
+package bimg
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestProcessor(t *testing.T) {
+	buf, _ := Read("testdata/test.jpg")
+	p := NewProcessor()
+
+	for i := 0; i < 3; i++ {
+		out, err := p.Process(buf, Options{Width: 300})
+		if err != nil {
+			t.Fatalf("Cannot process the image: %#v", err)
+		}
+		if size, _ := Size(out); size.Width != 300 {
+			t.Errorf("Invalid image width: %d", size.Width)
+		}
+		p.Release(out)
+	}
+
+	if _, err := p.Process([]byte("invalid"), Options{Width: 300}); err == nil {
+		t.Error("Expected error for an invalid image")
+	}
+}
+
+func TestProcessorHandoff(t *testing.T) {
+	buf, _ := Read("testdata/test.jpg")
+	p := NewProcessor()
+
+	// Outputs are held by C memory, freed on release
+	out, err := p.Process(buf, Options{Width: 300})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	output, ok := p.outputs[unsafe.Pointer(&out[0])]
+	if !ok || !output.holds(out) {
+		t.Fatal("The output is not held by a buffer")
+	}
+	p.Release(out)
+	if len(p.outputs) != 0 || output.Len() != 0 {
+		t.Error("The output buffer was not freed")
+	}
+}
+
+func TestProcessorReuse(t *testing.T) {
+	buf, _ := Read("testdata/test.jpg")
+	SetCache(NewLRUCache(10 << 20))
+	defer SetCache(nil)
+	p := NewProcessor()
+
+	// Cached outputs are copied into the pooled buffers
+	out, err := p.Process(buf, Options{Width: 300})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	// The pool may drop buffers, e.g. with the race detector, so the
+	// outputs must alias a released buffer at least once
+	reused := 0
+	for i := 0; i < 10; i++ {
+		released := &out[0]
+		p.Release(out)
+		out, err = p.Process(buf, Options{Width: 300})
+		if err != nil {
+			t.Fatalf("Cannot process the image: %#v", err)
+		}
+		if &out[0] == released {
+			reused++
+		}
+	}
+	if reused == 0 {
+		t.Error("The pooled output buffers were not reused")
+	}
+}
+
+func TestProcessorRelease(t *testing.T) {
+	p := NewProcessor()
+	p.Release(nil)
+
+	buf := make([]byte, 10, 100)
+	p.Release(buf)
+	if b, ok := p.buffers.Get().(*[]byte); ok && (len(*b) != 0 || cap(*b) != 100) {
+		t.Errorf("Invalid pooled buffer: %d/%d", len(*b), cap(*b))
+	}
+}
+
+func TestProcessorNoCache(t *testing.T) {
+	buf, _ := Read("testdata/test.jpg")
+	cacheEntries := func(p *Processor) int64 {
+		VipsCacheDropAll()
+		out, err := p.Process(buf, Options{Width: 300, Flip: true})
+		if err != nil {
+			t.Fatalf("Cannot process the image: %#v", err)
+		}
+		if size, _ := Size(out); size.Width != 300 {
+			t.Errorf("Invalid image width: %d", size.Width)
+		}
+		return VipsMemory().CacheEntries
+	}
+
+	shared := cacheEntries(NewProcessor())
+	uncached := cacheEntries(NewProcessor(ProcessorOptions{NoCache: true}))
+	if uncached >= shared {
+		t.Errorf("Cached operations not dropped: %d, expected fewer than %d", uncached, shared)
+	}
+}
+
+func TestProcessorNoCacheIsolation(t *testing.T) {
+	VipsCacheDropAll()
+	if _, err := Resize(readFile("test.png"), Options{Width: 100}); err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	stats := VipsMemory()
+	if stats.CacheEntries == 0 {
+		t.Skip("The libvips operation cache is disabled")
+	}
+
+	// Fill the cache, so any insertion trims the other image operations
+	VipsCacheSetMax(int(stats.CacheEntries))
+	defer VipsCacheSetMax(int(stats.CacheMaxEntries))
+
+	p := NewProcessor(ProcessorOptions{NoCache: true})
+	if _, err := p.Process(readFile("test.jpg"), Options{Width: 300, Flip: true, Rotate: D90}); err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if entries := VipsMemory().CacheEntries; entries != stats.CacheEntries {
+		t.Errorf("Cached operations of the other image evicted: %d entries, expected %d", entries, stats.CacheEntries)
+	}
+}
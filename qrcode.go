@@ -0,0 +1,155 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+)
+
+// QRLevel represents the QR code error correction level, the share of the
+// code which can be damaged and still be read.
+type QRLevel int
+
+const (
+	// QRLevelM recovers 15% of the code. It is the default.
+	QRLevelM QRLevel = iota
+	// QRLevelL recovers 7% of the code.
+	QRLevelL
+	// QRLevelQ recovers 25% of the code.
+	QRLevelQ
+	// QRLevelH recovers 30% of the code.
+	QRLevelH
+)
+
+// QRCodeOptions represents the NewQRCode and StampQRCode options.
+type QRCodeOptions struct {
+	// Size defines the maximum code width in pixels, including its quiet
+	// zone, rounded down to whole pixels per module, up to MaxSize.
+	// Defaults to 4 pixels per module, or a fifth of the smallest image
+	// side when stamped.
+	Size int
+	// Level defines the error correction level. Defaults to QRLevelM.
+	Level QRLevel
+	// Color defines the dark modules color. Defaults to black.
+	Color RGBAProvider
+	// Background defines the light modules and quiet zone color. Defaults
+	// to white.
+	Background RGBAProvider
+	// Gravity defines the position of the stamped code in the image.
+	Gravity Gravity
+	// Margin defines the space between the stamped code and the image
+	// edges, in pixels.
+	Margin int
+}
+
+// NewQRCode encodes the content as a QR code PNG image. The content is
+// encoded as bytes, up to 213 bytes with QRLevelM.
+func NewQRCode(content string, o QRCodeOptions) (*Image, error) {
+	defer C.vips_thread_shutdown()
+
+	image, err := qrCodeImage(content, o)
+	if err != nil {
+		return nil, err
+	}
+	return encodeGenerated(image)
+}
+
+// stampQRCode draws the QR code of the content over the auto-rotated image.
+func stampQRCode(img *Image, content string, o QRCodeOptions) ([]byte, error) {
+	defer C.vips_thread_shutdown()
+
+	if o.Margin < 0 {
+		return nil, fmt.Errorf("Invalid QR code margin: %d", o.Margin)
+	}
+	image, imageType, err := loadOrientedImage(img)
+	if err != nil {
+		return nil, err
+	}
+	width := int(image.Xsize) - 2*o.Margin
+	height := int(image.Ysize) - 2*o.Margin
+	if o.Size == 0 {
+		o.Size = int(image.Xsize) / 5
+		if image.Ysize < image.Xsize {
+			o.Size = int(image.Ysize) / 5
+		}
+	}
+
+	code, err := qrCodeImage(content, o)
+	if err != nil {
+		C.g_object_unref(C.gpointer(image))
+		return nil, err
+	}
+	if int(code.Xsize) > width || int(code.Ysize) > height {
+		C.g_object_unref(C.gpointer(image))
+		C.g_object_unref(C.gpointer(code))
+		return nil, errors.New("QR code does not fit in the image")
+	}
+
+	left, top := calculateCrop(width, height, int(code.Xsize), int(code.Ysize), o.Gravity)
+	image, err = vipsComposite(image, code, left+o.Margin, top+o.Margin, 1)
+	if err != nil {
+		return nil, err
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	save := applySaveDefaults(SaveOptions{}, imageType)
+	if !IsTypeSupportedSave(save.Type) {
+		return nil, wrapError(ErrUnsupportedFormat, "Unsupported image output type: "+ImageTypeName(save.Type))
+	}
+	return encodeImage(image, save, save.Quality)
+}
+
+// qrCodeImage renders the QR code of the content, with its four modules
+// wide quiet zone.
+func qrCodeImage(content string, o QRCodeOptions) (*C.VipsImage, error) {
+	if content == "" {
+		return nil, errors.New("QR code content cannot be empty")
+	}
+	if o.Size < 0 || o.Size > maxSize {
+		return nil, fmt.Errorf("Invalid QR code size: %d", o.Size)
+	}
+	code, err := qrEncode([]byte(content), o.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	modules := code.size + 8
+	scale := 4
+	if o.Size != 0 {
+		scale = o.Size / modules
+	}
+	if scale < 1 {
+		return nil, fmt.Errorf("QR code size too small, %d pixels at least are needed", modules)
+	}
+	if err := GetLimits().checkSize(modules*scale, modules*scale, 1); err != nil {
+		return nil, err
+	}
+
+	if o.Color == nil {
+		o.Color = ColorBlack
+	}
+	if o.Background == nil {
+		o.Background = Color{255, 255, 255}
+	}
+	dark, light := o.Color.RGBA(), o.Background.RGBA()
+
+	size := modules * scale
+	pixels := make([]byte, size*size*4)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			c := light
+			row, col := y/scale-4, x/scale-4
+			if row >= 0 && row < code.size && col >= 0 && col < code.size && code.modules[row][col] {
+				c = dark
+			}
+			p := pixels[(y*size+x)*4:]
+			p[0], p[1], p[2], p[3] = c.R, c.G, c.B, c.A
+		}
+	}
+	return vipsImageFromRGBA(pixels, size, size, dark.A < 255 || light.A < 255)
+}
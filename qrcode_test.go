@@ -0,0 +1,156 @@
+package bimg
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// HELLO WORLD encoded as a 1-M QR code
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	expected := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if ecc := reedSolomon(data, 10); !bytes.Equal(ecc, expected) {
+		t.Errorf("Invalid error correction codewords: %v", ecc)
+	}
+}
+
+func TestQRCodeFormat(t *testing.T) {
+	cases := []struct {
+		level    QRLevel
+		mask     int
+		expected string
+	}{
+		{QRLevelM, 0, "101010000010010"},
+		{QRLevelL, 0, "111011111000100"},
+		{QRLevelH, 7, "000100000111011"},
+	}
+	for _, c := range cases {
+		q := newQRCode(1)
+		q.drawFormat(qrFormatLevels[c.level], c.mask)
+
+		var bits strings.Builder
+		for _, p := range [][2]int{{0, 8}, {1, 8}, {2, 8}, {3, 8}, {4, 8}, {5, 8}, {7, 8}, {8, 8},
+			{8, 7}, {8, 5}, {8, 4}, {8, 3}, {8, 2}, {8, 1}, {8, 0}} {
+			if q.modules[p[1]][p[0]] {
+				bits.WriteByte('1')
+			} else {
+				bits.WriteByte('0')
+			}
+		}
+		if bits.String() != c.expected {
+			t.Errorf("Invalid format bits for level %d and mask %d: %s", c.level, c.mask, bits.String())
+		}
+	}
+}
+
+func TestQREncodeCapacity(t *testing.T) {
+	q, err := qrEncode([]byte(strings.Repeat("a", 213)), QRLevelM)
+	if err != nil {
+		t.Fatalf("Cannot encode the content: %s", err)
+	}
+	if q.size != 57 {
+		t.Errorf("Invalid QR code size: %d", q.size)
+	}
+	if _, err := qrEncode([]byte(strings.Repeat("a", 214)), QRLevelM); err == nil {
+		t.Error("Expected an error for a too long content")
+	}
+}
+
+func TestQREncodeGolden(t *testing.T) {
+	// Version 1-M code with mask 2, decoded by an independent reader
+	expected := []string{
+		"#######..#..#.#######",
+		"#.....#..#....#.....#",
+		"#.###.#.###...#.###.#",
+		"#.###.#.#.#.#.#.###.#",
+		"#.###.#.#...#.#.###.#",
+		"#.....#.#.##..#.....#",
+		"#######.#.#.#.#######",
+		"........##...........",
+		"#.#####..###..#####..",
+		"#..#...#...##...#....",
+		"#....###.##.#..#.###.",
+		"##..#..#...##....##..",
+		"..##.##.....###.#.##.",
+		"........#.#.#..##.##.",
+		"#######..#.#...#.#.#.",
+		"#.....#.##.....#.####",
+		"#.###.#.##.#.##...#.#",
+		"#.###.#.###.##.......",
+		"#.###.#.#.#.#....#...",
+		"#.....#..........##..",
+		"#######.#...###...##.",
+	}
+	q, err := qrEncode([]byte("TICKET-0042"), QRLevelM)
+	if err != nil {
+		t.Fatalf("Cannot encode the content: %s", err)
+	}
+	if q.size != len(expected) {
+		t.Fatalf("Invalid QR code size: %d", q.size)
+	}
+	for y, row := range q.modules {
+		var line strings.Builder
+		for _, dark := range row {
+			if dark {
+				line.WriteByte('#')
+			} else {
+				line.WriteByte('.')
+			}
+		}
+		if line.String() != expected[y] {
+			t.Errorf("Invalid modules row %d: %s", y, line.String())
+		}
+	}
+}
+
+func TestNewQRCode(t *testing.T) {
+	img, err := NewQRCode("https://example.com", QRCodeOptions{})
+	if err != nil {
+		t.Fatalf("Cannot generate the QR code: %#v", err)
+	}
+	// Version 2 code of 25 modules, plus the quiet zone, at 4 pixels per module
+	if err := assertSize(img.Image(), 132, 132); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := png.Decode(bytes.NewReader(img.Image()))
+	if err != nil {
+		t.Fatalf("Cannot decode the image: %s", err)
+	}
+	gray := func(x, y int) uint8 { return color.GrayModel.Convert(decoded.At(x, y)).(color.Gray).Y }
+	if gray(0, 0) != 255 || gray(16, 16) != 0 || gray(20, 20) != 255 {
+		t.Error("Invalid quiet zone or finder pattern")
+	}
+
+	for _, size := range []int{-1, 1 << 30} {
+		if _, err := NewQRCode("https://example.com", QRCodeOptions{Size: size}); err == nil {
+			t.Errorf("Expected an error for the size %d", size)
+		}
+	}
+
+	SetLimits(Limits{MaxPixels: 10000})
+	defer SetLimits(Limits{})
+	if _, err := NewQRCode("https://example.com", QRCodeOptions{Size: 1000}); !errors.Is(err, ErrDimensionsTooLarge) {
+		t.Errorf("Expected a limits error: %#v", err)
+	}
+}
+
+func TestImageStampQRCode(t *testing.T) {
+	options := QRCodeOptions{Size: 200, Gravity: GravitySouth, Margin: 20}
+	buf, err := initImage("test.jpg").StampQRCode("TICKET-0042", options)
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if err := assertSize(buf, 1680, 1050); err != nil {
+		t.Fatal(err)
+	}
+	Write("testdata/test_qrcode_out.jpg", buf)
+
+	if _, err := initImage("test.jpg").StampQRCode("TICKET-0042", QRCodeOptions{Size: 2000}); err == nil {
+		t.Error("Expected an error for a code larger than the image")
+	}
+}
@@ -0,0 +1,427 @@
+package bimg
+
+import "fmt"
+
+// qrBlocks describes the error correction blocks of a QR code version and
+// level: the error correction codewords per block, then the number of
+// blocks and their data codewords for both block groups.
+type qrBlocks struct {
+	ecc                 int
+	blocks1, dataWords1 int
+	blocks2, dataWords2 int
+}
+
+// qrVersions lists the blocks of the versions 1 to 10, by QRLevel.
+var qrVersions = [][4]qrBlocks{
+	{{10, 1, 16, 0, 0}, {7, 1, 19, 0, 0}, {13, 1, 13, 0, 0}, {17, 1, 9, 0, 0}},
+	{{16, 1, 28, 0, 0}, {10, 1, 34, 0, 0}, {22, 1, 22, 0, 0}, {28, 1, 16, 0, 0}},
+	{{26, 1, 44, 0, 0}, {15, 1, 55, 0, 0}, {18, 2, 17, 0, 0}, {22, 2, 13, 0, 0}},
+	{{18, 2, 32, 0, 0}, {20, 1, 80, 0, 0}, {26, 2, 24, 0, 0}, {16, 4, 9, 0, 0}},
+	{{24, 2, 43, 0, 0}, {26, 1, 108, 0, 0}, {18, 2, 15, 2, 16}, {22, 2, 11, 2, 12}},
+	{{16, 4, 27, 0, 0}, {18, 2, 68, 0, 0}, {24, 4, 19, 0, 0}, {28, 4, 15, 0, 0}},
+	{{18, 4, 31, 0, 0}, {20, 2, 78, 0, 0}, {18, 2, 14, 4, 15}, {26, 4, 13, 1, 14}},
+	{{22, 2, 38, 2, 39}, {24, 2, 97, 0, 0}, {22, 4, 18, 2, 19}, {26, 4, 14, 2, 15}},
+	{{22, 3, 36, 2, 37}, {30, 2, 116, 0, 0}, {20, 4, 16, 4, 17}, {24, 4, 12, 4, 13}},
+	{{26, 4, 43, 1, 44}, {18, 2, 68, 2, 69}, {24, 6, 19, 2, 20}, {28, 6, 15, 2, 16}},
+}
+
+// qrAlignments lists the alignment pattern coordinates of the versions.
+var qrAlignments = [][]int{
+	nil, {6, 18}, {6, 22}, {6, 26}, {6, 30}, {6, 34},
+	{6, 22, 38}, {6, 24, 42}, {6, 26, 46}, {6, 28, 50},
+}
+
+// qrFormatLevels are the format information bits of every QRLevel.
+var qrFormatLevels = [4]int{0, 1, 3, 2}
+
+func (b qrBlocks) dataWords() int {
+	return b.blocks1*b.dataWords1 + b.blocks2*b.dataWords2
+}
+
+// qrCode holds the modules of a QR code, true being dark.
+type qrCode struct {
+	size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+// qrEncode encodes the content in byte mode, in the smallest version
+// holding it.
+func qrEncode(content []byte, level QRLevel) (*qrCode, error) {
+	if level < QRLevelM || level > QRLevelH {
+		return nil, fmt.Errorf("Invalid QR code level: %d", level)
+	}
+
+	for version := 1; version <= len(qrVersions); version++ {
+		blocks := qrVersions[version-1][level]
+		countBits := 8
+		if version >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+len(content)*8 > blocks.dataWords()*8 {
+			continue
+		}
+
+		data := qrData(content, countBits, blocks.dataWords())
+		q := newQRCode(version)
+		q.drawCodewords(qrInterleave(data, blocks))
+		q.applyBestMask(level)
+		if version >= 7 {
+			q.drawVersion(version)
+		}
+		return q, nil
+	}
+	return nil, fmt.Errorf("QR code content too long: %d bytes", len(content))
+}
+
+// qrData returns the data codewords: the byte mode header and content, the
+// terminator and the padding.
+func qrData(content []byte, countBits, capacity int) []byte {
+	var bits []bool
+	appendBits := func(value, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, value>>uint(i)&1 == 1)
+		}
+	}
+
+	appendBits(4, 4)
+	appendBits(len(content), countBits)
+	for _, b := range content {
+		appendBits(int(b), 8)
+	}
+	for i := 0; i < 4 && len(bits) < capacity*8; i++ {
+		bits = append(bits, false)
+	}
+	for len(bits)%8 != 0 {
+		bits = append(bits, false)
+	}
+
+	data := make([]byte, 0, capacity)
+	for i := 0; i < len(bits); i += 8 {
+		var b byte
+		for _, bit := range bits[i : i+8] {
+			b <<= 1
+			if bit {
+				b |= 1
+			}
+		}
+		data = append(data, b)
+	}
+	for pad := byte(0xEC); len(data) < capacity; pad ^= 0xEC ^ 0x11 {
+		data = append(data, pad)
+	}
+	return data
+}
+
+// qrInterleave splits the data in blocks, computes their error correction
+// codewords, and interleaves them.
+func qrInterleave(data []byte, b qrBlocks) []byte {
+	var dataBlocks, eccBlocks [][]byte
+	for i := 0; i < b.blocks1+b.blocks2; i++ {
+		n := b.dataWords1
+		if i >= b.blocks1 {
+			n = b.dataWords2
+		}
+		dataBlocks = append(dataBlocks, data[:n])
+		eccBlocks = append(eccBlocks, reedSolomon(data[:n], b.ecc))
+		data = data[n:]
+	}
+
+	var out []byte
+	for _, blocks := range [][][]byte{dataBlocks, eccBlocks} {
+		for i := 0; ; i++ {
+			added := false
+			for _, block := range blocks {
+				if i < len(block) {
+					out = append(out, block[i])
+					added = true
+				}
+			}
+			if !added {
+				break
+			}
+		}
+	}
+	return out
+}
+
+// reedSolomon returns the n error correction codewords of the data, over
+// GF(256) with the 0x11D polynomial.
+func reedSolomon(data []byte, n int) []byte {
+	generator := []byte{1}
+	for i, root := 0, byte(1); i < n; i, root = i+1, gfMul(root, 2) {
+		next := make([]byte, len(generator)+1)
+		for j, c := range generator {
+			next[j] ^= c
+			next[j+1] ^= gfMul(c, root)
+		}
+		generator = next
+	}
+
+	remainder := make([]byte, n)
+	for _, b := range data {
+		factor := b ^ remainder[0]
+		copy(remainder, remainder[1:])
+		remainder[n-1] = 0
+		for j := range remainder {
+			remainder[j] ^= gfMul(generator[j+1], factor)
+		}
+	}
+	return remainder
+}
+
+func gfMul(x, y byte) byte {
+	var z byte
+	for i := 7; i >= 0; i-- {
+		carry := z >> 7
+		z <<= 1
+		if carry == 1 {
+			z ^= 0x1D
+		}
+		if y>>uint(i)&1 == 1 {
+			z ^= x
+		}
+	}
+	return z
+}
+
+// newQRCode creates a code of the version with its function patterns.
+func newQRCode(version int) *qrCode {
+	size := version*4 + 17
+	q := &qrCode{size: size, modules: make([][]bool, size), function: make([][]bool, size)}
+	for y := range q.modules {
+		q.modules[y] = make([]bool, size)
+		q.function[y] = make([]bool, size)
+	}
+
+	for i := 0; i < size; i++ {
+		q.set(6, i, i%2 == 0)
+		q.set(i, 6, i%2 == 0)
+	}
+	q.drawFinder(3, 3)
+	q.drawFinder(size-4, 3)
+	q.drawFinder(3, size-4)
+
+	positions := qrAlignments[version-1]
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					q.set(x+dx, y+dy, qrDistance(dx, dy) != 1)
+				}
+			}
+		}
+	}
+
+	// Reserve the format and version areas
+	q.drawFormat(0, 0)
+	if version >= 7 {
+		q.drawVersion(version)
+	}
+	return q
+}
+
+// set defines a function module, x being the column and y the row.
+func (q *qrCode) set(x, y int, dark bool) {
+	q.modules[y][x] = dark
+	q.function[y][x] = true
+}
+
+func (q *qrCode) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || x >= q.size || y < 0 || y >= q.size {
+				continue
+			}
+			dist := qrDistance(dx, dy)
+			q.set(x, y, dist != 2 && dist != 4)
+		}
+	}
+}
+
+// qrDistance returns the distance of a module to a pattern centre, in
+// rings.
+func qrDistance(dx, dy int) int {
+	if abs(dx) > abs(dy) {
+		return abs(dx)
+	}
+	return abs(dy)
+}
+
+// drawFormat draws both copies of the format information, and the dark
+// module.
+func (q *qrCode) drawFormat(formatLevel, mask int) {
+	data := formatLevel<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>uint(i)&1 == 1 }
+
+	for i := 0; i < 6; i++ {
+		q.set(8, i, bit(i))
+	}
+	q.set(8, 7, bit(6))
+	q.set(8, 8, bit(7))
+	q.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.set(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		q.set(q.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.set(8, q.size-15+i, bit(i))
+	}
+	q.set(8, q.size-8, true)
+}
+
+func (q *qrCode) drawVersion(version int) {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = rem<<1 ^ (rem>>11)*0x1F25
+	}
+	bits := version<<12 | rem
+	for i := 0; i < 18; i++ {
+		dark := bits>>uint(i)&1 == 1
+		a, b := q.size-11+i%3, i/3
+		q.set(a, b, dark)
+		q.set(b, a, dark)
+	}
+}
+
+// drawCodewords fills the data modules in the zigzag order, from the
+// bottom right corner.
+func (q *qrCode) drawCodewords(data []byte) {
+	i := 0
+	for right := q.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < q.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = q.size - 1 - vert
+				}
+				if !q.function[y][x] && i < len(data)*8 {
+					q.modules[y][x] = data[i>>3]>>uint(7-i&7)&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask flips the data modules matching the mask pattern. Applying it
+// twice undoes it.
+func (q *qrCode) applyMask(mask int) {
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !q.function[y][x] {
+				q.modules[y][x] = !q.modules[y][x]
+			}
+		}
+	}
+}
+
+// applyBestMask applies the mask pattern with the lowest penalty.
+func (q *qrCode) applyBestMask(level QRLevel) {
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormat(qrFormatLevels[level], mask)
+		if penalty := q.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		q.applyMask(mask)
+	}
+	q.applyMask(best)
+	q.drawFormat(qrFormatLevels[level], best)
+}
+
+// penalty scores the patterns which make the code harder to read: long
+// runs, 2x2 blocks, finder-like patterns and dark and light imbalance.
+func (q *qrCode) penalty() int {
+	penalty, dark := 0, 0
+	at := func(x, y int, transpose bool) bool {
+		if transpose {
+			return q.modules[x][y]
+		}
+		return q.modules[y][x]
+	}
+
+	for _, transpose := range []bool{false, true} {
+		for y := 0; y < q.size; y++ {
+			run := 0
+			var line uint
+			for x := 0; x < q.size; x++ {
+				if x > 0 && at(x, y, transpose) == at(x-1, y, transpose) {
+					run++
+					if run == 5 {
+						penalty += 3
+					} else if run > 5 {
+						penalty++
+					}
+				} else {
+					run = 1
+				}
+
+				// Dark-light-dark-dark-dark-light-dark with four light
+				// modules on either side
+				line = line << 1 & 0x7FF
+				if at(x, y, transpose) {
+					line |= 1
+				}
+				if x >= 10 && (line == 0x5D0 || line == 0x05D) {
+					penalty += 40
+				}
+			}
+		}
+	}
+
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			if q.modules[y][x] {
+				dark++
+			}
+			if x > 0 && y > 0 {
+				c := q.modules[y][x]
+				if c == q.modules[y][x-1] && c == q.modules[y-1][x] && c == q.modules[y-1][x-1] {
+					penalty += 3
+				}
+			}
+		}
+	}
+
+	total := q.size * q.size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	return penalty + k*10
+}
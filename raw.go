@@ -0,0 +1,92 @@
+package bimg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+)
+
+// RawFormat returns the camera RAW format of the image buffer, such as
+// "cr2", "cr3", "nef", "arw" or "dng", or an empty string.
+func RawFormat(buf []byte) string {
+	return rawFormat(buf)
+}
+
+func rawFormat(buf []byte) string {
+	if len(buf) < 16 {
+		return ""
+	}
+	// ISO base media file with the Canon "crx " brand
+	if string(buf[4:12]) == "ftypcrx " {
+		return "cr3"
+	}
+
+	order := tiffByteOrder(buf)
+	if order == nil || order.Uint16(buf[2:]) != 42 {
+		return ""
+	}
+	if string(buf[8:10]) == "CR" {
+		return "cr2"
+	}
+
+	ifd := int(order.Uint32(buf[4:]))
+	if ifd < 8 || ifd+2 > len(buf) {
+		return ""
+	}
+	// Nikon and Sony also write plain TIFFs, so their RAW files are told
+	// apart by the sub-IFDs, the CFA photometric interpretation or the Sony
+	// SR2 private data holding the sensor data
+	var maker string
+	var sensor bool
+	entries := int(order.Uint16(buf[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(buf) {
+			break
+		}
+		switch order.Uint16(buf[entry:]) {
+		// DNGVersion
+		case 0xC612:
+			return "dng"
+		// Make, an ASCII string
+		case 0x010F:
+			maker = tiffString(buf, order, entry)
+		// SubIFDs and SR2Private
+		case 0x014A, 0xC634:
+			sensor = true
+		// PhotometricInterpretation, a short
+		case 0x0106:
+			sensor = sensor || order.Uint16(buf[entry+8:]) == 32803
+		}
+	}
+
+	maker = strings.ToUpper(maker)
+	switch {
+	case !sensor:
+		return ""
+	case strings.HasPrefix(maker, "NIKON"):
+		return "nef"
+	case strings.HasPrefix(maker, "SONY"):
+		return "arw"
+	}
+	return ""
+}
+
+// tiffString reads the ASCII value of a TIFF IFD entry.
+func tiffString(buf []byte, order binary.ByteOrder, entry int) string {
+	if order.Uint16(buf[entry+2:]) != 2 {
+		return ""
+	}
+	count := int(order.Uint32(buf[entry+4:]))
+	value := buf[entry+8 : entry+12]
+	if count > 4 {
+		offset := int(order.Uint32(buf[entry+8:]))
+		if offset < 0 || offset+count > len(buf) {
+			return ""
+		}
+		value = buf[offset : offset+count]
+	} else if count < 4 {
+		value = value[:count]
+	}
+	return string(bytes.TrimRight(value, "\x00"))
+}
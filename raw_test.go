@@ -0,0 +1,123 @@
+package bimg
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// tiffEntry represents a TIFF IFD entry.
+type tiffEntry struct {
+	tag, kind uint16
+	value     []byte
+}
+
+// rawTIFF builds a little endian TIFF header with a single IFD.
+func rawTIFF(entries ...tiffEntry) []byte {
+	buf := make([]byte, 10+12*len(entries), 128)
+	copy(buf, "II*\x00")
+	binary.LittleEndian.PutUint32(buf[4:], 8)
+	binary.LittleEndian.PutUint16(buf[8:], uint16(len(entries)))
+	for i, e := range entries {
+		entry := buf[10+12*i:]
+		binary.LittleEndian.PutUint16(entry, e.tag)
+		binary.LittleEndian.PutUint16(entry[2:], e.kind)
+		binary.LittleEndian.PutUint32(entry[4:], uint32(len(e.value)))
+		if len(e.value) <= 4 {
+			copy(entry[8:], e.value)
+		} else {
+			binary.LittleEndian.PutUint32(entry[8:], uint32(len(buf)))
+			buf = append(buf, e.value...)
+		}
+	}
+	return append(buf, make([]byte, 16)...)
+}
+
+func TestRawFormat(t *testing.T) {
+	cr2 := rawTIFF(tiffEntry{0x0100, 3, []byte{1, 0}})
+	copy(cr2[8:], "CR\x02\x00")
+
+	nikon := tiffEntry{0x010F, 2, []byte("NIKON CORPORATION\x00")}
+	sony := tiffEntry{0x010F, 2, []byte("SONY\x00")}
+	subIFDs := tiffEntry{0x014A, 4, []byte{0, 0, 0, 0}}
+	cfa := tiffEntry{0x0106, 3, []byte{0x03, 0x80}}
+	rgb := tiffEntry{0x0106, 3, []byte{2, 0}}
+
+	cases := []struct {
+		buf    []byte
+		format string
+	}{
+		{append([]byte("\x00\x00\x00\x18ftypcrx "), make([]byte, 16)...), "cr3"},
+		{cr2, "cr2"},
+		{rawTIFF(tiffEntry{0xC612, 1, []byte{1, 4, 0, 0}}), "dng"},
+		{rawTIFF(nikon, subIFDs), "nef"},
+		{rawTIFF(sony, cfa), "arw"},
+		{rawTIFF(sony, tiffEntry{0xC634, 1, []byte{0, 0, 0, 0}}), "arw"},
+		// Plain TIFFs written by the cameras or their software
+		{rawTIFF(nikon), ""},
+		{rawTIFF(sony, rgb), ""},
+		{rawTIFF(tiffEntry{0x010F, 2, []byte("Scanner\x00")}, subIFDs), ""},
+		{readFile("test.jpg"), ""},
+		{[]byte("II*\x00"), ""},
+	}
+
+	for _, c := range cases {
+		if format := RawFormat(c.buf); format != c.format {
+			t.Errorf("Invalid RAW format: %q != %q", format, c.format)
+		}
+	}
+}
+
+func TestRawDefaultOutputType(t *testing.T) {
+	if o := applyDefaults(Options{}, RAW); o.Type != JPEG {
+		t.Errorf("RAW images should be saved as JPEG: %s", ImageTypeName(o.Type))
+	}
+	if o := applySaveDefaults(SaveOptions{}, RAW); o.Type != JPEG {
+		t.Errorf("RAW images should be saved as JPEG: %s", ImageTypeName(o.Type))
+	}
+}
+
+func TestVipsWhiteBalance(t *testing.T) {
+	// colourCast returns the distance of the red and blue means to the
+	// green one
+	colourCast := func(buf []byte) float64 {
+		stats, err := NewImage(buf).Stats()
+		if err != nil {
+			t.Fatalf("Cannot read the image stats: %s", err)
+		}
+		b := stats.Bands
+		return math.Abs(b[0].Mean-b[1].Mean) + math.Abs(b[2].Mean-b[1].Mean)
+	}
+	balance := func(gains [3]float64, auto bool) ([]byte, error) {
+		image, _, err := loadImage(readFile("test.jpg"))
+		if err != nil {
+			t.Fatalf("Cannot load the image: %s", err)
+		}
+		image, err = vipsWhiteBalance(image, gains, auto)
+		if err != nil {
+			return nil, err
+		}
+		return vipsSave(image, vipsSaveOptions{Type: PNG})
+	}
+
+	buf, err := balance([3]float64{1, 1, 1}, true)
+	if err != nil {
+		t.Fatalf("Cannot balance the image: %s", err)
+	}
+	if cast := colourCast(buf); cast >= colourCast(readFile("test.jpg")) {
+		t.Errorf("The grey world balance did not reduce the colour cast: %g", cast)
+	}
+
+	// Neutral gains keep the image
+	buf, err = balance([3]float64{1, 1, 1}, false)
+	if err != nil {
+		t.Fatalf("Cannot balance the image: %s", err)
+	}
+	if diff := math.Abs(colourCast(buf) - colourCast(readFile("test.jpg"))); diff > 1 {
+		t.Errorf("Neutral gains changed the image: %g", diff)
+	}
+
+	if _, err := balance([3]float64{1, 0, 1}, false); err == nil {
+		t.Error("Expected an error for a zero gain")
+	}
+}
@@ -12,5 +12,8 @@ func Resize(buf []byte, o Options) ([]byte, error) {
 	// Required in order to prevent premature garbage collection. See:
 	// https://github.com/h2non/bimg/pull/162
 	defer runtime.KeepAlive(buf)
+	if c := GetCache(); c != nil {
+		return cachedResize(c, buf, o)
+	}
 	return resizer(buf, o)
 }
@@ -6,5 +6,8 @@ package bimg
 // with the passed options.
 // Used as proxy to resizer() only in Go <= 1.6 versions
 func Resize(buf []byte, o Options) ([]byte, error) {
+	if c := GetCache(); c != nil {
+		return cachedResize(c, buf, o)
+	}
 	return resizer(buf, o)
 }
@@ -10,6 +10,8 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"reflect"
+	"time"
 )
 
 var (
@@ -19,20 +21,73 @@ var (
 
 // resizer is used to transform a given image as byte buffer
 // with the passed options.
-func resizer(buf []byte, o Options) ([]byte, error) {
+func resizer(buf []byte, o Options) (out []byte, err error) {
 	defer C.vips_thread_shutdown()
+	defer observeOperation("resize", vipsImageType(buf), time.Now(), &err)
 
-	image, imageType, err := loadImage(buf)
+	// Attach the content credentials once encoded, from the source image
+	if !o.C2PA.isZero() {
+		source, c2pa := buf, o.C2PA
+		defer func() {
+			if err == nil {
+				out, err = applyC2PA(out, source, SaveOptions{Type: DetermineImageType(out), C2PA: c2pa})
+			}
+		}()
+	}
+
+	if err := validateExtend(o.Extend); err != nil {
+		return nil, err
+	}
+	if err := validateExtend(o.Border.Extend); err != nil {
+		return nil, err
+	}
+	if o.ResizeMode == ResizeModeCover {
+		if o.Width <= 0 || o.Height <= 0 {
+			return nil, errors.New("Cover resize mode requires both width and height")
+		}
+		o.Crop, o.Enlarge, o.Embed, o.Force = true, true, false, false
+	}
+
+	load := o.Load
+	if load.Access == AccessAuto && isSequentialOperation(o) {
+		load.Access = AccessSequential
+	}
+	if load.NoCache {
+		load.scope = &cacheScope{}
+		load.scope.suspend()
+		defer load.scope.drop()
+	}
+
+	image, imageType, err := loadImageOptions(buf, load)
 	if err != nil {
 		return nil, err
 	}
 
+	// Images rotated by their EXIF orientation cannot be streamed
+	if o.Load.Access == AccessAuto && load.Access == AccessSequential && !o.NoAutoRotate && vipsExifOrientation(image) > 1 {
+		C.g_object_unref(C.gpointer(image))
+		load.Access = AccessRandom
+		image, imageType, err = loadImageOptions(buf, load)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Clone and define default options
 	o = applyDefaults(o, imageType)
 
 	// Ensure supported type
 	if !IsTypeSupportedSave(o.Type) {
-		return nil, errors.New("Unsupported image output type")
+		return nil, wrapError(ErrUnsupportedFormat, "Unsupported image output type")
+	}
+
+	// Rotate, flip and extract without re-encoding
+	if imageType == JPEG && isLosslessOperation(o) {
+		out, ok, err := transformLossless(buf, image, o)
+		if ok || err != nil {
+			C.g_object_unref(C.gpointer(image))
+			return out, err
+		}
 	}
 
 	// Autorate only
@@ -44,12 +99,30 @@ func resizer(buf []byte, o Options) ([]byte, error) {
 		return saveImage(image, o)
 	}
 
+	// Apply the orientation given by the caller instead of the EXIF one
+	if o.orientation != OrientationUndefined {
+		image, err = applyOrientation(image, o.orientation)
+		if err != nil {
+			return nil, err
+		}
+		return saveImage(image, o)
+	}
+
 	// Auto rotate image based on EXIF orientation header
 	image, rotated, err := rotateAndFlipImage(image, o)
 	if err != nil {
 		return nil, err
 	}
 
+	// Pad or crop the canvas only, if necessary
+	if o.canvas.width > 0 {
+		image, err = canvasImage(image, o.canvas)
+		if err != nil {
+			return nil, err
+		}
+		return saveImage(image, o)
+	}
+
 	// If JPEG or HEIF image, retrieve the buffer
 	if rotated && (imageType == JPEG || imageType == HEIF || imageType == AVIF) && !o.NoAutoRotate {
 		buf, err = getImageBuffer(image)
@@ -84,8 +157,11 @@ func resizer(buf []byte, o Options) ([]byte, error) {
 	// Try to use libjpeg/libwebp shrink-on-load
 	supportsShrinkOnLoad := imageType == WEBP && VipsMajorVersion >= 8 && VipsMinorVersion >= 3
 	supportsShrinkOnLoad = supportsShrinkOnLoad || imageType == JPEG
+	// Reloading would discard the decoded region
+	supportsShrinkOnLoad = supportsShrinkOnLoad && o.Load.Region == (Region{})
+	supportsShrinkOnLoad = supportsShrinkOnLoad && !o.Load.NoShrinkOnLoad
 	if supportsShrinkOnLoad && shrink >= 2 {
-		tmpImage, factor, err := shrinkOnLoad(buf, image, imageType, factor, shrink)
+		tmpImage, factor, err := shrinkOnLoad(buf, image, imageType, factor, shrink, load)
 		if err != nil {
 			return nil, err
 		}
@@ -96,6 +172,69 @@ func resizer(buf []byte, o Options) ([]byte, error) {
 		residual = float64(shrink) / factor
 	}
 
+	// Try to use the HEIF embedded thumbnail or the pyramidal TIFF levels.
+	// Rotated images are not reloaded, as their levels are not rotated.
+	supportsLevelOnLoad := imageType == HEIF || imageType == AVIF || imageType == TIFF
+	supportsLevelOnLoad = supportsLevelOnLoad && !rotated && o.Load.Region == (Region{})
+	if supportsLevelOnLoad && shrink >= 2 {
+		tmpImage, factor := levelOnLoad(buf, image, imageType, factor, load)
+
+		image = tmpImage
+		factor = math.Max(factor, 1.0)
+		shrink = int(math.Floor(factor))
+		residual = float64(shrink) / factor
+	}
+
+	if err := o.trace.mark("decode", &image); err != nil {
+		return nil, err
+	}
+
+	// Tone map high dynamic range images, if necessary
+	if o.ToneMap.Operator != ToneMapNone {
+		image, err = vipsToneMap(image, o.ToneMap)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Reduce noise before resizing, if necessary
+	image, err = applyDenoise(image, o.Denoise)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pixelate the image area before resizing, as it is given in the source
+	// image pixels, scaled to the image shrunk on load
+	xscale, yscale := float64(image.Xsize)/float64(inWidth), float64(image.Ysize)/float64(inHeight)
+	image, err = applyPixelate(image, o.Pixelate, xscale, yscale)
+	if err != nil {
+		return nil, err
+	}
+
+	// Likewise for the blurred image area
+	if b := o.GaussianBlur; b.Region != (Region{}) && len(b.Mask) == 0 {
+		image, err = applyGaussianBlurRegion(image, b, xscale, yscale)
+		if err != nil {
+			return nil, err
+		}
+		o.GaussianBlur = GaussianBlur{}
+	}
+
+	// Likewise for the motion and radial blurs, whose distance and center
+	// are given in the source image pixels
+	if o.MotionBlur.Distance > 1 {
+		image, err = applyMotionBlur(image, o.MotionBlur, xscale)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if o.RadialBlur.Amount > 0 {
+		image, err = applyRadialBlur(image, o.RadialBlur, xscale, yscale)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Zoom image, if necessary
 	image, err = zoomImage(image, o.Zoom)
 	if err != nil {
@@ -103,13 +242,22 @@ func resizer(buf []byte, o Options) ([]byte, error) {
 	}
 
 	// Transform image, if necessary
-	if shouldTransformImage(o, inWidth, inHeight) {
+	if o.ResizeMode == ResizeModeCover {
+		image, err = coverImage(image, o, inWidth, inHeight, shrink, residual)
+		if err != nil {
+			return nil, err
+		}
+	} else if shouldTransformImage(o, inWidth, inHeight) {
 		image, err = transformImage(image, o, shrink, residual)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	if err := o.trace.mark("transform", &image); err != nil {
+		return nil, err
+	}
+
 	// Apply effects, if necessary
 	if shouldApplyEffects(o) {
 		image, err = applyEffects(image, o)
@@ -118,6 +266,18 @@ func resizer(buf []byte, o Options) ([]byte, error) {
 		}
 	}
 
+	// Add vignette, if necessary
+	image, err = applyVignette(image, o.Vignette)
+	if err != nil {
+		return nil, err
+	}
+
+	// Add film grain, if necessary
+	image, err = applyGrain(image, o.Grain)
+	if err != nil {
+		return nil, err
+	}
+
 	// Add watermark, if necessary
 	image, err = watermarkImageWithText(image, o.Watermark)
 	if err != nil {
@@ -130,6 +290,24 @@ func resizer(buf []byte, o Options) ([]byte, error) {
 		return nil, err
 	}
 
+	// Apply alpha channel operations, if necessary
+	image, err = applyAlpha(image, o)
+	if err != nil {
+		return nil, err
+	}
+
+	// Round the image corners, if necessary
+	image, err = applyCornerMask(image, o)
+	if err != nil {
+		return nil, err
+	}
+
+	// Add the border, if necessary
+	image, err = applyBorder(image, o.Border)
+	if err != nil {
+		return nil, err
+	}
+
 	// Flatten image on a background, if necessary
 	image, err = imageFlatten(image, imageType, o)
 	if err != nil {
@@ -154,19 +332,145 @@ func resizer(buf []byte, o Options) ([]byte, error) {
 		return nil, err
 	}
 
+	// Detect edges, if necessary
+	image, err = applyEdgeDetection(image, o.EdgeDetection)
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply invert, if necessary
+	image, err = applyInvert(image, o)
+	if err != nil {
+		return nil, err
+	}
+
+	// Map the pixel values, if necessary
+	image, err = applyLUT(image, o.LUT)
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply threshold, if necessary
+	image, err = applyBinarize(image, o)
+	if err != nil {
+		return nil, err
+	}
+
+	// Premultiply or unpremultiply the alpha channel, if necessary
+	image, err = applyPremultiply(image, o)
+	if err != nil {
+		return nil, err
+	}
+
+	// Extract bands, if necessary
+	if o.ExtractBand.N > 0 {
+		image, err = vipsExtractBand(image, o.ExtractBand.Index, o.ExtractBand.N)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := o.trace.mark("effects", &image); err != nil {
+		return nil, err
+	}
+
 	return saveImage(image, o)
 }
 
+// isSequentialOperation reports whether the operation reads the image
+// pixels from top to bottom only, so the image can be streamed while
+// processed rather than fully decoded into memory.
+func isSequentialOperation(o Options) bool {
+	if o.Gravity == GravitySmart || o.SmartCrop {
+		return false
+	}
+	sequential := Options{
+		Width:          o.Width,
+		Height:         o.Height,
+		Quality:        o.Quality,
+		Compression:    o.Compression,
+		Crop:           o.Crop,
+		Enlarge:        o.Enlarge,
+		Embed:          o.Embed,
+		Force:          o.Force,
+		NoAutoRotate:   o.NoAutoRotate,
+		NoProfile:      o.NoProfile,
+		Interlace:      o.Interlace,
+		StripMetadata:  o.StripMetadata,
+		Lossless:       o.Lossless,
+		Extend:         o.Extend,
+		Background:     o.Background,
+		Gravity:        o.Gravity,
+		Type:           o.Type,
+		Interpolator:   o.Interpolator,
+		Interpretation: o.Interpretation,
+		OutputICC:      o.OutputICC,
+		InputICC:       o.InputICC,
+		Palette:        o.Palette,
+		Speed:          o.Speed,
+		BitDepth:       o.BitDepth,
+		KeepMetadata:   o.KeepMetadata,
+		JPEG:           o.JPEG,
+		PNG:            o.PNG,
+		WebP:           o.WebP,
+		TIFF:           o.TIFF,
+		GIF:            o.GIF,
+		C2PA:           o.C2PA,
+		Load:           o.Load,
+		output:         o.output,
+		trace:          o.trace,
+	}
+	return reflect.DeepEqual(o, sequential)
+}
+
 func loadImage(buf []byte) (*C.VipsImage, ImageType, error) {
+	return loadImageOptions(buf, LoadOptions{})
+}
+
+func loadImageOptions(buf []byte, o LoadOptions) (*C.VipsImage, ImageType, error) {
 	if len(buf) == 0 {
-		return nil, JPEG, errors.New("Image buffer is empty")
+		return nil, JPEG, wrapError(ErrTruncatedImage, "Image buffer is empty")
+	}
+
+	limits := o.Limits.withDefaults(GetLimits())
+	if err := limits.checkInput(buf); err != nil {
+		return nil, JPEG, err
+	}
+
+	region := o.Region
+	if region != (Region{}) && vipsImageType(buf) == JPEG {
+		// Check the whole image, as the cropped one is decoded from it
+		header, _, err := vipsReadOptions(buf, o)
+		if err != nil {
+			return nil, JPEG, err
+		}
+		err = limits.checkImage(header)
+		C.g_object_unref(C.gpointer(header))
+		if err != nil {
+			return nil, JPEG, err
+		}
+		buf, region = jpegRegion(buf, region)
 	}
 
-	image, imageType, err := vipsRead(buf)
+	image, imageType, err := vipsReadOptions(buf, o)
 	if err != nil {
 		return nil, JPEG, err
 	}
 
+	// libvips loaders only read the header until pixels are requested
+	if err := limits.checkImage(image); err != nil {
+		C.g_object_unref(C.gpointer(image))
+		return nil, JPEG, err
+	}
+
+	if region != (Region{}) && (region.Left != 0 || region.Top != 0 ||
+		region.Width != int(image.Xsize) || region.Height != int(image.Ysize)) {
+		image, err = vipsExtract(image, region.Left, region.Top, region.Width, region.Height)
+		if err != nil {
+			return nil, JPEG, err
+		}
+	}
+
 	return image, imageType, nil
 }
 
@@ -179,6 +483,18 @@ func applyDefaults(o Options, imageType ImageType) Options {
 	}
 	if o.Type == 0 {
 		o.Type = imageType
+		// Camera RAW images are developed to JPEG
+		if o.Type == RAW {
+			o.Type = JPEG
+		}
+		// Transparency requires an output format with alpha support
+		if requiresAlpha(o) && o.Type == JPEG {
+			o.Type = PNG
+		}
+		// Tone mapped images are no longer high dynamic range
+		if o.ToneMap.Operator != ToneMapNone && (o.Type == EXR || o.Type == HDR) {
+			o.Type = PNG
+		}
 	}
 	if o.Interpretation == 0 {
 		o.Interpretation = InterpretationSRGB
@@ -190,6 +506,16 @@ func applyDefaults(o Options, imageType ImageType) Options {
 	return o
 }
 
+func requiresAlpha(o Options) bool {
+	return o.CornerRadius > 0 || o.Circle || o.AddAlpha || len(o.AlphaMask) > 0 || isTranslucentBorder(o.Border) ||
+		o.canvas.width > 0 && o.canvas.background != nil && o.canvas.background.RGBA().A < 255
+}
+
+func isTranslucentBorder(b Border) bool {
+	return (b.Top > 0 || b.Right > 0 || b.Bottom > 0 || b.Left > 0) &&
+		b.Extend == ExtendBackground && b.Background != nil && b.Background.RGBA().A < 255
+}
+
 func saveImage(image *C.VipsImage, o Options) ([]byte, error) {
 	saveOptions := vipsSaveOptions{
 		Quality:        o.Quality,
@@ -204,9 +530,21 @@ func saveImage(image *C.VipsImage, o Options) ([]byte, error) {
 		Lossless:       o.Lossless,
 		Palette:        o.Palette,
 		Speed:          o.Speed,
+		BitDepth:       o.BitDepth,
+		KeepMetadata:   o.KeepMetadata,
+		JPEG:           o.JPEG,
+		PNG:            o.PNG,
+		WebP:           o.WebP,
+		TIFF:           o.TIFF,
+		GIF:            o.GIF,
+		Output:         o.output,
 	}
 	// Finally get the resultant buffer
-	return vipsSave(image, saveOptions)
+	buf, err := vipsSave(image, saveOptions)
+	if err == nil {
+		o.trace.mark("encode", nil)
+	}
+	return buf, err
 }
 
 func normalizeOperation(o *Options, inWidth, inHeight int) {
@@ -222,11 +560,25 @@ func shouldTransformImage(o Options, inWidth, inHeight int) bool {
 }
 
 func shouldApplyEffects(o Options) bool {
-	return o.GaussianBlur.Sigma > 0 || o.GaussianBlur.MinAmpl > 0 || o.Sharpen.Radius > 0 && o.Sharpen.Y2 > 0 || o.Sharpen.Y3 > 0
+	return o.GaussianBlur.Sigma > 0 || o.GaussianBlur.MinAmpl > 0 || o.Sharpen.Radius > 0 && o.Sharpen.Y2 > 0 || o.Sharpen.Y3 > 0 ||
+		o.UnsharpMask.Radius > 0 && o.UnsharpMask.Amount > 0
 }
 
 func transformImage(image *C.VipsImage, o Options, shrink int, residual float64) (*C.VipsImage, error) {
 	var err error
+
+	// Premultiply the alpha channel before resampling, otherwise the colour
+	// of fully transparent pixels bleeds into the edges as dark fringes
+	resample := shrink > 1 || o.Force || residual != 0
+	premultiplied := resample && vipsHasAlpha(image)
+	format := image.BandFmt
+	if premultiplied {
+		image, err = vipsPremultiply(image)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Use vips_shrink with the integral reduction
 	if shrink > 1 {
 		image, residual, err = shrinkImage(image, o, residual, shrink)
@@ -252,6 +604,13 @@ func transformImage(image *C.VipsImage, o Options, shrink int, residual float64)
 		}
 	}
 
+	if premultiplied {
+		image, err = vipsUnpremultiply(image, format)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if o.Force {
 		o.Crop = false
 		o.Embed = false
@@ -265,11 +624,31 @@ func transformImage(image *C.VipsImage, o Options, shrink int, residual float64)
 	return image, nil
 }
 
+// coverImage scales the image to the smallest size covering the Width and
+// Height box, then crops the overflow, so the output matches the box.
+func coverImage(image *C.VipsImage, o Options, inWidth, inHeight, shrink int, residual float64) (*C.VipsImage, error) {
+	scale := math.Max(float64(o.Width)/float64(inWidth), float64(o.Height)/float64(inHeight))
+
+	resize := o
+	resize.Width = int(math.Max(float64(o.Width), math.Round(float64(inWidth)*scale)))
+	resize.Height = int(math.Max(float64(o.Height), math.Round(float64(inHeight)*scale)))
+	resize.Force, resize.Crop, resize.SmartCrop, resize.Gravity = true, false, false, GravityCentre
+	resize.Trim, resize.Top, resize.Left, resize.AreaWidth, resize.AreaHeight = false, 0, 0, 0, 0
+	image, err := transformImage(image, resize, shrink, residual)
+	if err != nil {
+		return nil, err
+	}
+
+	crop := o
+	crop.Trim, crop.Top, crop.Left, crop.AreaWidth, crop.AreaHeight = false, 0, 0, 0, 0
+	return extractOrEmbedImage(image, crop)
+}
+
 func applyEffects(image *C.VipsImage, o Options) (*C.VipsImage, error) {
 	var err error
 
 	if o.GaussianBlur.Sigma > 0 || o.GaussianBlur.MinAmpl > 0 {
-		image, err = vipsGaussianBlur(image, o.GaussianBlur)
+		image, err = applyGaussianBlur(image, o.GaussianBlur)
 		if err != nil {
 			return nil, err
 		}
@@ -282,6 +661,13 @@ func applyEffects(image *C.VipsImage, o Options) (*C.VipsImage, error) {
 		}
 	}
 
+	if o.UnsharpMask.Radius > 0 && o.UnsharpMask.Amount > 0 {
+		image, err = vipsUnsharpMask(image, o.UnsharpMask)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return image, nil
 }
 
@@ -316,10 +702,7 @@ func extractOrEmbedImage(image *C.VipsImage, o Options) (*C.VipsImage, error) {
 		image, err = vipsEmbed(image, left, top, o.Width, o.Height, o.Extend, o.Background)
 		break
 	case o.Trim:
-		left, top, width, height, err := vipsTrim(image, o.Background, o.Threshold)
-		if err == nil {
-			image, err = vipsExtract(image, left, top, width, height)
-		}
+		image, err = trimImage(image, o)
 		break
 	case o.Top != 0 || o.Left != 0 || o.AreaWidth != 0 || o.AreaHeight != 0:
 		if o.AreaWidth == 0 {
@@ -341,6 +724,7 @@ func extractOrEmbedImage(image *C.VipsImage, o Options) (*C.VipsImage, error) {
 func rotateAndFlipImage(image *C.VipsImage, o Options) (*C.VipsImage, bool, error) {
 	var err error
 	var rotated bool
+	var oriented bool
 
 	if o.NoAutoRotate == false {
 		rotation, flip := calculateRotationAndFlip(image, o.Rotate)
@@ -350,6 +734,7 @@ func rotateAndFlipImage(image *C.VipsImage, o Options) (*C.VipsImage, bool, erro
 		if rotation > 0 && o.Rotate == 0 {
 			o.Rotate = rotation
 		}
+		oriented = flip || rotation > 0
 	}
 
 	if o.Rotate > 0 {
@@ -366,9 +751,54 @@ func rotateAndFlipImage(image *C.VipsImage, o Options) (*C.VipsImage, bool, erro
 		rotated = true
 		image, err = vipsFlip(image, Vertical)
 	}
+
+	// Reset the EXIF orientation once applied to avoid double rotations
+	if oriented && err == nil {
+		image, err = vipsResetOrientation(image)
+	}
 	return image, rotated, err
 }
 
+// transformLossless applies the rotation, flip and area extraction of
+// rotateAndFlipImage and extractOrEmbedImage to a JPEG image buffer,
+// without decoding it.
+func transformLossless(buf []byte, image *C.VipsImage, o Options) ([]byte, bool, error) {
+	angle := getAngle(o.Rotate)
+	flip := o.Flip
+	oriented := false
+
+	if o.NoAutoRotate == false {
+		rotation, exifFlip := calculateRotationAndFlip(image, o.Rotate)
+		if rotation > 0 && o.Rotate == 0 {
+			angle = rotation
+		}
+		flip = flip || exifFlip
+		oriented = exifFlip || rotation > 0
+	}
+
+	m := angleOrientation(angle)
+	if flip {
+		m = m.then(orientationMirrorX)
+	}
+	if o.Flop {
+		m = m.then(orientationMirrorY)
+	}
+
+	t := m.transform()
+	t.Left = int(math.Max(float64(o.Left), 0))
+	t.Top = int(math.Max(float64(o.Top), 0))
+	t.Width = o.AreaWidth
+	t.Height = o.AreaHeight
+	t.CopyMarkers = !o.StripMetadata
+	t.Progressive = o.Interlace
+
+	out, ok, err := losslessJPEG(buf, t)
+	if ok && oriented && t.CopyMarkers {
+		resetJPEGOrientation(out)
+	}
+	return out, ok, err
+}
+
 func watermarkImageWithText(image *C.VipsImage, w Watermark) (*C.VipsImage, error) {
 	if w.Text == "" {
 		return image, nil
@@ -406,11 +836,35 @@ func watermarkImageWithAnotherImage(image *C.VipsImage, w WatermarkImage) (*C.Vi
 		return image, nil
 	}
 
+	if w.WidthPercent < 0 || w.WidthPercent > 100 || w.HeightPercent < 0 || w.HeightPercent > 100 {
+		C.g_object_unref(C.gpointer(image))
+		return nil, fmt.Errorf("Invalid watermark size percentage: %gx%g", w.WidthPercent, w.HeightPercent)
+	}
+
 	if w.Opacity == 0.0 {
 		w.Opacity = 1.0
 	}
 
-	image, err := vipsDrawWatermark(image, w)
+	watermark, _, err := vipsRead(w.Buf)
+	if err != nil {
+		C.g_object_unref(C.gpointer(image))
+		return nil, err
+	}
+
+	scale := watermarkScale(int(image.Xsize), int(image.Ysize), int(watermark.Xsize), int(watermark.Ysize), w)
+	if scale != 1 {
+		watermark, err = vipsResize(watermark, scale, scale)
+		if err != nil {
+			C.g_object_unref(C.gpointer(image))
+			return nil, err
+		}
+	}
+
+	if w.AlphaBlend {
+		return vipsComposite(image, watermark, w.Left, w.Top, float64(w.Opacity))
+	}
+
+	image, err = vipsDrawWatermarkImage(image, watermark, w)
 
 	if err != nil {
 		return nil, err
@@ -419,6 +873,24 @@ func watermarkImageWithAnotherImage(image *C.VipsImage, w WatermarkImage) (*C.Vi
 	return image, nil
 }
 
+// watermarkScale returns the scale of the watermark fitting the size
+// percentages of the image, or 1 when none is given.
+func watermarkScale(imageWidth, imageHeight, width, height int, w WatermarkImage) float64 {
+	scale := 0.0
+	if w.WidthPercent > 0 {
+		scale = float64(imageWidth) * w.WidthPercent / 100 / float64(width)
+	}
+	if w.HeightPercent > 0 {
+		if s := float64(imageHeight) * w.HeightPercent / 100 / float64(height); scale == 0 || s < scale {
+			scale = s
+		}
+	}
+	if scale == 0 {
+		return 1
+	}
+	return scale
+}
+
 func imageFlatten(image *C.VipsImage, imageType ImageType, o Options) (*C.VipsImage, error) {
 	if o.Background == ColorBlack {
 		return image, nil
@@ -464,7 +936,7 @@ func shrinkImage(image *C.VipsImage, o Options, residual float64, shrink int) (*
 	return image, residual, nil
 }
 
-func shrinkOnLoad(buf []byte, input *C.VipsImage, imageType ImageType, factor float64, shrink int) (*C.VipsImage, float64, error) {
+func shrinkOnLoad(buf []byte, input *C.VipsImage, imageType ImageType, factor float64, shrink int, o LoadOptions) (*C.VipsImage, float64, error) {
 	var (
 		image *C.VipsImage
 		err   error
@@ -491,9 +963,9 @@ func shrinkOnLoad(buf []byte, input *C.VipsImage, imageType ImageType, factor fl
 	// Reload input using shrink-on-load
 	switch imageType {
 	case JPEG:
-		image, err = vipsShrinkJpeg(buf, input, shrinkOnLoad)
+		image, err = vipsShrinkJpeg(buf, input, shrinkOnLoad, o)
 	case WEBP:
-		image, err = vipsShrinkWebp(buf, input, shrinkOnLoad)
+		image, err = vipsShrinkWebp(buf, input, shrinkOnLoad, o)
 	default:
 		return nil, 0, fmt.Errorf("%v doesn't support shrink on load", ImageTypeName(imageType))
 	}
@@ -501,6 +973,60 @@ func shrinkOnLoad(buf []byte, input *C.VipsImage, imageType ImageType, factor fl
 	return image, factor, err
 }
 
+// levelOnLoad reloads the smallest HEIF thumbnail or pyramidal TIFF level
+// which is still larger than the output size, returning the input image
+// when there is none. The returned factor is relative to the new image.
+func levelOnLoad(buf []byte, input *C.VipsImage, imageType ImageType, factor float64, o LoadOptions) (*C.VipsImage, float64) {
+	width := math.Floor(float64(input.Xsize) / factor)
+	height := math.Floor(float64(input.Ysize) / factor)
+	ratio := float64(input.Xsize) / float64(input.Ysize)
+
+	fits := func(level *C.VipsImage) bool {
+		// Levels must keep the aspect ratio, discarding multi page images
+		levelRatio := float64(level.Xsize) / float64(level.Ysize)
+		return float64(level.Xsize) >= width && float64(level.Ysize) >= height &&
+			level.Xsize < input.Xsize && math.Abs(levelRatio-ratio) < ratio*0.01
+	}
+
+	var image *C.VipsImage
+	switch imageType {
+	case HEIF, AVIF:
+		thumbnail, err := vipsLoadHeifThumbnail(buf, o)
+		if err != nil {
+			return input, factor
+		}
+		if !fits(thumbnail) {
+			C.g_object_unref(C.gpointer(thumbnail))
+			return input, factor
+		}
+		image = thumbnail
+	case TIFF:
+		pages := vipsNPages(input)
+		for page := 1; page < pages; page++ {
+			level, err := vipsLoadTiffPage(buf, page, o)
+			if err != nil {
+				break
+			}
+			if !fits(level) || (image != nil && level.Xsize >= image.Xsize) {
+				C.g_object_unref(C.gpointer(level))
+				break
+			}
+			if image != nil {
+				C.g_object_unref(C.gpointer(image))
+			}
+			image = level
+		}
+	}
+
+	if image == nil {
+		return input, factor
+	}
+
+	factor = factor * float64(image.Xsize) / float64(input.Xsize)
+	C.g_object_unref(C.gpointer(input))
+	return image, factor
+}
+
 func imageCalculations(o *Options, inWidth, inHeight int) float64 {
 	factor := 1.0
 	xfactor := float64(inWidth) / float64(o.Width)
@@ -569,42 +1095,20 @@ func calculateCrop(inWidth, inHeight, outWidth, outHeight int, gravity Gravity)
 	return left, top
 }
 
-func calculateRotationAndFlip(image *C.VipsImage, angle Angle) (Angle, bool) {
-	rotate := D0
-	flip := false
+// aspectCropSize returns the size of the largest area with the given aspect
+// ratio fitting in the image.
+func aspectCropSize(inWidth, inHeight, ratioW, ratioH int) (int, int) {
+	if inWidth*ratioH > inHeight*ratioW {
+		return int(math.Max(float64(inHeight*ratioW/ratioH), 1)), inHeight
+	}
+	return inWidth, int(math.Max(float64(inWidth*ratioH/ratioW), 1))
+}
 
+func calculateRotationAndFlip(image *C.VipsImage, angle Angle) (Angle, bool) {
 	if angle > 0 {
-		return rotate, flip
+		return D0, false
 	}
-
-	switch vipsExifOrientation(image) {
-	case 6:
-		rotate = D90
-		break
-	case 3:
-		rotate = D180
-		break
-	case 8:
-		rotate = D270
-		break
-	case 2:
-		flip = true
-		break // flip 1
-	case 7:
-		flip = true
-		rotate = D270
-		break // flip 6
-	case 4:
-		flip = true
-		rotate = D180
-		break // flip 3
-	case 5:
-		flip = true
-		rotate = D90
-		break // flip 8
-	}
-
-	return rotate, flip
+	return vipsExifOrientation(image).TransformSteps()
 }
 
 func calculateShrink(factor float64, i Interpolator) int {
@@ -655,3 +1159,309 @@ func applyContrast(image *C.VipsImage, o Options) (*C.VipsImage, error) {
 	}
 	return image, nil
 }
+
+func applyInvert(image *C.VipsImage, o Options) (*C.VipsImage, error) {
+	var err error
+	if o.Invert {
+		image, err = vipsInvert(image, !o.InvertAlpha)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return image, nil
+}
+
+func applyLUT(image *C.VipsImage, lut []byte) (*C.VipsImage, error) {
+	if len(lut) == 0 {
+		return image, nil
+	}
+	if len(lut) != 256 {
+		C.g_object_unref(C.gpointer(image))
+		return nil, fmt.Errorf("Invalid LUT size: %d, expected 256 entries", len(lut))
+	}
+	return vipsMapLUT(image, lut)
+}
+
+func applyBinarize(image *C.VipsImage, o Options) (*C.VipsImage, error) {
+	var err error
+	b := o.Binarize
+	if b.Adaptive {
+		if b.Radius <= 0 {
+			b.Radius = 15
+		}
+		image, err = vipsAdaptiveThreshold(image, b.Radius, b.Offset)
+	} else if b.Enabled {
+		image, err = vipsThreshold(image, b.Threshold)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+func applyDenoise(image *C.VipsImage, d Denoise) (*C.VipsImage, error) {
+	if d.Size == 0 && d.Passes == 0 && d.Sigma == 0 {
+		return image, nil
+	}
+
+	// Median window must be odd
+	if d.Size < 3 {
+		d.Size = 3
+	} else if d.Size%2 == 0 {
+		d.Size++
+	}
+	if d.Passes < 1 {
+		d.Passes = 1
+	}
+
+	var err error
+	for pass := 0; pass < d.Passes; pass++ {
+		image, err = vipsMedian(image, d.Size)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if d.Sigma > 0 {
+		image, err = vipsGaussianBlur(image, GaussianBlur{Sigma: d.Sigma})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return image, nil
+}
+
+func applyEdgeDetection(image *C.VipsImage, e EdgeDetection) (*C.VipsImage, error) {
+	switch e.Detector {
+	case EdgeSobel:
+		return vipsSobel(image)
+	case EdgeCanny:
+		if e.Sigma <= 0 {
+			e.Sigma = 1.4
+		}
+		return vipsCanny(image, e.Sigma)
+	}
+	return image, nil
+}
+
+func applyPixelate(image *C.VipsImage, p Pixelate, xscale, yscale float64) (*C.VipsImage, error) {
+	if p.BlockSize <= 1 {
+		return image, nil
+	}
+
+	r := scaleRegion(p.Region, xscale, yscale)
+	if r == (Region{}) {
+		r = Region{Width: int(image.Xsize), Height: int(image.Ysize)}
+	}
+	r, ok := clampRegion(r, int(image.Xsize), int(image.Ysize))
+	if !ok {
+		return image, nil
+	}
+	blockSize := int(math.Max(math.Round(float64(p.BlockSize)*xscale), 2))
+	return vipsPixelate(image, r, blockSize)
+}
+
+// maxMotionBlurDistance is the largest motion blur distance, whose line
+// kernel is convolved with every pixel.
+const maxMotionBlurDistance = 256
+
+// applyMotionBlur blurs the image shrunk on load by the given factor. The
+// distance is checked against the source image diagonal, then scaled.
+func applyMotionBlur(image *C.VipsImage, m MotionBlur, scale float64) (*C.VipsImage, error) {
+	diagonal := math.Hypot(float64(image.Xsize), float64(image.Ysize)) / scale
+	if m.Distance > maxMotionBlurDistance || float64(m.Distance) > diagonal {
+		C.g_object_unref(C.gpointer(image))
+		return nil, fmt.Errorf("Invalid motion blur distance: %d", m.Distance)
+	}
+	m.Distance = int(math.Round(float64(m.Distance) * scale))
+	if m.Distance <= 1 {
+		return image, nil
+	}
+	return vipsMotionBlur(image, m)
+}
+
+// applyRadialBlur blurs the image shrunk on load by the given factors,
+// scaling the center accordingly.
+func applyRadialBlur(image *C.VipsImage, r RadialBlur, xscale, yscale float64) (*C.VipsImage, error) {
+	if r.Amount > 1 {
+		r.Amount = 1
+	}
+	r.Center = Point{X: int(math.Round(float64(r.Center.X) * xscale)), Y: int(math.Round(float64(r.Center.Y) * yscale))}
+	return vipsRadialBlur(image, r)
+}
+
+func applyGaussianBlur(image *C.VipsImage, b GaussianBlur) (*C.VipsImage, error) {
+	if len(b.Mask) > 0 {
+		return vipsGaussianBlurMask(image, b)
+	}
+	if b.Region == (Region{}) {
+		return vipsGaussianBlur(image, b)
+	}
+	return applyGaussianBlurRegion(image, b, 1, 1)
+}
+
+// applyGaussianBlurRegion blurs the region of the source image, scaling it
+// and the blur sigma by the given factors.
+func applyGaussianBlurRegion(image *C.VipsImage, b GaussianBlur, xscale, yscale float64) (*C.VipsImage, error) {
+	if b.Sigma <= 0 && b.MinAmpl <= 0 {
+		return image, nil
+	}
+	r, ok := clampRegion(scaleRegion(b.Region, xscale, yscale), int(image.Xsize), int(image.Ysize))
+	if !ok {
+		return image, nil
+	}
+	b.Sigma *= xscale
+	return vipsGaussianBlurRegion(image, b, r)
+}
+
+// scaleRegion scales the region by the given factors, covering every
+// pixel it overlaps.
+func scaleRegion(r Region, xscale, yscale float64) Region {
+	if r == (Region{}) || (xscale == 1 && yscale == 1) {
+		return r
+	}
+	left, top := math.Floor(float64(r.Left)*xscale), math.Floor(float64(r.Top)*yscale)
+	right := math.Ceil(float64(r.Left+r.Width) * xscale)
+	bottom := math.Ceil(float64(r.Top+r.Height) * yscale)
+	return Region{Left: int(left), Top: int(top), Width: int(right - left), Height: int(bottom - top)}
+}
+
+// clampRegion restricts the region to the image bounds, and reports whether
+// some area is left.
+func clampRegion(r Region, width, height int) (Region, bool) {
+	left, top := int(math.Max(float64(r.Left), 0)), int(math.Max(float64(r.Top), 0))
+	right := int(math.Min(float64(r.Left+r.Width), float64(width)))
+	bottom := int(math.Min(float64(r.Top+r.Height), float64(height)))
+	if right <= left || bottom <= top {
+		return Region{}, false
+	}
+	return Region{Left: left, Top: top, Width: right - left, Height: bottom - top}, true
+}
+
+func applyVignette(image *C.VipsImage, v Vignette) (*C.VipsImage, error) {
+	if v.Strength <= 0 {
+		return image, nil
+	}
+	if v.Strength > 1 {
+		v.Strength = 1
+	}
+	if v.Radius < 0 || v.Radius >= 1 {
+		v.Radius = 0
+	}
+	return vipsVignette(image, v)
+}
+
+func applyGrain(image *C.VipsImage, g GrainOptions) (*C.VipsImage, error) {
+	if g.Amount <= 0 {
+		return image, nil
+	}
+	if g.Size < 1 {
+		g.Size = 1
+	}
+	return vipsGrain(image, g)
+}
+
+func applyBorder(image *C.VipsImage, b Border) (*C.VipsImage, error) {
+	if b.Top < 0 || b.Right < 0 || b.Bottom < 0 || b.Left < 0 {
+		C.g_object_unref(C.gpointer(image))
+		return nil, errors.New("Border widths cannot be negative")
+	}
+	if b.Top == 0 && b.Right == 0 && b.Bottom == 0 && b.Left == 0 {
+		return image, nil
+	}
+	if b.Background == nil {
+		b.Background = ColorBlack
+	}
+	return vipsBorder(image, b)
+}
+
+// canvasImage crops the sides larger than the canvas and pads the smaller
+// ones, anchoring the image by the canvas gravity.
+func canvasImage(image *C.VipsImage, c canvas) (*C.VipsImage, error) {
+	var err error
+	inWidth, inHeight := int(image.Xsize), int(image.Ysize)
+	left, top := calculateCrop(inWidth, inHeight, c.width, c.height, c.gravity)
+
+	width := int(math.Min(float64(inWidth), float64(c.width)))
+	height := int(math.Min(float64(inHeight), float64(c.height)))
+	if width < inWidth || height < inHeight {
+		image, err = vipsExtract(image, int(math.Max(float64(left), 0)), int(math.Max(float64(top), 0)), width, height)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	border := Border{
+		Top:        int(math.Max(float64(-top), 0)),
+		Left:       int(math.Max(float64(-left), 0)),
+		Background: c.background,
+		Extend:     ExtendBackground,
+	}
+	border.Right = c.width - width - border.Left
+	border.Bottom = c.height - height - border.Top
+	return applyBorder(image, border)
+}
+
+func applyCornerMask(image *C.VipsImage, o Options) (*C.VipsImage, error) {
+	if o.Circle {
+		inWidth, inHeight := int(image.Xsize), int(image.Ysize)
+		side := int(math.Min(float64(inWidth), float64(inHeight)))
+		if inWidth != inHeight {
+			left, top := calculateCrop(inWidth, inHeight, side, side, GravityCentre)
+			var err error
+			image, err = vipsExtract(image, left, top, side, side)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return vipsRoundCorners(image, float64(side)/2)
+	}
+	if o.CornerRadius > 0 {
+		return vipsRoundCorners(image, float64(o.CornerRadius))
+	}
+	return image, nil
+}
+
+func applyAlpha(image *C.VipsImage, o Options) (*C.VipsImage, error) {
+	var err error
+	if o.AddAlpha {
+		image, err = vipsAddAlpha(image)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(o.AlphaMask) > 0 {
+		image, err = vipsApplyAlphaMask(image, o.AlphaMask)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if o.ExtractAlpha {
+		image, err = vipsExtractAlpha(image)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return image, nil
+}
+
+func applyPremultiply(image *C.VipsImage, o Options) (*C.VipsImage, error) {
+	if !vipsHasAlpha(image) {
+		return image, nil
+	}
+
+	var err error
+	format := image.BandFmt
+	if o.Premultiply {
+		image, err = vipsPremultiply(image)
+		if err != nil {
+			return nil, err
+		}
+		// Keep the original pixel format for saving
+		return vipsCast(image, format)
+	}
+	if o.Unpremultiply {
+		return vipsUnpremultiply(image, format)
+	}
+	return image, nil
+}
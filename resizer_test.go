@@ -242,6 +242,106 @@ func TestCorruptedImage(t *testing.T) {
 	Write("testdata/test_corrupt_out.jpg", newImg)
 }
 
+func TestCorruptedImageFailOn(t *testing.T) {
+	buf, _ := Read("testdata/corrupt.jpg")
+
+	options := Options{Width: 800, Height: 600, Load: LoadOptions{FailOn: FailOnWarning}}
+	if _, err := Resize(buf, options); err == nil {
+		t.Fatal("Corrupted image should fail with FailOnWarning")
+	}
+
+	options.Load.FailOn = FailOnNone
+	if _, err := Resize(buf, options); err != nil {
+		t.Errorf("Resize(imgData, %#v) error: %#v", options, err)
+	}
+}
+
+func TestIsSequentialOperation(t *testing.T) {
+	cases := []struct {
+		options    Options
+		sequential bool
+	}{
+		{Options{Width: 300}, true},
+		{Options{Width: 300, Height: 200, Crop: true, Type: WEBP, Quality: 80}, true},
+		{Options{Width: 300, Rotate: D90}, false},
+		{Options{Width: 300, Crop: true, Gravity: GravitySmart}, false},
+		{Options{Width: 300, Trim: true}, false},
+		{Options{Width: 300, Flip: true}, false},
+		// Processor, ResizeBuffer and traced options
+		{Options{Width: 300, output: &Buffer{}}, true},
+		{Options{Width: 300, trace: &traceRun{}}, true},
+	}
+
+	for _, c := range cases {
+		if isSequentialOperation(c.options) != c.sequential {
+			t.Errorf("Invalid sequential operation for %#v", c.options)
+		}
+	}
+}
+
+func TestResizeSequentialAccess(t *testing.T) {
+	buf, _ := Read("testdata/test.jpg")
+	newImg, err := Resize(buf, Options{Width: 300, Load: LoadOptions{Access: AccessSequential}})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if size, _ := Size(newImg); size.Width != 300 {
+		t.Errorf("Invalid image width: %d", size.Width)
+	}
+
+	// EXIF oriented images fallback to random access
+	buf, _ = Read("testdata/exif/Landscape_6.jpg")
+	newImg, err = Resize(buf, Options{Width: 800})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if size, _ := Size(newImg); size.Width != 800 || size.Height != 600 {
+		t.Errorf("Invalid image size: %dx%d", size.Width, size.Height)
+	}
+}
+
+func TestResizeNoShrinkOnLoad(t *testing.T) {
+	for _, file := range []string{"test.jpg", "test.webp"} {
+		newImg, err := Resize(readFile(file), Options{Width: 200, Load: LoadOptions{NoShrinkOnLoad: true}})
+		if err != nil {
+			t.Fatalf("Cannot process the image: %#v", err)
+		}
+		if size, _ := Size(newImg); size.Width != 200 {
+			t.Errorf("Invalid image width: %d", size.Width)
+		}
+	}
+}
+
+func TestResizePyramidTiff(t *testing.T) {
+	buf, _ := Read("testdata/test.jpg")
+	tiff, err := Resize(buf, Options{Type: TIFF, TIFF: TIFFOptions{Pyramid: true}})
+	if err != nil {
+		t.Fatalf("Cannot save the pyramid: %#v", err)
+	}
+
+	newImg, err := Resize(tiff, Options{Width: 200, Type: JPEG})
+	if err != nil {
+		t.Fatalf("Resize(imgData, %#v) error: %#v", Options{Width: 200}, err)
+	}
+	if size, _ := Size(newImg); size.Width != 200 {
+		t.Errorf("Invalid image width: %d", size.Width)
+	}
+}
+
+func TestResizeHeifThumbnail(t *testing.T) {
+	if !IsTypeSupported(HEIF) {
+		t.Skip("HEIF is not supported")
+	}
+	buf, _ := Read("testdata/test.heic")
+	newImg, err := Resize(buf, Options{Width: 100, Type: JPEG})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if size, _ := Size(newImg); size.Width != 100 {
+		t.Errorf("Invalid image width: %d", size.Width)
+	}
+}
+
 func TestNoColorProfile(t *testing.T) {
 	options := Options{Width: 800, Height: 600, NoProfile: true}
 	buf, _ := Read("testdata/test.jpg")
@@ -296,6 +396,46 @@ func TestEmbedExtendWithCustomColor(t *testing.T) {
 	Write("testdata/test_extend_background_out.jpg", newImg)
 }
 
+func TestResizeLUT(t *testing.T) {
+	lut := make([]byte, 256)
+	for v := range lut {
+		lut[v] = byte(255 - v)
+	}
+	buf, err := Resize(readFile("test.jpg"), Options{Width: 300, LUT: lut})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if size, _ := Size(buf); size.Width != 300 {
+		t.Errorf("Invalid width: %d", size.Width)
+	}
+
+	if _, err := Resize(readFile("test.jpg"), Options{LUT: lut[:10]}); err == nil {
+		t.Error("Expected an error for an invalid LUT size")
+	}
+}
+
+func TestEmbedExtendModes(t *testing.T) {
+	modes := []Extend{ExtendBlack, ExtendCopy, ExtendRepeat, ExtendMirror, ExtendWhite, ExtendBackground}
+	for _, mode := range modes {
+		buf, err := initImage("test.jpg").Embed(400, 600, EmbedOptions{Extend: mode, Background: Color{255, 20, 10}})
+		if err != nil {
+			t.Fatalf("Cannot process the image with extend %d: %#v", mode, err)
+		}
+		if err := assertSize(buf, 400, 600); err != nil {
+			t.Errorf("Extend %d: %s", mode, err)
+		}
+	}
+
+	for _, mode := range []Extend{ExtendLast, -1} {
+		if _, err := Resize(readFile("test.jpg"), Options{Width: 400, Height: 600, Embed: true, Extend: mode}); err == nil {
+			t.Errorf("Expected an error for extend %d", mode)
+		}
+		if _, err := initImage("test.jpg").AddBorder(10, 10, 10, 10, nil, mode); err == nil {
+			t.Errorf("Expected a border error for extend %d", mode)
+		}
+	}
+}
+
 func TestGaussianBlur(t *testing.T) {
 	options := Options{Width: 800, Height: 600, GaussianBlur: GaussianBlur{Sigma: 5}}
 	buf, _ := Read("testdata/test.jpg")
@@ -864,3 +1004,73 @@ func BenchmarkWatermarkImageWebp(b *testing.B) {
 	}
 	runBenchmarkResize("test.webp", options, b)
 }
+
+func TestAspectCropSize(t *testing.T) {
+	tests := []struct {
+		inWidth, inHeight, ratioW, ratioH int
+		width, height                     int
+	}{
+		{1680, 1050, 1, 1, 1050, 1050},
+		{1680, 1050, 16, 9, 1680, 945},
+		{1680, 1050, 9, 16, 590, 1050},
+		{1680, 1050, 16, 10, 1680, 1050},
+		{10, 1000, 100, 1, 10, 1},
+	}
+
+	for _, test := range tests {
+		width, height := aspectCropSize(test.inWidth, test.inHeight, test.ratioW, test.ratioH)
+		if width != test.width || height != test.height {
+			t.Errorf("Unexpected size for %d:%d: %dx%d", test.ratioW, test.ratioH, width, height)
+		}
+	}
+}
+
+func TestResizeModeCover(t *testing.T) {
+	tests := []struct {
+		file          string
+		width, height int
+		gravity       Gravity
+	}{
+		{"test.jpg", 300, 300, GravityCentre},
+		{"test.jpg", 1080, 1080, GravityNorth},
+		{"test.jpg", 2000, 500, GravitySouth},
+		{"test.jpg", 333, 777, GravitySmart},
+		{"test.png", 1000, 1000, GravityEast},
+		{"test.webp", 123, 45, GravityWest},
+		{"exif/Portrait_6.jpg", 400, 300, GravityCentre},
+	}
+
+	for _, test := range tests {
+		o := Options{Width: test.width, Height: test.height, ResizeMode: ResizeModeCover, Gravity: test.gravity}
+		buf, err := Resize(readFile(test.file), o)
+		if err != nil {
+			t.Fatalf("Resize(%s, %#v) error: %#v", test.file, o, err)
+		}
+		if err := assertSize(buf, test.width, test.height); err != nil {
+			t.Errorf("%s %dx%d: %s", test.file, test.width, test.height, err)
+		}
+	}
+
+	if _, err := Resize(readFile("test.jpg"), Options{Width: 300, ResizeMode: ResizeModeCover}); err == nil {
+		t.Error("Expected an error without height")
+	}
+}
+
+func TestWatermarkScale(t *testing.T) {
+	cases := []struct {
+		widthPercent, heightPercent float64
+		expected                    float64
+	}{
+		{0, 0, 1},
+		{20, 0, 0.5},
+		{0, 50, 2.5},
+		{20, 50, 0.5},
+		{100, 10, 0.5},
+	}
+	for _, c := range cases {
+		w := WatermarkImage{WidthPercent: c.widthPercent, HeightPercent: c.heightPercent}
+		if scale := watermarkScale(1000, 500, 400, 100, w); scale != c.expected {
+			t.Errorf("Invalid scale for %gx%g: %g != %g", c.widthPercent, c.heightPercent, scale, c.expected)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+)
+
+// saveAll decodes the region of the image buffer once, the whole image
+// when empty, and encodes it with every given save options.
+func saveAll(buf []byte, region Region, opts []SaveOptions) ([][]byte, error) {
+	defer C.vips_thread_shutdown()
+
+	if len(opts) == 0 {
+		return nil, errors.New("No save options given")
+	}
+
+	image, imageType, err := loadImageOptions(buf, LoadOptions{Region: region})
+	if err != nil {
+		return nil, err
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	out := make([][]byte, len(opts))
+	for i, o := range opts {
+		out[i], err = encodeSaveOptions(image, imageType, buf, o)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// encodeSaveOptions encodes the image decoded from buf with the save
+// options, keeping the image reference for further encodings.
+func encodeSaveOptions(image *C.VipsImage, imageType ImageType, buf []byte, o SaveOptions) ([]byte, error) {
+	if o.AutoFormat && o.Type == 0 {
+		o.Type = ChooseType(o.Accept, vipsHasAlpha(image), false)
+	}
+	o = applySaveDefaults(o, imageType)
+	if !IsTypeSupportedSave(o.Type) {
+		return nil, wrapError(ErrUnsupportedFormat, "Unsupported image output type: "+ImageTypeName(o.Type))
+	}
+
+	var out []byte
+	var err error
+	if o.TargetSize > 0 {
+		out, err = encodeTargetSize(image, o)
+	} else {
+		out, err = encodeImage(image, o, o.Quality)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return applyC2PA(out, buf, o)
+}
+
+// encodeImage encodes the image with the given quality, keeping the
+// image reference for further encodings.
+func encodeImage(image *C.VipsImage, o SaveOptions, quality int) ([]byte, error) {
+	// vipsSave releases the image
+	C.g_object_ref(C.gpointer(image))
+
+	saveOptions := o.vipsSaveOptions()
+	saveOptions.Quality = quality
+	return vipsSave(image, saveOptions)
+}
+
+// encodeTargetSize binary searches the highest quality whose output
+// fits in the target size.
+func encodeTargetSize(image *C.VipsImage, o SaveOptions) ([]byte, error) {
+	buf, err := encodeImage(image, o, o.Quality)
+	if err != nil || len(buf) <= o.TargetSize {
+		return buf, err
+	}
+	if !o.usesQuality() {
+		return nil, fmt.Errorf("Cannot fit the %s image in %d bytes", ImageTypeName(o.Type), o.TargetSize)
+	}
+
+	var best []byte
+	low, high := 1, o.Quality-1
+	for low <= high {
+		quality := (low + high) / 2
+		buf, err := encodeImage(image, o, quality)
+		if err != nil {
+			return nil, err
+		}
+		if len(buf) <= o.TargetSize {
+			best = buf
+			low = quality + 1
+		} else {
+			high = quality - 1
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("Cannot fit the %s image in %d bytes", ImageTypeName(o.Type), o.TargetSize)
+	}
+	return best, nil
+}
+
+func applySaveDefaults(o SaveOptions, imageType ImageType) SaveOptions {
+	if o.Quality == 0 {
+		o.Quality = Quality
+	}
+	if o.Compression == 0 {
+		o.Compression = 6
+	}
+	if o.Type == 0 {
+		o.Type = imageType
+		if o.Type == RAW {
+			o.Type = JPEG
+		}
+	}
+	return o
+}
+
+// usesQuality reports whether the output size depends on the quality.
+func (o SaveOptions) usesQuality() bool {
+	switch o.Type {
+	case JPEG, WEBP, HEIF, AVIF:
+		return !o.Lossless
+	case PNG:
+		return o.Palette
+	}
+	return false
+}
+
+func (o SaveOptions) vipsSaveOptions() vipsSaveOptions {
+	return vipsSaveOptions{
+		Quality:        o.Quality,
+		Type:           o.Type,
+		Compression:    o.Compression,
+		Interlace:      o.Interlace,
+		StripMetadata:  o.StripMetadata,
+		Lossless:       o.Lossless,
+		Palette:        o.Palette,
+		Speed:          o.Speed,
+		BitDepth:       o.BitDepth,
+		KeepMetadata:   o.KeepMetadata,
+		Interpretation: o.Interpretation,
+		JPEG:           o.JPEG,
+		PNG:            o.PNG,
+		WebP:           o.WebP,
+		TIFF:           o.TIFF,
+		GIF:            o.GIF,
+	}
+}
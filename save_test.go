@@ -0,0 +1,117 @@
+package bimg
+
+import "testing"
+
+func TestSaveAll(t *testing.T) {
+	opts := []SaveOptions{
+		{},
+		{Type: PNG},
+		{Type: WEBP, Quality: 60},
+	}
+
+	bufs, err := saveAll(readFile("test.jpg"), Region{}, opts)
+	if err != nil {
+		t.Fatalf("Cannot save the image: %#v", err)
+	}
+	if len(bufs) != len(opts) {
+		t.Fatalf("Invalid number of images: %d", len(bufs))
+	}
+
+	expected := []ImageType{JPEG, PNG, WEBP}
+	for i, buf := range bufs {
+		if kind := DetermineImageType(buf); kind != expected[i] {
+			t.Errorf("Invalid image type: %s != %s", ImageTypeName(kind), ImageTypeName(expected[i]))
+		}
+		if err := assertSize(buf, 1680, 1050); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestSaveAllNoOptions(t *testing.T) {
+	if _, err := saveAll(readFile("test.jpg"), Region{}, nil); err == nil {
+		t.Fatal("Expected error")
+	}
+}
+
+func TestApplySaveDefaults(t *testing.T) {
+	o := applySaveDefaults(SaveOptions{}, PNG)
+	if o.Quality != Quality || o.Compression != 6 || o.Type != PNG {
+		t.Errorf("Invalid defaults: %#v", o)
+	}
+
+	o = applySaveDefaults(SaveOptions{Type: WEBP, Quality: 50}, PNG)
+	if o.Quality != 50 || o.Type != WEBP {
+		t.Errorf("Invalid defaults: %#v", o)
+	}
+}
+
+func TestSaveAllTargetSize(t *testing.T) {
+	full, err := saveAll(readFile("test.jpg"), Region{}, []SaveOptions{{Quality: 95}})
+	if err != nil {
+		t.Fatalf("Cannot save the image: %#v", err)
+	}
+
+	target := len(full[0]) / 3
+	bufs, err := saveAll(readFile("test.jpg"), Region{}, []SaveOptions{{Quality: 95, TargetSize: target}})
+	if err != nil {
+		t.Fatalf("Cannot save the image: %#v", err)
+	}
+	if len(bufs[0]) > target {
+		t.Errorf("Image exceeds the target size: %d > %d", len(bufs[0]), target)
+	}
+	if DetermineImageType(bufs[0]) != JPEG {
+		t.Error("Image is not jpeg")
+	}
+
+	_, err = saveAll(readFile("test.jpg"), Region{}, []SaveOptions{{TargetSize: 100}})
+	if err == nil {
+		t.Error("Expected error for an unreachable target size")
+	}
+
+	_, err = saveAll(readFile("test.jpg"), Region{}, []SaveOptions{{Type: PNG, TargetSize: 1000}})
+	if err == nil {
+		t.Error("Expected error for a lossless format")
+	}
+}
+
+func TestSaveOptionsUsesQuality(t *testing.T) {
+	tt := []struct {
+		options  SaveOptions
+		expected bool
+	}{
+		{SaveOptions{Type: JPEG}, true},
+		{SaveOptions{Type: WEBP}, true},
+		{SaveOptions{Type: WEBP, Lossless: true}, false},
+		{SaveOptions{Type: PNG}, false},
+		{SaveOptions{Type: PNG, Palette: true}, true},
+		{SaveOptions{Type: TIFF}, false},
+	}
+
+	for _, tc := range tt {
+		if got := tc.options.usesQuality(); got != tc.expected {
+			t.Errorf("%s: expected: %t; got: %t", ImageTypeName(tc.options.Type), tc.expected, got)
+		}
+	}
+}
+
+func TestSaveAllAutoFormat(t *testing.T) {
+	if !IsTypeSupportedSave(WEBP) {
+		t.Skipf("Format %#v is not supported", ImageTypes[WEBP])
+	}
+
+	opts := []SaveOptions{
+		{AutoFormat: true, Accept: "image/webp,image/*,*/*;q=0.8"},
+		{AutoFormat: true, Accept: "*/*"},
+	}
+	bufs, err := saveAll(readFile("transparent.png"), Region{}, opts)
+	if err != nil {
+		t.Fatalf("Cannot save the image: %#v", err)
+	}
+	if kind := DetermineImageType(bufs[0]); kind != WEBP {
+		t.Errorf("Invalid image type: %s", ImageTypeName(kind))
+	}
+	if kind := DetermineImageType(bufs[1]); kind != PNG {
+		t.Errorf("Invalid image type: %s", ImageTypeName(kind))
+	}
+}
@@ -0,0 +1,213 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"math"
+)
+
+// maxSeamCarvePixels is the largest image seam carved, as the carving
+// time grows with both the pixels and the removed seams.
+const maxSeamCarvePixels = 4000000
+
+// seamCarve reduces the image to the given size by repeatedly removing its
+// lowest energy seam, the one pixel wide path crossing the image through
+// its least detailed areas.
+func seamCarve(img *Image, width, height int) ([]byte, error) {
+	defer C.vips_thread_shutdown()
+
+	image, imageType, err := loadOrientedImage(img)
+	if err != nil {
+		return nil, err
+	}
+	inWidth, inHeight := int(image.Xsize), int(image.Ysize)
+	if width <= 0 || height <= 0 || width > inWidth || height > inHeight {
+		C.g_object_unref(C.gpointer(image))
+		return nil, fmt.Errorf("Seam carving can only reduce the %dx%d image, not to %dx%d", inWidth, inHeight, width, height)
+	}
+	if inWidth*inHeight > maxSeamCarvePixels {
+		C.g_object_unref(C.gpointer(image))
+		return nil, wrapError(ErrDimensionsTooLarge, fmt.Sprintf("Seam carving is limited to %d pixels", maxSeamCarvePixels))
+	}
+	alpha := vipsHasAlpha(image)
+
+	pixels, inWidth, inHeight, err := vipsRGBA(image, int(math.Max(float64(inWidth), float64(inHeight))))
+	if err != nil {
+		return nil, err
+	}
+
+	c := newCarver(pixels, inWidth, inHeight)
+	for c.width > width {
+		c.removeSeam()
+	}
+	if c.height > height {
+		c.transpose()
+		for c.width > height {
+			c.removeSeam()
+		}
+		c.transpose()
+	}
+
+	image, err = vipsImageFromRGBA(c.pix, c.width, c.height, alpha)
+	if err != nil {
+		return nil, err
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	save := applySaveDefaults(SaveOptions{}, imageType)
+	if !IsTypeSupportedSave(save.Type) {
+		return nil, wrapError(ErrUnsupportedFormat, "Unsupported image output type: "+ImageTypeName(save.Type))
+	}
+	return encodeImage(image, save, save.Quality)
+}
+
+// carver holds the RGBA pixels being carved, their luminance and their
+// energy.
+type carver struct {
+	pix           []byte
+	lum           []int
+	energy        []int
+	width, height int
+}
+
+func newCarver(pix []byte, width, height int) *carver {
+	c := &carver{pix: pix, lum: make([]int, width*height), energy: make([]int, width*height), width: width, height: height}
+	for i := range c.lum {
+		p := pix[i*4 : i*4+3]
+		c.lum[i] = (299*int(p[0]) + 587*int(p[1]) + 114*int(p[2])) / 1000
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c.energy[y*width+x] = c.pixelEnergy(x, y)
+		}
+	}
+	return c
+}
+
+// pixelEnergy returns the gradient magnitude of the pixel.
+func (c *carver) pixelEnergy(x, y int) int {
+	w, h := c.width, c.height
+	up, down := (y-1)*w, (y+1)*w
+	if y == 0 {
+		up = 0
+	}
+	if y == h-1 {
+		down = y * w
+	}
+	left, right := x-1, x+1
+	if x == 0 {
+		left = 0
+	}
+	if x == w-1 {
+		right = x
+	}
+	dx := c.lum[y*w+right] - c.lum[y*w+left]
+	dy := c.lum[down+x] - c.lum[up+x]
+	return abs(dx) + abs(dy)
+}
+
+// removeSeam removes the lowest energy vertical seam.
+func (c *carver) removeSeam() {
+	w, h := c.width, c.height
+	cost := make([]int, len(c.energy))
+	copy(cost, c.energy)
+	for y := 1; y < h; y++ {
+		for x := 0; x < w; x++ {
+			best := cost[(y-1)*w+x]
+			if x > 0 && cost[(y-1)*w+x-1] < best {
+				best = cost[(y-1)*w+x-1]
+			}
+			if x < w-1 && cost[(y-1)*w+x+1] < best {
+				best = cost[(y-1)*w+x+1]
+			}
+			cost[y*w+x] += best
+		}
+	}
+
+	// Backtrack the seam from its cheapest bottom pixel
+	seam := make([]int, h)
+	for x := 1; x < w; x++ {
+		if cost[(h-1)*w+x] < cost[(h-1)*w+seam[h-1]] {
+			seam[h-1] = x
+		}
+	}
+	for y := h - 2; y >= 0; y-- {
+		x := seam[y+1]
+		seam[y] = x
+		if x > 0 && cost[y*w+x-1] < cost[y*w+seam[y]] {
+			seam[y] = x - 1
+		}
+		if x < w-1 && cost[y*w+x+1] < cost[y*w+seam[y]] {
+			seam[y] = x + 1
+		}
+	}
+
+	// Shift the pixels on the right of the seam, row by row
+	pix, lum, energy := c.pix[:0], c.lum[:0], c.energy[:0]
+	for y := 0; y < h; y++ {
+		row := y * w
+		pix = append(pix, c.pix[row*4:(row+seam[y])*4]...)
+		pix = append(pix, c.pix[(row+seam[y]+1)*4:(row+w)*4]...)
+		lum = append(lum, c.lum[row:row+seam[y]]...)
+		lum = append(lum, c.lum[row+seam[y]+1:row+w]...)
+		energy = append(energy, c.energy[row:row+seam[y]]...)
+		energy = append(energy, c.energy[row+seam[y]+1:row+w]...)
+	}
+	c.pix, c.lum, c.energy, c.width = pix, lum, energy, w-1
+
+	// Only the pixels around the seam got new neighbours. Seams move by a
+	// pixel at most between rows, so they span the seam of the adjacent rows.
+	for y := 0; y < h; y++ {
+		from, to := seam[y], seam[y]
+		for _, n := range []int{y - 1, y + 1} {
+			if n < 0 || n >= h {
+				continue
+			}
+			if seam[n] < from {
+				from = seam[n]
+			}
+			if seam[n] > to {
+				to = seam[n]
+			}
+		}
+		if from--; from < 0 {
+			from = 0
+		}
+		if to >= c.width {
+			to = c.width - 1
+		}
+		for x := from; x <= to; x++ {
+			c.energy[y*c.width+x] = c.pixelEnergy(x, y)
+		}
+	}
+}
+
+// transpose swaps the rows and columns, so horizontal seams can be removed
+// as vertical ones.
+func (c *carver) transpose() {
+	w, h := c.width, c.height
+	pix := make([]byte, len(c.pix))
+	lum := make([]int, len(c.lum))
+	// The gradient magnitude is unchanged by the transpose
+	energy := make([]int, len(c.energy))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			copy(pix[(x*h+y)*4:(x*h+y)*4+4], c.pix[(y*w+x)*4:(y*w+x)*4+4])
+			lum[x*h+y] = c.lum[y*w+x]
+			energy[x*h+y] = c.energy[y*w+x]
+		}
+	}
+	c.pix, c.lum, c.energy, c.width, c.height = pix, lum, energy, h, w
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,102 @@
+package bimg
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestCarverRemoveSeam(t *testing.T) {
+	// The flat third column has the lowest energy on every row
+	values := []byte{0, 100, 100, 100, 200}
+	var pix []byte
+	for y := 0; y < 3; y++ {
+		for _, v := range values {
+			pix = append(pix, v, v, v, 255)
+		}
+	}
+
+	c := newCarver(pix, 5, 3)
+	c.removeSeam()
+	if c.width != 4 || c.height != 3 || len(c.pix) != 4*3*4 || len(c.lum) != 4*3 {
+		t.Fatalf("Unexpected carved size: %dx%d", c.width, c.height)
+	}
+	for y := 0; y < 3; y++ {
+		row := c.pix[y*16 : y*16+16]
+		expected := []byte{0, 0, 0, 255, 100, 100, 100, 255, 100, 100, 100, 255, 200, 200, 200, 255}
+		if !bytes.Equal(row, expected) {
+			t.Errorf("Unexpected row %d: %v", y, row)
+		}
+	}
+}
+
+func TestCarverEnergy(t *testing.T) {
+	// The energy updated along the removed seams matches the recomputed one
+	pix := make([]byte, 16*12*4)
+	for i := range pix {
+		pix[i] = byte(i * 7919 % 251)
+	}
+	c := newCarver(pix, 16, 12)
+	for c.width > 8 {
+		c.removeSeam()
+		expected := newCarver(append([]byte(nil), c.pix...), c.width, c.height)
+		for i := range c.energy {
+			if c.energy[i] != expected.energy[i] {
+				t.Fatalf("Invalid energy of the pixel %d at width %d: %d != %d", i, c.width, c.energy[i], expected.energy[i])
+			}
+		}
+	}
+}
+
+func TestCarverTranspose(t *testing.T) {
+	pix := []byte{
+		1, 1, 1, 255, 2, 2, 2, 255, 3, 3, 3, 255,
+		4, 4, 4, 255, 5, 5, 5, 255, 6, 6, 6, 255,
+	}
+	c := newCarver(append([]byte(nil), pix...), 3, 2)
+	c.transpose()
+	if c.width != 2 || c.height != 3 || c.pix[4] != 4 || c.pix[8] != 2 || c.lum[1] != 4 {
+		t.Fatalf("Unexpected transposed pixels: %v", c.pix)
+	}
+	c.transpose()
+	if c.width != 3 || c.height != 2 || !bytes.Equal(c.pix, pix) {
+		t.Fatalf("Unexpected pixels: %v", c.pix)
+	}
+}
+
+func TestImageSeamCarve(t *testing.T) {
+	tests := []struct {
+		file          string
+		width, height int
+	}{
+		{"test.png", 300, 300},
+		{"test.png", 400, 200},
+		{"test.jpg", 1500, 1050},
+	}
+
+	for _, test := range tests {
+		img := initImage(test.file)
+		buf, err := img.SeamCarve(test.width, test.height)
+		if err != nil {
+			t.Fatalf("Cannot process the image: %#v", err)
+		}
+		if err := assertSize(buf, test.width, test.height); err != nil {
+			t.Errorf("%s: %s", test.file, err)
+		}
+		if DetermineImageType(buf) != DetermineImageType(readFile(test.file)) {
+			t.Errorf("Unexpected image type: %s", DetermineImageTypeName(buf))
+		}
+	}
+
+	if _, err := initImage("test.png").SeamCarve(500, 300); err == nil {
+		t.Error("Expected an error when enlarging")
+	}
+
+	buf, err := Resize(readFile("test.jpg"), Options{Width: 3000, Enlarge: true})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if _, err := NewImage(buf).SeamCarve(100, 100); !errors.Is(err, ErrDimensionsTooLarge) {
+		t.Errorf("Expected an error for a large image: %#v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SrcSetOptions represents the GenerateSrcSet options.
+type SrcSetOptions struct {
+	// Save defines the encoding options of every variant. Its Type is
+	// replaced by each of the requested formats.
+	Save SaveOptions
+	// Enlarge generates the widths larger than the image, which are
+	// skipped by default.
+	Enlarge bool
+	// URL returns the URL of a variant in the srcset attributes. Defaults
+	// to "<width>.<extension>", e.g. "640.webp" or "640.jpg".
+	URL func(v SrcSetVariant) string
+}
+
+// SrcSetVariant represents an encoded image of a responsive image set.
+type SrcSetVariant struct {
+	Width  int
+	Height int
+	Type   ImageType
+	Buffer []byte
+}
+
+// SrcSet represents a responsive image set.
+type SrcSet struct {
+	// Variants holds the encoded images by increasing width, then in the
+	// requested formats order.
+	Variants []SrcSetVariant
+	// SrcSet is the srcset attribute of the first format.
+	SrcSet string
+	// Sources holds the srcset attribute of every format, for the source
+	// elements of a picture element.
+	Sources map[ImageType]string
+}
+
+// GenerateSrcSet encodes the image at the given widths and formats, keeping
+// the aspect ratio. The image is decoded once, shrunk on load to the
+// largest width, and every width is resized from the decoded pixels, so the
+// cost of N variants is far below N independent resizes. Formats default
+// to the image type.
+func GenerateSrcSet(img *Image, widths []int, formats []ImageType, o SrcSetOptions) (*SrcSet, error) {
+	defer C.vips_thread_shutdown()
+
+	if len(widths) == 0 {
+		return nil, errors.New("No srcset widths given")
+	}
+	buf, region := img.source()
+	if len(formats) == 0 {
+		formats = []ImageType{applySaveDefaults(SaveOptions{}, vipsImageType(buf)).Type}
+	}
+	for _, t := range formats {
+		if !IsTypeSupportedSave(t) {
+			return nil, wrapError(ErrUnsupportedFormat, "Unsupported image output type: "+ImageTypeName(t))
+		}
+	}
+
+	url := o.URL
+	if url == nil {
+		url = func(v SrcSetVariant) string {
+			return outputPath(strconv.Itoa(v.Width), v.Type)
+		}
+	}
+
+	// Every width is encoded in every format, resized once
+	var types []ImageType
+	images, err := resizeAll(buf, region, func(width, height int) ([]ResizeOptions, error) {
+		sizes := srcSetWidths(widths, width, o.Enlarge)
+		if len(sizes) == 0 {
+			return nil, fmt.Errorf("Invalid srcset widths: %v", widths)
+		}
+		var options []ResizeOptions
+		for _, w := range sizes {
+			for _, t := range formats {
+				save := o.Save
+				save.Type = t
+				options = append(options, ResizeOptions{Width: w, Save: save, enlarge: o.Enlarge})
+				types = append(types, t)
+			}
+		}
+		return options, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	set := &SrcSet{Sources: make(map[ImageType]string)}
+	candidates := make(map[ImageType][]string)
+	for i, image := range images {
+		v := SrcSetVariant{Width: image.width, Height: image.height, Type: types[i], Buffer: image.buf}
+		candidates[v.Type] = append(candidates[v.Type], fmt.Sprintf("%s %dw", url(v), v.Width))
+		set.Variants = append(set.Variants, v)
+	}
+
+	for t, c := range candidates {
+		set.Sources[t] = strings.Join(c, ", ")
+	}
+	set.SrcSet = set.Sources[formats[0]]
+	return set, nil
+}
+
+// srcSetWidths returns the sorted unique positive widths, capped to the
+// image width unless enlarging.
+func srcSetWidths(widths []int, imageWidth int, enlarge bool) []int {
+	seen := make(map[int]bool)
+	var sizes []int
+	for _, w := range widths {
+		if w <= 0 || seen[w] {
+			continue
+		}
+		if w > imageWidth && !enlarge {
+			w = imageWidth
+			if seen[w] {
+				continue
+			}
+		}
+		seen[w] = true
+		sizes = append(sizes, w)
+	}
+	sort.Ints(sizes)
+	return sizes
+}
@@ -0,0 +1,93 @@
+package bimg
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestGenerateSrcSet(t *testing.T) {
+	set, err := GenerateSrcSet(initImage("test.jpg"), []int{640, 320, 3000}, []ImageType{WEBP, JPEG}, SrcSetOptions{})
+	if err != nil {
+		t.Fatalf("Cannot generate the srcset: %#v", err)
+	}
+	if len(set.Variants) != 6 {
+		t.Fatalf("Invalid number of variants: %d", len(set.Variants))
+	}
+
+	for _, v := range set.Variants {
+		if DetermineImageType(v.Buffer) != v.Type {
+			t.Errorf("Invalid variant type: %s", ImageTypeName(DetermineImageType(v.Buffer)))
+		}
+		size, err := NewImage(v.Buffer).Size()
+		if err != nil || size.Width != v.Width || size.Height != v.Height {
+			t.Errorf("Invalid variant size: %#v != %dx%d", size, v.Width, v.Height)
+		}
+	}
+	if set.Variants[0].Width != 320 || set.Variants[4].Width != 1680 {
+		t.Errorf("Invalid variant widths: %d, %d", set.Variants[0].Width, set.Variants[4].Width)
+	}
+
+	if set.SrcSet != "320.webp 320w, 640.webp 640w, 1680.webp 1680w" {
+		t.Errorf("Invalid srcset: %s", set.SrcSet)
+	}
+	if set.Sources[JPEG] != "320.jpg 320w, 640.jpg 640w, 1680.jpg 1680w" {
+		t.Errorf("Invalid jpeg srcset: %s", set.Sources[JPEG])
+	}
+}
+
+func TestGenerateSrcSetMultiResize(t *testing.T) {
+	// Variants match the equivalent MultiResize outputs, shrunk on load
+	set, err := GenerateSrcSet(initImage("test.jpg"), []int{200}, nil, SrcSetOptions{})
+	if err != nil {
+		t.Fatalf("Cannot generate the srcset: %#v", err)
+	}
+	out, err := initImage("test.jpg").MultiResize([]ResizeOptions{{Width: 200}})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if !bytes.Equal(set.Variants[0].Buffer, out[0]) {
+		t.Error("The srcset variant differs from the MultiResize output")
+	}
+
+	SetLimits(Limits{MaxWidth: 2000})
+	defer SetLimits(Limits{})
+	_, err = GenerateSrcSet(initImage("test.jpg"), []int{3000}, nil, SrcSetOptions{Enlarge: true})
+	if !errors.Is(err, ErrDimensionsTooLarge) {
+		t.Errorf("Expected a dimensions error: %v", err)
+	}
+}
+
+func TestGenerateSrcSetURL(t *testing.T) {
+	set, err := GenerateSrcSet(initImage("test.png"), []int{100}, nil, SrcSetOptions{
+		URL: func(v SrcSetVariant) string { return "/img/photo-" + ImageTypeName(v.Type) },
+	})
+	if err != nil {
+		t.Fatalf("Cannot generate the srcset: %#v", err)
+	}
+	if set.SrcSet != "/img/photo-png 100w" || set.Variants[0].Type != PNG {
+		t.Errorf("Invalid srcset: %s", set.SrcSet)
+	}
+
+	if _, err := GenerateSrcSet(initImage("test.png"), nil, nil, SrcSetOptions{}); err == nil {
+		t.Error("Missing widths should fail")
+	}
+}
+
+func TestSrcSetWidths(t *testing.T) {
+	cases := []struct {
+		widths   []int
+		enlarge  bool
+		expected []int
+	}{
+		{[]int{800, 200, 400, 200}, false, []int{200, 400, 800}},
+		{[]int{2000, 1200, 1000, -1}, false, []int{1000}},
+		{[]int{2000, 500}, true, []int{500, 2000}},
+	}
+	for _, c := range cases {
+		if sizes := srcSetWidths(c.widths, 1000, c.enlarge); !reflect.DeepEqual(sizes, c.expected) {
+			t.Errorf("Invalid widths for %v: %v", c.widths, sizes)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// maxTemplateFontSize is the largest font size of the template layers, in
+// points or pixels.
+const maxTemplateFontSize = 1000
+
+// Template describes an image composed of layers drawn in order over a
+// background color, e.g. an Open Graph card. It can be decoded from JSON.
+//
+//	{
+//	  "width": 1200, "height": 630, "background": "#1e293b",
+//	  "layers": [
+//	    {"image": "photo", "cover": true},
+//	    {"text": "{{.title}}", "font": "sans bold 64", "color": "#ffffff", "margin": 80},
+//	    {"image": "logo", "gravity": "southeast", "margin": 40}
+//	  ]
+//	}
+type Template struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// Background defines the canvas color as a "#rrggbb" or "#rrggbbaa"
+	// hex string. Defaults to transparent.
+	Background string `json:"background"`
+	// Type defines the output image type name, e.g. "jpeg". Defaults to
+	// PNG.
+	Type string `json:"type"`
+	// Quality defines the output quality. Defaults to 75.
+	Quality int             `json:"quality"`
+	Layers  []TemplateLayer `json:"layers"`
+}
+
+// TemplateLayer describes an image or text layer of a Template. The layer
+// is placed in its box by Gravity. The box is defined by Left, Top, Width
+// and Height, defaults to the rest of the canvas, and is inset by Margin.
+type TemplateLayer struct {
+	// Image names the RenderTemplate data entry of the layer image, an
+	// *Image or a []byte buffer. Images larger than the box are shrunk to
+	// fit it, keeping their aspect ratio.
+	Image string `json:"image"`
+	// Cover scales the image to cover the whole box, cropped by Gravity.
+	Cover bool `json:"cover"`
+	// Text defines the layer text, wrapped to the box width. It is a Go
+	// html/template executed with the RenderTemplate data, whose values are
+	// escaped so they cannot inject Pango markup.
+	Text string `json:"text"`
+	// Font defines the Pango font description. Defaults to "sans 12". The
+	// font size is limited to 1000.
+	Font string `json:"font"`
+	// Color defines the text color as a hex string. Defaults to black.
+	Color string `json:"color"`
+	// Align defines the alignment of the text lines: "left", "centre" or
+	// "right". Defaults to "left".
+	Align string `json:"align"`
+	// Gravity defines the layer position in the box: "north", "south",
+	// "east", "west", "northeast", "northwest", "southeast", "southwest"
+	// or "centre". Defaults to "northwest".
+	Gravity string `json:"gravity"`
+	Left    int    `json:"left"`
+	Top     int    `json:"top"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	Margin  int    `json:"margin"`
+}
+
+// RenderTemplate renders the template layers, taking the images and the
+// text values from the data.
+func RenderTemplate(tpl Template, data map[string]interface{}) (*Image, error) {
+	defer C.vips_thread_shutdown()
+
+	if err := checkGeneratedSize(tpl.Width, tpl.Height); err != nil {
+		return nil, err
+	}
+	background, err := parseHexColor(tpl.Background)
+	if err != nil {
+		return nil, err
+	}
+	imageType := PNG
+	if tpl.Type != "" {
+		imageType = ParseImageType(tpl.Type)
+	}
+	save := applySaveDefaults(SaveOptions{Type: imageType, Quality: tpl.Quality}, PNG)
+	if !IsTypeSupportedSave(save.Type) {
+		return nil, wrapError(ErrUnsupportedFormat, "Unsupported image output type: "+tpl.Type)
+	}
+
+	image, err := vipsGradient(tpl.Width, tpl.Height, background, background, 0)
+	if err != nil {
+		return nil, err
+	}
+	for index, layer := range tpl.Layers {
+		image, err = renderLayer(image, layer, data)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot render template layer %d: %s", index, err)
+		}
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	buf, err := encodeImage(image, save, save.Quality)
+	if err != nil {
+		return nil, err
+	}
+	return NewImage(buf), nil
+}
+
+// renderLayer draws the layer over the canvas, releasing it.
+func renderLayer(canvas *C.VipsImage, layer TemplateLayer, data map[string]interface{}) (*C.VipsImage, error) {
+	box := Region{Left: layer.Left, Top: layer.Top, Width: layer.Width, Height: layer.Height}
+	if box.Width == 0 {
+		box.Width = int(canvas.Xsize) - box.Left
+	}
+	if box.Height == 0 {
+		box.Height = int(canvas.Ysize) - box.Top
+	}
+	box = Region{
+		Left:   box.Left + layer.Margin,
+		Top:    box.Top + layer.Margin,
+		Width:  box.Width - 2*layer.Margin,
+		Height: box.Height - 2*layer.Margin,
+	}
+	gx, gy, err := templateGravity(layer.Gravity)
+	if err == nil && (box.Width <= 0 || box.Height <= 0) {
+		err = errors.New("Empty layer box")
+	}
+	if err != nil {
+		C.g_object_unref(C.gpointer(canvas))
+		return nil, err
+	}
+
+	var overlay *C.VipsImage
+	switch {
+	case layer.Image != "" && layer.Text != "":
+		err = errors.New("A layer cannot have both an image and a text")
+	case layer.Image != "":
+		overlay, err = templateImage(layer, data, box, gx, gy)
+	case layer.Text != "":
+		overlay, err = templateText(layer, data, box)
+	default:
+		err = errors.New("A layer needs an image or a text")
+	}
+	if err != nil {
+		C.g_object_unref(C.gpointer(canvas))
+		return nil, err
+	}
+
+	left := box.Left + int(math.Round(float64(box.Width-int(overlay.Xsize))*gx))
+	top := box.Top + int(math.Round(float64(box.Height-int(overlay.Ysize))*gy))
+	return vipsComposite(canvas, overlay, left, top, 1)
+}
+
+// templateImage loads the layer image from the data, fitted in the box or
+// covering it.
+func templateImage(layer TemplateLayer, data map[string]interface{}, box Region, gx, gy float64) (*C.VipsImage, error) {
+	var img *Image
+	switch value := data[layer.Image].(type) {
+	case *Image:
+		img = value
+	case []byte:
+		img = NewImage(value)
+	default:
+		return nil, fmt.Errorf("Missing template image: %s", layer.Image)
+	}
+
+	image, _, err := loadOrientedImage(img)
+	if err != nil {
+		return nil, err
+	}
+	width, height := float64(image.Xsize), float64(image.Ysize)
+	fit := math.Min(float64(box.Width)/width, float64(box.Height)/height)
+
+	if !layer.Cover {
+		if fit < 1 {
+			return vipsResize(image, fit, fit)
+		}
+		return image, nil
+	}
+
+	scale := math.Max(float64(box.Width)/width, float64(box.Height)/height)
+	image, err = vipsResize(image, scale, scale)
+	if err != nil {
+		return nil, err
+	}
+	width = math.Min(float64(image.Xsize), float64(box.Width))
+	height = math.Min(float64(image.Ysize), float64(box.Height))
+	left := int(math.Round((float64(image.Xsize) - width) * gx))
+	top := int(math.Round((float64(image.Ysize) - height) * gy))
+	return vipsExtract(image, left, top, int(width), int(height))
+}
+
+// templateText renders the layer text template, wrapped to the box width.
+func templateText(layer TemplateLayer, data map[string]interface{}, box Region) (*C.VipsImage, error) {
+	tmpl, err := template.New("text").Option("missingkey=error").Parse(layer.Text)
+	if err != nil {
+		return nil, err
+	}
+	var text strings.Builder
+	if err := tmpl.Execute(&text, data); err != nil {
+		return nil, err
+	}
+
+	color, err := parseHexColor(layer.Color)
+	if err != nil {
+		return nil, err
+	}
+	if layer.Color == "" {
+		color.A = 255
+	}
+
+	if size := fontSize(layer.Font); size > maxTemplateFontSize {
+		return nil, fmt.Errorf("Font size %g exceeds the limit of %d", size, maxTemplateFontSize)
+	}
+
+	o := TextOptions{Font: layer.Font, DPI: 72, Width: box.Width, Color: color}
+	switch layer.Align {
+	case "", "left":
+		o.Align = AlignLow
+	case "centre", "center":
+		o.Align = AlignCentre
+	case "right":
+		o.Align = AlignHigh
+	default:
+		return nil, fmt.Errorf("Invalid text alignment: %s", layer.Align)
+	}
+	return textImage(text.String(), o)
+}
+
+// fontSize returns the size of the Pango font description, e.g. 64 for
+// "sans bold 64" or "sans 64px", or zero when undefined.
+func fontSize(font string) float64 {
+	fields := strings.Fields(font)
+	if len(fields) == 0 {
+		return 0
+	}
+	size, err := strconv.ParseFloat(strings.TrimSuffix(fields[len(fields)-1], "px"), 64)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// templateGravity returns the horizontal and vertical position of a layer
+// in its box, from 0 (left or top) to 1 (right or bottom).
+func templateGravity(name string) (float64, float64, error) {
+	switch strings.ToLower(name) {
+	case "", "northwest":
+		return 0, 0, nil
+	case "north":
+		return 0.5, 0, nil
+	case "northeast":
+		return 1, 0, nil
+	case "west":
+		return 0, 0.5, nil
+	case "centre", "center":
+		return 0.5, 0.5, nil
+	case "east":
+		return 1, 0.5, nil
+	case "southwest":
+		return 0, 1, nil
+	case "south":
+		return 0.5, 1, nil
+	case "southeast":
+		return 1, 1, nil
+	}
+	return 0, 0, fmt.Errorf("Invalid layer gravity: %s", name)
+}
+
+// parseHexColor parses a "#rrggbb" or "#rrggbbaa" color. An empty string
+// is transparent.
+func parseHexColor(s string) (RGBA, error) {
+	if s == "" {
+		return RGBA{}, nil
+	}
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 8 {
+		return RGBA{}, fmt.Errorf("Invalid hex color: %s", s)
+	}
+	return RGBA{R: uint8(value >> 24), G: uint8(value >> 16), B: uint8(value >> 8), A: uint8(value)}, nil
+}
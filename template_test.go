@@ -0,0 +1,94 @@
+package bimg
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const testTemplate = `{
+	"width": 1200, "height": 630, "background": "#1e293b", "type": "jpeg",
+	"layers": [
+		{"image": "photo", "cover": true, "height": 400},
+		{"text": "{{.title}}", "font": "sans bold 48", "color": "#ffffff", "top": 400, "margin": 40},
+		{"image": "logo", "gravity": "southeast", "margin": 20}
+	]
+}`
+
+func TestRenderTemplate(t *testing.T) {
+	var tpl Template
+	if err := json.Unmarshal([]byte(testTemplate), &tpl); err != nil {
+		t.Fatalf("Cannot decode the template: %s", err)
+	}
+
+	img, err := RenderTemplate(tpl, map[string]interface{}{
+		"photo": readFile("test.jpg"),
+		"logo":  initImage("transparent.png"),
+		"title": "Server-side Open Graph images",
+	})
+	if err != nil {
+		t.Fatalf("Cannot render the template: %#v", err)
+	}
+	if err := assertSize(img.Image(), 1200, 630); err != nil {
+		t.Fatal(err)
+	}
+	if img.Type() != "jpeg" {
+		t.Errorf("Invalid image type: %s", img.Type())
+	}
+	Write("testdata/test_template_out.jpg", img.Image())
+}
+
+func TestRenderTemplateEscaping(t *testing.T) {
+	tpl := Template{Width: 400, Height: 200, Layers: []TemplateLayer{{Text: "<b>{{.title}}</b>"}}}
+	_, err := RenderTemplate(tpl, map[string]interface{}{
+		"title": `<span size="1000000">Tom & Jerry</span>`,
+	})
+	if err != nil {
+		t.Fatalf("Cannot render the template: %#v", err)
+	}
+}
+
+func TestRenderTemplateErrors(t *testing.T) {
+	tpl := Template{Width: 100, Height: 100}
+	cases := []TemplateLayer{
+		{Image: "missing"},
+		{Text: "{{.missing}}"},
+		{Text: "text", Gravity: "up"},
+		{Text: "text", Margin: 50},
+		{Text: "text", Font: "sans 5000"},
+		{Text: "text", Font: "sans bold 5000px"},
+		{},
+	}
+	for _, layer := range cases {
+		tpl.Layers = []TemplateLayer{layer}
+		if _, err := RenderTemplate(tpl, map[string]interface{}{}); err == nil {
+			t.Errorf("Expected an error for the layer %#v", layer)
+		}
+	}
+}
+
+func TestParseHexColor(t *testing.T) {
+	cases := []struct {
+		value    string
+		expected RGBA
+	}{
+		{"", RGBA{}},
+		{"#ff8000", RGBA{255, 128, 0, 255}},
+		{"#ff800080", RGBA{255, 128, 0, 128}},
+		{"00ff00", RGBA{0, 255, 0, 255}},
+	}
+	for _, c := range cases {
+		color, err := parseHexColor(c.value)
+		if err != nil {
+			t.Fatalf("Cannot parse %s: %s", c.value, err)
+		}
+		if color != c.expected {
+			t.Errorf("Invalid color for %s: %v", c.value, color)
+		}
+	}
+
+	for _, value := range []string{"#fff", "#gg0000", "#ff000000ff"} {
+		if _, err := parseHexColor(value); err == nil {
+			t.Errorf("Expected an error for %s", value)
+		}
+	}
+}
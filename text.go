@@ -0,0 +1,71 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import "errors"
+
+// maxTextDPI is the largest text resolution.
+const maxTextDPI = 2400
+
+// TextOptions represents the NewTextImage options.
+type TextOptions struct {
+	// Font defines the Pango font description, e.g. "sans bold 24".
+	// Defaults to "sans 12".
+	Font string
+	// DPI defines the text resolution, up to 2400. Defaults to 72.
+	DPI int
+	// Width defines the maximum line width in pixels. Longer lines wrap.
+	// Zero disables the wrapping.
+	Width int
+	// Color defines the text color. Defaults to opaque black.
+	Color RGBAProvider
+	// Background defines the background color. Defaults to transparent.
+	Background RGBAProvider
+	// Align defines the alignment of the lines: AlignLow on the left,
+	// AlignCentre or AlignHigh on the right.
+	Align Align
+}
+
+// NewTextImage renders the text as a PNG image fitting it exactly, e.g. for
+// social share cards and banners. The text can use Pango markup.
+func NewTextImage(text string, o TextOptions) (*Image, error) {
+	defer C.vips_thread_shutdown()
+
+	image, err := textImage(text, o)
+	if err != nil {
+		return nil, err
+	}
+	return encodeGenerated(image)
+}
+
+// textImage renders the text, applying the options defaults.
+func textImage(text string, o TextOptions) (*C.VipsImage, error) {
+	if text == "" {
+		return nil, errors.New("Text cannot be empty")
+	}
+	if o.Font == "" {
+		o.Font = "sans 12"
+	}
+	if o.DPI == 0 {
+		o.DPI = 72
+	}
+	if o.Width < 0 || o.DPI < 0 || o.DPI > maxTextDPI {
+		return nil, errors.New("Invalid text width or DPI")
+	}
+	if o.Color == nil {
+		o.Color = ColorBlack
+	}
+	color := o.Color.RGBA()
+
+	// A transparent background of the text color avoids dark fringes
+	// around the anti-aliased glyphs
+	background := RGBA{R: color.R, G: color.G, B: color.B}
+	if o.Background != nil {
+		background = o.Background.RGBA()
+	}
+	return vipsTextImage(text, o, color, background)
+}
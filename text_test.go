@@ -0,0 +1,44 @@
+package bimg
+
+import "testing"
+
+func TestNewTextImage(t *testing.T) {
+	img, err := NewTextImage("Hello world", TextOptions{Font: "sans 20", Color: Color{255, 0, 0}})
+	if err != nil {
+		t.Fatalf("Cannot render the text: %#v", err)
+	}
+	meta, err := img.Metadata()
+	if err != nil {
+		t.Fatalf("Cannot read the image metadata: %#v", err)
+	}
+	if meta.Type != "png" || !meta.Alpha {
+		t.Errorf("Expected a transparent PNG image, got %s with alpha %t", meta.Type, meta.Alpha)
+	}
+	line := meta.Size
+
+	img, err = NewTextImage("Hello world", TextOptions{
+		Font:       "sans 20",
+		Width:      line.Width / 2,
+		Background: Color{255, 255, 255},
+		Align:      AlignCentre,
+	})
+	if err != nil {
+		t.Fatalf("Cannot render the text: %#v", err)
+	}
+	meta, err = img.Metadata()
+	if err != nil {
+		t.Fatalf("Cannot read the image metadata: %#v", err)
+	}
+	if meta.Alpha {
+		t.Error("Opaque text image has an alpha channel")
+	}
+	if meta.Size.Width > line.Width/2 || meta.Size.Height <= line.Height {
+		t.Errorf("Text was not wrapped: %dx%d", meta.Size.Width, meta.Size.Height)
+	}
+}
+
+func TestNewTextImageEmpty(t *testing.T) {
+	if _, err := NewTextImage("", TextOptions{}); err == nil {
+		t.Error("Expected an error for an empty text")
+	}
+}
@@ -0,0 +1,62 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SaveMultiPageTIFF encodes the images as the pages of a single TIFF
+// document, in order, e.g. scanned or faxed documents. The pages must share
+// the same dimensions. The save options apply to every page, e.g.
+// InterpretationBW keeps scans in grayscale and the TIFF options define
+// their compression. Pyramidal TIFF cannot have several pages.
+func SaveMultiPageTIFF(pages []*Image, o SaveOptions) ([]byte, error) {
+	defer C.vips_thread_shutdown()
+
+	if len(pages) == 0 {
+		return nil, errors.New("No TIFF pages given")
+	}
+	if o.Type != UNKNOWN && o.Type != TIFF {
+		return nil, wrapError(ErrUnsupportedFormat, "Unsupported multi-page type: "+ImageTypeName(o.Type))
+	}
+	if o.TIFF.Pyramid {
+		return nil, errors.New("Pyramidal TIFF cannot have several pages")
+	}
+	o = applySaveDefaults(o, TIFF)
+	if !IsTypeSupportedSave(TIFF) {
+		return nil, wrapError(ErrUnsupportedFormat, "Unsupported image output type: "+ImageTypeName(TIFF))
+	}
+
+	images := make([]*C.VipsImage, 0, len(pages))
+	release := func() {
+		for _, image := range images {
+			C.g_object_unref(C.gpointer(image))
+		}
+	}
+	for i, page := range pages {
+		image, _, err := loadOrientedImage(page)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		images = append(images, image)
+		if image.Xsize != images[0].Xsize || image.Ysize != images[0].Ysize {
+			release()
+			return nil, fmt.Errorf("Page %d size %dx%d differs from %dx%d", i, image.Xsize, image.Ysize, images[0].Xsize, images[0].Ysize)
+		}
+	}
+
+	image, err := vipsPages(images)
+	if err != nil {
+		return nil, err
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	return encodeImage(image, o, o.Quality)
+}
@@ -0,0 +1,73 @@
+package bimg
+
+import "testing"
+
+func TestSaveMultiPageTIFF(t *testing.T) {
+	if !IsTypeSupportedSave(TIFF) {
+		t.Skip("TIFF save is not supported")
+	}
+
+	pages := animationFrames(t)
+	buf, err := SaveMultiPageTIFF(pages, SaveOptions{Interpretation: InterpretationBW})
+	if err != nil {
+		t.Fatalf("Cannot save the pages: %#v", err)
+	}
+	if DetermineImageType(buf) != TIFF {
+		t.Fatalf("Invalid image type: %s", ImageTypeName(DetermineImageType(buf)))
+	}
+	Write("testdata/test_pages_out.tiff", buf)
+
+	if err := assertSize(buf, 100, 80); err != nil {
+		t.Error(err)
+	}
+	metadata, err := NewImage(buf).Metadata()
+	if err != nil {
+		t.Fatalf("Cannot read the metadata: %#v", err)
+	}
+	if metadata.Channels != 1 {
+		t.Errorf("Invalid number of channels: %d", metadata.Channels)
+	}
+
+	if _, err := vipsLoadTiffPage(buf, 2, LoadOptions{}); err != nil {
+		t.Errorf("Cannot load the last page: %#v", err)
+	}
+	if _, err := vipsLoadTiffPage(buf, 3, LoadOptions{}); err == nil {
+		t.Error("Expected 3 pages only")
+	}
+}
+
+func TestSaveMultiPageTIFFErrors(t *testing.T) {
+	if _, err := SaveMultiPageTIFF(nil, SaveOptions{}); err == nil {
+		t.Error("Documents without pages should fail")
+	}
+	pages := append(animationFrames(t), initImage("test.png"))
+	if _, err := SaveMultiPageTIFF(pages, SaveOptions{}); err == nil {
+		t.Error("Pages of different sizes should fail")
+	}
+	if _, err := SaveMultiPageTIFF(pages[:1], SaveOptions{Type: JPEG}); err == nil {
+		t.Error("Non TIFF types should fail")
+	}
+}
+
+func TestSaveMultiPageTIFFAlpha(t *testing.T) {
+	if !IsTypeSupportedSave(TIFF) {
+		t.Skip("TIFF save is not supported")
+	}
+
+	buf, err := initImage("test.jpg").Process(Options{Width: 400, Height: 300, Force: true})
+	if err != nil {
+		t.Fatalf("Cannot resize the image: %#v", err)
+	}
+	pages := []*Image{NewImage(buf), initImage("test.png")}
+	buf, err = SaveMultiPageTIFF(pages, SaveOptions{})
+	if err != nil {
+		t.Fatalf("Cannot save the RGB and RGBA pages: %#v", err)
+	}
+	metadata, err := NewImage(buf).Metadata()
+	if err != nil {
+		t.Fatalf("Cannot read the metadata: %#v", err)
+	}
+	if metadata.Channels != 4 || !metadata.Alpha {
+		t.Errorf("Invalid number of channels: %d", metadata.Channels)
+	}
+}
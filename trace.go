@@ -0,0 +1,101 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import (
+	"sync"
+	"time"
+)
+
+// TraceSpan represents a timed step of a traced image operation.
+type TraceSpan struct {
+	// Operation is the traced Image method, e.g. "Resize" or "Process".
+	Operation string
+	// Step is the resize pipeline step: "decode", "transform", "effects"
+	// or "encode". It is empty for the span of the whole operation, which
+	// follows the spans of its steps.
+	Step     string
+	Duration time.Duration
+	// Memory is the change of the memory tracked by libvips, in bytes. It
+	// includes the concurrent operations of other images.
+	Memory int64
+}
+
+// maxTraceSpans is the number of spans kept by a trace, the oldest ones
+// being dropped.
+const maxTraceSpans = 1000
+
+// opTrace records the spans of the traced operations of an image.
+type opTrace struct {
+	mu    sync.Mutex
+	spans []TraceSpan
+}
+
+// traceRun records the steps of a traced operation.
+type traceRun struct {
+	trace          *opTrace
+	operation      string
+	begin, start   time.Time
+	initial, since int64
+}
+
+// begin starts a traced operation. It returns nil on nil traces, whose
+// runs record nothing.
+func (t *opTrace) begin(operation string) *traceRun {
+	if t == nil {
+		return nil
+	}
+	now, memory := time.Now(), int64(C.vips_tracked_get_mem())
+	return &traceRun{trace: t, operation: operation, begin: now, start: now, initial: memory, since: memory}
+}
+
+func (t *opTrace) record(span TraceSpan) {
+	t.mu.Lock()
+	if len(t.spans) == maxTraceSpans {
+		t.spans = append(t.spans[:0], t.spans[1:]...)
+	}
+	t.spans = append(t.spans, span)
+	t.mu.Unlock()
+}
+
+// mark records the step since the previous one. The image is evaluated
+// first, as libvips is lazy, so its work is attributed to the step.
+func (r *traceRun) mark(step string, image **C.VipsImage) error {
+	if r == nil {
+		return nil
+	}
+	if image != nil {
+		evaluated, err := vipsCopyMemory(*image)
+		if err != nil {
+			return err
+		}
+		*image = evaluated
+	}
+
+	now, memory := time.Now(), int64(C.vips_tracked_get_mem())
+	r.trace.record(TraceSpan{Operation: r.operation, Step: step, Duration: now.Sub(r.start), Memory: memory - r.since})
+	r.start, r.since = now, memory
+	return nil
+}
+
+// end records the span of the whole operation.
+func (r *traceRun) end() {
+	if r == nil {
+		return
+	}
+	memory := int64(C.vips_tracked_get_mem())
+	r.trace.record(TraceSpan{Operation: r.operation, Duration: time.Since(r.begin), Memory: memory - r.initial})
+}
+
+// traceOperation starts the span of a whole Image operation, recording
+// nothing when the image is not traced.
+func (i *Image) traceOperation(operation string) *traceRun {
+	i.mu.Lock()
+	trace := i.trace
+	i.mu.Unlock()
+	return trace.begin(operation)
+}
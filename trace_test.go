@@ -0,0 +1,100 @@
+package bimg
+
+import "testing"
+
+func TestImageTrace(t *testing.T) {
+	image := initImage("test.jpg")
+	image.SetTrace(true)
+
+	if _, err := image.Resize(300, 200); err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if _, err := image.Process(Options{GaussianBlur: GaussianBlur{Sigma: 2}}); err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+
+	spans := image.Trace()
+	expected := []TraceSpan{
+		{Operation: "Resize", Step: "decode"},
+		{Operation: "Resize", Step: "transform"},
+		{Operation: "Resize", Step: "effects"},
+		{Operation: "Resize", Step: "encode"},
+		{Operation: "Resize"},
+		{Operation: "Process", Step: "decode"},
+		{Operation: "Process", Step: "transform"},
+		{Operation: "Process", Step: "effects"},
+		{Operation: "Process", Step: "encode"},
+		{Operation: "Process"},
+	}
+	if len(spans) != len(expected) {
+		t.Fatalf("Invalid number of spans: %d", len(spans))
+	}
+	for i, span := range spans {
+		if span.Operation != expected[i].Operation || span.Step != expected[i].Step {
+			t.Errorf("Invalid span: %s %s", span.Operation, span.Step)
+		}
+		if span.Duration <= 0 {
+			t.Errorf("Invalid span duration: %s", span.Duration)
+		}
+	}
+	if err := assertSize(image.Image(), 300, 200); err != nil {
+		t.Error(err)
+	}
+
+	image.SetTrace(false)
+	if _, err := image.Rotate(D90); err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if spans := image.Trace(); spans != nil {
+		t.Errorf("Expected no spans, got %d", len(spans))
+	}
+}
+
+func TestImageTraceOperations(t *testing.T) {
+	image := initImage("test.jpg")
+	image.SetTrace(true)
+
+	if _, err := image.Despeckle(); err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if _, err := image.SaveAll(SaveOptions{Type: PNG}); err != nil {
+		t.Fatalf("Cannot save the image: %#v", err)
+	}
+	if _, err := image.RunPipeline(NewPipeline().Flip(), SaveOptions{}); err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+
+	// Delegating methods are traced with their own name, and the
+	// operations not based on Process with a single span
+	spans := image.Trace()
+	expected := []TraceSpan{
+		{Operation: "Despeckle", Step: "decode"},
+		{Operation: "Despeckle", Step: "transform"},
+		{Operation: "Despeckle", Step: "effects"},
+		{Operation: "Despeckle", Step: "encode"},
+		{Operation: "Despeckle"},
+		{Operation: "SaveAll"},
+		{Operation: "RunPipeline"},
+	}
+	if len(spans) != len(expected) {
+		t.Fatalf("Invalid number of spans: %d", len(spans))
+	}
+	for i, span := range spans {
+		if span.Operation != expected[i].Operation || span.Step != expected[i].Step {
+			t.Errorf("Invalid span: %s %s", span.Operation, span.Step)
+		}
+	}
+}
+
+func TestTraceSpansLimit(t *testing.T) {
+	trace := &opTrace{}
+	for i := 0; i < maxTraceSpans+10; i++ {
+		trace.record(TraceSpan{Memory: int64(i)})
+	}
+	if len(trace.spans) != maxTraceSpans {
+		t.Fatalf("Invalid number of spans: %d", len(trace.spans))
+	}
+	if trace.spans[0].Memory != 10 || trace.spans[maxTraceSpans-1].Memory != maxTraceSpans+9 {
+		t.Errorf("The oldest spans must be dropped: %d", trace.spans[0].Memory)
+	}
+}
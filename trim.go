@@ -0,0 +1,81 @@
+package bimg
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import "math"
+
+// findTrim returns the area of the image kept by Trim, in its stored
+// orientation, using the Background, Threshold, TrimLab and TrimOptions
+// options.
+func findTrim(buf []byte, o Options) (Region, error) {
+	defer C.vips_thread_shutdown()
+
+	image, _, err := loadImageOptions(buf, o.Load)
+	if err != nil {
+		return Region{}, err
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	return trimBounds(image, o)
+}
+
+// trimImage crops the background on the edges enabled by the trim options.
+func trimImage(image *C.VipsImage, o Options) (*C.VipsImage, error) {
+	r, err := trimBounds(image, o)
+	if err != nil {
+		C.g_object_unref(C.gpointer(image))
+		return nil, err
+	}
+	return vipsExtract(image, r.Left, r.Top, r.Width, r.Height)
+}
+
+// trimBounds returns the area kept once trimmed. The image is not consumed.
+func trimBounds(image *C.VipsImage, o Options) (Region, error) {
+	background := o.Background
+	if o.TrimOptions.AutoBackground {
+		var err error
+		background, err = vipsBorderBackground(image)
+		if err != nil {
+			return Region{}, err
+		}
+	}
+
+	C.g_object_ref(C.gpointer(image))
+	left, top, width, height, err := vipsTrim(image, background, o.Threshold, o.TrimLab)
+	if err != nil {
+		return Region{}, err
+	}
+
+	content := Region{Left: left, Top: top, Width: width, Height: height}
+	return trimRegion(o.TrimOptions, int(image.Xsize), int(image.Ysize), content), nil
+}
+
+// trimRegion returns the area kept once trimmed, out of the image size and
+// its content bounding box.
+func trimRegion(t TrimOptions, width, height int, content Region) Region {
+	if content.Width <= 0 || content.Height <= 0 {
+		return content
+	}
+	if !t.Top && !t.Right && !t.Bottom && !t.Left {
+		t.Top, t.Right, t.Bottom, t.Left = true, true, true, true
+	}
+
+	left, top, right, bottom := 0, 0, width, height
+	if t.Left {
+		left = int(math.Max(float64(content.Left-t.Padding), 0))
+	}
+	if t.Top {
+		top = int(math.Max(float64(content.Top-t.Padding), 0))
+	}
+	if t.Right {
+		right = int(math.Min(float64(content.Left+content.Width+t.Padding), float64(width)))
+	}
+	if t.Bottom {
+		bottom = int(math.Min(float64(content.Top+content.Height+t.Padding), float64(height)))
+	}
+	return Region{Left: left, Top: top, Width: right - left, Height: bottom - top}
+}
@@ -0,0 +1,58 @@
+package bimg
+
+import "testing"
+
+func TestImageFindTrim(t *testing.T) {
+	if !(VipsMajorVersion >= 8 && VipsMinorVersion >= 6) {
+		t.Skipf("Skipping this test, libvips doesn't meet version requirement %s >= 8.6", VipsVersion)
+	}
+
+	img := initImage("test.png")
+	o := Options{Background: Color{0.0, 0.0, 0.0}, Threshold: 10.0}
+	r, err := img.FindTrim(o)
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if r.Left != 0 || r.Width != 400 || r.Height != 257 {
+		t.Errorf("Unexpected trim area: %#v", r)
+	}
+
+	if size, err := img.Size(); err != nil || size.Width != 400 || size.Height != 300 {
+		t.Errorf("The image was modified: %#v", size)
+	}
+
+	o.Load.Region = r
+	buf, err := img.Process(o)
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if err := assertSize(buf, 400, 257); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTrimRegion(t *testing.T) {
+	content := Region{Left: 10, Top: 20, Width: 50, Height: 40}
+	tests := []struct {
+		trim     TrimOptions
+		expected Region
+	}{
+		{TrimOptions{}, content},
+		{TrimOptions{Top: true, Right: true, Bottom: true, Left: true}, content},
+		{TrimOptions{Top: true, Bottom: true}, Region{Left: 0, Top: 20, Width: 100, Height: 40}},
+		{TrimOptions{Left: true}, Region{Left: 10, Top: 0, Width: 90, Height: 80}},
+		{TrimOptions{Padding: 5}, Region{Left: 5, Top: 15, Width: 60, Height: 50}},
+		{TrimOptions{Padding: 30}, Region{Left: 0, Top: 0, Width: 90, Height: 80}},
+		{TrimOptions{Right: true, Padding: 60}, Region{Left: 0, Top: 0, Width: 100, Height: 80}},
+	}
+
+	for _, test := range tests {
+		if r := trimRegion(test.trim, 100, 80, content); r != test.expected {
+			t.Errorf("Unexpected region for %#v: %#v", test.trim, r)
+		}
+	}
+
+	if r := trimRegion(TrimOptions{Padding: 5}, 100, 80, Region{}); r != (Region{}) {
+		t.Errorf("Unexpected region of an empty image: %#v", r)
+	}
+}
@@ -2,6 +2,8 @@ package bimg
 
 import (
 	"regexp"
+	"strconv"
+	"strings"
 	"sync"
 	"unicode/utf8"
 )
@@ -32,6 +34,15 @@ const (
 	HEIF
 	// AVIF represents the AVIF image type.
 	AVIF
+	// EXR represents the OpenEXR high dynamic range image type.
+	EXR
+	// HDR represents the Radiance high dynamic range image type.
+	HDR
+	// RAW represents the camera RAW image types: CR2, CR3, NEF, ARW and
+	// DNG. They can be loaded, with libraw or ImageMagick, but not saved.
+	// Decoding uses the loader defaults, the camera white balance and
+	// demosaic, as libvips exposes no setting for them.
+	RAW
 )
 
 var (
@@ -51,6 +62,9 @@ var ImageTypes = map[ImageType]string{
 	MAGICK: "magick",
 	HEIF:   "heif",
 	AVIF:   "avif",
+	EXR:    "exr",
+	HDR:    "hdr",
+	RAW:    "raw",
 }
 
 // imageMutex is used to provide thread-safe synchronization
@@ -176,3 +190,64 @@ func ImageTypeName(t ImageType) string {
 	}
 	return imageType
 }
+
+// ParseImageType returns the image type of a name, as returned by
+// ImageTypeName, or UNKNOWN. "jpg" and "tif" are accepted too.
+func ParseImageType(name string) ImageType {
+	name = strings.ToLower(name)
+	switch name {
+	case "jpg":
+		return JPEG
+	case "tif":
+		return TIFF
+	}
+	for t, typeName := range ImageTypes {
+		if typeName == name {
+			return t
+		}
+	}
+	return UNKNOWN
+}
+
+// ChooseType picks the best output image type accepted by a client, based
+// on its HTTP Accept header. AVIF is preferred, then WebP. Otherwise GIF is
+// used for animated images, PNG for images with alpha and JPEG for the rest.
+func ChooseType(acceptHeader string, hasAlpha bool, animated bool) ImageType {
+	// libvips cannot save animated AVIF images
+	if !animated && acceptsMimeType(acceptHeader, "image/avif") && IsTypeSupportedSave(AVIF) {
+		return AVIF
+	}
+	if acceptsMimeType(acceptHeader, "image/webp") && IsTypeSupportedSave(WEBP) {
+		return WEBP
+	}
+	if animated && IsTypeSupportedSave(GIF) {
+		return GIF
+	}
+	if hasAlpha || animated {
+		return PNG
+	}
+	return JPEG
+}
+
+// acceptsMimeType reports whether an Accept header explicitly lists the
+// given MIME type with a non zero quality. Wildcards are ignored, as
+// clients sending them do not necessarily support modern formats.
+func acceptsMimeType(acceptHeader, mimeType string) bool {
+	for _, part := range strings.Split(acceptHeader, ",") {
+		params := strings.Split(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(params[0]), mimeType) {
+			continue
+		}
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			if q, err := strconv.ParseFloat(param[2:], 64); err == nil && q <= 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -23,6 +23,7 @@ func TestDeterminateImageType(t *testing.T) {
 		{"test2.heic", HEIF},
 		{"test3.heic", HEIF},
 		{"test.avif", AVIF},
+		{"test.hdr", HDR},
 	}
 
 	for _, file := range files {
@@ -168,3 +169,64 @@ func TestIsTypeNameSupportedSave(t *testing.T) {
 		}
 	}
 }
+
+func TestParseImageType(t *testing.T) {
+	cases := map[string]ImageType{"jpeg": JPEG, "JPG": JPEG, "png": PNG, "tif": TIFF, "webp": WEBP, "bmp": UNKNOWN}
+	for name, expected := range cases {
+		if imageType := ParseImageType(name); imageType != expected {
+			t.Errorf("Invalid image type for %s: %s", name, ImageTypeName(imageType))
+		}
+	}
+}
+
+func TestChooseType(t *testing.T) {
+	if !IsTypeSupportedSave(WEBP) {
+		t.Skipf("Format %#v is not supported", ImageTypes[WEBP])
+	}
+
+	avif := WEBP
+	if IsTypeSupportedSave(AVIF) {
+		avif = AVIF
+	}
+
+	tt := []struct {
+		accept   string
+		alpha    bool
+		animated bool
+		expected ImageType
+	}{
+		{"", false, false, JPEG},
+		{"*/*", true, false, PNG},
+		{"image/webp,*/*", false, false, WEBP},
+		{"image/avif,image/webp,*/*", false, false, avif},
+		{"image/avif,image/webp,*/*", true, true, WEBP},
+		{"image/avif;q=0,image/webp;q=0.5", false, false, WEBP},
+		{"image/avif;q=0,image/webp;q=0", true, false, PNG},
+	}
+
+	for _, tc := range tt {
+		if got := ChooseType(tc.accept, tc.alpha, tc.animated); got != tc.expected {
+			t.Errorf("%q: expected: %s; got: %s", tc.accept, ImageTypeName(tc.expected), ImageTypeName(got))
+		}
+	}
+}
+
+func TestAcceptsMimeType(t *testing.T) {
+	tt := []struct {
+		accept   string
+		expected bool
+	}{
+		{"", false},
+		{"image/*", false},
+		{"image/webp", true},
+		{"text/html, IMAGE/WEBP;q=0.9", true},
+		{"image/webp;q=0", false},
+		{"image/webpx", false},
+	}
+
+	for _, tc := range tt {
+		if got := acceptsMimeType(tc.accept, "image/webp"); got != tc.expected {
+			t.Errorf("%q: expected: %t; got: %t", tc.accept, tc.expected, got)
+		}
+	}
+}
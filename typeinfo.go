@@ -0,0 +1,255 @@
+package bimg
+
+import (
+	"encoding/binary"
+)
+
+// ImageTypeInfo represents the image properties read from its header.
+type ImageTypeInfo struct {
+	// Animated is true for GIF, PNG and WebP images with multiple frames.
+	Animated bool
+	// Pages defines the number of frames or pages.
+	Pages int
+	// Alpha is true when the image has an alpha channel or a
+	// transparent palette color.
+	Alpha bool
+	// BitDepth defines the bits per channel, or per palette index for
+	// palette based images. Zero when unknown.
+	BitDepth int
+}
+
+// DetermineImageTypeInfo determines the image type and reads its main
+// properties from the image header, without decoding the image. Only JPEG,
+// PNG, GIF, WebP and TIFF headers are read, other types report a single
+// page and an unknown bit depth.
+func DetermineImageTypeInfo(buf []byte) (ImageType, ImageTypeInfo, error) {
+	info := ImageTypeInfo{Pages: 1}
+
+	imageType := vipsImageType(buf)
+	if imageType == UNKNOWN {
+		return UNKNOWN, info, ErrUnsupportedFormat
+	}
+
+	var err error
+	switch imageType {
+	case JPEG:
+		err = jpegTypeInfo(buf, &info)
+	case PNG:
+		err = pngTypeInfo(buf, &info)
+	case GIF:
+		err = gifTypeInfo(buf, &info)
+	case WEBP:
+		err = webpTypeInfo(buf, &info)
+	case TIFF:
+		err = tiffTypeInfo(buf, &info)
+	}
+	if err != nil {
+		return imageType, info, err
+	}
+
+	if imageType != TIFF {
+		info.Animated = info.Pages > 1
+	}
+	return imageType, info, nil
+}
+
+func invalidHeader(imageType ImageType) error {
+	return wrapError(ErrTruncatedImage, "Invalid "+ImageTypeName(imageType)+" image header")
+}
+
+func jpegTypeInfo(buf []byte, info *ImageTypeInfo) error {
+	for i := 2; i+4 < len(buf); {
+		if buf[i] != 0xFF {
+			break
+		}
+		marker := buf[i+1]
+		// Fill bytes
+		if marker == 0xFF {
+			i++
+			continue
+		}
+		// Start of frame markers, excluding DHT, JPG and DAC
+		if marker >= 0xC0 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC {
+			info.BitDepth = int(buf[i+4])
+			return nil
+		}
+		if marker == 0xDA {
+			break
+		}
+		i += 2 + int(binary.BigEndian.Uint16(buf[i+2:]))
+	}
+	return invalidHeader(JPEG)
+}
+
+func pngTypeInfo(buf []byte, info *ImageTypeInfo) error {
+	if len(buf) < 33 || string(buf[12:16]) != "IHDR" {
+		return invalidHeader(PNG)
+	}
+	info.BitDepth = int(buf[24])
+	// Grey and RGB with alpha color types
+	info.Alpha = buf[25] == 4 || buf[25] == 6
+
+	for i := 8; i+8 <= len(buf); {
+		size := int(binary.BigEndian.Uint32(buf[i:]))
+		chunk := string(buf[i+4 : i+8])
+		data := buf[i+8:]
+		if size < len(data) {
+			data = data[:size]
+		}
+		switch chunk {
+		case "tRNS":
+			info.Alpha = true
+		case "acTL":
+			if len(data) >= 4 {
+				info.Pages = int(binary.BigEndian.Uint32(data))
+			}
+		case "IDAT", "IEND":
+			return nil
+		}
+		i += 12 + size
+	}
+	return nil
+}
+
+func gifTypeInfo(buf []byte, info *ImageTypeInfo) error {
+	if len(buf) < 13 {
+		return invalidHeader(GIF)
+	}
+	packed := buf[10]
+	info.BitDepth = int(packed&0x07) + 1
+
+	i := 13
+	if packed&0x80 != 0 {
+		i += 3 << (packed&0x07 + 1)
+	}
+
+	frames := 0
+	// Stop on the trailer or a truncated image
+	for i < len(buf) && buf[i] != 0x3B {
+		switch buf[i] {
+		case 0x21:
+			// Graphic control extension with a transparent color
+			if i+3 < len(buf) && buf[i+1] == 0xF9 && buf[i+3]&0x01 != 0 {
+				info.Alpha = true
+			}
+			i = skipGIFSubBlocks(buf, i+2)
+		case 0x2C:
+			frames++
+			if i+10 > len(buf) {
+				i = len(buf)
+				break
+			}
+			local := buf[i+9]
+			i += 10
+			if local&0x80 != 0 {
+				i += 3 << (local&0x07 + 1)
+			}
+			// LZW minimum code size, then the image data
+			i = skipGIFSubBlocks(buf, i+1)
+		default:
+			i = len(buf)
+		}
+	}
+
+	if frames > 0 {
+		info.Pages = frames
+	}
+	return nil
+}
+
+// skipGIFSubBlocks returns the index after the sub-blocks starting at i.
+func skipGIFSubBlocks(buf []byte, i int) int {
+	for i < len(buf) && buf[i] != 0 {
+		i += int(buf[i]) + 1
+	}
+	return i + 1
+}
+
+func webpTypeInfo(buf []byte, info *ImageTypeInfo) error {
+	if len(buf) < 20 || string(buf[0:4]) != "RIFF" || string(buf[8:12]) != "WEBP" {
+		return invalidHeader(WEBP)
+	}
+	info.BitDepth = 8
+
+	frames := 0
+	for i := 12; i+8 <= len(buf); {
+		chunk := string(buf[i : i+4])
+		size := int(binary.LittleEndian.Uint32(buf[i+4:]))
+		data := buf[i+8:]
+		switch chunk {
+		case "VP8X":
+			if len(data) > 0 {
+				info.Alpha = data[0]&0x10 != 0
+			}
+		case "VP8L":
+			// Alpha hint bit, after the signature and the image size
+			if len(data) > 4 {
+				info.Alpha = info.Alpha || data[4]&0x10 != 0
+			}
+		case "ANMF":
+			frames++
+		}
+		// Chunks are padded to an even size
+		i += 8 + size + size&1
+	}
+
+	if frames > 0 {
+		info.Pages = frames
+	}
+	return nil
+}
+
+func tiffTypeInfo(buf []byte, info *ImageTypeInfo) error {
+	if len(buf) < 8 {
+		return invalidHeader(TIFF)
+	}
+	var order binary.ByteOrder
+	switch string(buf[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return invalidHeader(TIFF)
+	}
+
+	// BitsPerSample defaults to bilevel images
+	info.BitDepth = 1
+	pages := 0
+	visited := map[int]bool{}
+	for ifd := int(order.Uint32(buf[4:])); ifd > 0 && ifd+2 <= len(buf) && !visited[ifd]; {
+		visited[ifd] = true
+		entries := int(order.Uint16(buf[ifd:]))
+		end := ifd + 2 + entries*12
+		if end+4 > len(buf) {
+			break
+		}
+
+		if pages == 0 {
+			for entry := ifd + 2; entry < end; entry += 12 {
+				tag := order.Uint16(buf[entry:])
+				value := order.Uint16(buf[entry+8:])
+				count := order.Uint32(buf[entry+4:])
+				switch {
+				case tag == 258 && count <= 2:
+					// BitsPerSample, inline
+					info.BitDepth = int(value)
+				case tag == 258 && int(order.Uint32(buf[entry+8:]))+2 <= len(buf):
+					info.BitDepth = int(order.Uint16(buf[order.Uint32(buf[entry+8:]):]))
+				case tag == 338:
+					// ExtraSamples, associated or unassociated alpha
+					info.Alpha = value == 1 || value == 2
+				}
+			}
+		}
+
+		pages++
+		ifd = int(order.Uint32(buf[end:]))
+	}
+
+	if pages == 0 {
+		return invalidHeader(TIFF)
+	}
+	info.Pages = pages
+	return nil
+}
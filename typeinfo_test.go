@@ -0,0 +1,103 @@
+package bimg
+
+import (
+	"testing"
+)
+
+func TestDetermineImageTypeInfo(t *testing.T) {
+	files := []struct {
+		name     string
+		kind     ImageType
+		expected ImageTypeInfo
+	}{
+		{"test.jpg", JPEG, ImageTypeInfo{Pages: 1, BitDepth: 8}},
+		{"test.png", PNG, ImageTypeInfo{Pages: 1, Alpha: true, BitDepth: 8}},
+		{"test.webp", WEBP, ImageTypeInfo{Pages: 1, BitDepth: 8}},
+		{"test.gif", GIF, ImageTypeInfo{Animated: true, Pages: 24, Alpha: true, BitDepth: 4}},
+	}
+
+	for _, file := range files {
+		kind, info, err := DetermineImageTypeInfo(readFile(file.name))
+		if err != nil {
+			t.Fatalf("%s: cannot read the image type info: %s", file.name, err)
+		}
+		if kind != file.kind {
+			t.Errorf("%s: invalid image type: %s", file.name, ImageTypeName(kind))
+		}
+		if info != file.expected {
+			t.Errorf("%s: expected: %#v; got: %#v", file.name, file.expected, info)
+		}
+	}
+}
+
+func TestDetermineImageTypeInfoAnimated(t *testing.T) {
+	// Header of a two frames APNG, up to its acTL chunk
+	apng := []byte("\x89PNG\r\n\x1a\n" +
+		"\x00\x00\x00\x0dIHDR\x00\x00\x00\x10\x00\x00\x00\x10\x08\x03\x00\x00\x00\x00\x00\x00\x00" +
+		"\x00\x00\x00\x08acTL\x00\x00\x00\x02\x00\x00\x00\x00\x00\x00\x00\x00")
+	_, info, err := DetermineImageTypeInfo(apng)
+	if err != nil {
+		t.Fatalf("Cannot read the image type info: %s", err)
+	}
+	if !info.Animated || info.Pages != 2 || info.Alpha || info.BitDepth != 8 {
+		t.Errorf("Invalid apng info: %#v", info)
+	}
+
+	// Animated WebP with alpha, made of two empty frames
+	webp := []byte("RIFF\x00\x00\x00\x00WEBP" +
+		"VP8X\x0a\x00\x00\x00\x12\x00\x00\x00\x0f\x00\x00\x0f\x00\x00" +
+		"ANMF\x00\x00\x00\x00" +
+		"ANMF\x00\x00\x00\x00")
+	kind, info, err := DetermineImageTypeInfo(webp)
+	if err != nil {
+		t.Fatalf("Cannot read the image type info: %s", err)
+	}
+	if kind != WEBP || !info.Animated || info.Pages != 2 || !info.Alpha {
+		t.Errorf("Invalid webp info: %#v", info)
+	}
+}
+
+func TestGifTypeInfo(t *testing.T) {
+	// Two frames with a transparent color and no color tables
+	gif := []byte("GIF89a\x01\x00\x01\x00\x00\x00\x00" +
+		"\x21\xf9\x04\x01\x00\x00\x00\x00" +
+		"\x2c\x00\x00\x00\x00\x01\x00\x01\x00\x00\x02\x02\x44\x01\x00" +
+		"\x2c\x00\x00\x00\x00\x01\x00\x01\x00\x00\x02\x02\x44\x01\x00" +
+		"\x3b")
+
+	info := ImageTypeInfo{Pages: 1}
+	if err := gifTypeInfo(gif, &info); err != nil {
+		t.Fatalf("Cannot read the image type info: %s", err)
+	}
+	if info.Pages != 2 || !info.Alpha || info.BitDepth != 1 {
+		t.Errorf("Invalid gif info: %#v", info)
+	}
+}
+
+func TestTiffTypeInfo(t *testing.T) {
+	// Two pages little endian TIFF with 16 bits samples and alpha
+	tiff := []byte("II\x2a\x00\x08\x00\x00\x00" +
+		"\x02\x00" +
+		"\x02\x01\x03\x00\x01\x00\x00\x00\x10\x00\x00\x00" +
+		"\x52\x01\x03\x00\x01\x00\x00\x00\x02\x00\x00\x00" +
+		"\x26\x00\x00\x00" +
+		"\x00\x00" +
+		"\x00\x00\x00\x00")
+
+	info := ImageTypeInfo{Pages: 1}
+	if err := tiffTypeInfo(tiff, &info); err != nil {
+		t.Fatalf("Cannot read the image type info: %s", err)
+	}
+	if info.Pages != 2 || !info.Alpha || info.BitDepth != 16 {
+		t.Errorf("Invalid tiff info: %#v", info)
+	}
+}
+
+func TestDetermineImageTypeInfoInvalid(t *testing.T) {
+	if _, _, err := DetermineImageTypeInfo([]byte("invalid image")); err == nil {
+		t.Error("Expected error for an unknown image type")
+	}
+	if _, _, err := DetermineImageTypeInfo([]byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00\x01\x01\x00")); err == nil {
+		t.Error("Expected error for a truncated jpeg")
+	}
+}
@@ -7,13 +7,14 @@ package bimg
 import "C"
 
 import (
-	"errors"
 	"fmt"
+	"io/ioutil"
 	"math"
 	"os"
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 	"unsafe"
 )
 
@@ -27,8 +28,9 @@ const VipsMajorVersion = int(C.VIPS_MAJOR_VERSION)
 const VipsMinorVersion = int(C.VIPS_MINOR_VERSION)
 
 const (
-	maxCacheMem  = 100 * 1024 * 1024
-	maxCacheSize = 500
+	maxCacheMem   = 100 * 1024 * 1024
+	maxCacheSize  = 500
+	maxCacheFiles = 100
 )
 
 var (
@@ -41,6 +43,59 @@ type VipsMemoryInfo struct {
 	Memory          int64
 	MemoryHighwater int64
 	Allocations     int64
+	// Files is the number of open files.
+	Files int64
+	// CacheEntries is the number of operations in the cache.
+	CacheEntries int64
+	// CacheMaxEntries, CacheMaxMem and CacheMaxFiles are the cache limits.
+	CacheMaxEntries int64
+	CacheMaxMem     int64
+	CacheMaxFiles   int64
+}
+
+// VipsConfig represents the libvips tuning options used by Initialize.
+// Zero values use the bimg defaults.
+type VipsConfig struct {
+	// ConcurrencyLevel defines the number of libvips worker threads per
+	// image. Defaults to 1, unless VIPS_CONCURRENCY is defined.
+	ConcurrencyLevel int
+	// CacheMaxMem defines the operation cache memory limit in bytes.
+	// Defaults to 100MB.
+	CacheMaxMem int
+	// CacheMaxOps defines the maximum number of cached operations.
+	// Defaults to 500.
+	CacheMaxOps int
+	// CacheMaxFiles defines the maximum number of files kept open by the
+	// cache. Defaults to 100.
+	CacheMaxFiles int
+	// Leak enables the libvips leak checks, reported on Shutdown. They
+	// are also enabled by the VIPS_LEAK environment variable.
+	Leak bool
+}
+
+// VipsFeaturesInfo represents the capabilities of the linked libvips library.
+type VipsFeaturesInfo struct {
+	// Version is the runtime libvips version, which may differ from the
+	// VipsVersion bimg was compiled against.
+	Version      string
+	MajorVersion int
+	MinorVersion int
+	// Types reports the bimg image types that can be loaded and saved.
+	Types map[ImageType]SupportedImageType
+	// Loaders and Savers report the available libvips format operations,
+	// including formats bimg has no image type for, e.g. "jxl".
+	Loaders map[string]bool
+	Savers  map[string]bool
+	// SmartCrop is true when smart cropping is available (libvips 8.5+).
+	SmartCrop bool
+	// Interesting lists the available smart crop strategies.
+	Interesting []string
+}
+
+// vipsFormats lists the libvips format nicknames reported by VipsFeatures.
+var vipsFormats = []string{
+	"jpeg", "png", "webp", "tiff", "gif", "heif", "jxl", "pdf",
+	"svg", "magick", "openexr", "rad", "jp2k",
 }
 
 // vipsSaveOptions represents the internal option used to talk with libvips.
@@ -57,6 +112,14 @@ type vipsSaveOptions struct {
 	OutputICC      string // Absolute path to the output ICC profile
 	Interpretation Interpretation
 	Palette        bool
+	BitDepth       int
+	KeepMetadata   Keep
+	JPEG           JPEGOptions
+	PNG            PNGOptions
+	WebP           WebPOptions
+	TIFF           TIFFOptions
+	GIF            GIFOptions
+	Output         *Buffer // Takes the libvips output buffer, not copied
 }
 
 type vipsWatermarkOptions struct {
@@ -84,8 +147,10 @@ func init() {
 }
 
 // Initialize is used to explicitly start libvips in thread-safe way.
-// Only call this function if you have previously turned off libvips.
-func Initialize() {
+// libvips is started on package initialization with the default config,
+// call it again with a config to tune libvips, or to restart it after a
+// Shutdown.
+func Initialize(config ...VipsConfig) {
 	if C.VIPS_MAJOR_VERSION <= 7 && C.VIPS_MINOR_VERSION < 40 {
 		panic("unsupported libvips version!")
 	}
@@ -95,19 +160,33 @@ func Initialize() {
 	defer m.Unlock()
 	defer runtime.UnlockOSThread()
 
-	err := C.vips_init(C.CString("bimg"))
-	if err != 0 {
-		panic("unable to start vips!")
+	if !initialized {
+		err := C.vips_init(C.CString("bimg"))
+		if err != 0 {
+			panic("unable to start vips!")
+		}
 	}
 
+	var c VipsConfig
+	if len(config) > 0 {
+		c = config[0]
+	}
+	c = vipsConfigDefaults(c)
+
 	// Set libvips cache params
-	C.vips_cache_set_max_mem(maxCacheMem)
-	C.vips_cache_set_max(maxCacheSize)
+	setCacheLimits(func(l *cacheLimits) {
+		l.maxMem, l.maxOps, l.maxFiles = c.CacheMaxMem, c.CacheMaxOps, c.CacheMaxFiles
+	})
 
 	// Define a custom thread concurrency limit in libvips (this may generate thread-unsafe issues)
 	// See: https://github.com/jcupitt/libvips/issues/261#issuecomment-92850414
-	if os.Getenv("VIPS_CONCURRENCY") == "" {
-		C.vips_concurrency_set(1)
+	if c.ConcurrencyLevel > 0 {
+		C.vips_concurrency_set(C.int(c.ConcurrencyLevel))
+	}
+
+	// Keep the leak checks enabled by VIPS_LEAK otherwise
+	if c.Leak {
+		C.vips_leak_set(C.gboolean(1))
 	}
 
 	// Enable libvips cache tracing
@@ -118,6 +197,29 @@ func Initialize() {
 	initialized = true
 }
 
+// vipsConfigDefaults fills the unset libvips config values with their
+// defaults. Negative cache limits disable the cache.
+func vipsConfigDefaults(c VipsConfig) VipsConfig {
+	// Keep the libvips default concurrency when defined by the environment
+	if c.ConcurrencyLevel == 0 && os.Getenv("VIPS_CONCURRENCY") == "" {
+		c.ConcurrencyLevel = 1
+	}
+	c.CacheMaxMem = cacheLimit(c.CacheMaxMem, maxCacheMem)
+	c.CacheMaxOps = cacheLimit(c.CacheMaxOps, maxCacheSize)
+	c.CacheMaxFiles = cacheLimit(c.CacheMaxFiles, maxCacheFiles)
+	return c
+}
+
+func cacheLimit(value, defaultValue int) int {
+	if value == 0 {
+		return defaultValue
+	}
+	if value < 0 {
+		return 0
+	}
+	return value
+}
+
 // Shutdown is used to shutdown libvips in a thread-safe way.
 // You can call this to drop caches as well.
 // If libvips was already initialized, the function is no-op
@@ -134,12 +236,12 @@ func Shutdown() {
 // VipsCacheSetMaxMem Sets the maximum amount of tracked memory allowed before the vips operation cache
 // begins to drop entries.
 func VipsCacheSetMaxMem(maxCacheMem int) {
-	C.vips_cache_set_max_mem(C.size_t(maxCacheMem))
+	setCacheLimits(func(l *cacheLimits) { l.maxMem = maxCacheMem })
 }
 
 // VipsCacheSetMax sets the maximum number of operations to keep in the vips operation cache.
 func VipsCacheSetMax(maxCacheSize int) {
-	C.vips_cache_set_max(C.int(maxCacheSize))
+	setCacheLimits(func(l *cacheLimits) { l.maxOps = maxCacheSize })
 }
 
 // VipsCacheDropAll drops the vips operation cache, freeing the allocated memory.
@@ -147,6 +249,105 @@ func VipsCacheDropAll() {
 	C.vips_cache_drop_all()
 }
 
+// cacheLimits represents the libvips operation cache limits.
+type cacheLimits struct {
+	maxMem   int
+	maxOps   int
+	maxFiles int
+}
+
+// cacheSuspension lifts the libvips cache limits while cache scopes are
+// processed, so their operations do not trim the cached operations of other
+// requests. The configured limits are restored once the last scope drops
+// its operations.
+var cacheSuspension struct {
+	sync.Mutex
+	scopes int
+	limits cacheLimits
+}
+
+// setCacheLimits updates the libvips cache limits, applied once the cache
+// scopes being processed are dropped.
+func setCacheLimits(update func(*cacheLimits)) {
+	cacheSuspension.Lock()
+	defer cacheSuspension.Unlock()
+	if cacheSuspension.scopes == 0 {
+		cacheSuspension.limits = currentCacheLimits()
+	}
+	update(&cacheSuspension.limits)
+	if cacheSuspension.scopes == 0 {
+		applyCacheLimits(cacheSuspension.limits)
+	}
+}
+
+func currentCacheLimits() cacheLimits {
+	return cacheLimits{
+		maxMem:   int(C.vips_cache_get_max_mem()),
+		maxOps:   int(C.vips_cache_get_max()),
+		maxFiles: int(C.vips_cache_get_max_files()),
+	}
+}
+
+func applyCacheLimits(l cacheLimits) {
+	C.vips_cache_set_max_mem(C.size_t(l.maxMem))
+	C.vips_cache_set_max(C.int(l.maxOps))
+	C.vips_cache_set_max_files(C.int(l.maxFiles))
+}
+
+// cacheScope collects the images decoded by an operation whose cached
+// operations are dropped once processed. See LoadOptions.NoCache.
+type cacheScope struct {
+	images    []*C.VipsImage
+	suspended bool
+}
+
+// suspend lifts the cache limits until the scope is dropped. A disabled
+// cache is left as is, as it trims no other operations.
+func (s *cacheScope) suspend() {
+	cacheSuspension.Lock()
+	defer cacheSuspension.Unlock()
+	if cacheSuspension.scopes == 0 {
+		cacheSuspension.limits = currentCacheLimits()
+		if cacheSuspension.limits.maxOps == 0 {
+			return
+		}
+		applyCacheLimits(cacheLimits{maxMem: int(^uint(0) >> 1), maxOps: math.MaxInt32, maxFiles: math.MaxInt32})
+	}
+	cacheSuspension.scopes++
+	s.suspended = true
+}
+
+// add keeps a reference to the decoded image until the scope is dropped.
+// It does nothing on nil scopes.
+func (s *cacheScope) add(image *C.VipsImage) {
+	if s == nil {
+		return
+	}
+	C.g_object_ref(C.gpointer(image))
+	s.images = append(s.images, image)
+}
+
+// drop removes from the operation cache every operation derived from the
+// decoded images, releasing their memory.
+func (s *cacheScope) drop() {
+	for _, image := range s.images {
+		C.vips_image_invalidate_all(image)
+		C.g_object_unref(C.gpointer(image))
+	}
+	s.images = nil
+
+	if !s.suspended {
+		return
+	}
+	s.suspended = false
+	cacheSuspension.Lock()
+	defer cacheSuspension.Unlock()
+	cacheSuspension.scopes--
+	if cacheSuspension.scopes == 0 {
+		applyCacheLimits(cacheSuspension.limits)
+	}
+}
+
 // VipsVectorSetEnabled enables or disables SIMD vector instructions. This can give speed-up,
 // but can also be unstable on some systems and versions.
 func VipsVectorSetEnabled(enable bool) {
@@ -169,9 +370,87 @@ func VipsMemory() VipsMemoryInfo {
 		Memory:          int64(C.vips_tracked_get_mem()),
 		MemoryHighwater: int64(C.vips_tracked_get_mem_highwater()),
 		Allocations:     int64(C.vips_tracked_get_allocs()),
+		Files:           int64(C.vips_tracked_get_files()),
+		CacheEntries:    int64(C.vips_cache_get_size()),
+		CacheMaxEntries: int64(C.vips_cache_get_max()),
+		CacheMaxMem:     int64(C.vips_cache_get_max_mem()),
+		CacheMaxFiles:   int64(C.vips_cache_get_max_files()),
+	}
+}
+
+// VipsFeatures reports the libvips version and the formats and features
+// available at runtime, so unsupported features can be disabled at startup.
+func VipsFeatures() VipsFeaturesInfo {
+	features := VipsFeaturesInfo{
+		MajorVersion: int(C.vips_version(0)),
+		MinorVersion: int(C.vips_version(1)),
+		Types:        map[ImageType]SupportedImageType{},
+		Loaders:      map[string]bool{},
+		Savers:       map[string]bool{},
+	}
+	features.Version = fmt.Sprintf("%d.%d.%d", features.MajorVersion, features.MinorVersion, int(C.vips_version(2)))
+
+	for imageType := range ImageTypes {
+		features.Types[imageType] = IsImageTypeSupportedByVips(imageType)
+	}
+	for _, format := range vipsFormats {
+		features.Loaders[format] = vipsHasOperation(format + "load")
+		features.Savers[format] = vipsHasOperation(format + "save")
+	}
+
+	// Smart crop strategies, by the libvips version bimg was built with
+	if VipsMajorVersion > 8 || VipsMinorVersion >= 5 {
+		features.SmartCrop = true
+		features.Interesting = []string{"centre", "entropy", "attention"}
+	}
+	if VipsMajorVersion > 8 || VipsMinorVersion >= 8 {
+		features.Interesting = append(features.Interesting, "low", "high")
+	}
+	if VipsMajorVersion > 8 || VipsMinorVersion >= 10 {
+		features.Interesting = append(features.Interesting, "all")
+	}
+	return features
+}
+
+// vipsHasOperation returns true if the given libvips operation exists.
+func vipsHasOperation(name string) bool {
+	base := C.CString("VipsOperation")
+	defer C.free(unsafe.Pointer(base))
+	nickname := C.CString(name)
+	defer C.free(unsafe.Pointer(nickname))
+
+	return C.vips_type_find(base, nickname) != 0
+}
+
+// WatchVipsMemory calls fn with the libvips memory stats at every
+// interval, until the returned stop function is called. Useful to export
+// the stats or to alert on leaks in long running processes.
+func WatchVipsMemory(interval time.Duration, fn func(VipsMemoryInfo)) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				fn(VipsMemory())
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
 	}
 }
 
+func vipsSetLogHandler(enabled bool) {
+	C.vips_set_log_handler(C.int(boolToInt(enabled)))
+}
+
 // VipsIsTypeSupported returns true if the given image type
 // is supported by the current libvips compilation.
 func VipsIsTypeSupported(t ImageType) bool {
@@ -205,6 +484,15 @@ func VipsIsTypeSupported(t ImageType) bool {
 	if t == AVIF {
 		return int(C.vips_type_find_bridge(C.HEIF)) != 0
 	}
+	if t == EXR {
+		return int(C.vips_type_find_bridge(C.EXR)) != 0
+	}
+	if t == HDR {
+		return int(C.vips_type_find_bridge(C.HDR)) != 0
+	}
+	if t == RAW {
+		return int(C.vips_type_find_bridge(C.RAW)) != 0
+	}
 	return false
 }
 
@@ -233,6 +521,9 @@ func VipsIsTypeSupportedSave(t ImageType) bool {
 	if t == GIF {
 		return int(C.vips_type_find_save_bridge(C.GIF)) != 0
 	}
+	if t == HDR {
+		return int(C.vips_type_find_save_bridge(C.HDR)) != 0
+	}
 	return false
 }
 
@@ -244,8 +535,46 @@ func vipsExifIntTag(image *C.VipsImage, tag string) int {
 	return int(C.vips_exif_tag_to_int(image, C.CString(tag)))
 }
 
-func vipsExifOrientation(image *C.VipsImage) int {
-	return int(C.vips_exif_orientation(image))
+// vipsExifTags returns every EXIF tag of the image, keyed by its libvips
+// name, such as "exif-ifd0-Make".
+func vipsExifTags(image *C.VipsImage) map[string]string {
+	fields := C.vips_image_get_fields(image)
+	defer C.g_strfreev(fields)
+
+	tags := make(map[string]string)
+	for i := 0; ; i++ {
+		field := C.vips_field_name(fields, C.int(i))
+		if field == nil {
+			break
+		}
+		if name := C.GoString(field); strings.HasPrefix(name, "exif-ifd") {
+			tags[name] = vipsExifShort(C.GoString(C.vips_exif_tag(image, field)))
+		}
+	}
+	return tags
+}
+
+func vipsExifOrientation(image *C.VipsImage) ExifOrientation {
+	return ExifOrientation(C.vips_exif_orientation(image))
+}
+
+// vipsNPages returns the number of pages of the image, at least 1.
+func vipsNPages(image *C.VipsImage) int {
+	return int(C.vips_n_pages(image))
+}
+
+// vipsPageHeight returns the height of the pages of a multi-page image.
+func vipsPageHeight(image *C.VipsImage) int {
+	return int(C.vips_page_height_bridge(image))
+}
+
+// vipsFrameDelay returns the delay of the page of an animated image, or
+// zero when undefined.
+func vipsFrameDelay(image *C.VipsImage, page int) time.Duration {
+	if delay := int(C.vips_frame_delay(image, C.int(page))); delay > 0 {
+		return time.Duration(delay) * time.Millisecond
+	}
+	return 0
 }
 
 func vipsExifShort(s string) string {
@@ -256,6 +585,15 @@ func vipsExifShort(s string) string {
 	return s
 }
 
+func vipsExifData(image *C.VipsImage) []byte {
+	var length C.size_t
+	data := C.vips_exif_data(image, &length)
+	if data == nil {
+		return nil
+	}
+	return C.GoBytes(data, C.int(length))
+}
+
 func vipsHasAlpha(image *C.VipsImage) bool {
 	return int(C.has_alpha_channel(image)) > 0
 }
@@ -298,6 +636,18 @@ func vipsAutoRotate(image *C.VipsImage) (*C.VipsImage, error) {
 	return out, nil
 }
 
+func vipsResetOrientation(image *C.VipsImage) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_reset_orientation_bridge(image, &out)
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return out, nil
+}
+
 func vipsTransformICC(image *C.VipsImage, inputICC string, outputICC string) (*C.VipsImage, error) {
 	var out *C.VipsImage
 	defer C.g_object_unref(C.gpointer(image))
@@ -367,24 +717,120 @@ func vipsWatermark(image *C.VipsImage, w Watermark) (*C.VipsImage, error) {
 }
 
 func vipsRead(buf []byte) (*C.VipsImage, ImageType, error) {
+	return vipsReadOptions(buf, LoadOptions{})
+}
+
+func vipsReadOptions(buf []byte, o LoadOptions) (*C.VipsImage, ImageType, error) {
 	var image *C.VipsImage
 	imageType := vipsImageType(buf)
 
 	if imageType == UNKNOWN {
-		return nil, UNKNOWN, errors.New("Unsupported image format")
+		return nil, UNKNOWN, ErrUnsupportedFormat
+	}
+
+	if imageType == EXR {
+		image, err := vipsReadEXR(buf)
+		if err != nil {
+			return nil, UNKNOWN, err
+		}
+		o.scope.add(image)
+		return image, imageType, nil
+	}
+
+	if imageType == RAW && o.Raw != (RawOptions{}) {
+		image, err := vipsReadRaw(buf, o)
+		if err != nil {
+			return nil, UNKNOWN, err
+		}
+		o.scope.add(image)
+		return image, imageType, nil
 	}
 
 	length := C.size_t(len(buf))
 	imageBuf := unsafe.Pointer(&buf[0])
 
-	err := C.vips_init_image(imageBuf, length, C.int(imageType), &image)
+	err := C.vips_init_image(imageBuf, length, C.int(imageType), vipsAccess(o.Access), C.int(o.FailOn), &image)
 	if err != 0 {
 		return nil, UNKNOWN, catchVipsError()
 	}
+	o.scope.add(image)
 
 	return image, imageType, nil
 }
 
+// vipsReadRaw loads a camera RAW image with the given demosaicing, then
+// applies the white balance.
+func vipsReadRaw(buf []byte, o LoadOptions) (*C.VipsImage, error) {
+	var image *C.VipsImage
+	halfSize := o.Raw.Demosaic == RawDemosaicHalfSize
+
+	err := C.vips_rawload_bridge(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), vipsAccess(o.Access), C.int(o.FailOn), C.int(boolToInt(halfSize)), &image)
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	switch o.Raw.WhiteBalance {
+	case RawWhiteBalanceAuto:
+		return vipsWhiteBalance(image, [3]float64{1, 1, 1}, true)
+	case RawWhiteBalanceCustom:
+		return vipsWhiteBalance(image, o.Raw.Multipliers, false)
+	}
+	return image, nil
+}
+
+// vipsWhiteBalance multiplies the red, green and blue bands by the gains
+// in linear light, or by the grey world gains when auto is set.
+func vipsWhiteBalance(image *C.VipsImage, gains [3]float64, auto bool) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	for _, gain := range gains {
+		if gain <= 0 {
+			return nil, fmt.Errorf("Invalid white balance multipliers: %v", gains)
+		}
+	}
+
+	err := C.vips_white_balance_bridge(image, &out, C.double(gains[0]), C.double(gains[1]), C.double(gains[2]), C.int(boolToInt(auto)))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+// vipsAccess returns the libvips access mode for the load option.
+func vipsAccess(access Access) C.int {
+	if access == AccessSequential {
+		return C.int(C.VIPS_ACCESS_SEQUENTIAL)
+	}
+	return C.int(C.VIPS_ACCESS_RANDOM)
+}
+
+// vipsReadEXR loads an OpenEXR image, which libvips can only read from files.
+func vipsReadEXR(buf []byte) (*C.VipsImage, error) {
+	file, err := ioutil.TempFile("", "bimg")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(buf)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	filename := C.CString(file.Name())
+	defer C.free(unsafe.Pointer(filename))
+
+	var image *C.VipsImage
+	if C.vips_openexrload_bridge(filename, &image) != 0 {
+		return nil, catchVipsError()
+	}
+	return image, nil
+}
+
 func vipsColourspaceIsSupportedBuffer(buf []byte) (bool, error) {
 	image, _, err := vipsRead(buf)
 	if err != nil {
@@ -445,6 +891,9 @@ func vipsPreSave(image *C.VipsImage, o *vipsSaveOptions) (*C.VipsImage, error) {
 	if o.Interpretation == 0 {
 		o.Interpretation = InterpretationSRGB
 	}
+	if o.Type == PNG || o.Type == TIFF {
+		o.Interpretation = interpretationForDepth(o.Interpretation, o.BitDepth)
+	}
 	interpretation := C.VipsInterpretation(o.Interpretation)
 
 	// Apply the proper colour space
@@ -486,8 +935,142 @@ func vipsPreSave(image *C.VipsImage, o *vipsSaveOptions) (*C.VipsImage, error) {
 	return image, nil
 }
 
-func vipsSave(image *C.VipsImage, o vipsSaveOptions) ([]byte, error) {
+// vipsDeepZoom saves the image as a tile pyramid in the given layout. The
+// tiles are written under o.Path when set, otherwise they are returned as
+// a zip archive.
+func vipsDeepZoom(image *C.VipsImage, o DeepZoomOptions) ([]byte, error) {
+	defer C.g_object_unref(C.gpointer(image))
+
+	suffix := C.CString(o.Suffix)
+	defer C.free(unsafe.Pointer(suffix))
+
+	layout := C.int(o.Layout)
+	tileSize := C.int(o.TileSize)
+	overlap := C.int(o.Overlap)
+
+	if o.Path != "" {
+		path := C.CString(o.Path)
+		defer C.free(unsafe.Pointer(path))
+
+		err := C.vips_dzsave_bridge(image, path, layout, suffix, tileSize, overlap)
+		if err != 0 {
+			return nil, catchVipsError()
+		}
+		return nil, nil
+	}
+
+	var ptr unsafe.Pointer
+	length := C.size_t(0)
+	err := C.vips_dzsave_buffer_bridge(image, &ptr, &length, layout, suffix, tileSize, overlap)
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	buf := C.GoBytes(ptr, C.int(length))
+	C.g_free(C.gpointer(ptr))
+	C.vips_error_clear()
+
+	return buf, nil
+}
+
+// webpDefaults fills the unset WebP encoder options with their defaults.
+func webpDefaults(o WebPOptions) WebPOptions {
+	if o.AlphaQuality == 0 {
+		o.AlphaQuality = 100
+	}
+	if o.Effort == 0 {
+		o.Effort = 4
+	}
+	if o.KMax == 0 {
+		o.KMax = 1
+	}
+	if o.KMin == 0 || o.KMin >= o.KMax {
+		o.KMin = o.KMax - 1
+	}
+	return o
+}
+
+// tiffDefaults fills the unset TIFF encoder options with their defaults.
+// Pyramids are always tiled.
+func tiffDefaults(o TIFFOptions) TIFFOptions {
+	if o.Pyramid {
+		o.Tile = true
+	}
+	if o.TileWidth == 0 {
+		o.TileWidth = 128
+	}
+	if o.TileHeight == 0 {
+		o.TileHeight = 128
+	}
+	return o
+}
+
+// gifDefaults fills the unset GIF encoder options with their defaults.
+func gifDefaults(o GIFOptions) GIFOptions {
+	if o.Colors <= 0 || o.Colors > 256 {
+		o.Colors = 256
+	}
+	if o.Effort == 0 {
+		o.Effort = 7
+	}
+	return o
+}
+
+// gifBitDepth returns the smallest palette bit depth fitting the given
+// number of colors.
+func gifBitDepth(colors int) int {
+	depth := 1
+	for depth < 8 && 1<<uint(depth) < colors {
+		depth++
+	}
+	return depth
+}
+
+// pngPaletteDepth returns the bit depth of a palette based PNG image, or
+// zero to let libvips choose it.
+func pngPaletteDepth(o vipsSaveOptions) int {
+	if o.Type != PNG || !o.Palette {
+		return 0
+	}
+	switch o.BitDepth {
+	case 1, 2, 4, 8:
+		return o.BitDepth
+	}
+	if o.PNG.Colors <= 0 {
+		return 0
+	}
+	for _, depth := range []int{1, 2, 4} {
+		if o.PNG.Colors <= 1<<uint(depth) {
+			return depth
+		}
+	}
+	return 8
+}
+
+// interpretationForDepth returns the equivalent interpretation with the
+// given bits per channel. Zero depth keeps the interpretation as is.
+func interpretationForDepth(interpretation Interpretation, depth int) Interpretation {
+	switch {
+	case depth == 16 && interpretation == InterpretationSRGB:
+		return InterpretationRGB16
+	case depth == 16 && interpretation == InterpretationBW:
+		return InterpretationGREY16
+	case depth == 8 && interpretation == InterpretationRGB16:
+		return InterpretationSRGB
+	case depth == 8 && interpretation == InterpretationGREY16:
+		return InterpretationBW
+	}
+	return interpretation
+}
+
+func vipsSave(image *C.VipsImage, o vipsSaveOptions) (_ []byte, err error) {
 	defer C.g_object_unref(C.gpointer(image))
+	defer observeOperation("save", o.Type, time.Now(), &err)
+
+	paletteDepth := pngPaletteDepth(o)
+	if o.BitDepth != 0 && o.BitDepth != 8 && o.BitDepth != 16 && o.BitDepth != paletteDepth {
+		return nil, fmt.Errorf("Unsupported bit depth: %d", o.BitDepth)
+	}
 
 	tmpImage, err := vipsPreSave(image, &o)
 	if err != nil {
@@ -503,6 +1086,17 @@ func vipsSave(image *C.VipsImage, o vipsSaveOptions) ([]byte, error) {
 		defer C.g_object_unref(C.gpointer(tmpImage))
 	}
 
+	// Remove the metadata not kept, the rest must not be stripped
+	if o.KeepMetadata != 0 {
+		var keptImage *C.VipsImage
+		if C.vips_keep_metadata_bridge(tmpImage, &keptImage, C.int(o.KeepMetadata)) != 0 {
+			return nil, catchVipsError()
+		}
+		defer C.g_object_unref(C.gpointer(keptImage))
+		tmpImage = keptImage
+		o.StripMetadata = false
+	}
+
 	length := C.size_t(0)
 	saveErr := C.int(0)
 	interlace := C.int(boolToInt(o.Interlace))
@@ -513,35 +1107,57 @@ func vipsSave(image *C.VipsImage, o vipsSaveOptions) ([]byte, error) {
 	speed := C.int(o.Speed)
 
 	if o.Type != 0 && !IsTypeSupportedSave(o.Type) {
-		return nil, fmt.Errorf("VIPS cannot save to %#v", ImageTypes[o.Type])
+		return nil, wrapError(ErrUnsupportedFormat, fmt.Sprintf("VIPS cannot save to %#v", ImageTypes[o.Type]))
 	}
 	var ptr unsafe.Pointer
 	switch o.Type {
 	case WEBP:
-		saveErr = C.vips_webpsave_bridge(tmpImage, &ptr, &length, strip, quality, lossless)
+		webp := webpDefaults(o.WebP)
+		saveErr = C.vips_webpsave_bridge(tmpImage, &ptr, &length, strip, quality, lossless,
+			C.int(boolToInt(webp.NearLossless)), C.int(webp.AlphaQuality), C.int(webp.Effort),
+			C.int(boolToInt(webp.SmartSubsample)), C.int(webp.KMin), C.int(webp.KMax))
 	case PNG:
-		saveErr = C.vips_pngsave_bridge(tmpImage, &ptr, &length, strip, C.int(o.Compression), quality, interlace, palette, speed)
+		saveErr = C.vips_pngsave_bridge(tmpImage, &ptr, &length, strip, C.int(o.Compression), quality, interlace, palette, speed,
+			C.int(o.PNG.Filter), C.int(paletteDepth), C.double(o.PNG.Dither))
 	case TIFF:
-		saveErr = C.vips_tiffsave_bridge(tmpImage, &ptr, &length)
+		tiff := tiffDefaults(o.TIFF)
+		saveErr = C.vips_tiffsave_bridge(tmpImage, &ptr, &length, quality, C.int(tiff.Compression), C.int(tiff.Predictor),
+			C.int(boolToInt(tiff.Tile)), C.int(tiff.TileWidth), C.int(tiff.TileHeight),
+			C.int(boolToInt(tiff.Pyramid)), C.int(boolToInt(tiff.BigTIFF)))
 	case HEIF:
 		saveErr = C.vips_heifsave_bridge(tmpImage, &ptr, &length, strip, quality, lossless)
 	case AVIF:
 		saveErr = C.vips_avifsave_bridge(tmpImage, &ptr, &length, strip, quality, lossless, speed)
 	case GIF:
-		saveErr = C.vips_gifsave_bridge(tmpImage, &ptr, &length, strip)
+		gif := gifDefaults(o.GIF)
+		saveErr = C.vips_gifsave_bridge(tmpImage, &ptr, &length, strip, C.int(gifBitDepth(gif.Colors)),
+			C.double(gif.Dither), C.int(gif.Effort), C.double(gif.InterframeMaxError))
+	case HDR:
+		saveErr = C.vips_radsave_bridge(tmpImage, &ptr, &length)
 	default:
-		saveErr = C.vips_jpegsave_bridge(tmpImage, &ptr, &length, strip, quality, interlace)
+		jpeg := o.JPEG
+		saveErr = C.vips_jpegsave_bridge(tmpImage, &ptr, &length, strip, quality, interlace,
+			C.int(jpeg.Subsample), C.int(boolToInt(jpeg.TrellisQuant)), C.int(boolToInt(jpeg.OvershootDeringing)),
+			C.int(boolToInt(jpeg.OptimizeScans)), C.int(jpeg.QuantTable), C.int(boolToInt(!jpeg.NoOptimizeCoding)))
 	}
 
 	if int(saveErr) != 0 {
 		return nil, catchVipsError()
 	}
 
+	C.vips_error_clear()
+
+	// Hand over the libvips buffer rather than copying it, unless it is
+	// too large to be sliced
+	if o.Output != nil && int(length) <= maxBufferSize {
+		o.Output.own(ptr, int(length))
+		return o.Output.Bytes(), nil
+	}
+
 	buf := C.GoBytes(ptr, C.int(length))
 
 	// Clean up
 	C.g_free(C.gpointer(ptr))
-	C.vips_error_clear()
 
 	return buf, nil
 }
@@ -554,7 +1170,7 @@ func getImageBuffer(image *C.VipsImage) ([]byte, error) {
 	quality := C.int(100)
 
 	err := C.int(0)
-	err = C.vips_jpegsave_bridge(image, &ptr, &length, 1, quality, interlace)
+	err = C.vips_jpegsave_bridge(image, &ptr, &length, 1, quality, interlace, 0, 0, 0, 0, 0, 1)
 	if int(err) != 0 {
 		return nil, catchVipsError()
 	}
@@ -570,7 +1186,7 @@ func vipsExtract(image *C.VipsImage, left, top, width, height int) (*C.VipsImage
 	defer C.g_object_unref(C.gpointer(image))
 
 	if width > maxSize || height > maxSize {
-		return nil, errors.New("Maximum image size exceeded")
+		return nil, ErrDimensionsTooLarge
 	}
 
 	top, left = max(top), max(left)
@@ -587,7 +1203,7 @@ func vipsSmartCrop(image *C.VipsImage, width, height int) (*C.VipsImage, error)
 	defer C.g_object_unref(C.gpointer(image))
 
 	if width > maxSize || height > maxSize {
-		return nil, errors.New("Maximum image size exceeded")
+		return nil, ErrDimensionsTooLarge
 	}
 
 	err := C.vips_smartcrop_bridge(image, &buf, C.int(width), C.int(height))
@@ -598,7 +1214,7 @@ func vipsSmartCrop(image *C.VipsImage, width, height int) (*C.VipsImage, error)
 	return buf, nil
 }
 
-func vipsTrim(image *C.VipsImage, background Color, threshold float64) (int, int, int, int, error) {
+func vipsTrim(image *C.VipsImage, background Color, threshold float64, lab bool) (int, int, int, int, error) {
 	defer C.g_object_unref(C.gpointer(image))
 
 	top := C.int(0)
@@ -606,10 +1222,19 @@ func vipsTrim(image *C.VipsImage, background Color, threshold float64) (int, int
 	width := C.int(0)
 	height := C.int(0)
 
-	err := C.vips_find_trim_bridge(image,
-		&top, &left, &width, &height,
-		C.double(background.R), C.double(background.G), C.double(background.B),
-		C.double(threshold))
+	var err C.int
+	if lab {
+		l, a, b := background.Lab()
+		err = C.vips_find_trim_lab_bridge(image,
+			&top, &left, &width, &height,
+			C.double(l), C.double(a), C.double(b),
+			C.double(threshold))
+	} else {
+		err = C.vips_find_trim_bridge(image,
+			&top, &left, &width, &height,
+			C.double(background.R), C.double(background.G), C.double(background.B),
+			C.double(threshold))
+	}
 	if err != 0 {
 		return 0, 0, 0, 0, catchVipsError()
 	}
@@ -617,67 +1242,89 @@ func vipsTrim(image *C.VipsImage, background Color, threshold float64) (int, int
 	return int(top), int(left), int(width), int(height), nil
 }
 
-func vipsShrinkJpeg(buf []byte, input *C.VipsImage, shrink int) (*C.VipsImage, error) {
+// vipsBorderBackground returns the median colour of the image corners and
+// edge middles, as ImageMagick does to find the background to trim.
+func vipsBorderBackground(image *C.VipsImage) (Color, error) {
+	r, g, b := C.int(0), C.int(0), C.int(0)
+	err := C.vips_border_background_bridge(image, &r, &g, &b)
+	if err != 0 {
+		return Color{}, catchVipsError()
+	}
+	return Color{R: uint8(r), G: uint8(g), B: uint8(b)}, nil
+}
+
+func vipsShrinkJpeg(buf []byte, input *C.VipsImage, shrink int, o LoadOptions) (*C.VipsImage, error) {
 	var image *C.VipsImage
 	var ptr = unsafe.Pointer(&buf[0])
 	defer C.g_object_unref(C.gpointer(input))
 
-	err := C.vips_jpegload_buffer_shrink(ptr, C.size_t(len(buf)), &image, C.int(shrink))
+	err := C.vips_jpegload_buffer_shrink(ptr, C.size_t(len(buf)), &image, C.int(shrink), vipsAccess(o.Access), C.int(o.FailOn))
 	if err != 0 {
 		return nil, catchVipsError()
 	}
 
+	o.scope.add(image)
 	return image, nil
 }
 
-func vipsShrinkWebp(buf []byte, input *C.VipsImage, shrink int) (*C.VipsImage, error) {
+func vipsShrinkWebp(buf []byte, input *C.VipsImage, shrink int, o LoadOptions) (*C.VipsImage, error) {
 	var image *C.VipsImage
 	var ptr = unsafe.Pointer(&buf[0])
 	defer C.g_object_unref(C.gpointer(input))
 
-	err := C.vips_webpload_buffer_shrink(ptr, C.size_t(len(buf)), &image, C.int(shrink))
+	err := C.vips_webpload_buffer_shrink(ptr, C.size_t(len(buf)), &image, C.int(shrink), vipsAccess(o.Access), C.int(o.FailOn))
 	if err != 0 {
 		return nil, catchVipsError()
 	}
 
+	o.scope.add(image)
 	return image, nil
 }
 
-func vipsShrink(input *C.VipsImage, shrink int) (*C.VipsImage, error) {
+func vipsLoadHeifThumbnail(buf []byte, o LoadOptions) (*C.VipsImage, error) {
 	var image *C.VipsImage
-	defer C.g_object_unref(C.gpointer(input))
+	var ptr = unsafe.Pointer(&buf[0])
 
-	err := C.vips_shrink_bridge(input, &image, C.double(float64(shrink)), C.double(float64(shrink)))
+	err := C.vips_heifload_buffer_thumbnail(ptr, C.size_t(len(buf)), &image, vipsAccess(o.Access), C.int(o.FailOn))
 	if err != 0 {
 		return nil, catchVipsError()
 	}
 
+	o.scope.add(image)
 	return image, nil
 }
 
-func vipsReduce(input *C.VipsImage, xshrink float64, yshrink float64) (*C.VipsImage, error) {
+func vipsLoadTiffPage(buf []byte, page int, o LoadOptions) (*C.VipsImage, error) {
 	var image *C.VipsImage
-	defer C.g_object_unref(C.gpointer(input))
+	var ptr = unsafe.Pointer(&buf[0])
 
-	err := C.vips_reduce_bridge(input, &image, C.double(xshrink), C.double(yshrink))
+	err := C.vips_tiffload_buffer_page(ptr, C.size_t(len(buf)), &image, C.int(page), vipsAccess(o.Access), C.int(o.FailOn))
 	if err != 0 {
 		return nil, catchVipsError()
 	}
 
+	o.scope.add(image)
 	return image, nil
 }
 
-func vipsEmbed(input *C.VipsImage, left, top, width, height int, extend Extend, background Color) (*C.VipsImage, error) {
+func vipsLoadFrames(buf []byte, imageType ImageType, o LoadOptions) (*C.VipsImage, error) {
 	var image *C.VipsImage
+	var ptr = unsafe.Pointer(&buf[0])
 
-	// Max extend value, see: https://libvips.github.io/libvips/API/current/libvips-conversion.html#VipsExtend
-	if extend > 5 {
-		extend = ExtendBackground
+	err := C.vips_load_frames_bridge(ptr, C.size_t(len(buf)), C.int(imageType), C.int(o.FailOn), &image)
+	if err != 0 {
+		return nil, catchVipsError()
 	}
 
+	o.scope.add(image)
+	return image, nil
+}
+
+func vipsShrink(input *C.VipsImage, shrink int) (*C.VipsImage, error) {
+	var image *C.VipsImage
 	defer C.g_object_unref(C.gpointer(input))
-	err := C.vips_embed_bridge(input, &image, C.int(left), C.int(top), C.int(width),
-		C.int(height), C.int(extend), C.double(background.R), C.double(background.G), C.double(background.B))
+
+	err := C.vips_shrink_bridge(input, &image, C.double(float64(shrink)), C.double(float64(shrink)))
 	if err != 0 {
 		return nil, catchVipsError()
 	}
@@ -685,20 +1332,23 @@ func vipsEmbed(input *C.VipsImage, left, top, width, height int, extend Extend,
 	return image, nil
 }
 
-func vipsAffine(input *C.VipsImage, residualx, residualy float64, i Interpolator, extend Extend) (*C.VipsImage, error) {
-	if extend > 5 {
-		extend = ExtendBackground
+func vipsReduce(input *C.VipsImage, xshrink float64, yshrink float64) (*C.VipsImage, error) {
+	var image *C.VipsImage
+	defer C.g_object_unref(C.gpointer(input))
+
+	err := C.vips_reduce_bridge(input, &image, C.double(xshrink), C.double(yshrink))
+	if err != 0 {
+		return nil, catchVipsError()
 	}
 
-	var image *C.VipsImage
-	cstring := C.CString(i.String())
-	interpolator := C.vips_interpolate_new(cstring)
+	return image, nil
+}
 
-	defer C.free(unsafe.Pointer(cstring))
+func vipsResize(input *C.VipsImage, hscale, vscale float64) (*C.VipsImage, error) {
+	var image *C.VipsImage
 	defer C.g_object_unref(C.gpointer(input))
-	defer C.g_object_unref(C.gpointer(interpolator))
 
-	err := C.vips_affine_interpolator(input, &image, C.double(residualx), 0, 0, C.double(residualy), interpolator, C.int(extend))
+	err := C.vips_resize_bridge(input, &image, C.double(hscale), C.double(vscale))
 	if err != 0 {
 		return nil, catchVipsError()
 	}
@@ -706,7 +1356,72 @@ func vipsAffine(input *C.VipsImage, residualx, residualy float64, i Interpolator
 	return image, nil
 }
 
-func vipsImageType(buf []byte) ImageType {
+func vipsCopyMemory(input *C.VipsImage) (*C.VipsImage, error) {
+	var image *C.VipsImage
+	defer C.g_object_unref(C.gpointer(input))
+
+	err := C.vips_copy_memory_bridge(input, &image)
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return image, nil
+}
+
+func vipsEmbed(input *C.VipsImage, left, top, width, height int, extend Extend, background Color) (*C.VipsImage, error) {
+	var image *C.VipsImage
+
+	// Max extend value, see: https://libvips.github.io/libvips/API/current/libvips-conversion.html#VipsExtend
+	if extend > 5 {
+		extend = ExtendBackground
+	}
+
+	defer C.g_object_unref(C.gpointer(input))
+	err := C.vips_embed_bridge(input, &image, C.int(left), C.int(top), C.int(width),
+		C.int(height), C.int(extend), C.double(background.R), C.double(background.G), C.double(background.B))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return image, nil
+}
+
+func vipsBorder(input *C.VipsImage, b Border) (*C.VipsImage, error) {
+	var image *C.VipsImage
+	defer C.g_object_unref(C.gpointer(input))
+
+	bg := b.Background.RGBA()
+	err := C.vips_border_bridge(input, &image, C.int(b.Top), C.int(b.Right), C.int(b.Bottom), C.int(b.Left),
+		C.int(b.Extend), C.double(bg.R), C.double(bg.G), C.double(bg.B), C.double(bg.A))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return image, nil
+}
+
+func vipsAffine(input *C.VipsImage, residualx, residualy float64, i Interpolator, extend Extend) (*C.VipsImage, error) {
+	if extend > 5 {
+		extend = ExtendBackground
+	}
+
+	var image *C.VipsImage
+	cstring := C.CString(i.String())
+	interpolator := C.vips_interpolate_new(cstring)
+
+	defer C.free(unsafe.Pointer(cstring))
+	defer C.g_object_unref(C.gpointer(input))
+	defer C.g_object_unref(C.gpointer(interpolator))
+
+	err := C.vips_affine_interpolator(input, &image, C.double(residualx), 0, 0, C.double(residualy), interpolator, C.int(extend))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return image, nil
+}
+
+func vipsImageType(buf []byte) ImageType {
 	if len(buf) < 12 {
 		return UNKNOWN
 	}
@@ -719,6 +1434,10 @@ func vipsImageType(buf []byte) ImageType {
 	if buf[0] == 0x89 && buf[1] == 0x50 && buf[2] == 0x4E && buf[3] == 0x47 {
 		return PNG
 	}
+	// Most camera RAW formats are TIFF based
+	if IsTypeSupported(RAW) && rawFormat(buf) != "" {
+		return RAW
+	}
 	if IsTypeSupported(TIFF) &&
 		((buf[0] == 0x49 && buf[1] == 0x49 && buf[2] == 0x2A && buf[3] == 0x0) ||
 			(buf[0] == 0x4D && buf[1] == 0x4D && buf[2] == 0x0 && buf[3] == 0x2A)) {
@@ -767,6 +1486,13 @@ func vipsImageType(buf []byte) ImageType {
 		buf[8] == 0x61 && buf[9] == 0x76 && buf[10] == 0x69 && buf[11] == 0x66 {
 		return AVIF
 	}
+	if IsTypeSupported(EXR) && buf[0] == 0x76 && buf[1] == 0x2F && buf[2] == 0x31 && buf[3] == 0x01 {
+		return EXR
+	}
+	if IsTypeSupported(HDR) && buf[0] == 0x23 && buf[1] == 0x3F {
+		// Radiance header starts with "#?RADIANCE" or "#?RGBE"
+		return HDR
+	}
 
 	return UNKNOWN
 }
@@ -782,7 +1508,7 @@ func catchVipsError() error {
 	s := C.GoString(C.vips_error_buffer())
 	C.vips_error_clear()
 	C.vips_thread_shutdown()
-	return errors.New(s)
+	return newVipsOperationError(-1, s)
 }
 
 func boolToInt(b bool) int {
@@ -803,6 +1529,56 @@ func vipsGaussianBlur(image *C.VipsImage, o GaussianBlur) (*C.VipsImage, error)
 	return out, nil
 }
 
+func vipsGaussianBlurRegion(image *C.VipsImage, o GaussianBlur, r Region) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_gaussblur_region_bridge(image, &out, C.double(o.Sigma), C.double(o.MinAmpl),
+		C.int(r.Left), C.int(r.Top), C.int(r.Width), C.int(r.Height))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+func vipsGaussianBlurMask(image *C.VipsImage, o GaussianBlur) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	mask, _, err := vipsRead(o.Mask)
+	if err != nil {
+		return nil, err
+	}
+	defer C.g_object_unref(C.gpointer(mask))
+
+	if C.vips_gaussblur_mask_bridge(image, mask, &out, C.double(o.Sigma), C.double(o.MinAmpl)) != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+func vipsMotionBlur(image *C.VipsImage, o MotionBlur) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_motion_blur_bridge(image, &out, C.double(o.Angle), C.int(o.Distance))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+func vipsRadialBlur(image *C.VipsImage, o RadialBlur) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_radial_blur_bridge(image, &out, C.int(o.Center.X), C.int(o.Center.Y), C.double(o.Amount))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
 func vipsSharpen(image *C.VipsImage, o Sharpen) (*C.VipsImage, error) {
 	var out *C.VipsImage
 	defer C.g_object_unref(C.gpointer(image))
@@ -814,18 +1590,535 @@ func vipsSharpen(image *C.VipsImage, o Sharpen) (*C.VipsImage, error) {
 	return out, nil
 }
 
+func vipsMedian(image *C.VipsImage, size int) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_median_bridge(image, &out, C.int(size))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+func vipsSobel(image *C.VipsImage) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_sobel_bridge(image, &out)
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+func vipsCanny(image *C.VipsImage, sigma float64) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_canny_bridge(image, &out, C.double(sigma))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+func vipsVignette(image *C.VipsImage, v Vignette) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_vignette_bridge(image, &out, C.double(v.Strength), C.double(v.Radius),
+		C.double(v.Color.R), C.double(v.Color.G), C.double(v.Color.B))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+func vipsGrain(image *C.VipsImage, o GrainOptions) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_grain_bridge(image, &out, C.double(o.Amount), C.int(boolToInt(o.Monochrome)), C.int(o.Size))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+func vipsPixelate(image *C.VipsImage, r Region, blockSize int) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_pixelate_bridge(image, &out, C.int(r.Left), C.int(r.Top), C.int(r.Width), C.int(r.Height), C.int(blockSize))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+func vipsRoundCorners(image *C.VipsImage, radius float64) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_round_corners_bridge(image, &out, C.double(radius))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+func vipsAddAlpha(image *C.VipsImage) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_addalpha_bridge(image, &out)
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+func vipsExtractAlpha(image *C.VipsImage) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_extract_alpha_bridge(image, &out)
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+func vipsApplyAlphaMask(image *C.VipsImage, buf []byte) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	mask, _, err := vipsRead(buf)
+	if err != nil {
+		return nil, err
+	}
+	defer C.g_object_unref(C.gpointer(mask))
+
+	if C.vips_apply_alpha_mask_bridge(image, mask, &out) != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+func vipsPremultiply(image *C.VipsImage) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_premultiply_bridge(image, &out)
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+func vipsUnpremultiply(image *C.VipsImage, format C.VipsBandFormat) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_unpremultiply_bridge(image, &out, format)
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+func vipsExtractBand(image *C.VipsImage, index, n int) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_extract_band_bridge(image, &out, C.int(index), C.int(n))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+func vipsBandJoin(images []*C.VipsImage) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer func() {
+		for _, image := range images {
+			C.g_object_unref(C.gpointer(image))
+		}
+	}()
+
+	err := C.vips_bandjoin_bridge(&images[0], &out, C.int(len(images)))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+func vipsArrayJoin(images []*C.VipsImage, across, shim, hspacing, vspacing int, background Color) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer func() {
+		for _, image := range images {
+			C.g_object_unref(C.gpointer(image))
+		}
+	}()
+
+	err := C.vips_arrayjoin_bridge(&images[0], &out, C.int(len(images)), C.int(across), C.int(shim),
+		C.int(hspacing), C.int(vspacing), C.double(background.R), C.double(background.G), C.double(background.B))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+func vipsJoin(a, b *C.VipsImage, direction Direction, o JoinOptions) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(a))
+	defer C.g_object_unref(C.gpointer(b))
+
+	err := C.vips_join_bridge(a, b, &out, C.int(direction), C.int(boolToInt(!o.Crop)), C.int(o.Shim), C.int(o.Align),
+		C.double(o.Background.R), C.double(o.Background.G), C.double(o.Background.B))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+func vipsText(text, font string, width, dpi int) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	ctext := C.CString(text)
+	cfont := C.CString(font)
+	defer C.free(unsafe.Pointer(ctext))
+	defer C.free(unsafe.Pointer(cfont))
+
+	err := C.vips_text_bridge(&out, ctext, cfont, C.int(width), C.int(dpi))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+func vipsLabel(mask *C.VipsImage, width, height int, ink, background Color) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(mask))
+
+	err := C.vips_label_bridge(mask, &out, C.int(width), C.int(height),
+		C.double(ink.R), C.double(ink.G), C.double(ink.B),
+		C.double(background.R), C.double(background.G), C.double(background.B))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+func vipsAnimation(frames []*C.VipsImage, delays []C.int, loop int) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer func() {
+		for _, image := range frames {
+			C.g_object_unref(C.gpointer(image))
+		}
+	}()
+
+	err := C.vips_animation_bridge(&frames[0], &out, C.int(len(frames)), &delays[0], C.int(loop))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+func vipsPages(pages []*C.VipsImage) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer func() {
+		for _, image := range pages {
+			C.g_object_unref(C.gpointer(image))
+		}
+	}()
+
+	err := C.vips_pages_bridge(&pages[0], &out, C.int(len(pages)))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+func vipsToneMap(image *C.VipsImage, t ToneMap) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	reinhard := C.int(boolToInt(t.Operator == ToneMapReinhard))
+	scale := C.double(math.Pow(2, t.Exposure))
+	err := C.vips_tonemap_bridge(image, &out, reinhard, scale, C.double(t.Gamma))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+// vipsRGBA returns the raw 8-bit RGBA pixels of the image downscaled to fit
+// the given size, along with its dimensions.
+func vipsRGBA(image *C.VipsImage, size int) ([]byte, int, int, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_rgba_thumbnail_bridge(image, &out, C.int(size))
+	if err != 0 {
+		return nil, 0, 0, catchVipsError()
+	}
+	defer C.g_object_unref(C.gpointer(out))
+
+	length := C.size_t(0)
+	ptr := C.vips_image_write_to_memory(out, &length)
+	if ptr == nil {
+		return nil, 0, 0, catchVipsError()
+	}
+	defer C.g_free(C.gpointer(ptr))
+
+	return C.GoBytes(ptr, C.int(length)), int(out.Xsize), int(out.Ysize), nil
+}
+
+// vipsGradient creates a linear gradient image between the colors, with an
+// alpha channel unless both colors are opaque.
+func vipsGradient(width, height int, from, to RGBA, angle float64) (*C.VipsImage, error) {
+	var image *C.VipsImage
+
+	bands := 3
+	if from.A < 255 || to.A < 255 {
+		bands = 4
+	}
+	start := [4]C.double{C.double(from.R), C.double(from.G), C.double(from.B), C.double(from.A)}
+	end := [4]C.double{C.double(to.R), C.double(to.G), C.double(to.B), C.double(to.A)}
+
+	err := C.vips_gradient_bridge(&image, C.int(width), C.int(height), &start[0], &end[0], C.int(bands), C.double(angle))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return image, nil
+}
+
+// vipsTextImage renders the text in the color over the background, with an
+// alpha channel unless both colors are opaque.
+func vipsTextImage(text string, o TextOptions, color, background RGBA) (*C.VipsImage, error) {
+	var image *C.VipsImage
+
+	bands := 3
+	if color.A < 255 || background.A < 255 {
+		bands = 4
+	}
+	ink := [4]C.double{C.double(color.R), C.double(color.G), C.double(color.B), C.double(color.A)}
+	paper := [4]C.double{C.double(background.R), C.double(background.G), C.double(background.B), C.double(background.A)}
+
+	ctext := C.CString(text)
+	defer C.free(unsafe.Pointer(ctext))
+	font := C.CString(o.Font)
+	defer C.free(unsafe.Pointer(font))
+
+	err := C.vips_text_image_bridge(&image, ctext, font, C.int(o.Width), C.int(o.DPI), C.int(o.Align),
+		&ink[0], &paper[0], C.int(bands))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return image, nil
+}
+
+// vipsComposite draws the overlay over the image at the given position,
+// releasing both.
+func vipsComposite(image, overlay *C.VipsImage, left, top int, opacity float64) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+	defer C.g_object_unref(C.gpointer(overlay))
+
+	err := C.vips_composite_bridge(image, overlay, &out, C.int(left), C.int(top), C.double(opacity))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+func vipsNoiseTexture(width, height int, o PerlinOptions) (*C.VipsImage, error) {
+	var image *C.VipsImage
+
+	err := C.vips_noise_texture_bridge(&image, C.int(width), C.int(height), C.int(o.CellSize), C.int(o.Seed), C.int(boolToInt(o.Worley)))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return image, nil
+}
+
+// vipsImageFromRGBA creates an image from 8-bit RGBA pixels, dropping the
+// alpha channel unless told otherwise.
+func vipsImageFromRGBA(pixels []byte, width, height int, alpha bool) (*C.VipsImage, error) {
+	var image *C.VipsImage
+
+	err := C.vips_rgba_image_bridge(unsafe.Pointer(&pixels[0]), C.size_t(len(pixels)),
+		C.int(width), C.int(height), C.int(boolToInt(alpha)), &image)
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return image, nil
+}
+
+// vipsAddDelta adds the signed changes of the 8-bit RGB pixels, three per
+// pixel, to the image, keeping its depth and metadata.
+func vipsAddDelta(image *C.VipsImage, delta []float32, width, height int) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_add_delta_bridge(image, &out, unsafe.Pointer(&delta[0]), C.size_t(len(delta)*4),
+		C.int(width), C.int(height))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+// vipsGreyPixels returns the raw 8-bit greyscale pixels of the image resized
+// to the exact given dimensions.
+func vipsGreyPixels(image *C.VipsImage, width, height int) ([]byte, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_grey_thumbnail_bridge(image, &out, C.int(width), C.int(height))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	defer C.g_object_unref(C.gpointer(out))
+
+	length := C.size_t(0)
+	ptr := C.vips_image_write_to_memory(out, &length)
+	if ptr == nil {
+		return nil, catchVipsError()
+	}
+	defer C.g_free(C.gpointer(ptr))
+
+	return C.GoBytes(ptr, C.int(length)), nil
+}
+
+func vipsCompare(a, b *C.VipsImage) (float64, float64, error) {
+	defer C.g_object_unref(C.gpointer(a))
+	defer C.g_object_unref(C.gpointer(b))
+
+	mse := C.double(0)
+	ssim := C.double(0)
+	err := C.vips_compare_bridge(a, b, &mse, &ssim)
+	if err != 0 {
+		return 0, 0, catchVipsError()
+	}
+	return float64(mse), float64(ssim), nil
+}
+
+func vipsDiff(a, b *C.VipsImage, threshold float64, ink Color) (*C.VipsImage, float64, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(a))
+	defer C.g_object_unref(C.gpointer(b))
+
+	ratio := C.double(0)
+	err := C.vips_diff_bridge(a, b, &out, &ratio, C.double(threshold), C.double(ink.R), C.double(ink.G), C.double(ink.B))
+	if err != 0 {
+		return nil, 0, catchVipsError()
+	}
+	return out, float64(ratio), nil
+}
+
+func vipsSharpness(image *C.VipsImage, size int) (float64, error) {
+	defer C.g_object_unref(C.gpointer(image))
+
+	score := C.double(0)
+	err := C.vips_sharpness_bridge(image, &score, C.int(size))
+	if err != 0 {
+		return 0, catchVipsError()
+	}
+	return float64(score), nil
+}
+
+func vipsStats(image *C.VipsImage) (ImageStats, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	entropy := C.double(0)
+	err := C.vips_stats_bridge(image, &out, &entropy)
+	if err != 0 {
+		return ImageStats{}, catchVipsError()
+	}
+	defer C.g_object_unref(C.gpointer(out))
+
+	length := C.size_t(0)
+	ptr := C.vips_image_write_to_memory(out, &length)
+	if ptr == nil {
+		return ImageStats{}, catchVipsError()
+	}
+	defer C.g_free(C.gpointer(ptr))
+
+	// Columns are min, max, sum, sum of squares, mean, deviation and the
+	// coordinates of the min and max values
+	width := int(out.Xsize)
+	matrix := (*[1 << 20]C.double)(ptr)[: int(length)/8 : int(length)/8]
+	stats := ImageStats{Entropy: float64(entropy)}
+	for row := 1; row < int(out.Ysize); row++ {
+		values := matrix[row*width:]
+		stats.Bands = append(stats.Bands, BandStats{
+			Min:    float64(values[0]),
+			Max:    float64(values[1]),
+			Mean:   float64(values[4]),
+			StdDev: float64(values[5]),
+		})
+	}
+	return stats, nil
+}
+
+func vipsCast(image *C.VipsImage, format C.VipsBandFormat) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_cast_bridge(image, &out, format)
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+func vipsUnsharpMask(image *C.VipsImage, o UnsharpMask) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	sigma, x1, m1, m2 := unsharpMaskParams(o)
+	err := C.vips_sharpen_sigma_bridge(image, &out, C.double(sigma), C.double(x1), 100, 100, C.double(m1), C.double(m2))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+// unsharpMaskParams maps the unsharp mask semantics onto the vips_sharpen
+// parameters, which operate on the L* channel (0-100): pixels whose
+// difference is below the threshold (x1) are left untouched (m1) and the
+// rest are sharpened by the given amount (m2).
+func unsharpMaskParams(o UnsharpMask) (sigma, x1, m1, m2 float64) {
+	sigma = o.Radius
+	x1 = math.Max(o.Threshold, 0) * 100 / 255
+	m2 = o.Amount / 100
+	m1 = m2
+	if x1 > 0 {
+		m1 = 0
+	}
+	return sigma, x1, m1, m2
+}
+
 func max(x int) int {
 	return int(math.Max(float64(x), 0))
 }
 
 func vipsDrawWatermark(image *C.VipsImage, o WatermarkImage) (*C.VipsImage, error) {
-	var out *C.VipsImage
-
 	watermark, _, e := vipsRead(o.Buf)
 	if e != nil {
 		return nil, e
 	}
 
+	return vipsDrawWatermarkImage(image, watermark, o)
+}
+
+func vipsDrawWatermarkImage(image, watermark *C.VipsImage, o WatermarkImage) (*C.VipsImage, error) {
+	var out *C.VipsImage
+
 	opts := vipsWatermarkImageOptions{C.int(o.Left), C.int(o.Top), C.float(o.Opacity)}
 
 	err := C.vips_watermark_image(image, watermark, &out, (*C.WatermarkImageOptions)(unsafe.Pointer(&opts)))
@@ -848,6 +2141,31 @@ func vipsGamma(image *C.VipsImage, Gamma float64) (*C.VipsImage, error) {
 	return out, nil
 }
 
+func vipsPipeline(image *C.VipsImage, p *Pipeline) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	if len(p.ops) == 0 {
+		C.g_object_ref(C.gpointer(image))
+		return image, nil
+	}
+
+	ops := make([]C.int, len(p.ops))
+	for i, op := range p.ops {
+		ops[i] = C.int(op)
+	}
+	args := make([]C.double, len(p.args))
+	for i, arg := range p.args {
+		args[i] = C.double(arg)
+	}
+
+	err := C.vips_pipeline_bridge(image, &out, &ops[0], &args[0], C.int(len(ops)))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
 func vipsBrightness(image *C.VipsImage, brightness float64) (*C.VipsImage, error) {
 	var out *C.VipsImage
 	defer C.g_object_unref(C.gpointer(image))
@@ -869,3 +2187,47 @@ func vipsContrast(image *C.VipsImage, contrast float64) (*C.VipsImage, error) {
 	}
 	return out, nil
 }
+
+func vipsInvert(image *C.VipsImage, skipAlpha bool) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_invert_bridge(image, &out, C.int(boolToInt(skipAlpha)))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+func vipsMapLUT(image *C.VipsImage, lut []byte) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_maplut_bridge(image, &out, unsafe.Pointer(&lut[0]))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+func vipsThreshold(image *C.VipsImage, threshold float64) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_threshold_bridge(image, &out, C.double(threshold))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+func vipsAdaptiveThreshold(image *C.VipsImage, radius int, offset float64) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_adaptive_threshold_bridge(image, &out, C.int(radius), C.double(offset))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
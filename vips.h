@@ -1,3 +1,4 @@
+#include <math.h>
 #include <stdlib.h>
 #include <string.h>
 #include <vips/vips.h>
@@ -23,6 +24,15 @@
 
 #define INT_TO_GBOOLEAN(bool) (bool > 0 ? TRUE : FALSE)
 
+/**
+ * Load error policy. libvips before 8.12 only supports failing on any warning.
+ */
+#if (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 12))
+#define LOAD_FAIL_ON(fail_on) "fail_on", fail_on
+#else
+#define LOAD_FAIL_ON(fail_on) "fail", INT_TO_GBOOLEAN(fail_on)
+#endif
+
 
 enum types {
 	UNKNOWN = 0,
@@ -35,7 +45,43 @@ enum types {
 	SVG,
 	MAGICK,
 	HEIF,
-	AVIF
+	AVIF,
+	EXR,
+	HDR,
+	RAW
+};
+
+// Keep the values in sync with the Keep constants of options.go
+enum keep {
+	KEEP_EXIF = 1,
+	KEEP_XMP = 2,
+	KEEP_IPTC = 4,
+	KEEP_ICC = 8,
+	KEEP_ORIENTATION = 16
+};
+
+// Operations of vips_pipeline_bridge, each reading PIPELINE_ARGS arguments.
+// Keep the values in sync with the pipelineOp constants of pipeline.go
+enum pipeline {
+	PIPELINE_ROTATE = 1,
+	PIPELINE_FLIP,
+	PIPELINE_GAMMA,
+	PIPELINE_SHARPEN,
+	PIPELINE_BLUR,
+	PIPELINE_BRIGHTNESS,
+	PIPELINE_CONTRAST,
+	PIPELINE_RESIZE
+};
+
+#define PIPELINE_ARGS 6
+
+// Keep the values in sync with the DeepZoomLayout constants of options.go
+enum dz_layout {
+	DZ_LAYOUT_DZ,
+	DZ_LAYOUT_ZOOMIFY,
+	DZ_LAYOUT_GOOGLE,
+	DZ_LAYOUT_IIIF,
+	DZ_LAYOUT_IIIF3
 };
 
 typedef struct {
@@ -109,13 +155,13 @@ vips_affine_interpolator(VipsImage *in, VipsImage **out, double a, double b, dou
 }
 
 int
-vips_jpegload_buffer_shrink(void *buf, size_t len, VipsImage **out, int shrink) {
-	return vips_jpegload_buffer(buf, len, out, "shrink", shrink, NULL);
+vips_jpegload_buffer_shrink(void *buf, size_t len, VipsImage **out, int shrink, int access, int fail_on) {
+	return vips_jpegload_buffer(buf, len, out, "shrink", shrink, "access", access, LOAD_FAIL_ON(fail_on), NULL);
 }
 
 int
-vips_webpload_buffer_shrink(void *buf, size_t len, VipsImage **out, int shrink) {
-	return vips_webpload_buffer(buf, len, out, "shrink", shrink, NULL);
+vips_webpload_buffer_shrink(void *buf, size_t len, VipsImage **out, int shrink, int access, int fail_on) {
+	return vips_webpload_buffer(buf, len, out, "shrink", shrink, "access", access, LOAD_FAIL_ON(fail_on), NULL);
 }
 
 int
@@ -123,6 +169,21 @@ vips_flip_bridge(VipsImage *in, VipsImage **out, int direction) {
 	return vips_flip(in, out, direction, NULL);
 }
 
+int
+vips_heifload_buffer_thumbnail(void *buf, size_t len, VipsImage **out, int access, int fail_on) {
+#if (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 8))
+	return vips_heifload_buffer(buf, len, out, "thumbnail", TRUE, "access", access, LOAD_FAIL_ON(fail_on), NULL);
+#else
+	vips_error("bimg", "HEIF thumbnails require libvips 8.8+");
+	return 1;
+#endif
+}
+
+int
+vips_tiffload_buffer_page(void *buf, size_t len, VipsImage **out, int page, int access, int fail_on) {
+	return vips_tiffload_buffer(buf, len, out, "page", page, "access", access, LOAD_FAIL_ON(fail_on), NULL);
+}
+
 int
 vips_shrink_bridge(VipsImage *in, VipsImage **out, double xshrink, double yshrink) {
 	return vips_shrink(in, out, xshrink, yshrink, NULL);
@@ -133,6 +194,17 @@ vips_reduce_bridge(VipsImage *in, VipsImage **out, double xshrink, double yshrin
 	return vips_reduce(in, out, xshrink, yshrink, NULL);
 }
 
+int
+vips_resize_bridge(VipsImage *in, VipsImage **out, double hscale, double vscale) {
+	return vips_resize(in, out, hscale, "vscale", vscale, NULL);
+}
+
+int
+vips_copy_memory_bridge(VipsImage *in, VipsImage **out) {
+	*out = vips_image_copy_memory(in);
+	return *out == NULL ? -1 : 0;
+}
+
 int
 vips_type_find_bridge(int t) {
 	if (t == GIF) {
@@ -164,6 +236,19 @@ vips_type_find_bridge(int t) {
 		return vips_type_find("VipsOperation", "heifload");
 	}
 #endif
+	if (t == EXR) {
+		return vips_type_find("VipsOperation", "openexrload");
+	}
+#if (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 7))
+	if (t == HDR) {
+		return vips_type_find("VipsOperation", "radload_buffer");
+	}
+#endif
+	if (t == RAW) {
+		// libraw since libvips 8.16, otherwise ImageMagick delegates
+		int type = vips_type_find("VipsOperation", "dcrawload_buffer");
+		return type != 0 ? type : vips_type_find("VipsOperation", "magickload");
+	}
 	return 0;
 }
 
@@ -190,6 +275,11 @@ vips_type_find_save_bridge(int t) {
 	if (t == GIF) {
 		return vips_type_find("VipsOperation", "gifsave_buffer");
 	}
+#endif
+#if (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 7))
+	if (t == HDR) {
+		return vips_type_find("VipsOperation", "radsave_buffer");
+	}
 #endif
 	return 0;
 }
@@ -225,9 +315,67 @@ vips_rotate_bridge(VipsImage *in, VipsImage **out, int angle) {
 	}
 }
 
+int
+vips_reset_orientation_bridge(VipsImage *in, VipsImage **out) {
+	if (vips_copy(in, out, NULL)) {
+		return 1;
+	}
+
+	// Pixels are already upright, so viewers must not rotate them again
+	vips_image_set_int(*out, VIPS_META_ORIENTATION, 1);
+	vips_image_remove(*out, EXIF_IFD0_ORIENTATION);
+	return 0;
+}
+
+int
+vips_keep_metadata_bridge(VipsImage *in, VipsImage **out, int keep) {
+	if (vips_copy(in, out, NULL)) {
+		return 1;
+	}
+
+	if (!(keep & KEEP_ICC)) {
+		vips_image_remove(*out, VIPS_META_ICC_NAME);
+	}
+	if (!(keep & KEEP_XMP)) {
+		vips_image_remove(*out, VIPS_META_XMP_NAME);
+	}
+	if (!(keep & KEEP_IPTC)) {
+		vips_image_remove(*out, VIPS_META_IPTC_NAME);
+	}
+
+	// Savers rebuild a minimal EXIF block with the orientation, if any
+	if (!(keep & KEEP_EXIF)) {
+		char **fields = vips_image_get_fields(*out);
+		for (int i = 0; fields[i] != NULL; i++) {
+			if (vips_isprefix("exif-", fields[i]) && strcmp(fields[i], EXIF_IFD0_ORIENTATION) != 0) {
+				vips_image_remove(*out, fields[i]);
+			}
+		}
+		g_strfreev(fields);
+	}
+
+	if (!(keep & (KEEP_EXIF | KEEP_ORIENTATION))) {
+		vips_image_set_int(*out, VIPS_META_ORIENTATION, 1);
+		vips_image_remove(*out, EXIF_IFD0_ORIENTATION);
+	}
+
+	return 0;
+}
+
 int
 vips_autorot_bridge(VipsImage *in, VipsImage **out) {
-	return vips_autorot(in, out, NULL);
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 1);
+
+	if (
+		vips_autorot(in, &t[0], NULL) ||
+		vips_reset_orientation_bridge(t[0], out)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	g_object_unref(base);
+	return 0;
 }
 
 const char *
@@ -242,6 +390,11 @@ vips_exif_tag(VipsImage *image, const char *tag) {
 	return "";
 }
 
+const char *
+vips_field_name(gchar **fields, int i) {
+	return fields[i];
+}
+
 int
 vips_exif_tag_to_int(VipsImage *image, const char *tag) {
 	int value = 0;
@@ -257,6 +410,75 @@ vips_exif_orientation(VipsImage *image) {
 	return vips_exif_tag_to_int(image, EXIF_IFD0_ORIENTATION);
 }
 
+const void *
+vips_exif_data(VipsImage *image, size_t *length) {
+	const void *data = NULL;
+	if (vips_image_get_typeof(image, VIPS_META_EXIF_NAME) == 0 ||
+		vips_image_get_blob(image, VIPS_META_EXIF_NAME, &data, length)) {
+		return NULL;
+	}
+	return data;
+}
+
+int
+vips_n_pages(VipsImage *image) {
+	int n_pages = 1;
+	if (vips_image_get_typeof(image, "n-pages") != 0) {
+		vips_image_get_int(image, "n-pages", &n_pages);
+	}
+	return n_pages > 0 ? n_pages : 1;
+}
+
+int
+vips_load_frames_bridge(void *buf, size_t len, int imageType, int fail_on, VipsImage **out) {
+	if (imageType == GIF) {
+		return vips_gifload_buffer(buf, len, out, "n", -1, LOAD_FAIL_ON(fail_on), NULL);
+	} else if (imageType == WEBP) {
+		return vips_webpload_buffer(buf, len, out, "n", -1, LOAD_FAIL_ON(fail_on), NULL);
+	}
+
+	vips_error("bimg", "Unsupported animation format");
+	return 1;
+}
+
+int
+vips_page_height_bridge(VipsImage *image) {
+#if (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 8))
+	return vips_image_get_page_height(image);
+#else
+	int page_height = 0;
+
+	if (vips_image_get_typeof(image, "page-height") != 0 &&
+		vips_image_get_int(image, "page-height", &page_height) == 0 &&
+		page_height > 0 && page_height <= image->Ysize && image->Ysize % page_height == 0) {
+		return page_height;
+	}
+	return image->Ysize;
+#endif
+}
+
+// vips_frame_delay returns the delay of the page in milliseconds, or -1
+// when undefined.
+int
+vips_frame_delay(VipsImage *image, int page) {
+	int gif_delay = 0;
+
+#if (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 9))
+	int *delay = NULL;
+	int n = 0;
+
+	if (vips_image_get_typeof(image, "delay") != 0 &&
+		vips_image_get_array_int(image, "delay", &delay, &n) == 0 && page < n) {
+		return delay[page];
+	}
+#endif
+	if (vips_image_get_typeof(image, "gif-delay") != 0 &&
+		vips_image_get_int(image, "gif-delay", &gif_delay) == 0) {
+		return gif_delay * 10;
+	}
+	return -1;
+}
+
 int
 interpolator_window_size(char const *name) {
 	VipsInterpolate *interpolator = vips_interpolate_new(name);
@@ -324,27 +546,94 @@ vips_icc_transform_with_default_bridge (VipsImage *in, VipsImage **out, const ch
 }
 
 int
-vips_jpegsave_bridge(VipsImage *in, void **buf, size_t *len, int strip, int quality, int interlace) {
+vips_jpegsave_bridge(VipsImage *in, void **buf, size_t *len, int strip, int quality, int interlace, int subsample, int trellis, int deringing, int optimize_scans, int quant_table, int optimize_coding) {
+#if (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 11))
+	// Subsample modes match VipsForeignSubsample values: auto, on and off
+	return vips_jpegsave_buffer(in, buf, len,
+		"strip", INT_TO_GBOOLEAN(strip),
+		"Q", quality,
+		"optimize_coding", INT_TO_GBOOLEAN(optimize_coding),
+		"interlace", INT_TO_GBOOLEAN(interlace),
+		"subsample_mode", subsample,
+		"trellis_quant", INT_TO_GBOOLEAN(trellis),
+		"overshoot_deringing", INT_TO_GBOOLEAN(deringing),
+		"optimize_scans", INT_TO_GBOOLEAN(optimize_scans),
+		"quant_table", quant_table,
+		NULL
+	);
+#elif (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 6)
 	return vips_jpegsave_buffer(in, buf, len,
 		"strip", INT_TO_GBOOLEAN(strip),
 		"Q", quality,
-		"optimize_coding", TRUE,
+		"optimize_coding", INT_TO_GBOOLEAN(optimize_coding),
 		"interlace", INT_TO_GBOOLEAN(interlace),
+		"no_subsample", INT_TO_GBOOLEAN(subsample == 2),
+		"trellis_quant", INT_TO_GBOOLEAN(trellis),
+		"overshoot_deringing", INT_TO_GBOOLEAN(deringing),
+		"optimize_scans", INT_TO_GBOOLEAN(optimize_scans),
+		"quant_table", quant_table,
 		NULL
 	);
+#else
+	return vips_jpegsave_buffer(in, buf, len,
+		"strip", INT_TO_GBOOLEAN(strip),
+		"Q", quality,
+		"optimize_coding", INT_TO_GBOOLEAN(optimize_coding),
+		"interlace", INT_TO_GBOOLEAN(interlace),
+		NULL
+	);
+#endif
 }
 
 int
-vips_pngsave_bridge(VipsImage *in, void **buf, size_t *len, int strip, int compression, int quality, int interlace, int palette, int speed) {
+vips_pngsave_bridge(VipsImage *in, void **buf, size_t *len, int strip, int compression, int quality, int interlace, int palette, int speed, int filter, int bitdepth, double dither) {
 #if (VIPS_MAJOR_VERSION >= 8 && VIPS_MINOR_VERSION >= 7)
 	int effort = 10 - speed;
+	if (filter == 0) {
+		filter = VIPS_FOREIGN_PNG_FILTER_ALL;
+	}
+	if (dither < 0) {
+		dither = 0;
+	} else if (dither == 0) {
+		dither = 1.0;
+	}
+	if (bitdepth > 0) {
+#if (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 10))
+		return vips_pngsave_buffer(in, buf, len,
+			"strip", INT_TO_GBOOLEAN(strip),
+			"compression", compression,
+			"interlace", INT_TO_GBOOLEAN(interlace),
+			"filter", filter,
+			"palette", INT_TO_GBOOLEAN(palette),
+			"Q", quality,
+			"dither", dither,
+			"bitdepth", bitdepth,
+			"effort", effort,
+			NULL
+		);
+#else
+		return vips_pngsave_buffer(in, buf, len,
+			"strip", INT_TO_GBOOLEAN(strip),
+			"compression", compression,
+			"interlace", INT_TO_GBOOLEAN(interlace),
+			"filter", filter,
+			"palette", INT_TO_GBOOLEAN(palette),
+			"Q", quality,
+			"dither", dither,
+			"colours", 1 << bitdepth,
+			"effort", effort,
+			NULL
+		);
+#endif
+	}
 	return vips_pngsave_buffer(in, buf, len,
 		"strip", INT_TO_GBOOLEAN(strip),
 		"compression", compression,
 		"interlace", INT_TO_GBOOLEAN(interlace),
-		"filter", VIPS_FOREIGN_PNG_FILTER_ALL,
+		"filter", filter,
 		"palette", INT_TO_GBOOLEAN(palette),
 		"Q", quality,
+		"dither", dither,
 		"effort", effort,
 		NULL
 	);
@@ -359,25 +648,123 @@ vips_pngsave_bridge(VipsImage *in, void **buf, size_t *len, int strip, int compr
 }
 
 int
-vips_webpsave_bridge(VipsImage *in, void **buf, size_t *len, int strip, int quality, int lossless) {
+vips_webpsave_bridge(VipsImage *in, void **buf, size_t *len, int strip, int quality, int lossless, int near_lossless, int alpha_q, int effort, int smart_subsample, int kmin, int kmax) {
+#if (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 12))
+	return vips_webpsave_buffer(in, buf, len,
+		"strip", INT_TO_GBOOLEAN(strip),
+		"Q", quality,
+		"lossless", INT_TO_GBOOLEAN(lossless),
+		"near_lossless", INT_TO_GBOOLEAN(near_lossless),
+		"alpha_q", alpha_q,
+		"effort", effort,
+		"smart_subsample", INT_TO_GBOOLEAN(smart_subsample),
+		"kmin", kmin,
+		"kmax", kmax,
+		NULL
+	);
+#elif (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 8)
+	return vips_webpsave_buffer(in, buf, len,
+		"strip", INT_TO_GBOOLEAN(strip),
+		"Q", quality,
+		"lossless", INT_TO_GBOOLEAN(lossless),
+		"near_lossless", INT_TO_GBOOLEAN(near_lossless),
+		"alpha_q", alpha_q,
+		"reduction_effort", effort,
+		"smart_subsample", INT_TO_GBOOLEAN(smart_subsample),
+		"kmin", kmin,
+		"kmax", kmax,
+		NULL
+	);
+#else
 	return vips_webpsave_buffer(in, buf, len,
 		"strip", INT_TO_GBOOLEAN(strip),
 		"Q", quality,
 		"lossless", INT_TO_GBOOLEAN(lossless),
-		"kmax", 1,
+		"near_lossless", INT_TO_GBOOLEAN(near_lossless),
+		"alpha_q", alpha_q,
+		"smart_subsample", INT_TO_GBOOLEAN(smart_subsample),
+		"kmin", kmin,
+		"kmax", kmax,
 		NULL
 	);
+#endif
 }
 
 int
-vips_tiffsave_bridge(VipsImage *in, void **buf, size_t *len) {
+vips_tiffsave_bridge(VipsImage *in, void **buf, size_t *len, int quality, int compression, int predictor, int tile, int tile_width, int tile_height, int pyramid, int bigtiff) {
 #if (VIPS_MAJOR_VERSION >= 8 && VIPS_MINOR_VERSION >= 5)
-	return vips_tiffsave_buffer(in, buf, len, NULL);
+	if (predictor == 0) {
+		predictor = VIPS_FOREIGN_TIFF_PREDICTOR_HORIZONTAL;
+	}
+	return vips_tiffsave_buffer(in, buf, len,
+		"Q", quality,
+		"compression", compression,
+		"predictor", predictor,
+		"tile", INT_TO_GBOOLEAN(tile),
+		"tile_width", tile_width,
+		"tile_height", tile_height,
+		"pyramid", INT_TO_GBOOLEAN(pyramid),
+		"bigtiff", INT_TO_GBOOLEAN(bigtiff),
+		NULL
+	);
 #else
 	return 0;
 #endif
 }
 
+static int
+vips_dz_layout_check(int layout) {
+#if (VIPS_MAJOR_VERSION < 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION < 10))
+	if (layout == DZ_LAYOUT_IIIF) {
+		vips_error("bimg", "IIIF tile pyramids require libvips 8.10+");
+		return 1;
+	}
+#endif
+#if (VIPS_MAJOR_VERSION < 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION < 11))
+	if (layout == DZ_LAYOUT_IIIF3) {
+		vips_error("bimg", "IIIF 3 tile pyramids require libvips 8.11+");
+		return 1;
+	}
+#endif
+	return 0;
+}
+
+int
+vips_dzsave_bridge(VipsImage *in, const char *path, int layout, const char *suffix, int tile_size, int overlap) {
+	if (vips_dz_layout_check(layout)) {
+		return 1;
+	}
+
+	return vips_dzsave(in, path,
+		"layout", layout,
+		"suffix", suffix,
+		"tile_size", tile_size,
+		"overlap", overlap,
+		NULL
+	);
+}
+
+int
+vips_dzsave_buffer_bridge(VipsImage *in, void **buf, size_t *len, int layout, const char *suffix, int tile_size, int overlap) {
+#if (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 7))
+	if (vips_dz_layout_check(layout)) {
+		return 1;
+	}
+
+	return vips_dzsave_buffer(in, buf, len,
+		"layout", layout,
+		"suffix", suffix,
+		"tile_size", tile_size,
+		"overlap", overlap,
+		"container", VIPS_FOREIGN_DZ_CONTAINER_ZIP,
+		NULL
+	);
+#else
+	vips_error("bimg", "In memory tile pyramids require libvips 8.7+");
+	return 1;
+#endif
+}
+
 int
 vips_avifsave_bridge(VipsImage *in, void **buf, size_t *len, int strip, int quality, int lossless, int speed) {
 #if (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION >= 8 && VIPS_MINOR_VERSION > 10) || (VIPS_MAJOR_VERSION >= 8 && VIPS_MINOR_VERSION >= 10 && VIPS_MICRO_VERSION >= 2))
@@ -417,10 +804,27 @@ vips_heifsave_bridge(VipsImage *in, void **buf, size_t *len, int strip, int qual
 }
 
 int
-vips_gifsave_bridge(VipsImage *in, void **buf, size_t *len, int strip) {
-#if (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 12))
-	return vips_gifsave_buffer(in, buf, len, 
+vips_gifsave_bridge(VipsImage *in, void **buf, size_t *len, int strip, int bitdepth, double dither, int effort, double interframe_maxerror) {
+	if (dither < 0) {
+		dither = 0;
+	} else if (dither == 0) {
+		dither = 1.0;
+	}
+#if (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 13))
+	return vips_gifsave_buffer(in, buf, len,
 		"strip", INT_TO_GBOOLEAN(strip),
+		"bitdepth", bitdepth,
+		"dither", dither,
+		"effort", effort,
+		"interframe_maxerror", interframe_maxerror,
+		NULL
+	);
+#elif (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 12)
+	return vips_gifsave_buffer(in, buf, len,
+		"strip", INT_TO_GBOOLEAN(strip),
+		"bitdepth", bitdepth,
+		"dither", dither,
+		"effort", effort,
 		NULL
 	);
 #else
@@ -428,6 +832,31 @@ vips_gifsave_bridge(VipsImage *in, void **buf, size_t *len, int strip) {
 #endif
 }
 
+extern void bimgLog(char *domain, int level, char *message);
+
+static void
+vips_log_handler(const gchar *domain, GLogLevelFlags level, const gchar *message, gpointer data) {
+	bimgLog((char *) domain, (int) (level & G_LOG_LEVEL_MASK), (char *) message);
+}
+
+static const char *vips_log_domains[] = { "VIPS", "GLib", "GLib-GObject" };
+static guint vips_log_handlers[3] = { 0, 0, 0 };
+
+void
+vips_set_log_handler(int enabled) {
+	int i;
+	for (i = 0; i < 3; i++) {
+		if (vips_log_handlers[i] != 0) {
+			g_log_remove_handler(vips_log_domains[i], vips_log_handlers[i]);
+			vips_log_handlers[i] = 0;
+		}
+		if (enabled) {
+			vips_log_handlers[i] = g_log_set_handler(vips_log_domains[i],
+				G_LOG_LEVEL_MASK | G_LOG_FLAG_FATAL | G_LOG_FLAG_RECURSION, vips_log_handler, NULL);
+		}
+	}
+}
+
 int
 vips_is_16bit (VipsInterpretation interpretation) {
 	return interpretation == VIPS_INTERPRETATION_RGB16 || interpretation == VIPS_INTERPRETATION_GREY16;
@@ -452,84 +881,257 @@ vips_flatten_background_brigde(VipsImage *in, VipsImage **out, double r, double
 }
 
 int
-vips_init_image (void *buf, size_t len, int imageType, VipsImage **out) {
+vips_border_bridge(VipsImage *in, VipsImage **out, int top, int right, int bottom, int left, int extend, double r, double g, double b, double a) {
+	int width = in->Xsize + left + right;
+	int height = in->Ysize + top + bottom;
+
+	if (extend != VIPS_EXTEND_BACKGROUND) {
+		return vips_embed(in, out, left, top, width, height, "extend", extend, NULL);
+	}
+
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 2);
+	VipsImage *image = in;
+
+	// Grey images are converted to paint a coloured border
+	if (image->Bands < 3 && (r != g || g != b)) {
+		if (vips_colourspace(image, &t[0], VIPS_INTERPRETATION_sRGB, NULL)) {
+			g_object_unref(base);
+			return 1;
+		}
+		image = t[0];
+	}
+
+	// A translucent border needs an alpha channel
+	if (a < 255 && !has_alpha_channel(image)) {
+		if (vips_addalpha(image, &t[1], NULL)) {
+			g_object_unref(base);
+			return 1;
+		}
+		image = t[1];
+	}
+
+	double scale = vips_is_16bit(image->Type) ? 65535.0 / 255.0 : 1.0;
+	double background[4];
+	int n = image->Bands;
+	if (n < 3) {
+		background[0] = r * scale;
+		background[1] = a * scale;
+	} else {
+		background[0] = r * scale;
+		background[1] = g * scale;
+		background[2] = b * scale;
+		background[3] = a * scale;
+	}
+	if (n > 4) {
+		n = 4;
+	}
+
+	VipsArrayDouble *vipsBackground = vips_array_double_new(background, n);
+	int err = vips_embed(image, out, left, top, width, height, "extend", extend, "background", vipsBackground, NULL);
+	vips_area_unref(VIPS_AREA(vipsBackground));
+	g_object_unref(base);
+	return err;
+}
+
+int
+vips_init_image (void *buf, size_t len, int imageType, int access, int fail_on, VipsImage **out) {
 	int code = 1;
 
 	if (imageType == JPEG) {
-		code = vips_jpegload_buffer(buf, len, out, "access", VIPS_ACCESS_RANDOM, NULL);
+		code = vips_jpegload_buffer(buf, len, out, "access", access, LOAD_FAIL_ON(fail_on), NULL);
 	} else if (imageType == PNG) {
-		code = vips_pngload_buffer(buf, len, out, "access", VIPS_ACCESS_RANDOM, NULL);
+		code = vips_pngload_buffer(buf, len, out, "access", access, LOAD_FAIL_ON(fail_on), NULL);
 	} else if (imageType == WEBP) {
-		code = vips_webpload_buffer(buf, len, out, "access", VIPS_ACCESS_RANDOM, NULL);
+		code = vips_webpload_buffer(buf, len, out, "access", access, LOAD_FAIL_ON(fail_on), NULL);
 	} else if (imageType == TIFF) {
-		code = vips_tiffload_buffer(buf, len, out, "access", VIPS_ACCESS_RANDOM, NULL);
+		code = vips_tiffload_buffer(buf, len, out, "access", access, LOAD_FAIL_ON(fail_on), NULL);
 #if (VIPS_MAJOR_VERSION >= 8)
 #if (VIPS_MINOR_VERSION >= 3)
 	} else if (imageType == GIF) {
-		code = vips_gifload_buffer(buf, len, out, "access", VIPS_ACCESS_RANDOM, NULL);
+		code = vips_gifload_buffer(buf, len, out, "access", access, LOAD_FAIL_ON(fail_on), NULL);
 	} else if (imageType == PDF) {
-		code = vips_pdfload_buffer(buf, len, out, "access", VIPS_ACCESS_RANDOM, NULL);
+		code = vips_pdfload_buffer(buf, len, out, "access", access, LOAD_FAIL_ON(fail_on), NULL);
 	} else if (imageType == SVG) {
-		code = vips_svgload_buffer(buf, len, out, "access", VIPS_ACCESS_RANDOM, NULL);
+		code = vips_svgload_buffer(buf, len, out, "access", access, LOAD_FAIL_ON(fail_on), NULL);
 #endif
 	} else if (imageType == MAGICK) {
-		code = vips_magickload_buffer(buf, len, out, "access", VIPS_ACCESS_RANDOM, NULL);
+		code = vips_magickload_buffer(buf, len, out, "access", access, LOAD_FAIL_ON(fail_on), NULL);
 #endif
 #if (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 8))
 	} else if (imageType == HEIF) {
-		code = vips_heifload_buffer(buf, len, out, "access", VIPS_ACCESS_RANDOM, NULL);
+		code = vips_heifload_buffer(buf, len, out, "access", access, LOAD_FAIL_ON(fail_on), NULL);
 #endif
 #if (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 9)
 	} else if (imageType == AVIF) {
-		code = vips_heifload_buffer(buf, len, out, "access", VIPS_ACCESS_RANDOM, NULL);
+		code = vips_heifload_buffer(buf, len, out, "access", access, LOAD_FAIL_ON(fail_on), NULL);
+#endif
+#if (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 7))
+	} else if (imageType == HDR) {
+		code = vips_radload_buffer(buf, len, out, "access", access, LOAD_FAIL_ON(fail_on), NULL);
 #endif
+	} else if (imageType == RAW) {
+#if (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 16))
+		if (vips_type_find("VipsOperation", "dcrawload_buffer") != 0) {
+			code = vips_dcrawload_buffer(buf, len, out, "access", access, LOAD_FAIL_ON(fail_on), NULL);
+		} else
+#endif
+		code = vips_magickload_buffer(buf, len, out, "access", access, LOAD_FAIL_ON(fail_on), NULL);
 	}
 
 	return code;
 }
 
+// vips_operation_has_argument reports whether a libvips operation takes
+// the named argument, which depends on the libvips and delegates versions.
+static int
+vips_operation_has_argument(const char *nickname, const char *name) {
+	VipsOperation *op = vips_operation_new(nickname);
+	if (op == NULL) {
+		vips_error_clear();
+		return 0;
+	}
+	int found = g_object_class_find_property(G_OBJECT_GET_CLASS(op), name) != NULL;
+	g_object_unref(op);
+	return found;
+}
+
 int
-vips_watermark_replicate (VipsImage *orig, VipsImage *in, VipsImage **out) {
-	VipsImage *cache = vips_image_new();
+vips_rawload_bridge(void *buf, size_t len, int access, int fail_on, int half_size, VipsImage **out) {
+#if (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 16))
+	// libraw skips the demosaicing of half size images
+	if (half_size && vips_operation_has_argument("dcrawload_buffer", "half_size")) {
+		return vips_dcrawload_buffer(buf, len, out, "access", access, LOAD_FAIL_ON(fail_on), "half_size", TRUE, NULL);
+	}
+#endif
+	if (!half_size) {
+		return vips_init_image(buf, len, RAW, access, fail_on, out);
+	}
 
+	// Otherwise average the demosaiced pixels by blocks of 2x2
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 1);
 	if (
-		vips_replicate(in, &cache,
-			1 + orig->Xsize / in->Xsize,
-			1 + orig->Ysize / in->Ysize, NULL) ||
-		vips_crop(cache, out, 0, 0, orig->Xsize, orig->Ysize, NULL)
-	) {
-		g_object_unref(cache);
+		vips_init_image(buf, len, RAW, access, fail_on, &t[0]) ||
+		vips_shrink(t[0], out, 2.0, 2.0, NULL)) {
+		g_object_unref(base);
 		return 1;
 	}
-
-	g_object_unref(cache);
+	g_object_unref(base);
 	return 0;
 }
 
 int
-vips_watermark(VipsImage *in, VipsImage **out, WatermarkTextOptions *to, WatermarkOptions *o) {
-	double ones[3] = { 1, 1, 1 };
-
+vips_white_balance_bridge(VipsImage *in, VipsImage **out, double red, double green, double blue, int grey_world) {
 	VipsImage *base = vips_image_new();
-	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 10);
-	t[0] = in;
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 5);
+	VipsInterpretation interpretation = in->Type == VIPS_INTERPRETATION_RGB16 ? VIPS_INTERPRETATION_RGB16 : VIPS_INTERPRETATION_sRGB;
+	double gains[4] = {red, green, blue, 1.0};
+	double offsets[4] = {0.0, 0.0, 0.0, 0.0};
+	int n = VIPS_MIN(in->Bands, 4);
+
+	// The gains apply to the linear light
+	if (in->Bands < 3 || vips_colourspace(in, &t[0], VIPS_INTERPRETATION_scRGB, NULL)) {
+		if (in->Bands < 3) {
+			vips_error("bimg", "White balance requires a colour image");
+		}
+		g_object_unref(base);
+		return 1;
+	}
+
+	// Scale the red and blue bands so they average to the green one
+	if (grey_world) {
+		double means[3];
+		for (int i = 0; i < 3; i++) {
+			if (vips_extract_band(t[0], &t[1 + i], i, NULL) || vips_avg(t[1 + i], &means[i], NULL)) {
+				g_object_unref(base);
+				return 1;
+			}
+		}
+		for (int i = 0; i < 3; i++) {
+			gains[i] = means[i] > 0 ? means[1] / means[i] : 1.0;
+		}
+	}
 
-	// Make the mask.
 	if (
-		vips_text(&t[1], to->Text,
-			"width", o->Width,
-			"dpi", o->DPI,
-			"font", to->Font,
-			NULL) ||
-		vips_linear1(t[1], &t[2], o->Opacity, 0.0, NULL) ||
-		vips_cast(t[2], &t[3], VIPS_FORMAT_UCHAR, NULL) ||
-		vips_embed(t[3], &t[4], 100, 100, t[3]->Xsize + o->Margin, t[3]->Ysize + o->Margin, NULL)
-		) {
+		vips_linear(t[0], &t[4], gains, offsets, n, NULL) ||
+		vips_colourspace(t[4], out, interpretation, NULL)) {
 		g_object_unref(base);
 		return 1;
 	}
+	g_object_unref(base);
+	return 0;
+}
 
-	// Replicate if necessary
+int
+vips_openexrload_bridge(const char *filename, VipsImage **out) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 1);
+
+	// OpenEXR can only be loaded from files, decode it at once so the
+	// temporary file can be removed straight away
+	if (vips_openexrload(filename, &t[0], NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	*out = vips_image_copy_memory(t[0]);
+	g_object_unref(base);
+	return *out == NULL ? 1 : 0;
+}
+
+int
+vips_radsave_bridge(VipsImage *in, void **buf, size_t *len) {
+#if (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 7))
+	return vips_radsave_buffer(in, buf, len, NULL);
+#else
+	vips_error("bimg", "Radiance HDR saving requires libvips 8.7+");
+	return 1;
+#endif
+}
+
+int
+vips_watermark_replicate (VipsImage *orig, VipsImage *in, VipsImage **out) {
+	VipsImage *cache = vips_image_new();
+
+	if (
+		vips_replicate(in, &cache,
+			1 + orig->Xsize / in->Xsize,
+			1 + orig->Ysize / in->Ysize, NULL) ||
+		vips_crop(cache, out, 0, 0, orig->Xsize, orig->Ysize, NULL)
+	) {
+		g_object_unref(cache);
+		return 1;
+	}
+
+	g_object_unref(cache);
+	return 0;
+}
+
+int
+vips_watermark(VipsImage *in, VipsImage **out, WatermarkTextOptions *to, WatermarkOptions *o) {
+	double ones[3] = { 1, 1, 1 };
+
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 10);
+	t[0] = in;
+
+	// Make the mask.
+	if (
+		vips_text(&t[1], to->Text,
+			"width", o->Width,
+			"dpi", o->DPI,
+			"font", to->Font,
+			NULL) ||
+		vips_linear1(t[1], &t[2], o->Opacity, 0.0, NULL) ||
+		vips_cast(t[2], &t[3], VIPS_FORMAT_UCHAR, NULL) ||
+		vips_embed(t[3], &t[4], 100, 100, t[3]->Xsize + o->Margin, t[3]->Ysize + o->Margin, NULL)
+		) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	// Replicate if necessary
 	if (o->NoReplicate != 1) {
 		VipsImage *cache = vips_image_new();
 		if (vips_watermark_replicate(t[0], t[4], &cache)) {
@@ -572,6 +1174,129 @@ vips_gaussblur_bridge(VipsImage *in, VipsImage **out, double sigma, double min_a
 #endif
 }
 
+int
+vips_gaussblur_region_bridge(VipsImage *in, VipsImage **out, double sigma, double min_ampl, int left, int top, int width, int height) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 4);
+
+	// Blur the area with a margin taken from the image, so its edges are
+	// blurred as if the whole image was
+	int margin = (int) VIPS_RINT(sigma * 3) + 1;
+	int x0 = VIPS_MAX(left - margin, 0);
+	int y0 = VIPS_MAX(top - margin, 0);
+	int x1 = VIPS_MIN(left + width + margin, in->Xsize);
+	int y1 = VIPS_MIN(top + height + margin, in->Ysize);
+
+	if (
+		vips_extract_area(in, &t[0], x0, y0, x1 - x0, y1 - y0, NULL) ||
+		vips_gaussblur_bridge(t[0], &t[1], sigma, min_ampl) ||
+		vips_extract_area(t[1], &t[2], left - x0, top - y0, width, height, NULL) ||
+		vips_cast(t[2], &t[3], in->BandFmt, NULL) ||
+		vips_insert(in, t[3], out, left, top, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	g_object_unref(base);
+	return 0;
+}
+
+int
+vips_gaussblur_mask_bridge(VipsImage *in, VipsImage *mask, VipsImage **out, double sigma, double min_ampl) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 6);
+
+	// Blend the blurred image over the original, using the mask luminance
+	// scaled to the image size
+	if (
+		vips_gaussblur_bridge(in, &t[0], sigma, min_ampl) ||
+		vips_cast(t[0], &t[1], in->BandFmt, NULL) ||
+		vips_colourspace(mask, &t[2], VIPS_INTERPRETATION_B_W, NULL) ||
+		vips_extract_band(t[2], &t[3], 0, NULL) ||
+		vips_resize(t[3], &t[4], (double) in->Xsize / t[3]->Xsize, "vscale", (double) in->Ysize / t[3]->Ysize, NULL) ||
+		vips_cast(t[4], &t[5], VIPS_FORMAT_UCHAR, NULL) ||
+		vips_ifthenelse(t[5], t[1], in, out, "blend", TRUE, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	g_object_unref(base);
+	return 0;
+}
+
+int
+vips_motion_blur_bridge(VipsImage *in, VipsImage **out, double angle, int distance) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 3);
+
+	// Rasterise the line through the centre of an odd sized kernel
+	int size = distance | 1;
+	double centre = (size - 1) / 2.0;
+	double dx = cos(angle * VIPS_PI / 180);
+	double dy = -sin(angle * VIPS_PI / 180);
+	int i, count = 0;
+
+	t[0] = vips_image_new_matrix(size, size);
+	for (i = 0; i <= 2 * (size - 1); i++) {
+		double d = i / 2.0 - centre;
+		int x = (int) VIPS_RINT(centre + d * dx);
+		int y = (int) VIPS_RINT(centre + d * dy);
+		if (*VIPS_MATRIX(t[0], x, y) == 0) {
+			*VIPS_MATRIX(t[0], x, y) = 1;
+			count++;
+		}
+	}
+	vips_image_set_double(t[0], "scale", count);
+
+	if (
+		vips_conv(in, &t[1], t[0], "precision", VIPS_PRECISION_FLOAT, NULL) ||
+		vips_cast(t[1], out, in->BandFmt, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	g_object_unref(base);
+	return 0;
+}
+
+int
+vips_radial_blur_bridge(VipsImage *in, VipsImage **out, int cx, int cy, double amount) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 35);
+	VipsArrayInt *area = vips_array_int_newv(4, 0, 0, in->Xsize, in->Ysize);
+	int err = 0;
+	int i;
+
+	// Average copies zoomed about the centre, a step apart at most every
+	// couple of pixels at the farthest edge
+	int steps = VIPS_CLIP(2, (int) (amount * VIPS_MAX(in->Xsize, in->Ysize) / 2), 32);
+
+	for (i = 0; i < steps && !err; i++) {
+		double scale = 1 + amount * i / (steps - 1);
+		err = vips_affine(in, &t[i], scale, 0, 0, scale,
+			"idx", (double) -cx,
+			"idy", (double) -cy,
+			"odx", (double) cx,
+			"ody", (double) cy,
+			"oarea", area,
+			"extend", VIPS_EXTEND_COPY,
+			NULL);
+	}
+	vips_area_unref(VIPS_AREA(area));
+
+	if (
+		err ||
+		vips_sum(t, &t[32], steps, NULL) ||
+		vips_linear1(t[32], &t[33], 1.0 / steps, 0, NULL) ||
+		vips_cast(t[33], out, in->BandFmt, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	g_object_unref(base);
+	return 0;
+}
+
 int
 vips_sharpen_bridge(VipsImage *in, VipsImage **out, int radius, double x1, double y2, double y3, double m1, double m2) {
 #if (VIPS_MAJOR_VERSION == 7 && VIPS_MINOR_VERSION < 41)
@@ -582,100 +1307,1148 @@ vips_sharpen_bridge(VipsImage *in, VipsImage **out, int radius, double x1, doubl
 }
 
 int
-vips_add_band(VipsImage *in, VipsImage **out, double c) {
-#if (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION >= 8 && VIPS_MINOR_VERSION >= 2))
-	return vips_bandjoin_const1(in, out, c, NULL);
-#else
+vips_median_bridge(VipsImage *in, VipsImage **out, int size) {
+	return vips_rank(in, out, size, size, (size * size) / 2, NULL);
+}
+
+int
+vips_sobel_bridge(VipsImage *in, VipsImage **out) {
+#if (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 9))
 	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 2);
+
 	if (
-		vips_black(&base, in->Xsize, in->Ysize, NULL) ||
-		vips_linear1(base, &base, 1, c, NULL)) {
-			g_object_unref(base);
-			return 1;
-		}
+		vips_colourspace(in, &t[0], VIPS_INTERPRETATION_B_W, NULL) ||
+		vips_extract_band(t[0], &t[1], 0, NULL) ||
+		vips_sobel(t[1], out, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
 	g_object_unref(base);
-	return vips_bandjoin2(in, base, out, c, NULL);
+	return 0;
+#else
+	vips_error("bimg", "sobel edge detection requires libvips 8.9+");
+	return 1;
 #endif
 }
 
 int
-vips_watermark_image(VipsImage *in, VipsImage *sub, VipsImage **out, WatermarkImageOptions *o) {
+vips_canny_bridge(VipsImage *in, VipsImage **out, double sigma) {
+#if (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 9))
 	VipsImage *base = vips_image_new();
-	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 10);
-
-  // add in and sub for unreffing and later use
-	t[0] = in;
-	t[1] = sub;
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 3);
 
-  if (has_alpha_channel(in) == 0) {
-		vips_add_band(in, &t[0], 255.0);
-		// in is no longer in the array and won't be unreffed, so add it at the end
-		t[8] = in;
+	if (
+		vips_colourspace(in, &t[0], VIPS_INTERPRETATION_B_W, NULL) ||
+		vips_extract_band(t[0], &t[1], 0, NULL) ||
+		vips_canny(t[1], &t[2], "sigma", sigma, NULL) ||
+		vips_cast(t[2], out, VIPS_FORMAT_UCHAR, NULL)) {
+		g_object_unref(base);
+		return 1;
 	}
 
-	if (has_alpha_channel(sub) == 0) {
-		vips_add_band(sub, &t[1], 255.0);
-		// sub is no longer in the array and won't be unreffed, so add it at the end
-		t[9] = sub;
+	g_object_unref(base);
+	return 0;
+#else
+	vips_error("bimg", "canny edge detection requires libvips 8.9+");
+	return 1;
+#endif
+}
+
+int
+vips_sharpen_sigma_bridge(VipsImage *in, VipsImage **out, double sigma, double x1, double y2, double y3, double m1, double m2) {
+#if (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 5))
+	return vips_sharpen(in, out, "sigma", sigma, "x1", x1, "y2", y2, "y3", y3, "m1", m1, "m2", m2, NULL);
+#else
+	return vips_sharpen(in, out, "radius", (int) (sigma * 2), "x1", x1, "y2", y2, "y3", y3, "m1", m1, "m2", m2, NULL);
+#endif
+}
+
+static VipsInterpretation
+vips_bands_interpretation(VipsImage *in) {
+	int is16 = in->BandFmt == VIPS_FORMAT_USHORT;
+	if (in->Bands < 3) {
+		return is16 ? VIPS_INTERPRETATION_GREY16 : VIPS_INTERPRETATION_B_W;
 	}
+	return is16 ? VIPS_INTERPRETATION_RGB16 : VIPS_INTERPRETATION_sRGB;
+}
 
-	// Place watermark image in the right place and size it to the size of the
-	// image that should be watermarked
+int
+vips_extract_band_bridge(VipsImage *in, VipsImage **out, int index, int n) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 1);
+
+	// Single bands lose their colour meaning, mark them as greyscale
 	if (
-		vips_embed(t[1], &t[2], o->Left, o->Top, t[0]->Xsize, t[0]->Ysize, NULL)) {
-			g_object_unref(base);
+		vips_extract_band(in, &t[0], index, "n", n, NULL) ||
+		vips_copy(t[0], out, "interpretation", n < 3 ? vips_bands_interpretation(t[0]) : in->Type, NULL)) {
+		g_object_unref(base);
 		return 1;
 	}
 
-	// Create a mask image based on the alpha band from the watermark image
-	// and place it in the right position
-	if (
-		vips_extract_band(t[1], &t[3], t[1]->Bands - 1, "n", 1, NULL) ||
-		vips_linear1(t[3], &t[4], o->Opacity, 0.0, NULL) ||
-		vips_cast(t[4], &t[5], VIPS_FORMAT_UCHAR, NULL) ||
-		vips_copy(t[5], &t[6], "interpretation", t[0]->Type, NULL) ||
-		vips_embed(t[6], &t[7], o->Left, o->Top, t[0]->Xsize, t[0]->Ysize, NULL))	{
+	g_object_unref(base);
+	return 0;
+}
+
+int
+vips_bandjoin_bridge(VipsImage **in, VipsImage **out, int n) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 1);
+
+	if (vips_bandjoin(in, &t[0], n, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	// Joined greyscale bands are treated as a colour image
+	if (in[0]->Bands < 3 && t[0]->Bands >= 3) {
+		if (vips_copy(t[0], out, "interpretation", vips_bands_interpretation(t[0]), NULL)) {
 			g_object_unref(base);
+			return 1;
+		}
+	} else {
+		*out = t[0];
+		g_object_ref(*out);
+	}
+
+	g_object_unref(base);
+	return 0;
+}
+
+// vips_cells_prepare converts the images to sRGB, 8-bit and adds an alpha
+// channel to all of them when any has one, so they can be joined. The
+// returned images are owned by base.
+static VipsImage **
+vips_cells_prepare(VipsImage *base, VipsImage **in, int n, int *alpha) {
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 3 * n);
+	int i;
+
+	*alpha = 0;
+	for (i = 0; i < n; i++) {
+		*alpha |= has_alpha_channel(in[i]);
+	}
+
+	for (i = 0; i < n; i++) {
+		if (vips_colourspace(in[i], &t[i], VIPS_INTERPRETATION_sRGB, NULL) ||
+			vips_cast(t[i], &t[n + i], VIPS_FORMAT_UCHAR, NULL)) {
+			return NULL;
+		}
+		if (*alpha && t[n + i]->Bands == 3) {
+			if (vips_bandjoin_const1(t[n + i], &t[2 * n + i], 255.0, NULL)) {
+				return NULL;
+			}
+		} else {
+			t[2 * n + i] = t[n + i];
+			g_object_ref(t[2 * n + i]);
+		}
+	}
+
+	return &t[2 * n];
+}
+
+// vips_cells_background returns the background of joined images, which
+// is transparent for images with alpha channel.
+static VipsArrayDouble *
+vips_cells_background(int alpha, double r, double g, double b) {
+	if (alpha) {
+		double rgba[4] = {r, g, b, 0.0};
+		return vips_array_double_new(rgba, 4);
+	}
+	double rgb[3] = {r, g, b};
+	return vips_array_double_new(rgb, 3);
+}
+
+int
+vips_arrayjoin_bridge(VipsImage **in, VipsImage **out, int n, int across, int shim, int hspacing, int vspacing, double r, double g, double b) {
+	VipsImage *base = vips_image_new();
+	VipsArrayDouble *background;
+	VipsImage **cells;
+	int alpha, err;
+
+	cells = vips_cells_prepare(base, in, n, &alpha);
+	if (cells == NULL) {
+		g_object_unref(base);
 		return 1;
 	}
 
-	// Blend the mask and watermark image and write to output.
-	if (vips_ifthenelse(t[7], t[2], t[0], out, "blend", TRUE, NULL)) {
+	background = vips_cells_background(alpha, r, g, b);
+	err = vips_arrayjoin(cells, out, n,
+		"across", across,
+		"shim", shim,
+		"background", background,
+		"halign", VIPS_ALIGN_CENTRE,
+		"valign", VIPS_ALIGN_CENTRE,
+		"hspacing", hspacing,
+		"vspacing", vspacing,
+		NULL);
+
+	vips_area_unref(VIPS_AREA(background));
+	g_object_unref(base);
+	return err;
+}
+
+int
+vips_join_bridge(VipsImage *a, VipsImage *b, VipsImage **out, int direction, int expand, int shim, int align, double r, double g, double bg) {
+	VipsImage *base = vips_image_new();
+	VipsImage *in[2] = {a, b};
+	VipsArrayDouble *background;
+	VipsImage **cells;
+	int alpha, err;
+
+	cells = vips_cells_prepare(base, in, 2, &alpha);
+	if (cells == NULL) {
 		g_object_unref(base);
 		return 1;
 	}
 
+	background = vips_cells_background(alpha, r, g, bg);
+	err = vips_join(cells[0], cells[1], out, direction,
+		"expand", expand,
+		"shim", shim,
+		"background", background,
+		"align", align,
+		NULL);
+
+	vips_area_unref(VIPS_AREA(background));
 	g_object_unref(base);
-	return 0;
+	return err;
 }
 
 int
-vips_smartcrop_bridge(VipsImage *in, VipsImage **out, int width, int height) {
-#if (VIPS_MAJOR_VERSION >= 8 && VIPS_MINOR_VERSION >= 5)
-	return vips_smartcrop(in, out, width, height, NULL);
-#else
+vips_text_bridge(VipsImage **out, const char *text, const char *font, int width, int dpi) {
+	return vips_text(out, text,
+		"font", font,
+		"width", width,
+		"dpi", dpi,
+		"align", VIPS_ALIGN_CENTRE,
+		NULL);
+}
+
+// vips_label_bridge paints a text mask with the ink colour over the
+// background, centred at the top of a width x height sRGB image.
+int
+vips_label_bridge(VipsImage *mask, VipsImage **out, int width, int height, double ir, double ig, double ib, double br, double bg, double bb) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 3);
+	double a[3] = {(ir - br) / 255.0, (ig - bg) / 255.0, (ib - bb) / 255.0};
+	double b[3] = {br, bg, bb};
+
+	if (
+		vips_embed(mask, &t[0], (width - mask->Xsize) / 2, 0, width, height, NULL) ||
+		vips_linear(t[0], &t[1], a, b, 3, NULL) ||
+		vips_cast(t[1], &t[2], VIPS_FORMAT_UCHAR, NULL) ||
+		vips_copy(t[2], out, "interpretation", VIPS_INTERPRETATION_sRGB, NULL)
+		) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	g_object_unref(base);
 	return 0;
-#endif
 }
 
-int vips_find_trim_bridge(VipsImage *in, int *top, int *left, int *width, int *height, double r, double g, double b, double threshold) {
-#if (VIPS_MAJOR_VERSION >= 8 && VIPS_MINOR_VERSION >= 6)
-	if (vips_is_16bit(in->Type)) {
-		r = 65535 * r / 255;
-		g = 65535 * g / 255;
-		b = 65535 * b / 255;
+// vips_animation_bridge stacks the frames vertically as the pages of an
+// animation, with the delays in milliseconds.
+int
+vips_animation_bridge(VipsImage **in, VipsImage **out, int n, int *delays, int loop) {
+#if (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 9))
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 1);
+	VipsImage **frames;
+	int alpha;
+
+	frames = vips_cells_prepare(base, in, n, &alpha);
+	if (frames == NULL ||
+		vips_arrayjoin(frames, &t[0], n, "across", 1, NULL) ||
+		vips_copy(t[0], out, NULL)) {
+		g_object_unref(base);
+		return 1;
 	}
 
-	double background[3] = {r, g, b};
-	VipsArrayDouble *vipsBackground = vips_array_double_new(background, 3);
-	return vips_find_trim(in, top, left, width, height, "background", vipsBackground, "threshold", threshold, NULL);
-#else
+	vips_image_set_int(*out, "page-height", in[0]->Ysize);
+	vips_image_set_int(*out, "n-pages", n);
+	vips_image_set_int(*out, "loop", loop);
+	vips_image_set_array_int(*out, "delay", delays, n);
+
+	g_object_unref(base);
 	return 0;
+#else
+	vips_error("bimg", "Animations require libvips 8.9+");
+	return 1;
 #endif
 }
 
-int vips_gamma_bridge(VipsImage *in, VipsImage **out, double exponent)
-{
+// vips_pages_bridge stacks the images vertically as the pages of a
+// multi-page document, keeping their bands and format. An alpha channel is
+// added to all of them when any has one, so they can be joined.
+int
+vips_pages_bridge(VipsImage **in, VipsImage **out, int n) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), n + 1);
+	int alpha = 0;
+	int i;
+
+	for (i = 0; i < n; i++) {
+		alpha |= has_alpha_channel(in[i]);
+	}
+
+	for (i = 0; i < n; i++) {
+		if (alpha && !has_alpha_channel(in[i])) {
+			if (vips_addalpha(in[i], &t[i], NULL)) {
+				g_object_unref(base);
+				return 1;
+			}
+		} else {
+			t[i] = in[i];
+			g_object_ref(t[i]);
+		}
+	}
+
+	if (vips_arrayjoin(t, &t[n], n, "across", 1, NULL) ||
+		vips_copy(t[n], out, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	vips_image_set_int(*out, "page-height", in[0]->Ysize);
+	vips_image_set_int(*out, "n-pages", n);
+
+	g_object_unref(base);
+	return 0;
+}
+
+int
+vips_tonemap_bridge(VipsImage *in, VipsImage **out, int reinhard, double scale, double gamma) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 18);
+
+	// Radiance images are packed as RGBE, unpack them to float first
+	VipsImage *x = in;
+	if (in->Coding == VIPS_CODING_RAD) {
+		if (vips_rad2float(in, &t[0], NULL)) {
+			g_object_unref(base);
+			return 1;
+		}
+		x = t[0];
+	}
+
+	int alpha = has_alpha_channel(x);
+	int bands = alpha ? x->Bands - 1 : x->Bands;
+	if (vips_extract_band(x, &t[1], 0, "n", bands, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	// Clamp negative values, max(x, 0) == (x + |x|) / 2, and apply exposure
+	VipsImage *mapped;
+	if (
+		vips_cast(t[1], &t[2], VIPS_FORMAT_FLOAT, NULL) ||
+		vips_abs(t[2], &t[3], NULL) ||
+		vips_add(t[2], t[3], &t[4], NULL) ||
+		vips_linear1(t[4], &t[5], 0.5 * scale, 0, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+	mapped = t[5];
+
+	// Reinhard operator compresses the highlights with x / (1 + x)
+	if (reinhard) {
+		if (
+			vips_linear1(t[5], &t[6], 1.0, 1.0, NULL) ||
+			vips_divide(t[5], t[6], &t[7], NULL)) {
+			g_object_unref(base);
+			return 1;
+		}
+		mapped = t[7];
+	}
+
+	// Greyscale images have no linear light colourspace to convert from
+	if (bands < 3 && gamma <= 0) {
+		gamma = 2.2;
+	}
+
+	if (gamma > 0) {
+		if (
+			vips_pow_const1(mapped, &t[8], 1.0 / gamma, NULL) ||
+			vips_linear1(t[8], &t[9], 255.0, 0, NULL) ||
+			vips_cast(t[9], &t[10], VIPS_FORMAT_UCHAR, NULL) ||
+			vips_copy(t[10], &t[11], "interpretation", bands < 3 ? VIPS_INTERPRETATION_B_W : VIPS_INTERPRETATION_sRGB, NULL)) {
+			g_object_unref(base);
+			return 1;
+		}
+	} else if (
+		vips_copy(mapped, &t[10], "interpretation", VIPS_INTERPRETATION_scRGB, NULL) ||
+		vips_colourspace(t[10], &t[11], VIPS_INTERPRETATION_sRGB, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	// Float alpha ranges from 0 to 1
+	if (alpha) {
+		double alpha_scale = 1.0;
+		if (x->BandFmt == VIPS_FORMAT_FLOAT || x->BandFmt == VIPS_FORMAT_DOUBLE) {
+			alpha_scale = 255.0;
+		} else if (x->BandFmt == VIPS_FORMAT_USHORT) {
+			alpha_scale = 1.0 / 257.0;
+		}
+		if (
+			vips_extract_band(x, &t[12], bands, "n", 1, NULL) ||
+			vips_linear1(t[12], &t[13], alpha_scale, 0, NULL) ||
+			vips_cast(t[13], &t[14], VIPS_FORMAT_UCHAR, NULL) ||
+			vips_bandjoin2(t[11], t[14], out, NULL)) {
+			g_object_unref(base);
+			return 1;
+		}
+	} else {
+		*out = t[11];
+		g_object_ref(*out);
+	}
+
+	g_object_unref(base);
+	return 0;
+}
+
+int
+vips_rgba_thumbnail_bridge(VipsImage *in, VipsImage **out, int size) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 4);
+
+	double scale = (double) size / VIPS_MAX(in->Xsize, in->Ysize);
+	if (scale > 1.0) {
+		scale = 1.0;
+	}
+
+	if (
+		vips_resize(in, &t[0], scale, NULL) ||
+		vips_colourspace(t[0], &t[1], VIPS_INTERPRETATION_sRGB, NULL) ||
+		vips_cast(t[1], &t[2], VIPS_FORMAT_UCHAR, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	// Always output four bands, opaque unless there is an alpha channel
+	if (t[2]->Bands == 3) {
+		if (vips_bandjoin_const1(t[2], out, 255.0, NULL)) {
+			g_object_unref(base);
+			return 1;
+		}
+	} else if (vips_extract_band(t[2], out, 0, "n", 4, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	g_object_unref(base);
+	return 0;
+}
+
+int
+vips_gradient_bridge(VipsImage **out, int width, int height, double *from, double *to, int bands, double angle) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 8);
+	double c = cos(angle * VIPS_PI / 180);
+	double s = sin(angle * VIPS_PI / 180);
+	double length = fabs(width * c) + fabs(height * s);
+	double delta[4];
+	int i;
+
+	for (i = 0; i < bands; i++) {
+		delta[i] = to[i] - from[i];
+	}
+
+	// Project the pixel centres on the gradient axis, from 0 to 1 between
+	// the image corners
+	double offset = 0.5 - ((width / 2.0 - 0.5) * c + (height / 2.0 - 0.5) * s) / length;
+
+	if (
+		vips_xyz(&t[0], width, height, NULL) ||
+		vips_extract_band(t[0], &t[1], 0, NULL) ||
+		vips_extract_band(t[0], &t[2], 1, NULL) ||
+		vips_linear1(t[1], &t[3], c / length, offset, NULL) ||
+		vips_linear1(t[2], &t[4], s / length, 0, NULL) ||
+		vips_add(t[3], t[4], &t[5], NULL) ||
+		vips_linear(t[5], &t[6], delta, from, bands, NULL) ||
+		vips_cast(t[6], &t[7], VIPS_FORMAT_UCHAR, NULL) ||
+		vips_copy(t[7], out, "interpretation", VIPS_INTERPRETATION_sRGB, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	g_object_unref(base);
+	return 0;
+}
+
+int
+vips_text_image_bridge(VipsImage **out, const char *text, const char *font, int width, int dpi, int align, double *ink, double *paper, int bands) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 7);
+	double zeros[4] = { 0, 0, 0, 0 };
+
+	// Blend the text color over the background, using the text as mask
+	if (
+		vips_text(&t[0], text,
+			"font", font,
+			"width", width,
+			"dpi", dpi,
+			"align", align,
+			NULL) ||
+		vips_black(&t[1], t[0]->Xsize, t[0]->Ysize, NULL) ||
+		vips_linear(t[1], &t[2], zeros, ink, bands, NULL) ||
+		vips_cast(t[2], &t[3], VIPS_FORMAT_UCHAR, NULL) ||
+		vips_linear(t[1], &t[4], zeros, paper, bands, NULL) ||
+		vips_cast(t[4], &t[5], VIPS_FORMAT_UCHAR, NULL) ||
+		vips_ifthenelse(t[0], t[3], t[5], &t[6], "blend", TRUE, NULL) ||
+		vips_copy(t[6], out, "interpretation", VIPS_INTERPRETATION_sRGB, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	g_object_unref(base);
+	return 0;
+}
+
+int
+vips_composite_bridge(VipsImage *in, VipsImage *overlay, VipsImage **out, int x, int y, double opacity) {
+#if (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 6))
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 9);
+	VipsImage *layer;
+
+	if (
+		vips_colourspace(in, &t[0], VIPS_INTERPRETATION_sRGB, NULL) ||
+		vips_colourspace(overlay, &t[1], VIPS_INTERPRETATION_sRGB, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	// Multiply the overlay alpha channel by the opacity
+	layer = t[1];
+	if (opacity < 1.0) {
+		if (!has_alpha_channel(t[1])) {
+			if (vips_bandjoin_const1(t[1], &t[2], 255.0, NULL)) {
+				g_object_unref(base);
+				return 1;
+			}
+		} else {
+			t[2] = t[1];
+			g_object_ref(t[2]);
+		}
+		if (
+			vips_extract_band(t[2], &t[3], 0, "n", t[2]->Bands - 1, NULL) ||
+			vips_extract_band(t[2], &t[4], t[2]->Bands - 1, NULL) ||
+			vips_linear1(t[4], &t[5], opacity, 0.0, NULL) ||
+			vips_bandjoin2(t[3], t[5], &t[6], NULL)) {
+			g_object_unref(base);
+			return 1;
+		}
+		layer = t[6];
+	}
+
+	// Composite in sRGB, keeping the image format and bands, so opaque
+	// images stay opaque
+	if (
+		vips_composite2(t[0], layer, &t[7], VIPS_BLEND_MODE_OVER, "x", x, "y", y, NULL) ||
+		vips_extract_band(t[7], &t[8], 0, "n", t[0]->Bands, NULL) ||
+		vips_cast(t[8], out, t[0]->BandFmt, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	g_object_unref(base);
+	return 0;
+#else
+	vips_error("bimg", "Image composition requires libvips 8.6+");
+	return 1;
+#endif
+}
+
+int
+vips_noise_texture_bridge(VipsImage **out, int width, int height, int cell_size, int seed, int worley) {
+#if (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 6))
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 2);
+	int err;
+
+#if (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 13))
+	if (seed != 0) {
+		err = worley ?
+			vips_worley(&t[0], width, height, "cell_size", cell_size, "seed", seed, NULL) :
+			vips_perlin(&t[0], width, height, "cell_size", cell_size, "seed", seed, NULL);
+	} else
+#else
+	if (seed != 0) {
+		vips_error("bimg", "Texture seeds require libvips 8.13+");
+		g_object_unref(base);
+		return 1;
+	}
+#endif
+	{
+		err = worley ?
+			vips_worley(&t[0], width, height, "cell_size", cell_size, NULL) :
+			vips_perlin(&t[0], width, height, "cell_size", cell_size, NULL);
+	}
+
+	// Stretch the float values to the 8-bit range
+	if (
+		err ||
+		vips_scale(t[0], &t[1], NULL) ||
+		vips_copy(t[1], out, "interpretation", VIPS_INTERPRETATION_B_W, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	g_object_unref(base);
+	return 0;
+#else
+	vips_error("bimg", "Texture generation requires libvips 8.6+");
+	return 1;
+#endif
+}
+
+int
+vips_rgba_image_bridge(void *data, size_t len, int width, int height, int alpha, VipsImage **out) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 2);
+
+	t[0] = vips_image_new_from_memory_copy(data, len, width, height, 4, VIPS_FORMAT_UCHAR);
+	if (t[0] == NULL ||
+		vips_copy(t[0], &t[1], "interpretation", VIPS_INTERPRETATION_sRGB, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	// Drop the opaque alpha channel added to images without one
+	int err = alpha ?
+		vips_copy(t[1], out, NULL) :
+		vips_extract_band(t[1], out, 0, "n", 3, NULL);
+	g_object_unref(base);
+	return err;
+}
+
+// Adds the 3 band float delta of the 8-bit sRGB pixels to the colour bands
+// of the image, keeping its alpha channel, 16-bit depth and metadata
+int
+vips_add_delta_bridge(VipsImage *in, VipsImage **out, void *delta, size_t len, int width, int height) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 8);
+
+	int rgb16 = in->Type == VIPS_INTERPRETATION_RGB16 || in->Type == VIPS_INTERPRETATION_GREY16;
+	VipsInterpretation space = rgb16 ? VIPS_INTERPRETATION_RGB16 : VIPS_INTERPRETATION_sRGB;
+
+	t[0] = vips_image_new_from_memory_copy(delta, len, width, height, 3, VIPS_FORMAT_FLOAT);
+	if (t[0] == NULL ||
+		vips_colourspace(in, &t[1], space, NULL) ||
+		vips_extract_band(t[1], &t[2], 0, "n", 3, NULL) ||
+		vips_linear1(t[0], &t[3], rgb16 ? 257.0 : 1.0, 0.0, NULL) ||
+		vips_add(t[2], t[3], &t[4], NULL) ||
+		vips_cast(t[4], &t[5], t[1]->BandFmt, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	int err = t[1]->Bands > 3 ?
+		vips_extract_band(t[1], &t[6], 3, "n", t[1]->Bands - 3, NULL) ||
+			vips_bandjoin2(t[5], t[6], out, NULL) :
+		vips_copy(t[5], out, NULL);
+	g_object_unref(base);
+	return err;
+}
+
+int
+vips_grey_thumbnail_bridge(VipsImage *in, VipsImage **out, int width, int height) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 3);
+
+	if (
+		vips_colourspace(in, &t[0], VIPS_INTERPRETATION_B_W, NULL) ||
+		vips_cast(t[0], &t[1], VIPS_FORMAT_UCHAR, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	// Transparent areas are treated as white
+	VipsImage *grey = t[1];
+	if (t[1]->Bands > 1) {
+		double white[1] = {255.0};
+		VipsArrayDouble *background = vips_array_double_new(white, 1);
+		int err = vips_flatten(t[1], &t[2], "background", background, NULL);
+		vips_area_unref(VIPS_AREA(background));
+		if (err) {
+			g_object_unref(base);
+			return 1;
+		}
+		grey = t[2];
+	}
+
+	// Aspect ratio is ignored on purpose
+	if (vips_resize(grey, out, (double) width / grey->Xsize, "vscale", (double) height / grey->Ysize, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	g_object_unref(base);
+	return 0;
+}
+
+static int
+vips_compare_prepare(VipsImage *in, VipsImage **out) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 3);
+
+	if (
+		vips_colourspace(in, &t[0], VIPS_INTERPRETATION_sRGB, NULL) ||
+		vips_cast(t[0], &t[1], VIPS_FORMAT_UCHAR, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	// Transparent areas are compared as white
+	VipsImage *rgb = t[1];
+	if (t[1]->Bands > 3) {
+		double white[3] = {255.0, 255.0, 255.0};
+		VipsArrayDouble *background = vips_array_double_new(white, 3);
+		int err = vips_flatten(t[1], &t[2], "background", background, NULL);
+		vips_area_unref(VIPS_AREA(background));
+		if (err) {
+			g_object_unref(base);
+			return 1;
+		}
+		rgb = t[2];
+	}
+
+	if (vips_cast(rgb, out, VIPS_FORMAT_FLOAT, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	g_object_unref(base);
+	return 0;
+}
+
+int
+vips_compare_bridge(VipsImage *a, VipsImage *b, double *mse, double *ssim) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 31);
+
+	// SSIM stabilization constants for 8-bit values
+	double c1 = (0.01 * 255) * (0.01 * 255);
+	double c2 = (0.03 * 255) * (0.03 * 255);
+
+	if (vips_compare_prepare(a, &t[0]) || vips_compare_prepare(b, &t[1])) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	// Compare against the same dimensions
+	VipsImage *y = t[1];
+	if (t[1]->Xsize != t[0]->Xsize || t[1]->Ysize != t[0]->Ysize) {
+		if (vips_resize(t[1], &t[2], (double) t[0]->Xsize / t[1]->Xsize, "vscale", (double) t[0]->Ysize / t[1]->Ysize, NULL)) {
+			g_object_unref(base);
+			return 1;
+		}
+		y = t[2];
+	}
+
+	t[30] = vips_image_new_matrixv(3, 1, 0.299, 0.587, 0.114);
+
+	if (
+		// Mean squared error of all the bands
+		vips_subtract(t[0], y, &t[3], NULL) ||
+		vips_multiply(t[3], t[3], &t[4], NULL) ||
+		vips_avg(t[4], mse, NULL) ||
+		// Structural similarity of the luminance using a gaussian window
+		vips_recomb(t[0], &t[5], t[30], NULL) ||
+		vips_recomb(y, &t[6], t[30], NULL) ||
+		vips_gaussblur(t[5], &t[7], 1.5, "precision", VIPS_PRECISION_FLOAT, NULL) ||
+		vips_gaussblur(t[6], &t[8], 1.5, "precision", VIPS_PRECISION_FLOAT, NULL) ||
+		vips_multiply(t[5], t[5], &t[9], NULL) ||
+		vips_gaussblur(t[9], &t[10], 1.5, "precision", VIPS_PRECISION_FLOAT, NULL) ||
+		vips_multiply(t[6], t[6], &t[11], NULL) ||
+		vips_gaussblur(t[11], &t[12], 1.5, "precision", VIPS_PRECISION_FLOAT, NULL) ||
+		vips_multiply(t[5], t[6], &t[13], NULL) ||
+		vips_gaussblur(t[13], &t[14], 1.5, "precision", VIPS_PRECISION_FLOAT, NULL) ||
+		vips_multiply(t[7], t[8], &t[15], NULL) ||
+		vips_multiply(t[7], t[7], &t[16], NULL) ||
+		vips_multiply(t[8], t[8], &t[17], NULL) ||
+		vips_subtract(t[10], t[16], &t[18], NULL) ||
+		vips_subtract(t[12], t[17], &t[19], NULL) ||
+		vips_subtract(t[14], t[15], &t[20], NULL) ||
+		vips_linear1(t[15], &t[21], 2.0, c1, NULL) ||
+		vips_linear1(t[20], &t[22], 2.0, c2, NULL) ||
+		vips_multiply(t[21], t[22], &t[23], NULL) ||
+		vips_add(t[16], t[17], &t[24], NULL) ||
+		vips_linear1(t[24], &t[25], 1.0, c1, NULL) ||
+		vips_add(t[18], t[19], &t[26], NULL) ||
+		vips_linear1(t[26], &t[27], 1.0, c2, NULL) ||
+		vips_multiply(t[25], t[27], &t[28], NULL) ||
+		vips_divide(t[23], t[28], &t[29], NULL) ||
+		vips_avg(t[29], ssim, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	g_object_unref(base);
+	return 0;
+}
+
+// vips_diff_bridge highlights with the ink colour the pixels of b whose
+// channels differ from a by more than the threshold, over a faded
+// greyscale copy of a, and returns the ratio of differing pixels.
+int
+vips_diff_bridge(VipsImage *a, VipsImage *b, VipsImage **out, double *ratio, double threshold, double r, double g, double bl) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 16);
+	double zeros[3] = {0.0, 0.0, 0.0};
+	double ink[3] = {r, g, bl};
+	double mean;
+
+	if (vips_compare_prepare(a, &t[0]) || vips_compare_prepare(b, &t[1])) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	VipsImage *y = t[1];
+	if (t[1]->Xsize != t[0]->Xsize || t[1]->Ysize != t[0]->Ysize) {
+		if (vips_resize(t[1], &t[2], (double) t[0]->Xsize / t[1]->Xsize, "vscale", (double) t[0]->Ysize / t[1]->Ysize, NULL)) {
+			g_object_unref(base);
+			return 1;
+		}
+		y = t[2];
+	}
+
+	t[15] = vips_image_new_matrixv(3, 1, 0.299, 0.587, 0.114);
+
+	if (
+		// Mask of the pixels with any channel beyond the threshold
+		vips_subtract(t[0], y, &t[3], NULL) ||
+		vips_abs(t[3], &t[4], NULL) ||
+		vips_more_const1(t[4], &t[5], threshold, NULL) ||
+		vips_bandor(t[5], &t[6], NULL) ||
+		vips_avg(t[6], &mean, NULL) ||
+		// Faded greyscale background
+		vips_recomb(t[0], &t[7], t[15], NULL) ||
+		vips_linear1(t[7], &t[8], 0.3, 255.0 * 0.7, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	VipsImage *grey[3] = {t[8], t[8], t[8]};
+	if (
+		vips_bandjoin(grey, &t[9], 3, NULL) ||
+		vips_black(&t[10], t[0]->Xsize, t[0]->Ysize, "bands", 3, NULL) ||
+		vips_linear(t[10], &t[11], zeros, ink, 3, NULL) ||
+		vips_ifthenelse(t[6], t[11], t[9], &t[12], NULL) ||
+		vips_cast(t[12], &t[13], VIPS_FORMAT_UCHAR, NULL) ||
+		vips_copy(t[13], out, "interpretation", VIPS_INTERPRETATION_sRGB, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	*ratio = mean / 255.0;
+	g_object_unref(base);
+	return 0;
+}
+
+int
+vips_sharpness_bridge(VipsImage *in, double *out, int size) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 7);
+
+	if (
+		vips_colourspace(in, &t[0], VIPS_INTERPRETATION_B_W, NULL) ||
+		vips_cast(t[0], &t[1], VIPS_FORMAT_UCHAR, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	VipsImage *grey = t[1];
+	if (t[1]->Bands > 1) {
+		double white[1] = {255.0};
+		VipsArrayDouble *background = vips_array_double_new(white, 1);
+		int err = vips_flatten(t[1], &t[2], "background", background, NULL);
+		vips_area_unref(VIPS_AREA(background));
+		if (err) {
+			g_object_unref(base);
+			return 1;
+		}
+		grey = t[2];
+	}
+
+	// Normalize the scale, so scores are comparable across image sizes
+	double scale = (double) size / VIPS_MAX(grey->Xsize, grey->Ysize);
+	if (scale < 1.0) {
+		if (vips_resize(grey, &t[3], scale, NULL)) {
+			g_object_unref(base);
+			return 1;
+		}
+		grey = t[3];
+	}
+
+	// Variance of the laplacian
+	double deviation;
+	t[6] = vips_image_new_matrixv(3, 3,
+		0.0, 1.0, 0.0,
+		1.0, -4.0, 1.0,
+		0.0, 1.0, 0.0);
+	if (
+		vips_cast(grey, &t[4], VIPS_FORMAT_FLOAT, NULL) ||
+		vips_conv(t[4], &t[5], t[6], "precision", VIPS_PRECISION_FLOAT, NULL) ||
+		vips_deviate(t[5], &deviation, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	*out = deviation * deviation;
+	g_object_unref(base);
+	return 0;
+}
+
+int
+vips_stats_bridge(VipsImage *in, VipsImage **out, double *entropy) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 5);
+
+	// Statistics are returned as a double matrix with a row per band,
+	// with the overall statistics in the first row
+	if (
+		vips_stats(in, &t[0], NULL) ||
+		vips_cast(t[0], out, VIPS_FORMAT_DOUBLE, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	// Entropy of the 8-bit luminance histogram
+	if (
+		vips_colourspace(in, &t[1], VIPS_INTERPRETATION_B_W, NULL) ||
+		vips_extract_band(t[1], &t[2], 0, NULL) ||
+		vips_cast(t[2], &t[3], VIPS_FORMAT_UCHAR, NULL) ||
+		vips_hist_find(t[3], &t[4], NULL) ||
+		vips_hist_entropy(t[4], entropy, NULL)) {
+		g_object_unref(*out);
+		g_object_unref(base);
+		return 1;
+	}
+
+	g_object_unref(base);
+	return 0;
+}
+
+int
+vips_cast_bridge(VipsImage *in, VipsImage **out, VipsBandFormat format) {
+	return vips_cast(in, out, format, NULL);
+}
+
+int
+vips_premultiply_bridge(VipsImage *in, VipsImage **out) {
+	return vips_premultiply(in, out, "max_alpha", vips_is_16bit(in->Type) ? 65535.0 : 255.0, NULL);
+}
+
+int
+vips_unpremultiply_bridge(VipsImage *in, VipsImage **out, VipsBandFormat format) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 1);
+
+	// Unpremultiply outputs float pixels, cast them back to the given format
+	if (
+		vips_unpremultiply(in, &t[0], "max_alpha", vips_is_16bit(in->Type) ? 65535.0 : 255.0, NULL) ||
+		vips_cast(t[0], out, format, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	g_object_unref(base);
+	return 0;
+}
+
+int
+vips_add_band(VipsImage *in, VipsImage **out, double c) {
+#if (VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION >= 8 && VIPS_MINOR_VERSION >= 2))
+	return vips_bandjoin_const1(in, out, c, NULL);
+#else
+	VipsImage *base = vips_image_new();
+	if (
+		vips_black(&base, in->Xsize, in->Ysize, NULL) ||
+		vips_linear1(base, &base, 1, c, NULL)) {
+			g_object_unref(base);
+			return 1;
+		}
+	g_object_unref(base);
+	return vips_bandjoin2(in, base, out, c, NULL);
+#endif
+}
+
+int
+vips_watermark_image(VipsImage *in, VipsImage *sub, VipsImage **out, WatermarkImageOptions *o) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 10);
+
+  // add in and sub for unreffing and later use
+	t[0] = in;
+	t[1] = sub;
+
+  if (has_alpha_channel(in) == 0) {
+		vips_add_band(in, &t[0], 255.0);
+		// in is no longer in the array and won't be unreffed, so add it at the end
+		t[8] = in;
+	}
+
+	if (has_alpha_channel(sub) == 0) {
+		vips_add_band(sub, &t[1], 255.0);
+		// sub is no longer in the array and won't be unreffed, so add it at the end
+		t[9] = sub;
+	}
+
+	// Place watermark image in the right place and size it to the size of the
+	// image that should be watermarked
+	if (
+		vips_embed(t[1], &t[2], o->Left, o->Top, t[0]->Xsize, t[0]->Ysize, NULL)) {
+			g_object_unref(base);
+		return 1;
+	}
+
+	// Create a mask image based on the alpha band from the watermark image
+	// and place it in the right position
+	if (
+		vips_extract_band(t[1], &t[3], t[1]->Bands - 1, "n", 1, NULL) ||
+		vips_linear1(t[3], &t[4], o->Opacity, 0.0, NULL) ||
+		vips_cast(t[4], &t[5], VIPS_FORMAT_UCHAR, NULL) ||
+		vips_copy(t[5], &t[6], "interpretation", t[0]->Type, NULL) ||
+		vips_embed(t[6], &t[7], o->Left, o->Top, t[0]->Xsize, t[0]->Ysize, NULL))	{
+			g_object_unref(base);
+		return 1;
+	}
+
+	// Blend the mask and watermark image and write to output.
+	if (vips_ifthenelse(t[7], t[2], t[0], out, "blend", TRUE, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	g_object_unref(base);
+	return 0;
+}
+
+int
+vips_smartcrop_bridge(VipsImage *in, VipsImage **out, int width, int height) {
+#if (VIPS_MAJOR_VERSION >= 8 && VIPS_MINOR_VERSION >= 5)
+	return vips_smartcrop(in, out, width, height, NULL);
+#else
+	return 0;
+#endif
+}
+
+int vips_find_trim_bridge(VipsImage *in, int *top, int *left, int *width, int *height, double r, double g, double b, double threshold) {
+#if (VIPS_MAJOR_VERSION >= 8 && VIPS_MINOR_VERSION >= 6)
+	if (vips_is_16bit(in->Type)) {
+		r = 65535 * r / 255;
+		g = 65535 * g / 255;
+		b = 65535 * b / 255;
+	}
+
+	double background[3] = {r, g, b};
+	VipsArrayDouble *vipsBackground = vips_array_double_new(background, 3);
+	return vips_find_trim(in, top, left, width, height, "background", vipsBackground, "threshold", threshold, NULL);
+#else
+	return 0;
+#endif
+}
+
+int vips_find_trim_lab_bridge(VipsImage *in, int *top, int *left, int *width, int *height, double l, double a, double b, double threshold) {
+#if (VIPS_MAJOR_VERSION >= 8 && VIPS_MINOR_VERSION >= 6)
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 7);
+
+	double background[3] = {-l, -a, -b};
+	double ones[3] = {1.0, 1.0, 1.0};
+	double zero[1] = {0.0};
+	VipsArrayDouble *vipsBackground = vips_array_double_new(zero, 1);
+
+	// Trim the delta E distance map against the background colour
+	int err = vips_colourspace(in, &t[0], VIPS_INTERPRETATION_LAB, NULL) ||
+		vips_extract_band(t[0], &t[1], 0, "n", 3, NULL) ||
+		vips_linear(t[1], &t[2], ones, background, 3, NULL) ||
+		vips_multiply(t[2], t[2], &t[3], NULL) ||
+		vips_bandmean(t[3], &t[4], NULL) ||
+		vips_linear1(t[4], &t[5], 3.0, 0.0, NULL) ||
+		vips_pow_const1(t[5], &t[6], 0.5, NULL) ||
+		vips_find_trim(t[6], top, left, width, height, "background", vipsBackground, "threshold", threshold, NULL);
+
+	vips_area_unref(VIPS_AREA(vipsBackground));
+	g_object_unref(base);
+	return err;
+#else
+	return 0;
+#endif
+}
+
+int vips_border_background_bridge(VipsImage *in, int *r, int *g, int *b) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 3);
+
+	// Sample the corners and the middle of the edges
+	int right = in->Xsize - 1, bottom = in->Ysize - 1;
+	int points[8][2] = {
+		{0, 0}, {right / 2, 0}, {right, 0}, {right, bottom / 2},
+		{right, bottom}, {right / 2, bottom}, {0, bottom}, {0, bottom / 2}
+	};
+	double samples[3][8];
+
+	if (vips_colourspace(in, &t[0], VIPS_INTERPRETATION_sRGB, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	// Transparent pixels are treated as white, as find_trim flattens the
+	// image against the background colour
+	VipsImage *rgb = t[0];
+	if (has_alpha_channel(t[0])) {
+		double white[1] = {255.0};
+		VipsArrayDouble *background = vips_array_double_new(white, 1);
+		int err = vips_flatten(t[0], &t[1], "background", background, NULL);
+		vips_area_unref(VIPS_AREA(background));
+		if (err) {
+			g_object_unref(base);
+			return 1;
+		}
+		rgb = t[1];
+	}
+	if (vips_extract_band(rgb, &t[2], 0, "n", 3, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	for (int i = 0; i < 8; i++) {
+		double *vector;
+		int n;
+		if (vips_getpoint(t[2], &vector, &n, points[i][0], points[i][1], NULL)) {
+			g_object_unref(base);
+			return 1;
+		}
+		for (int band = 0; band < 3; band++) {
+			samples[band][i] = vector[band < n ? band : 0];
+		}
+		g_free(vector);
+	}
+	g_object_unref(base);
+
+	// The median of the samples ignores content touching a few of them
+	int *channels[3] = {r, g, b};
+	for (int band = 0; band < 3; band++) {
+		double *v = samples[band];
+		for (int i = 1; i < 8; i++) {
+			for (int j = i; j > 0 && v[j - 1] > v[j]; j--) {
+				double tmp = v[j];
+				v[j] = v[j - 1];
+				v[j - 1] = tmp;
+			}
+		}
+		*channels[band] = (int) VIPS_RINT((v[3] + v[4]) / 2);
+	}
+	return 0;
+}
+
+int vips_gamma_bridge(VipsImage *in, VipsImage **out, double exponent)
+{
   return vips_gamma(in, out, "exponent", 1.0 / exponent, NULL);
 }
 
@@ -688,3 +2461,518 @@ int vips_contrast_bridge(VipsImage *in, VipsImage **out, double k)
 {
     return vips_linear1(in, out, k , 0.0, NULL);
 }
+
+int vips_invert_bridge(VipsImage *in, VipsImage **out, int skip_alpha)
+{
+	if (skip_alpha == 0 || has_alpha_channel(in) == 0) {
+		return vips_invert(in, out, NULL);
+	}
+
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 3);
+
+	// Invert the colour bands only and join the untouched alpha band back
+	if (
+		vips_extract_band(in, &t[0], 0, "n", in->Bands - 1, NULL) ||
+		vips_extract_band(in, &t[1], in->Bands - 1, "n", 1, NULL) ||
+		vips_invert(t[0], &t[2], NULL) ||
+		vips_bandjoin2(t[2], t[1], out, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	g_object_unref(base);
+	return 0;
+}
+
+int vips_maplut_bridge(VipsImage *in, VipsImage **out, void *lut)
+{
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 7);
+	int is16bit = vips_is_16bit(in->Type);
+	int alpha = has_alpha_channel(in);
+	VipsImage *colour;
+
+	// Map the colour bands only and join the untouched alpha band back
+	t[0] = vips_image_new_from_memory_copy(lut, 256, 256, 1, 1, VIPS_FORMAT_UCHAR);
+	if (t[0] == NULL ||
+		vips_extract_band(in, &t[1], 0, "n", in->Bands - alpha, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+	colour = t[1];
+
+	// 16-bit images are mapped by their most significant byte
+	if (is16bit) {
+		if (vips_msb(colour, &t[2], NULL)) {
+			g_object_unref(base);
+			return 1;
+		}
+		colour = t[2];
+	}
+
+	if (vips_maplut(colour, &t[3], t[0], NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+	colour = t[3];
+
+	if (is16bit) {
+		if (
+			vips_linear1(colour, &t[4], 257.0, 0, NULL) ||
+			vips_cast(t[4], &t[5], VIPS_FORMAT_USHORT, NULL)) {
+			g_object_unref(base);
+			return 1;
+		}
+		colour = t[5];
+	}
+
+	int err = alpha ?
+		vips_extract_band(in, &t[6], in->Bands - 1, NULL) || vips_bandjoin2(colour, t[6], out, NULL) :
+		vips_copy(colour, out, NULL);
+	g_object_unref(base);
+	return err;
+}
+
+int vips_threshold_bridge(VipsImage *in, VipsImage **out, double threshold)
+{
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 2);
+
+	if (vips_is_16bit(in->Type)) {
+		threshold = 65535 * threshold / 255;
+	}
+
+	if (
+		vips_colourspace(in, &t[0], vips_is_16bit(in->Type) ? VIPS_INTERPRETATION_GREY16 : VIPS_INTERPRETATION_B_W, NULL) ||
+		vips_extract_band(t[0], &t[1], 0, NULL) ||
+		vips_more_const1(t[1], out, threshold, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	g_object_unref(base);
+	return 0;
+}
+
+int vips_adaptive_threshold_bridge(VipsImage *in, VipsImage **out, int radius, double offset)
+{
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 4);
+	double sigma = radius / 2.0 > 0.5 ? radius / 2.0 : 0.5;
+
+	// Compare every pixel against the gaussian weighted mean of its neighbourhood
+	if (
+		vips_colourspace(in, &t[0], VIPS_INTERPRETATION_B_W, NULL) ||
+		vips_extract_band(t[0], &t[1], 0, NULL) ||
+		vips_gaussblur(t[1], &t[2], sigma, NULL) ||
+		vips_linear1(t[2], &t[3], 1.0, -offset, NULL) ||
+		vips_relational(t[1], t[3], out, VIPS_OPERATION_RELATIONAL_MORE, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	g_object_unref(base);
+	return 0;
+}
+
+int vips_pixelate_bridge(VipsImage *in, VipsImage **out, int left, int top, int width, int height, int block)
+{
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 6);
+
+	// Pad the area to whole blocks, then average and replicate every block
+	int across = (width + block - 1) / block;
+	int down = (height + block - 1) / block;
+
+	if (
+		vips_extract_area(in, &t[0], left, top, width, height, NULL) ||
+		vips_embed(t[0], &t[1], 0, 0, across * block, down * block, "extend", VIPS_EXTEND_COPY, NULL) ||
+		vips_shrink(t[1], &t[2], block, block, NULL) ||
+		vips_zoom(t[2], &t[3], block, block, NULL) ||
+		vips_extract_area(t[3], &t[4], 0, 0, width, height, NULL) ||
+		vips_cast(t[4], &t[5], in->BandFmt, NULL) ||
+		vips_insert(in, t[5], out, left, top, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	g_object_unref(base);
+	return 0;
+}
+
+int vips_grain_bridge(VipsImage *in, VipsImage **out, double sigma, int monochrome, int size)
+{
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 7);
+	int alpha = has_alpha_channel(in);
+	int bands = in->Bands - alpha;
+	int n = monochrome ? 1 : bands;
+	VipsImage **bandNoise = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), n);
+	int width = (in->Xsize + size - 1) / size;
+	int height = (in->Ysize + size - 1) / size;
+	VipsImage *noise;
+	int i;
+
+	if (vips_is_16bit(in->Type)) {
+		sigma *= 257;
+	}
+
+	// Generate a noise band per colour band, or a single one shared by all
+	// of them to change the luminance only. The noise is generated at a
+	// lower resolution then upscaled for a coarser grain.
+	for (i = 0; i < n; i++) {
+		if (vips_gaussnoise(&bandNoise[i], width, height, "sigma", sigma, "mean", 0.0, NULL)) {
+			g_object_unref(base);
+			return 1;
+		}
+	}
+	noise = bandNoise[0];
+	if (n > 1) {
+		if (vips_bandjoin(bandNoise, &t[0], n, NULL)) {
+			g_object_unref(base);
+			return 1;
+		}
+		noise = t[0];
+	}
+	if (size > 1) {
+		if (
+			vips_resize(noise, &t[1], size, "kernel", VIPS_KERNEL_LINEAR, NULL) ||
+			vips_extract_area(t[1], &t[2], 0, 0, in->Xsize, in->Ysize, NULL)) {
+			g_object_unref(base);
+			return 1;
+		}
+		noise = t[2];
+	}
+
+	if (
+		vips_extract_band(in, &t[3], 0, "n", bands, NULL) ||
+		vips_add(t[3], noise, &t[4], NULL) ||
+		vips_cast(t[4], &t[5], in->BandFmt, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	int err = alpha ?
+		vips_extract_band(in, &t[6], in->Bands - 1, NULL) || vips_bandjoin2(t[5], t[6], out, NULL) :
+		vips_copy(t[5], out, NULL);
+	g_object_unref(base);
+	return err;
+}
+
+int vips_vignette_bridge(VipsImage *in, VipsImage **out, double strength, double radius, double r, double g, double b)
+{
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 20);
+	double ones[3] = { 1, 1, 1 };
+	double colour[3] = { r, g, b };
+	double xyz_scale[2] = { 1, 1 };
+	double xyz_offset[2];
+	double cx, cy, max_distance;
+
+	t[0] = in;
+	g_object_ref(in);
+
+	// Work always in RGB so the vignette colour can be applied
+	if (in->Bands < 3 || in->Type == VIPS_INTERPRETATION_CMYK) {
+		if (vips_colourspace(in, &t[0], VIPS_INTERPRETATION_sRGB, NULL)) {
+			// t[0] still holds the input reference, released with base
+			g_object_unref(base);
+			return 1;
+		}
+		g_object_unref(in);
+	}
+
+	cx = t[0]->Xsize / 2.0;
+	cy = t[0]->Ysize / 2.0;
+	max_distance = cx * cx + cy * cy;
+	xyz_offset[0] = -cx;
+	xyz_offset[1] = -cy;
+
+	if (vips_is_16bit(t[0]->Type)) {
+		colour[0] = 65535 * r / 255;
+		colour[1] = 65535 * g / 255;
+		colour[2] = 65535 * b / 255;
+	}
+
+	// Build the radial mask: the normalised distance to the centre, zero
+	// within radius, growing quadratically towards the corners.
+	if (
+		vips_xyz(&t[1], t[0]->Xsize, t[0]->Ysize, NULL) ||
+		vips_linear(t[1], &t[2], xyz_scale, xyz_offset, 2, NULL) ||
+		vips_multiply(t[2], t[2], &t[3], NULL) ||
+		vips_bandmean(t[3], &t[4], NULL) ||
+		vips_linear1(t[4], &t[5], 2.0 / max_distance, 0.0, NULL) ||
+		vips_pow_const1(t[5], &t[6], 0.5, NULL) ||
+		vips_linear1(t[6], &t[7], 255.0 / (1.0 - radius), -255.0 * radius / (1.0 - radius), NULL) ||
+		vips_cast(t[7], &t[8], VIPS_FORMAT_UCHAR, NULL) ||
+		vips_multiply(t[8], t[8], &t[9], NULL) ||
+		vips_linear1(t[9], &t[10], strength / 255.0, 0.0, NULL) ||
+		vips_cast(t[10], &t[11], VIPS_FORMAT_UCHAR, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	// Make the constant image to paint the vignette with.
+	if (
+		vips_black(&t[12], 1, 1, NULL) ||
+		vips_linear(t[12], &t[13], ones, colour, 3, NULL) ||
+		vips_cast(t[13], &t[14], t[0]->BandFmt, NULL) ||
+		vips_copy(t[14], &t[15], "interpretation", t[0]->Type, NULL) ||
+		vips_embed(t[15], &t[16], 0, 0, t[0]->Xsize, t[0]->Ysize, "extend", VIPS_EXTEND_COPY, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	t[17] = t[16];
+	g_object_ref(t[17]);
+	if (has_alpha_channel(t[0])) {
+		g_object_unref(t[17]);
+		if (vips_extract_band(t[0], &t[18], t[0]->Bands - 1, "n", 1, NULL) ||
+			vips_bandjoin2(t[16], t[18], &t[17], NULL)) {
+			g_object_unref(base);
+			return 1;
+		}
+	}
+
+	// Blend the mask and colour and write to output.
+	if (vips_ifthenelse(t[11], t[17], t[0], out, "blend", TRUE, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	g_object_unref(base);
+	return 0;
+}
+
+static int
+vips_rounded_mask(int width, int height, double radius, VipsImage **out) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 12);
+	double cx = width / 2.0;
+	double cy = height / 2.0;
+	double ones[2] = { 1, 1 };
+	double centre[2] = { 0.5 - cx, 0.5 - cy };
+	double corner[2];
+
+	if (radius > cx) {
+		radius = cx;
+	}
+	if (radius > cy) {
+		radius = cy;
+	}
+	corner[0] = radius - cx;
+	corner[1] = radius - cy;
+
+	// Distance of every pixel centre to the nearest corner circle, being zero
+	// for pixels out of the corner areas. The mask is then antialiased over
+	// one pixel along the edge of the circle.
+	if (
+		vips_xyz(&t[0], width, height, NULL) ||
+		vips_linear(t[0], &t[1], ones, centre, 2, NULL) ||
+		vips_abs(t[1], &t[2], NULL) ||
+		vips_linear(t[2], &t[3], ones, corner, 2, NULL) ||
+		vips_abs(t[3], &t[4], NULL) ||
+		vips_add(t[3], t[4], &t[5], NULL) ||
+		vips_linear1(t[5], &t[6], 0.5, 0.0, NULL) ||
+		vips_multiply(t[6], t[6], &t[7], NULL) ||
+		vips_bandmean(t[7], &t[8], NULL) ||
+		vips_linear1(t[8], &t[9], 2.0, 0.0, NULL) ||
+		vips_pow_const1(t[9], &t[10], 0.5, NULL) ||
+		vips_linear1(t[10], &t[11], -255.0, 255.0 * (radius + 0.5), NULL) ||
+		vips_cast(t[11], out, VIPS_FORMAT_UCHAR, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	g_object_unref(base);
+	return 0;
+}
+
+int vips_round_corners_bridge(VipsImage *in, VipsImage **out, double radius)
+{
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 9);
+	double max_alpha;
+
+	t[0] = in;
+	g_object_ref(in);
+
+	if (in->Type == VIPS_INTERPRETATION_CMYK) {
+		if (vips_colourspace(in, &t[0], VIPS_INTERPRETATION_sRGB, NULL)) {
+			// t[0] still holds the input reference, released with base
+			g_object_unref(base);
+			return 1;
+		}
+		g_object_unref(in);
+	}
+
+	max_alpha = vips_is_16bit(t[0]->Type) ? 65535.0 : 255.0;
+
+	if (vips_rounded_mask(t[0]->Xsize, t[0]->Ysize, radius, &t[1])) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	// Scale the mask to the image range
+	if (vips_is_16bit(t[0]->Type)) {
+		if (
+			vips_linear1(t[1], &t[2], 257.0, 0.0, NULL) ||
+			vips_cast(t[2], &t[3], VIPS_FORMAT_USHORT, NULL)) {
+			g_object_unref(base);
+			return 1;
+		}
+	} else {
+		t[3] = t[1];
+		g_object_ref(t[3]);
+	}
+
+	if (has_alpha_channel(t[0]) == 0) {
+		if (vips_bandjoin2(t[0], t[3], out, NULL)) {
+			g_object_unref(base);
+			return 1;
+		}
+		g_object_unref(base);
+		return 0;
+	}
+
+	// Combine the mask with the existing alpha channel
+	if (
+		vips_extract_band(t[0], &t[4], 0, "n", t[0]->Bands - 1, NULL) ||
+		vips_extract_band(t[0], &t[5], t[0]->Bands - 1, "n", 1, NULL) ||
+		vips_multiply(t[5], t[3], &t[6], NULL) ||
+		vips_linear1(t[6], &t[7], 1.0 / max_alpha, 0.0, NULL) ||
+		vips_cast(t[7], &t[8], t[5]->BandFmt, NULL) ||
+		vips_bandjoin2(t[4], t[8], out, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	g_object_unref(base);
+	return 0;
+}
+
+int vips_addalpha_bridge(VipsImage *in, VipsImage **out)
+{
+	if (has_alpha_channel(in)) {
+		return vips_copy(in, out, NULL);
+	}
+	return vips_add_band(in, out, vips_is_16bit(in->Type) ? 65535.0 : 255.0);
+}
+
+int vips_extract_alpha_bridge(VipsImage *in, VipsImage **out)
+{
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 3);
+
+	// Images without alpha channel are fully opaque
+	if (has_alpha_channel(in)) {
+		if (vips_extract_band(in, &t[0], in->Bands - 1, "n", 1, NULL)) {
+			g_object_unref(base);
+			return 1;
+		}
+	} else if (
+		vips_black(&t[1], in->Xsize, in->Ysize, NULL) ||
+		vips_linear1(t[1], &t[2], 1.0, 255.0, NULL) ||
+		vips_cast(t[2], &t[0], VIPS_FORMAT_UCHAR, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	if (vips_copy(t[0], out, "interpretation", t[0]->BandFmt == VIPS_FORMAT_USHORT ? VIPS_INTERPRETATION_GREY16 : VIPS_INTERPRETATION_B_W, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	g_object_unref(base);
+	return 0;
+}
+
+int vips_apply_alpha_mask_bridge(VipsImage *in, VipsImage *mask, VipsImage **out)
+{
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 7);
+	int is16bit = vips_is_16bit(in->Type);
+
+	t[0] = in;
+	g_object_ref(in);
+
+	// Drop the current alpha channel, if any
+	if (has_alpha_channel(in)) {
+		if (vips_extract_band(in, &t[0], 0, "n", in->Bands - 1, NULL)) {
+			// t[0] still holds the input reference, released with base
+			g_object_unref(base);
+			return 1;
+		}
+		g_object_unref(in);
+	}
+
+	// Use the mask luminance, scaled to the image size, as the new alpha channel
+	if (
+		vips_colourspace(mask, &t[1], is16bit ? VIPS_INTERPRETATION_GREY16 : VIPS_INTERPRETATION_B_W, NULL) ||
+		vips_extract_band(t[1], &t[2], 0, NULL) ||
+		vips_resize(t[2], &t[3], (double) t[0]->Xsize / t[2]->Xsize, "vscale", (double) t[0]->Ysize / t[2]->Ysize, NULL) ||
+		vips_cast(t[3], &t[4], t[0]->BandFmt, NULL) ||
+		vips_bandjoin2(t[0], t[4], out, NULL)) {
+		g_object_unref(base);
+		return 1;
+	}
+
+	g_object_unref(base);
+	return 0;
+}
+
+// Run a list of operations in a single call, sparing a cgo call per operation
+int
+vips_pipeline_bridge(VipsImage *in, VipsImage **out, const int *ops, const double *args, int n) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), n);
+	VipsImage *image = in;
+	int i, code;
+
+	for (i = 0; i < n; i++) {
+		const double *a = args + i * PIPELINE_ARGS;
+
+		switch (ops[i]) {
+		case PIPELINE_ROTATE:
+			code = vips_rotate_bridge(image, &t[i], (int) a[0]);
+			break;
+		case PIPELINE_FLIP:
+			code = vips_flip_bridge(image, &t[i], (int) a[0]);
+			break;
+		case PIPELINE_GAMMA:
+			code = vips_gamma_bridge(image, &t[i], a[0]);
+			break;
+		case PIPELINE_SHARPEN:
+			code = vips_sharpen_bridge(image, &t[i], (int) a[0], a[1], a[2], a[3], a[4], a[5]);
+			break;
+		case PIPELINE_BLUR:
+			code = vips_gaussblur_bridge(image, &t[i], a[0], a[1]);
+			break;
+		case PIPELINE_BRIGHTNESS:
+			code = vips_brightness_bridge(image, &t[i], a[0]);
+			break;
+		case PIPELINE_CONTRAST:
+			code = vips_contrast_bridge(image, &t[i], a[0]);
+			break;
+		case PIPELINE_RESIZE:
+			code = vips_resize_bridge(image, &t[i], a[0], a[1]);
+			break;
+		default:
+			vips_error("bimg", "unknown pipeline operation %d", ops[i]);
+			code = 1;
+		}
+
+		if (code) {
+			g_object_unref(base);
+			return 1;
+		}
+		image = t[i];
+	}
+
+	// The intermediate images are kept alive by the output
+	g_object_ref(image);
+	*out = image;
+	g_object_unref(base);
+	return 0;
+}
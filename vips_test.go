@@ -5,6 +5,7 @@ import (
 	"os"
 	"path"
 	"testing"
+	"time"
 )
 
 func TestVipsRead(t *testing.T) {
@@ -58,6 +59,35 @@ func TestVipsSaveTiff(t *testing.T) {
 	}
 }
 
+func TestVipsSaveTiffOptions(t *testing.T) {
+	if !IsTypeSupportedSave(TIFF) {
+		t.Skipf("Format %#v is not supported", ImageTypes[TIFF])
+	}
+
+	image, _, _ := vipsRead(readImage("test.jpg"))
+	plain, _ := vipsSave(image, vipsSaveOptions{Quality: 95, Type: TIFF})
+
+	image, _, _ = vipsRead(readImage("test.jpg"))
+	tiff := TIFFOptions{Compression: TIFFCompressionJPEG}
+	buf, err := vipsSave(image, vipsSaveOptions{Quality: 90, Type: TIFF, TIFF: tiff})
+	if err != nil {
+		t.Fatalf("Error saving image type %v: %v", ImageTypes[TIFF], err)
+	}
+	if len(buf) == 0 || len(buf) >= len(plain) {
+		t.Fatalf("Invalid compressed '%v' image size: %d", ImageTypes[TIFF], len(buf))
+	}
+
+	image, _, _ = vipsRead(readImage("test.jpg"))
+	tiff = TIFFOptions{Compression: TIFFCompressionDeflate, Pyramid: true, TileWidth: 256, TileHeight: 256}
+	buf, err = vipsSave(image, vipsSaveOptions{Quality: 95, Type: TIFF, TIFF: tiff})
+	if err != nil {
+		t.Fatalf("Error saving image type %v: %v", ImageTypes[TIFF], err)
+	}
+	if len(buf) == 0 {
+		t.Fatalf("Empty saved '%v' image", ImageTypes[TIFF])
+	}
+}
+
 func TestVipsSaveAvif(t *testing.T) {
 	if !IsTypeSupportedSave(AVIF) {
 		t.Skipf("Format %#v is not supported", ImageTypes[AVIF])
@@ -106,7 +136,7 @@ func TestVipsAutoRotate(t *testing.T) {
 
 	files := []struct {
 		name        string
-		orientation int
+		orientation ExifOrientation
 	}{
 		{"test.jpg", 0},
 		{"test_exif.jpg", 0},
@@ -217,6 +247,85 @@ func TestVipsMemory(t *testing.T) {
 	if mem.Allocations == 0 {
 		t.Fatal("Invalid memory allocations")
 	}
+	if mem.MemoryHighwater < mem.Memory {
+		t.Fatalf("Invalid memory highwater: %d", mem.MemoryHighwater)
+	}
+	if mem.CacheMaxEntries <= 0 || mem.CacheEntries > mem.CacheMaxEntries {
+		t.Fatalf("Invalid cache entries: %d", mem.CacheEntries)
+	}
+}
+
+func TestVipsFeatures(t *testing.T) {
+	features := VipsFeatures()
+
+	if features.MajorVersion < 8 || features.Version == "" {
+		t.Fatalf("Invalid libvips version: %s", features.Version)
+	}
+	if !features.Types[JPEG].Load || !features.Types[JPEG].Save {
+		t.Error("JPEG must be supported")
+	}
+	if !features.Loaders["jpeg"] || !features.Savers["png"] {
+		t.Error("JPEG and PNG operations must be available")
+	}
+	if features.Loaders["unknown"] {
+		t.Error("Unknown formats must not be reported")
+	}
+	if features.SmartCrop && len(features.Interesting) == 0 {
+		t.Error("Missing smart crop strategies")
+	}
+}
+
+func TestVipsInitializeConfig(t *testing.T) {
+	defer Initialize()
+
+	Initialize(VipsConfig{ConcurrencyLevel: 2, CacheMaxOps: 10, CacheMaxFiles: -1})
+
+	buf, err := Resize(readImage("test.jpg"), Options{Width: 100})
+	if err != nil {
+		t.Fatalf("Cannot process the image: %#v", err)
+	}
+	if len(buf) == 0 {
+		t.Fatal("Empty image")
+	}
+}
+
+func TestVipsConfigDefaults(t *testing.T) {
+	c := vipsConfigDefaults(VipsConfig{CacheMaxOps: 10, CacheMaxFiles: -1, Leak: true})
+	if c.CacheMaxMem != maxCacheMem || c.CacheMaxOps != 10 || c.CacheMaxFiles != 0 || !c.Leak {
+		t.Errorf("Invalid config: %#v", c)
+	}
+	if os.Getenv("VIPS_CONCURRENCY") == "" && c.ConcurrencyLevel != 1 {
+		t.Errorf("Invalid concurrency level: %d", c.ConcurrencyLevel)
+	}
+
+	c = vipsConfigDefaults(VipsConfig{ConcurrencyLevel: 4})
+	if c.ConcurrencyLevel != 4 || c.CacheMaxFiles != maxCacheFiles {
+		t.Errorf("Invalid config: %#v", c)
+	}
+}
+
+func TestWatchVipsMemory(t *testing.T) {
+	calls := make(chan VipsMemoryInfo, 10)
+	stop := WatchVipsMemory(time.Millisecond, func(stats VipsMemoryInfo) {
+		select {
+		case calls <- stats:
+		default:
+		}
+	})
+	defer stop()
+
+	select {
+	case stats := <-calls:
+		if stats.Memory == 0 {
+			t.Error("Invalid memory")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Stats callback not called")
+	}
+
+	stop()
+	// Stopping twice is a no-op
+	stop()
 }
 
 func TestVipsExifShort(t *testing.T) {
@@ -246,6 +355,122 @@ func TestVipsExifShort(t *testing.T) {
 	}
 }
 
+func TestVipsUnsharpMaskParams(t *testing.T) {
+	tt := []struct {
+		input         UnsharpMask
+		sigma, x1     float64
+		flat, sharpen float64
+	}{
+		{UnsharpMask{Radius: 1, Amount: 100}, 1, 0, 1, 1},
+		{UnsharpMask{Radius: 2.5, Amount: 150, Threshold: 51}, 2.5, 20, 0, 1.5},
+		{UnsharpMask{Radius: 1, Amount: 50, Threshold: -10}, 1, 0, 0.5, 0.5},
+	}
+
+	for _, tc := range tt {
+		sigma, x1, m1, m2 := unsharpMaskParams(tc.input)
+		if sigma != tc.sigma || x1 != tc.x1 || m1 != tc.flat || m2 != tc.sharpen {
+			t.Fatalf("invalid params for %#v: %v %v %v %v", tc.input, sigma, x1, m1, m2)
+		}
+	}
+}
+
+func TestInterpretationForDepth(t *testing.T) {
+	tt := []struct {
+		input    Interpretation
+		depth    int
+		expected Interpretation
+	}{
+		{InterpretationSRGB, 0, InterpretationSRGB},
+		{InterpretationSRGB, 16, InterpretationRGB16},
+		{InterpretationBW, 16, InterpretationGREY16},
+		{InterpretationRGB16, 8, InterpretationSRGB},
+		{InterpretationGREY16, 8, InterpretationBW},
+		{InterpretationCMYK, 16, InterpretationCMYK},
+	}
+
+	for _, tc := range tt {
+		if got := interpretationForDepth(tc.input, tc.depth); got != tc.expected {
+			t.Errorf("expected: %d; got: %d", tc.expected, got)
+		}
+	}
+}
+
+func TestPNGPaletteDepth(t *testing.T) {
+	tt := []struct {
+		options  vipsSaveOptions
+		expected int
+	}{
+		{vipsSaveOptions{Type: PNG}, 0},
+		{vipsSaveOptions{Type: PNG, Palette: true}, 0},
+		{vipsSaveOptions{Type: PNG, Palette: true, BitDepth: 2}, 2},
+		{vipsSaveOptions{Type: PNG, Palette: true, PNG: PNGOptions{Colors: 2}}, 1},
+		{vipsSaveOptions{Type: PNG, Palette: true, PNG: PNGOptions{Colors: 12}}, 4},
+		{vipsSaveOptions{Type: PNG, Palette: true, PNG: PNGOptions{Colors: 200}}, 8},
+		{vipsSaveOptions{Type: JPEG, Palette: true, BitDepth: 4}, 0},
+	}
+
+	for _, tc := range tt {
+		if got := pngPaletteDepth(tc.options); got != tc.expected {
+			t.Errorf("expected: %d; got: %d", tc.expected, got)
+		}
+	}
+}
+
+func TestWebPDefaults(t *testing.T) {
+	tt := []struct {
+		input    WebPOptions
+		expected WebPOptions
+	}{
+		{WebPOptions{}, WebPOptions{AlphaQuality: 100, Effort: 4, KMin: 0, KMax: 1}},
+		{WebPOptions{AlphaQuality: 50, Effort: 6}, WebPOptions{AlphaQuality: 50, Effort: 6, KMin: 0, KMax: 1}},
+		{WebPOptions{KMin: 3, KMax: 10}, WebPOptions{AlphaQuality: 100, Effort: 4, KMin: 3, KMax: 10}},
+		{WebPOptions{KMin: 10, KMax: 5}, WebPOptions{AlphaQuality: 100, Effort: 4, KMin: 4, KMax: 5}},
+	}
+
+	for _, tc := range tt {
+		if got := webpDefaults(tc.input); got != tc.expected {
+			t.Errorf("expected: %#v; got: %#v", tc.expected, got)
+		}
+	}
+}
+
+func TestTiffDefaults(t *testing.T) {
+	tt := []struct {
+		input    TIFFOptions
+		expected TIFFOptions
+	}{
+		{TIFFOptions{}, TIFFOptions{TileWidth: 128, TileHeight: 128}},
+		{TIFFOptions{Tile: true, TileWidth: 256}, TIFFOptions{Tile: true, TileWidth: 256, TileHeight: 128}},
+		{TIFFOptions{Pyramid: true}, TIFFOptions{Tile: true, Pyramid: true, TileWidth: 128, TileHeight: 128}},
+	}
+
+	for _, tc := range tt {
+		if got := tiffDefaults(tc.input); got != tc.expected {
+			t.Errorf("expected: %#v; got: %#v", tc.expected, got)
+		}
+	}
+}
+
+func TestGifBitDepth(t *testing.T) {
+	tt := []struct {
+		colors   int
+		expected int
+	}{
+		{1, 1},
+		{2, 1},
+		{3, 2},
+		{16, 4},
+		{17, 5},
+		{256, 8},
+	}
+
+	for _, tc := range tt {
+		if got := gifBitDepth(tc.colors); got != tc.expected {
+			t.Errorf("expected: %d; got: %d", tc.expected, got)
+		}
+	}
+}
+
 func readImage(file string) []byte {
 	img, _ := os.Open(path.Join("testdata", file))
 	buf, _ := ioutil.ReadAll(img)
@@ -0,0 +1,107 @@
+package bimg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WalkOptions represents the options of ConvertTree.
+type WalkOptions struct {
+	// Patterns are the file name patterns to convert, as supported by
+	// filepath.Match, e.g. "*.jpg". Matching is case insensitive.
+	// Defaults to every file.
+	Patterns []string
+	// Concurrency defines the number of workers, defaulting to the
+	// number of CPUs.
+	Concurrency int
+	// Force converts the files even if their output is up to date.
+	Force bool
+	// Progress is called after each converted file, see Batch.
+	Progress func(done, total int, result BatchResult)
+}
+
+// ConvertTree mirrors the srcDir directory tree into dstDir, converting the
+// matching files with the recipe options. The output file extension follows
+// the recipe output type, when defined. Outputs are given the modification
+// time of their source, and skipped when up to date. The recipe Output
+// function is ignored.
+func ConvertTree(srcDir, dstDir string, recipe Recipe, opts WalkOptions) ([]BatchResult, error) {
+	var paths []string
+	outputs := map[string]string{}
+	modTimes := map[string]os.FileInfo{}
+
+	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !matchPatterns(opts.Patterns, info.Name()) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		output := filepath.Join(dstDir, outputPath(rel, recipe.Options.Type))
+		if !opts.Force && isUpToDate(output, info) {
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+			return err
+		}
+
+		paths = append(paths, path)
+		outputs[path] = output
+		modTimes[path] = info
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	recipe.Output = func(path string) string {
+		return outputs[path]
+	}
+	batch := Batch{Progress: opts.Progress}
+	results := batch.ProcessFiles(paths, recipe, opts.Concurrency)
+
+	for i, result := range results {
+		if result.Err == nil {
+			modTime := modTimes[result.Path].ModTime()
+			results[i].Err = os.Chtimes(result.Output, modTime, modTime)
+		}
+	}
+	return results, nil
+}
+
+func matchPatterns(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(strings.ToLower(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// outputPath replaces the path extension by the one of the image type.
+func outputPath(path string, imageType ImageType) string {
+	if imageType == UNKNOWN {
+		return path
+	}
+	ext := "." + ImageTypeName(imageType)
+	if imageType == JPEG {
+		ext = ".jpg"
+	}
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ext
+}
+
+// isUpToDate reports whether the output file is newer than its source.
+func isUpToDate(output string, source os.FileInfo) bool {
+	info, err := os.Stat(output)
+	return err == nil && !info.ModTime().Before(source.ModTime())
+}
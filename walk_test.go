@@ -0,0 +1,72 @@
+package bimg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConvertTree(t *testing.T) {
+	src, err := ioutil.TempDir("", "bimg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(src)
+	dst := filepath.Join(src, "out")
+	src = filepath.Join(src, "in")
+
+	buf, _ := Read("testdata/test.jpg")
+	os.MkdirAll(filepath.Join(src, "nested"), 0755)
+	Write(filepath.Join(src, "a.JPG"), buf)
+	Write(filepath.Join(src, "nested", "b.jpg"), buf)
+	Write(filepath.Join(src, "notes.txt"), []byte("skip me"))
+
+	recipe := Recipe{Options: Options{Width: 100, Type: WEBP}}
+	opts := WalkOptions{Patterns: []string{"*.jpg"}}
+
+	results, err := ConvertTree(src, dst, recipe, opts)
+	if err != nil {
+		t.Fatalf("Cannot convert the tree: %#v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Invalid number of results: %d", len(results))
+	}
+	for _, result := range results {
+		if result.Err != nil {
+			t.Errorf("Cannot convert %s: %#v", result.Path, result.Err)
+		}
+	}
+
+	output := filepath.Join(dst, "nested", "b.webp")
+	out, err := Read(output)
+	if err != nil || DetermineImageType(out) != WEBP {
+		t.Fatalf("Invalid output file: %#v", err)
+	}
+	srcInfo, _ := os.Stat(filepath.Join(src, "nested", "b.jpg"))
+	dstInfo, _ := os.Stat(output)
+	if !dstInfo.ModTime().Equal(srcInfo.ModTime()) {
+		t.Errorf("Output modification time not preserved: %s", dstInfo.ModTime())
+	}
+
+	// Up to date outputs are skipped
+	results, err = ConvertTree(src, dst, recipe, opts)
+	if err != nil || len(results) != 0 {
+		t.Errorf("Up to date files must be skipped: %d %#v", len(results), err)
+	}
+
+	opts.Force = true
+	results, _ = ConvertTree(src, dst, recipe, opts)
+	if len(results) != 2 {
+		t.Errorf("Forced conversion must convert every file: %d", len(results))
+	}
+}
+
+func TestOutputPath(t *testing.T) {
+	cases := map[ImageType]string{UNKNOWN: "a/b.png", JPEG: "a/b.jpg", WEBP: "a/b.webp"}
+	for imageType, expected := range cases {
+		if path := outputPath("a/b.png", imageType); path != expected {
+			t.Errorf("Invalid output path: %s != %s", path, expected)
+		}
+	}
+}